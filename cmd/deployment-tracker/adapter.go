@@ -0,0 +1,156 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+	"github.com/github/deployment-tracker/internal/orchestrator"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/registry"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runAdapter tracks the tasks of an orchestrator other than
+// Kubernetes, posting the records of their containers. It returns the
+// exit code.
+func runAdapter(args []string) int {
+	var (
+		common         commonFlags
+		kind           string
+		ecsClusters    string
+		pollInterval   time.Duration
+		nomadNamespace string
+		resolve        bool
+		metricsAddr    string
+	)
+
+	fs := flag.NewFlagSet("adapter", flag.ContinueOnError)
+	common.register(fs, false)
+	fs.StringVar(&kind, "orchestrator", "", "orchestrator to track: ecs or nomad")
+	fs.StringVar(&ecsClusters, "ecs-clusters", "", "comma separated list of the names or ARNs of the ECS clusters to track")
+	fs.DurationVar(&pollInterval, "poll-interval", 30*time.Second, "interval between polls of the ECS tasks")
+	fs.StringVar(&nomadNamespace, "nomad-namespace", "*", "namespace of the Nomad allocations to track, * for all")
+	fs.BoolVar(&resolve, "resolve-digests", true, "resolve the digests of images the orchestrator reports none of from their registry")
+	fs.StringVar(&metricsAddr, "metrics-addr", ":9090", "address (host:port) to listen to for metrics and health checks")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	setupLogging(os.Stdout)
+
+	cfg, ok := common.loadDirectConfig()
+	if !ok {
+		return 1
+	}
+	if !validateConfig(&cfg) {
+		return 1
+	}
+	if cfg.SinksOnly {
+		slog.Error("Sinks are not supported by the adapter command")
+		return 1
+	}
+
+	adapter, err := newAdapter(kind, ecsClusters, pollInterval, nomadNamespace)
+	if err != nil {
+		slog.Error("Invalid orchestrator configuration",
+			"orchestrator", kind,
+			"error", err)
+		return 2
+	}
+	apiClient, ok := newAPIClient(&cfg)
+	if !ok {
+		return 1
+	}
+
+	var resolver controller.DigestResolver
+	if resolve {
+		resolver = registry.NewClient().Digest
+	}
+	tracker := orchestrator.NewTracker(adapter.Name(), apiClient,
+		func(ctx context.Context, task orchestrator.Task) []*deploymentrecord.DeploymentRecord {
+			return controller.TaskRecords(ctx, &cfg, task, resolver)
+		})
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsMux.HandleFunc("/healthz", checkHandler(func() error { return nil }))
+	metricsMux.HandleFunc("/readyz", checkHandler(func() error {
+		if !apiClient.Reachable() {
+			return errors.New("the API is unreachable")
+		}
+		return nil
+	}))
+	promSrv := &http.Server{
+		Addr:              metricsAddr,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		Handler:           metricsMux,
+	}
+	go func() {
+		slog.Info("starting Prometheus metrics server",
+			"url", promSrv.Addr)
+		if err := promSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("failed to start metrics server",
+				"error", err)
+		}
+	}()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	slog.Info("Starting deployment-tracker adapter",
+		"orchestrator", adapter.Name(),
+		"cluster", cfg.Cluster)
+	err = adapter.Run(ctx, tracker)
+	slog.Info("Shutting down...")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
+	if err := promSrv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to shutdown metrics server gracefully",
+			"error", err)
+	}
+	if err != nil {
+		slog.Error("Adapter failed",
+			"error", err)
+		return 1
+	}
+	return 0
+}
+
+// newAdapter creates the adapter of the orchestrator kind, configured
+// with the flags and the environment.
+func newAdapter(kind, ecsClusters string, pollInterval time.Duration, nomadNamespace string) (orchestrator.Adapter, error) {
+	switch kind {
+	case "ecs":
+		var clusters []string
+		for _, c := range strings.Split(ecsClusters, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				clusters = append(clusters, c)
+			}
+		}
+		region := cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+		return orchestrator.NewECS(region, clusters, pollInterval)
+	case "nomad":
+		addr := getEnvOrDefault("NOMAD_ADDR", "http://127.0.0.1:4646")
+		return orchestrator.NewNomad(addr, os.Getenv("NOMAD_TOKEN"), nomadNamespace)
+	case "":
+		return nil, errors.New("an orchestrator is required, set with -orchestrator")
+	default:
+		return nil, fmt.Errorf("unknown orchestrator %q, expected ecs or nomad", kind)
+	}
+}
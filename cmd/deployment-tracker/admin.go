@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+)
+
+// deadLetterSource lists and flushes the dead-lettered records.
+type deadLetterSource interface {
+	DeadLetters() []controller.DeadLetter
+	FlushDeadLetters(ctx context.Context) (int, int)
+}
+
+// cacheSource lists and evicts the entries of the observation cache.
+type cacheSource interface {
+	CachedDeployments() []controller.CacheEntry
+	EvictCached(deploymentName, digest string) (int, int)
+	ClearCache() (int, int)
+}
+
+// snapshotSource returns the deployments currently seen by the
+// controller.
+type snapshotSource interface {
+	Snapshot() ([]controller.SnapshotEntry, error)
+}
+
+// adminSource is the controller served by the admin server.
+type adminSource interface {
+	deadLetterSource
+	cacheSource
+	snapshotSource
+}
+
+// newAdminServer creates the admin server, serving the health,
+// readiness, dead letter, snapshot and pprof endpoints. It is kept
+// separate from the metrics server so it can be bound to a more
+// restricted address. All endpoints but the health and readiness
+// checks are only served if token is set, and require it as bearer
+// token.
+func newAdminServer(addr string, healthy, ready func() error, cntrl adminSource, token string) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", checkHandler(healthy))
+	mux.HandleFunc("/readyz", checkHandler(ready))
+
+	if token != "" {
+		mux.HandleFunc("GET /debug/deadletter", requireToken(token, func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, cntrl.DeadLetters())
+		}))
+		mux.HandleFunc("POST /debug/deadletter/flush", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+			posted, remaining := cntrl.FlushDeadLetters(r.Context())
+			writeJSON(w, map[string]int{
+				"posted":    posted,
+				"remaining": remaining,
+			})
+		}))
+		mux.HandleFunc("GET /debug/snapshot", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+			entries, err := cntrl.Snapshot()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			switch r.URL.Query().Get("format") {
+			case "", "json":
+				writeJSON(w, entries)
+			case "csv":
+				writeSnapshotCSV(w, entries)
+			default:
+				http.Error(w, "format must be json or csv", http.StatusBadRequest)
+			}
+		}))
+		mux.HandleFunc("GET /debug/cache", requireToken(token, func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, cntrl.CachedDeployments())
+		}))
+		mux.HandleFunc("DELETE /debug/cache", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+			deploymentName := r.URL.Query().Get("deployment_name")
+			if deploymentName == "" {
+				http.Error(w, "deployment_name is required", http.StatusBadRequest)
+				return
+			}
+			evicted, enqueued := cntrl.EvictCached(deploymentName, r.URL.Query().Get("digest"))
+			writeJSON(w, map[string]int{
+				"evicted":  evicted,
+				"enqueued": enqueued,
+			})
+		}))
+		mux.HandleFunc("POST /debug/cache/clear", requireToken(token, func(w http.ResponseWriter, _ *http.Request) {
+			evicted, enqueued := cntrl.ClearCache()
+			writeJSON(w, map[string]int{
+				"evicted":  evicted,
+				"enqueued": enqueued,
+			})
+		}))
+		mux.HandleFunc("/debug/pprof/", requireToken(token, pprof.Index))
+		mux.HandleFunc("/debug/pprof/cmdline", requireToken(token, pprof.Cmdline))
+		mux.HandleFunc("/debug/pprof/profile", requireToken(token, pprof.Profile))
+		mux.HandleFunc("/debug/pprof/symbol", requireToken(token, pprof.Symbol))
+		mux.HandleFunc("/debug/pprof/trace", requireToken(token, pprof.Trace))
+	}
+
+	return &http.Server{
+		Addr:        addr,
+		ReadTimeout: 10 * time.Second,
+		// CPU profiles and traces stream for up to 30 seconds by
+		// default
+		WriteTimeout:      60 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		Handler:           mux,
+	}
+}
+
+// checkHandler returns a handler responding with 200 if check passes,
+// and 503 with the error otherwise.
+func checkHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+// requireToken returns a handler responding with 401 unless the
+// request has token as bearer token.
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	want := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// snapshotColumns are the columns of the CSV snapshot.
+var snapshotColumns = []string{
+	"cluster", "namespace", "deployment_name", "image", "version", "digest", "status", "pods", "last_posted",
+}
+
+// writeSnapshotCSV responds with the snapshot entries as CSV, with a
+// header row.
+func writeSnapshotCSV(w http.ResponseWriter, entries []controller.SnapshotEntry) {
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	rows := [][]string{snapshotColumns}
+	for _, e := range entries {
+		var lastPosted string
+		if e.LastPosted != nil {
+			lastPosted = e.LastPosted.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, []string{
+			e.Cluster, e.Namespace, e.DeploymentName, e.Image, e.Version, e.Digest, e.Status,
+			strconv.Itoa(e.Pods), lastPosted,
+		})
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		slog.Warn("Failed to write response",
+			"error", err,
+		)
+	}
+}
+
+// writeJSON responds with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write response",
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/github/deployment-tracker/internal/controller"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// trackerController is the controller run by the run command: a
+// single controller, or one per cluster in multi-cluster mode.
+type trackerController interface {
+	adminSource
+	Healthy() error
+	Ready() error
+	Reload(cfg *controller.Config) error
+	Run(ctx context.Context, workers int) error
+}
+
+// clusterController is the controller of one of several clusters.
+type clusterController struct {
+	cluster   controller.ClusterConfig
+	clientset kubernetes.Interface
+	*controller.Controller
+}
+
+// clusterControllers runs one controller per cluster in multi-cluster
+// mode.
+type clusterControllers []clusterController
+
+// newClusterControllers creates the controllers of the clusters of
+// cfg. The kubeconfig of a cluster defaults to kubeconfig.
+func newClusterControllers(kubeconfig, namespaces, excludeNamespaces string, cfg *controller.Config) (clusterControllers, error) {
+	var res clusterControllers
+	for _, cluster := range cfg.Clusters {
+		path := cluster.Kubeconfig
+		if path == "" {
+			path = kubeconfig
+		}
+		k8sCfg, err := createK8sContextConfig(path, cluster.Context)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: failed to create Kubernetes config: %w", cluster.ClusterName(), err)
+		}
+		clientset, err := kubernetes.NewForConfig(k8sCfg)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: failed to create Kubernetes client: %w", cluster.ClusterName(), err)
+		}
+
+		clusterCfg := cfg.ForCluster(cluster)
+		opts, err := controllerOptions(k8sCfg, &clusterCfg)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.ClusterName(), err)
+		}
+		cntrl, err := controller.New(clientset, namespaces, excludeNamespaces, &clusterCfg, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.ClusterName(), err)
+		}
+		slog.Info("Cluster to watch",
+			"cluster", clusterCfg.Cluster,
+			"context", cluster.Context,
+			"physical_environment", clusterCfg.PhysicalEnvironment,
+		)
+		res = append(res, clusterController{
+			cluster:    cluster,
+			clientset:  clientset,
+			Controller: cntrl,
+		})
+	}
+	return res, nil
+}
+
+// Healthy returns the health check failures of all clusters.
+func (cs clusterControllers) Healthy() error {
+	return cs.check((*controller.Controller).Healthy)
+}
+
+// Ready returns the readiness check failures of all clusters.
+func (cs clusterControllers) Ready() error {
+	return cs.check((*controller.Controller).Ready)
+}
+
+// check returns the failures of check for all clusters, prefixed with
+// the cluster name.
+func (cs clusterControllers) check(check func(*controller.Controller) error) error {
+	var errs []error
+	for _, c := range cs {
+		if err := check(c.Controller); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", c.cluster.ClusterName(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeadLetters returns the dead letters of all clusters.
+func (cs clusterControllers) DeadLetters() []controller.DeadLetter {
+	var res []controller.DeadLetter
+	for _, c := range cs {
+		res = append(res, c.DeadLetters()...)
+	}
+	return res
+}
+
+// FlushDeadLetters flushes the dead letters of all clusters. It
+// returns the total number of posted and remaining dead letters.
+func (cs clusterControllers) FlushDeadLetters(ctx context.Context) (int, int) {
+	var posted, remaining int
+	for _, c := range cs {
+		p, r := c.FlushDeadLetters(ctx)
+		posted += p
+		remaining += r
+	}
+	return posted, remaining
+}
+
+// CachedDeployments returns the observation cache entries of all
+// clusters.
+func (cs clusterControllers) CachedDeployments() []controller.CacheEntry {
+	var res []controller.CacheEntry
+	for _, c := range cs {
+		res = append(res, c.CachedDeployments()...)
+	}
+	return res
+}
+
+// EvictCached evicts the deployment name from the observation caches
+// of all clusters. It returns the total number of evicted entries and
+// enqueued pods.
+func (cs clusterControllers) EvictCached(deploymentName, digest string) (int, int) {
+	var evicted, enqueued int
+	for _, c := range cs {
+		e, q := c.EvictCached(deploymentName, digest)
+		evicted += e
+		enqueued += q
+	}
+	return evicted, enqueued
+}
+
+// ClearCache clears the observation caches of all clusters. It
+// returns the total number of removed entries and enqueued pods.
+func (cs clusterControllers) ClearCache() (int, int) {
+	var cleared, enqueued int
+	for _, c := range cs {
+		e, q := c.ClearCache()
+		cleared += e
+		enqueued += q
+	}
+	return cleared, enqueued
+}
+
+// Snapshot returns the snapshot entries of all clusters. It fails if
+// the snapshot of any cluster fails.
+func (cs clusterControllers) Snapshot() ([]controller.SnapshotEntry, error) {
+	var res []controller.SnapshotEntry
+	for _, c := range cs {
+		entries, err := c.Snapshot()
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", c.cluster.ClusterName(), err)
+		}
+		res = append(res, entries...)
+	}
+	return res, nil
+}
+
+// Reload applies cfg, with the overrides of each cluster, to the
+// controllers of all clusters.
+func (cs clusterControllers) Reload(cfg *controller.Config) error {
+	var errs []error
+	for _, c := range cs {
+		clusterCfg := cfg.ForCluster(c.cluster)
+		if err := c.Controller.Reload(&clusterCfg); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", c.cluster.ClusterName(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run runs the controllers of all clusters until ctx is cancelled or
+// one of them fails, which stops the others.
+func (cs clusterControllers) Run(ctx context.Context, workers int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(cs))
+	var wg sync.WaitGroup
+	for i, c := range cs {
+		wg.Go(func() {
+			if err := c.Controller.Run(ctx, workers); err != nil {
+				errs[i] = fmt.Errorf("cluster %s: %w", c.cluster.ClusterName(), err)
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// parseContexts returns the clusters of the comma separated list of
+// kubeconfig contexts.
+func parseContexts(list string) []controller.ClusterConfig {
+	var res []controller.ClusterConfig
+	for _, context := range strings.Split(list, ",") {
+		if context = strings.TrimSpace(context); context != "" {
+			res = append(res, controller.ClusterConfig{Context: context})
+		}
+	}
+	return res
+}
+
+// createK8sContextConfig creates the config of the kubeconfig
+// context. An empty kubeconfig uses the default loading rules, i.e.
+// $KUBECONFIG or ~/.kube/config, and an empty context the current
+// context.
+func createK8sContextConfig(kubeconfig, context string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+)
+
+// configReloadInterval is how often the config file is checked for
+// changes. Polling the contents, rather than watching for file events,
+// also picks up updates of mounted ConfigMaps, which are swapped in
+// through symlinks.
+const configReloadInterval = 10 * time.Second
+
+// watchConfigFile reloads the config file at path whenever its
+// contents change, and passes the result, applied on top of base, to
+// reload. It returns when ctx is cancelled.
+func watchConfigFile(ctx context.Context, path string, base controller.Config, reload func(*controller.Config) error) {
+	last, err := os.ReadFile(path)
+	if err != nil {
+		slog.Warn("Failed to read config file",
+			"path", path,
+			"error", err)
+	}
+
+	ticker := time.NewTicker(configReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			slog.Warn("Failed to read config file",
+				"path", path,
+				"error", err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+
+		cfg := base
+		if err := controller.LoadConfigFile(path, &cfg); err != nil {
+			slog.Error("Failed to load config file, keeping current configuration",
+				"path", path,
+				"error", err)
+			continue
+		}
+		if err := reload(&cfg); err != nil {
+			slog.Error("Failed to reload configuration, keeping current configuration",
+				"path", path,
+				"error", err)
+		}
+	}
+}
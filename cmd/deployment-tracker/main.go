@@ -2,24 +2,31 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
-	"net/http"
+	"maps"
 	"os"
-	"os/signal"
-	"syscall"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/github/deployment-tracker/internal/controller"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/sink"
+	"github.com/github/deployment-tracker/pkg/vault"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// clusterAutodetectTimeout bounds the discovery of the cluster name.
+const clusterAutodetectTimeout = 15 * time.Second
+
 var defaultTemplate = controller.TmplNS + "/" +
 	controller.TmplDN + "/" +
 	controller.TmplCN
@@ -31,141 +38,404 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// lookupEnvOrDefault is like getEnvOrDefault, but keeps the value of
+// key if it is set to an empty string.
+func lookupEnvOrDefault(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// commands are the subcommands, keyed by name. They are run with the
+// remaining arguments and return the exit code.
+var commands = map[string]struct {
+	run   func(args []string) int
+	usage string
+}{
+	"adapter":   {runAdapter, "track the tasks of AWS ECS or Nomad, orchestrators other than Kubernetes"},
+	"run":       {runController, "run the controller (default)"},
+	"reconcile": {runReconcile, "compare the running pods with the API records, and optionally apply the differences"},
+	"relay":     {runRelay, "accept the records of edge instances and post them to the API"},
+	"scan":      {runScan, "record the workloads of Kubernetes manifests, e.g. before they are deployed"},
+	"validate":  {runValidate, "check the configuration, template and credentials"},
+	"version":   {runVersion, "print version and build information"},
+}
+
 func main() {
-	var (
-		kubeconfig        string
-		namespace         string
-		excludeNamespaces string
-		workers           int
-		metricsPort       string
-	)
-
-	flag.StringVar(&kubeconfig, "kubeconfig", "", "path to kubeconfig file (uses in-cluster config if not set)")
-	flag.StringVar(&namespace, "namespace", "", "namespace to monitor (empty for all namespaces)")
-	flag.StringVar(&excludeNamespaces, "exclude-namespaces", "", "comma separated list of namespaces to exclude from monitoring (empty to include all namespaces)")
-	flag.IntVar(&workers, "workers", 2, "number of worker goroutines")
-	flag.StringVar(&metricsPort, "metrics-port", "9090", "port to listen to for metrics")
-	flag.Parse()
-
-	// Cannot use both
-	if namespace != "" && excludeNamespaces != "" {
-		slog.Error("Cannot set both -namespace and -exclude-namespaces")
-		os.Exit(1)
-	}
-
-	// Validate worker count
-	if workers < 1 || workers > 100 {
-		slog.Error("Invalid worker count, must be between 1 and 100",
-			"workers", workers)
-		os.Exit(1)
-	}
-
-	// init logging
-	log.SetFlags(log.LstdFlags | log.Lshortfile | log.LUTC)
-	opts := slog.HandlerOptions{Level: slog.LevelInfo}
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &opts)))
+	args := os.Args[1:]
 
-	var cntrlCfg = controller.Config{
-		Template:            getEnvOrDefault("DN_TEMPLATE", defaultTemplate),
-		LogicalEnvironment:  os.Getenv("LOGICAL_ENVIRONMENT"),
-		PhysicalEnvironment: os.Getenv("PHYSICAL_ENVIRONMENT"),
-		Cluster:             os.Getenv("CLUSTER"),
-		APIToken:            getEnvOrDefault("API_TOKEN", ""),
-		BaseURL:             getEnvOrDefault("BASE_URL", "api.github.com"),
-		GHAppID:             getEnvOrDefault("GH_APP_ID", ""),
-		GHInstallID:         getEnvOrDefault("GH_INSTALL_ID", ""),
-		GHAppPrivateKey:     getEnvOrDefault("GH_APP_PRIV_KEY", ""),
-		Organization:        os.Getenv("GITHUB_ORG"),
+	// Without a command, the flags are passed to run
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage(os.Stdout)
+		return
 	}
 
-	if !controller.ValidTemplate(cntrlCfg.Template) {
-		slog.Error("Template must contain at least one placeholder",
-			"template", cntrlCfg.Template,
-			"valid_placeholders", []string{controller.TmplNS, controller.TmplDN, controller.TmplCN})
-		os.Exit(1)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
 	}
+	os.Exit(cmd.run(args))
+}
 
-	if cntrlCfg.LogicalEnvironment == "" {
-		slog.Error("Logical environment is required")
-		os.Exit(1)
+// usage writes the list of commands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: deployment-tracker [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := slices.Sorted(maps.Keys(commands))
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].usage)
 	}
-	if cntrlCfg.Cluster == "" {
-		slog.Error("Cluster is required")
-		os.Exit(1)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'deployment-tracker <command> -h' for the flags of a command.")
+}
+
+// hideFlags leaves the flags out of the help output of fs, e.g. those
+// only meant for testing. They are parsed as usual.
+func hideFlags(fs *flag.FlagSet, names ...string) {
+	hidden := make(map[string]bool, len(names))
+	for _, name := range names {
+		hidden[name] = true
 	}
-	if cntrlCfg.Organization == "" {
-		slog.Error("Organization is required")
-		os.Exit(1)
+	fs.Usage = func() {
+		visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		visible.SetOutput(fs.Output())
+		fs.VisitAll(func(f *flag.Flag) {
+			if !hidden[f.Name] {
+				visible.Var(f.Value, f.Name, f.Usage)
+				visible.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		visible.PrintDefaults()
 	}
+}
 
-	k8sCfg, err := createK8sConfig(kubeconfig)
-	if err != nil {
-		slog.Error("Failed to create Kubernetes config",
+// parseFlags parses the arguments of a command. It returns false with
+// the exit code if the command should not run, e.g. after printing
+// its help.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0, false
+		}
+		return 2, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", fs.Args())
+		return 2, false
+	}
+	return 0, true
+}
+
+// commonFlags are the flags shared by the commands working on the
+// watched pods.
+type commonFlags struct {
+	configFile        string
+	kubeconfig        string
+	namespace         string
+	excludeNamespaces string
+	batchWorkloads    bool
+	staticPods        bool
+	deploymentConfigs bool
+	knative           bool
+	optIn             bool
+	templateAnns      bool
+	clusterAutodetect bool
+	normalizeImages   bool
+	recordResources   bool
+	nodeTopology      bool
+	nodePlatform      bool
+	gitOpsApps        bool
+	helmReleases      bool
+	sinksOnly         bool
+}
+
+// register registers the flags on fs. reload describes whether the
+// command reloads the config file on change.
+func (f *commonFlags) register(fs *flag.FlagSet, reload bool) {
+	configHelp := "path to a YAML or JSON config file"
+	if reload {
+		configHelp += ", reloaded on change"
+	}
+	fs.StringVar(&f.configFile, "config", "", configHelp)
+	fs.StringVar(&f.kubeconfig, "kubeconfig", "", "path to kubeconfig file (uses in-cluster config if not set)")
+	fs.StringVar(&f.namespace, "namespace", "", "comma separated list of namespaces or namespace regular expressions to monitor (empty for all namespaces)")
+	fs.StringVar(&f.excludeNamespaces, "exclude-namespaces", "", "comma separated list of namespaces or namespace regular expressions to exclude from monitoring (empty to include all namespaces)")
+	fs.BoolVar(&f.batchWorkloads, "batch-workloads", false, "track pods owned by Jobs and CronJobs")
+	fs.BoolVar(&f.staticPods, "static-pods", false, "track the mirror pods of static pods, e.g. of the control plane")
+	fs.BoolVar(&f.deploymentConfigs, "deployment-configs", false, "track pods owned by OpenShift DeploymentConfigs")
+	fs.BoolVar(&f.knative, "knative", false, "record the pods of Knative revisions under their Knative Service")
+	fs.BoolVar(&f.optIn, "opt-in", false, "only track pods and workloads annotated with deployment-tracker.github.com/track=true")
+	fs.BoolVar(&f.templateAnns, "template-annotations", false, "read per-namespace templates from the deployment-tracker.github.com/template namespace annotation")
+	fs.BoolVar(&f.clusterAutodetect, "cluster-autodetect", false, "discover the cluster name from node labels, the kubeadm config or the cloud metadata when CLUSTER is not set")
+	fs.BoolVar(&f.recordResources, "record-resources", false, "add the replica count of the owning Deployment and the resource requests and limits of containers to records")
+	fs.BoolVar(&f.nodeTopology, "node-topology", false, "add the node of the pod and its zone, region and instance type labels to records")
+	fs.BoolVar(&f.nodePlatform, "node-platform", false, "add the OS and architecture of the node of the pod to records")
+	fs.BoolVar(&f.gitOpsApps, "gitops-apps", false, "add the Argo CD or Flux application that applied the workload to records")
+	fs.BoolVar(&f.helmReleases, "helm-releases", false, "add the Helm release and chart that installed the workload to the record metadata")
+	fs.BoolVar(&f.sinksOnly, "sinks-only", false, "deliver records only to the configured sinks, without posting them to the API")
+	fs.BoolVar(&f.normalizeImages, "normalize-image-names", false, "canonicalize the image names of records, e.g. nginx to docker.io/library/nginx")
+}
+
+// validate returns an error if the flags conflict.
+func (f *commonFlags) validate() error {
+	return controller.ValidateNamespaces(f.namespace, f.excludeNamespaces)
+}
+
+// loadConfig applies the flags and then the config file, if any, to
+// cfg. Settings from the config file take precedence. It returns cfg
+// as it was before the config file was applied.
+func (f *commonFlags) loadConfig(cfg *controller.Config) (controller.Config, error) {
+	cfg.BatchWorkloads = f.batchWorkloads
+	cfg.StaticPods = f.staticPods
+	cfg.DeploymentConfigs = f.deploymentConfigs
+	cfg.Knative = f.knative
+	cfg.OptIn = f.optIn
+	cfg.TemplateAnnotations = f.templateAnns
+	cfg.NormalizeImageNames = f.normalizeImages
+	cfg.RecordResources = f.recordResources
+	cfg.NodeTopology = f.nodeTopology
+	cfg.NodePlatform = f.nodePlatform
+	cfg.GitOpsApps = f.gitOpsApps
+	cfg.HelmReleases = f.helmReleases
+	cfg.SinksOnly = f.sinksOnly
+
+	base := *cfg
+	if f.configFile != "" {
+		if err := controller.LoadConfigFile(f.configFile, cfg); err != nil {
+			return base, err
+		}
+	}
+	return base, nil
+}
+
+// loadDirectConfig returns the configuration from the environment,
+// the flags and the config file of the commands posting records
+// directly, without the queues, retry queue, cache and status
+// resources of the controller: scan, adapter and reconcile. It logs
+// why and returns false if the config file can't be loaded.
+func (f *commonFlags) loadDirectConfig() (controller.Config, bool) {
+	cfg := configFromEnv()
+	if _, err := f.loadConfig(&cfg); err != nil {
+		slog.Error("Failed to load config file",
 			"error", err)
-		os.Exit(1)
+		return cfg, false
+	}
+	cfg.PostBatchSize = 1
+	cfg.PostBatchInterval = time.Second
+	cfg.RetryQueueDir = ""
+	cfg.CacheConfigMap = ""
+	cfg.StatusResources = false
+	return cfg, true
+}
+
+// autodetectCluster discovers the cluster name with
+// -cluster-autodetect, unless cfg already has one or lists several
+// clusters.
+func (f *commonFlags) autodetectCluster(cfg *controller.Config) error {
+	if !f.clusterAutodetect || cfg.Cluster != "" || len(cfg.Clusters) > 0 {
+		return nil
 	}
 
-	clientset, err := kubernetes.NewForConfig(k8sCfg)
+	clientset, err := createClientset(f.kubeconfig)
 	if err != nil {
-		slog.Error("Error creating Kubernetes client",
-			"error", err)
-		os.Exit(1)
-	}
-
-	// Start the metrics server
-	var promSrv = &http.Server{
-		Addr:              ":" + metricsPort,
-		ReadTimeout:       10 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		ReadHeaderTimeout: 10 * time.Second,
-		IdleTimeout:       120 * time.Second,
-		Handler:           http.NewServeMux(),
-	}
-	promSrv.Handler.(*http.ServeMux).Handle("/metrics", promhttp.Handler())
-
-	go func() {
-		slog.Info("starting Prometheus metrics server",
-			"url", promSrv.Addr)
-		if err := promSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("failed to start metrics server",
-				"error", err)
-		}
-	}()
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		slog.Info("Shutting down...")
-
-		// Gracefully shutdown the metrics server
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutdownCancel()
-		if err := promSrv.Shutdown(shutdownCtx); err != nil {
-			slog.Error("failed to shutdown metrics server gracefully",
-				"error", err)
-		}
+		return fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterAutodetectTimeout)
+	defer cancel()
+	name, source, err := controller.DetectClusterName(ctx, clientset)
+	if err != nil {
+		return err
+	}
 
-		cancel()
-	}()
+	slog.Info("Detected cluster name",
+		"cluster", name,
+		"source", source)
+	cfg.Cluster = name
+	return nil
+}
 
-	cntrl, err := controller.New(clientset, namespace, excludeNamespaces, &cntrlCfg)
+// newAPIClient creates the client of the deployment records API. It
+// logs why and returns false if it can't be created.
+func newAPIClient(cfg *controller.Config) (*deploymentrecord.Client, bool) {
+	apiClient, err := controller.NewAPIClient(cfg)
 	if err != nil {
-		slog.Error("Failed to create controller",
+		slog.Error("Failed to create API client",
 			"error", err)
-		os.Exit(1)
+		return nil, false
+	}
+	return apiClient, true
+}
+
+// setupLogging sets up the default JSON logger writing to w.
+func setupLogging(w io.Writer) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile | log.LUTC)
+	opts := slog.HandlerOptions{Level: slog.LevelInfo}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(w, &opts)))
+}
+
+// configFromEnv returns the controller configuration set through
+// environment variables.
+func configFromEnv() controller.Config {
+	return controller.Config{
+		Template:             getEnvOrDefault("DN_TEMPLATE", defaultTemplate),
+		LogicalEnvironment:   os.Getenv("LOGICAL_ENVIRONMENT"),
+		PhysicalEnvironment:  os.Getenv("PHYSICAL_ENVIRONMENT"),
+		Cluster:              os.Getenv("CLUSTER"),
+		APIToken:             getEnvOrDefault("API_TOKEN", ""),
+		APITokenFile:         os.Getenv("API_TOKEN_FILE"),
+		BaseURL:              getEnvOrDefault("BASE_URL", "api.github.com"),
+		GHAppID:              getEnvOrDefault("GH_APP_ID", ""),
+		GHInstallID:          getEnvOrDefault("GH_INSTALL_ID", ""),
+		GHAppPrivateKey:      getEnvOrDefault("GH_APP_PRIV_KEY", ""),
+		GHAppPrivateKeyPEM:   os.Getenv("GH_APP_PRIV_KEY_PEM"),
+		TokenExchangeURL:     os.Getenv("TOKEN_EXCHANGE_URL"),
+		OIDCTokenPath:        os.Getenv("OIDC_TOKEN_PATH"),
+		VaultAddr:            os.Getenv("VAULT_ADDR"),
+		VaultNamespace:       os.Getenv("VAULT_NAMESPACE"),
+		VaultToken:           os.Getenv("VAULT_TOKEN"),
+		VaultRole:            os.Getenv("VAULT_ROLE"),
+		VaultAuthMount:       getEnvOrDefault("VAULT_AUTH_MOUNT", vault.DefaultAuthMount),
+		VaultAPIToken:        os.Getenv("VAULT_API_TOKEN"),
+		VaultGHAppKey:        os.Getenv("VAULT_GH_APP_KEY"),
+		Organization:         os.Getenv("GITHUB_ORG"),
+		FieldProfile:         getEnvOrDefault("FIELD_PROFILE", "default"),
+		FieldMapping:         os.Getenv("FIELD_MAPPING"),
+		MetadataLabels:       os.Getenv("METADATA_LABELS"),
+		MetadataAnnotations:  os.Getenv("METADATA_ANNOTATIONS"),
+		CommitAnnotations:    os.Getenv("COMMIT_ANNOTATIONS"),
+		ExcludeContainers:    lookupEnvOrDefault("EXCLUDE_CONTAINERS", controller.DefaultExcludeContainers),
+		ExcludeImagePrefixes: lookupEnvOrDefault("EXCLUDE_IMAGE_PREFIXES", controller.DefaultExcludeImagePrefixes),
+		WebhookURL:           os.Getenv("WEBHOOK_URL"),
+		WebhookSecret:        os.Getenv("WEBHOOK_SECRET"),
+		WebhookHeaders:       os.Getenv("WEBHOOK_HEADERS"),
+		NotifyURL:            os.Getenv("NOTIFY_URL"),
+		NotifyFormat:         getEnvOrDefault("NOTIFY_FORMAT", sink.NotifyFormatSlack),
+		NotifyNamespaces:     os.Getenv("NOTIFY_NAMESPACES"),
+		NotifySelector:       os.Getenv("NOTIFY_SELECTOR"),
+		NotifyTemplate:       os.Getenv("NOTIFY_TEMPLATE"),
+		GHDeploymentsToken:   os.Getenv("GH_DEPLOYMENTS_TOKEN"),
+		GHDeploymentsURL:     getEnvOrDefault("GH_DEPLOYMENTS_URL", "api.github.com"),
+		SQSQueueURL:          os.Getenv("SQS_QUEUE_URL"),
+		SNSTopicARN:          os.Getenv("SNS_TOPIC_ARN"),
+		PubSubTopic:          os.Getenv("PUBSUB_TOPIC"),
+		WebhookFieldProfile:  os.Getenv("WEBHOOK_FIELD_PROFILE"),
+		WebhookFieldMapping:  os.Getenv("WEBHOOK_FIELD_MAPPING"),
+		QueueFieldProfile:    os.Getenv("QUEUE_FIELD_PROFILE"),
+		QueueFieldMapping:    os.Getenv("QUEUE_FIELD_MAPPING"),
+		NotifyFieldProfile:   os.Getenv("NOTIFY_FIELD_PROFILE"),
+		NotifyFieldMapping:   os.Getenv("NOTIFY_FIELD_MAPPING"),
+		APIHeaders:           os.Getenv("API_HEADERS"),
+		ClientCert:           os.Getenv("CLIENT_CERT"),
+		ClientKey:            os.Getenv("CLIENT_KEY"),
+	}
+}
+
+// validateConfig logs the problems of the controller configuration
+// and returns false if it is invalid.
+func validateConfig(cfg *controller.Config) bool {
+	if cfg.MaxRetries < 0 {
+		slog.Error("Invalid max retries, must not be negative",
+			"max_retries", cfg.MaxRetries)
+		return false
+	}
+
+	if cfg.PostBatchSize < 1 || cfg.PostBatchInterval <= 0 {
+		slog.Error("Invalid batch settings, size must be at least 1 and interval positive",
+			"post_batch_size", cfg.PostBatchSize,
+			"post_batch_interval", cfg.PostBatchInterval)
+		return false
+	}
+
+	if cfg.APIRateLimit < 0 || (cfg.APIRateLimit > 0 && cfg.APIBurst < 1) {
+		slog.Error("Invalid API rate limit, limit must not be negative and burst at least 1",
+			"api_rate_limit", cfg.APIRateLimit,
+			"api_burst", cfg.APIBurst)
+		return false
+	}
+
+	if cfg.DecommissionGracePeriod < 0 {
+		slog.Error("Decommission grace period must not be negative",
+			"decommission_grace_period", cfg.DecommissionGracePeriod)
+		return false
 	}
 
-	slog.Info("Starting deployment-tracker controller")
-	if err := cntrl.Run(ctx, workers); err != nil {
-		slog.Error("Error running controller",
+	if err := cfg.ValidateTemplates(); err != nil {
+		slog.Error("Invalid template",
+			"error", err,
+			"valid_placeholders", controller.TemplatePlaceholders)
+		return false
+	}
+
+	if cfg.LogicalEnvironment == "" {
+		slog.Error("Logical environment is required")
+		return false
+	}
+	if err := cfg.ValidateClusters(); err != nil {
+		slog.Error("Invalid clusters",
 			"error", err)
-		cancel()
-		os.Exit(1)
+		return false
+	}
+	if cfg.Cluster == "" && len(cfg.Clusters) == 0 {
+		slog.Error("Cluster is required")
+		return false
+	}
+	if cfg.Organization == "" {
+		slog.Error("Organization is required")
+		return false
+	}
+	if (cfg.VaultAPIToken != "" || cfg.VaultGHAppKey != "") && cfg.VaultAddr == "" {
+		slog.Error("Vault address is required to read credentials from Vault")
+		return false
+	}
+	if cfg.APIToken != "" && cfg.APITokenFile != "" {
+		slog.Error("API token and API token file are mutually exclusive")
+		return false
+	}
+	ghAppKey := cfg.GHAppPrivateKey != "" || cfg.GHAppPrivateKeyPEM != "" || cfg.VaultGHAppKey != ""
+	if (cfg.GHAppID != "" || cfg.GHInstallID != "" || ghAppKey) &&
+		(cfg.GHAppID == "" || cfg.GHInstallID == "" || !ghAppKey) {
+		slog.Error("GitHub App ID, installation ID and private key must be set together",
+			"gh_app_id", cfg.GHAppID,
+			"gh_install_id", cfg.GHInstallID)
+		return false
+	}
+	if cfg.GHAppPrivateKey != "" && cfg.GHAppPrivateKeyPEM != "" ||
+		cfg.VaultGHAppKey != "" && (cfg.GHAppPrivateKey != "" || cfg.GHAppPrivateKeyPEM != "") {
+		slog.Error("GitHub App private key file, PEM and Vault secret are mutually exclusive")
+		return false
+	}
+	if cfg.SinksOnly && cfg.WebhookURL == "" && cfg.SQSQueueURL == "" &&
+		cfg.SNSTopicARN == "" && cfg.PubSubTopic == "" {
+		slog.Error("A webhook, SQS, SNS or Pub/Sub sink is required to deliver records with -sinks-only")
+		return false
+	}
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		slog.Error("Client certificate and key must be set together",
+			"client_cert", cfg.ClientCert,
+			"client_key", cfg.ClientKey)
+		return false
+	}
+
+	return true
+}
+
+// createClientset creates a Kubernetes client from the kubeconfig, see
+// createK8sConfig.
+func createClientset(kubeconfig string) (kubernetes.Interface, error) {
+	k8sCfg, err := createK8sConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes config: %w", err)
 	}
-	cancel()
+	return kubernetes.NewForConfig(k8sCfg)
 }
 
 func createK8sConfig(kubeconfig string) (*rest.Config, error) {
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// trackingPolicyGVR is the resource of the cluster scoped
+// TrackingPolicy configuration objects, see deploy/crd.yaml.
+var trackingPolicyGVR = schema.GroupVersionResource{
+	Group:    "deployment-tracker.github.com",
+	Version:  "v1alpha1",
+	Resource: "trackingpolicies",
+}
+
+// policyResync is how often the watched TrackingPolicy is applied
+// again, even if it did not change.
+const policyResync = 10 * time.Minute
+
+// watchPolicy applies the spec of the TrackingPolicy name, on top of
+// base, whenever it changes, and base alone when it is deleted. The
+// spec holds the keys of the config file. It returns when ctx is
+// cancelled.
+func watchPolicy(ctx context.Context, client dynamic.Interface, name string, base controller.Config, reload func(*controller.Config) error) error {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, policyResync, metav1.NamespaceAll,
+		func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		})
+	informer := factory.ForResource(trackingPolicyGVR).Informer()
+
+	apply := func(obj any) {
+		policy, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		cfg, err := policyConfig(policy, base)
+		if err != nil {
+			slog.Error("Invalid tracking policy, keeping current configuration",
+				"policy", name,
+				"error", err)
+			return
+		}
+		if err := reload(&cfg); err != nil {
+			slog.Error("Failed to apply tracking policy, keeping current configuration",
+				"policy", name,
+				"error", err)
+			return
+		}
+		slog.Info("Applied tracking policy",
+			"policy", name,
+			"generation", policy.GetGeneration())
+	}
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: apply,
+		UpdateFunc: func(_, newObj any) {
+			apply(newObj)
+		},
+		DeleteFunc: func(any) {
+			cfg := base
+			if err := reload(&cfg); err != nil {
+				slog.Error("Failed to reset configuration after tracking policy deletion",
+					"policy", name,
+					"error", err)
+				return
+			}
+			slog.Info("Tracking policy deleted, reset configuration",
+				"policy", name)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	informer.Run(ctx.Done())
+	return nil
+}
+
+// newPolicyClient creates the client of the TrackingPolicy objects of
+// the cluster of the kubeconfig.
+func newPolicyClient(kubeconfig string) (dynamic.Interface, error) {
+	k8sCfg, err := createK8sConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes config: %w", err)
+	}
+	return dynamic.NewForConfig(k8sCfg)
+}
+
+// loadPolicy returns base with the spec of the TrackingPolicy name
+// applied, so the controller starts with it. A missing policy is not
+// an error.
+func loadPolicy(ctx context.Context, client dynamic.Interface, name string, base controller.Config) (controller.Config, error) {
+	policy, err := client.Resource(trackingPolicyGVR).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		slog.Warn("Tracking policy not found, using the configuration from the environment and flags",
+			"policy", name)
+		return base, nil
+	}
+	if err != nil {
+		return base, fmt.Errorf("failed to get tracking policy %s: %w", name, err)
+	}
+	cfg, err := policyConfig(policy, base)
+	if err != nil {
+		return base, fmt.Errorf("invalid tracking policy %s: %w", name, err)
+	}
+	return cfg, nil
+}
+
+// policyConfig returns base with the spec of the TrackingPolicy
+// applied.
+func policyConfig(policy *unstructured.Unstructured, base controller.Config) (controller.Config, error) {
+	cfg := base
+	spec, ok := policy.Object["spec"]
+	if !ok {
+		return cfg, nil
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return cfg, err
+	}
+	if err := controller.ParseConfig(data, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
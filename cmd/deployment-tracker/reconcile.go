@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+)
+
+// runReconcile runs a one-shot reconciliation: the running pods are
+// compared with the records of the API, and the actions needed to
+// bring the API in line are printed and, with -apply, applied. It
+// returns the exit code.
+func runReconcile(args []string) int {
+	var (
+		common  commonFlags
+		diff    bool
+		apply   bool
+		timeout time.Duration
+	)
+
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	common.register(fs, false)
+	fs.BoolVar(&diff, "diff", true, "print the actions needed to bring the API in line with the cluster")
+	fs.BoolVar(&apply, "apply", false, "apply the actions")
+	fs.DurationVar(&timeout, "timeout", 5*time.Minute, "maximum duration of the reconciliation")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := common.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Keep stdout for the actions
+	setupLogging(os.Stderr)
+
+	cfg, ok := common.loadDirectConfig()
+	if !ok {
+		return 1
+	}
+	if len(cfg.Clusters) > 0 {
+		slog.Error("Multi-cluster mode is only supported by the run command, reconcile each cluster separately")
+		return 1
+	}
+	if err := common.autodetectCluster(&cfg); err != nil {
+		slog.Error("Failed to detect the cluster name",
+			"error", err)
+		return 1
+	}
+	if !validateConfig(&cfg) {
+		return 1
+	}
+
+	clientset, err := createClientset(common.kubeconfig)
+	if err != nil {
+		slog.Error("Failed to create Kubernetes client",
+			"error", err)
+		return 1
+	}
+
+	cntrl, err := controller.New(clientset, common.namespace, common.excludeNamespaces, &cfg)
+	if err != nil {
+		slog.Error("Failed to create controller",
+			"error", err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+
+	actions, err := cntrl.Reconcile(ctx)
+	if err != nil {
+		slog.Error("Failed to reconcile",
+			"error", err)
+		return 1
+	}
+
+	if diff {
+		printActions(os.Stdout, actions)
+	}
+
+	if apply && len(actions) > 0 {
+		if err := cntrl.ApplyActions(ctx, actions); err != nil {
+			slog.Error("Failed to apply actions",
+				"error", err)
+			return 1
+		}
+		slog.Info("Applied actions",
+			"count", len(actions),
+		)
+	}
+
+	return 0
+}
+
+// printActions writes the actions as a table, followed by a summary.
+func printActions(w io.Writer, actions []controller.ReconcileAction) {
+	counts := make(map[string]int)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tDEPLOYMENT NAME\tIMAGE\tVERSION\tDIGEST")
+	for _, a := range actions {
+		counts[a.Type]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			a.Type, a.Record.DeploymentName, a.Record.Name, a.Record.Version, a.Record.Digest)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(w, "\n%d to create, %d to update, %d to decommission\n",
+		counts[controller.ActionCreate],
+		counts[controller.ActionUpdate],
+		counts[controller.ActionDecommission])
+}
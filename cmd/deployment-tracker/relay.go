@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/relay"
+	"github.com/github/deployment-tracker/pkg/sink"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runRelay runs the relay: the records of edge instances, delivered by
+// their webhook sink or submitted with the gRPC record submission API,
+// are posted to the API with the credentials of the relay. It returns
+// the exit code.
+func runRelay(args []string) int {
+	var (
+		addr        string
+		metricsAddr string
+		tlsCert     string
+		tlsKey      string
+		clientCA    string
+		postTimeout time.Duration
+	)
+
+	fs := flag.NewFlagSet("relay", flag.ContinueOnError)
+	fs.StringVar(&addr, "addr", ":8443", "address (host:port) to accept the records of edge instances on")
+	fs.StringVar(&metricsAddr, "metrics-addr", ":9090", "address (host:port) to listen to for metrics and health checks")
+	fs.StringVar(&tlsCert, "tls-cert", "", "path of the PEM certificate of the relay")
+	fs.StringVar(&tlsKey, "tls-key", "", "path of the PEM private key of the relay")
+	fs.StringVar(&clientCA, "client-ca", "", "path of a PEM CA bundle; if set, edge instances must present a client certificate it issued")
+	fs.DurationVar(&postTimeout, "post-timeout", relay.DefaultPostTimeout, "timeout of the post of each record, below the -webhook-timeout of the edge instances")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	setupLogging(os.Stdout)
+
+	if tlsCert == "" || tlsKey == "" {
+		slog.Error("A TLS certificate and key are required")
+		return 2
+	}
+	tlsConfig, err := relayTLSConfig(clientCA)
+	if err != nil {
+		slog.Error("Invalid TLS configuration",
+			"error", err)
+		return 1
+	}
+
+	secrets, err := sink.ParseHeaders(os.Getenv("RELAY_SECRETS"))
+	if err != nil {
+		slog.Error("Invalid relay secrets, expected cluster=secret pairs",
+			"error", err)
+		return 1
+	}
+
+	// Records are posted as received, the edge instances fill in
+	// their cluster and environments
+	cfg := configFromEnv()
+	if cfg.Organization == "" {
+		slog.Error("Organization is required")
+		return 1
+	}
+	apiClient, ok := newAPIClient(&cfg)
+	if !ok {
+		return 1
+	}
+	handler, err := relay.NewHandler(apiClient, secrets, relay.WithPostTimeout(postTimeout))
+	if err != nil {
+		slog.Error("Invalid relay configuration",
+			"error", err)
+		return 1
+	}
+
+	// gRPC calls are served over the same TLS listener, as HTTP/2
+	// requests with a gRPC content type
+	grpcSrv := relay.NewGRPCServer(handler)
+	mux := http.NewServeMux()
+	mux.Handle("/events", handler)
+	srv := &http.Server{
+		Addr:              addr,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      time.Minute,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if relay.IsGRPC(r) {
+				grpcSrv.ServeHTTP(w, r)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}),
+		TLSConfig: tlsConfig,
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsMux.HandleFunc("/healthz", checkHandler(func() error { return nil }))
+	metricsMux.HandleFunc("/readyz", checkHandler(func() error {
+		if !apiClient.Reachable() {
+			return errors.New("the API is unreachable")
+		}
+		return nil
+	}))
+	promSrv := &http.Server{
+		Addr:              metricsAddr,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		Handler:           metricsMux,
+	}
+	go func() {
+		slog.Info("starting Prometheus metrics server",
+			"url", promSrv.Addr)
+		if err := promSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("failed to start metrics server",
+				"error", err)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		slog.Info("Shutting down...")
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdownCancel()
+		if err := promSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown metrics server gracefully",
+				"error", err)
+		}
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown relay server gracefully",
+				"error", err)
+		}
+	}()
+
+	slog.Info("Starting deployment-tracker relay",
+		"url", srv.Addr,
+		"clusters", len(secrets),
+		"client_certificates", clientCA != "")
+	if err := srv.ListenAndServeTLS(tlsCert, tlsKey); err != nil && err != http.ErrServerClosed {
+		slog.Error("Relay server failed",
+			"error", err)
+		return 1
+	}
+	return 0
+}
+
+// relayTLSConfig returns the TLS configuration of the relay server,
+// requiring client certificates issued by the CA bundle in the PEM
+// file clientCA, if set.
+func relayTLSConfig(clientCA string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCA == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA %s", clientCA)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+	"github.com/github/deployment-tracker/internal/version"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/github/deployment-tracker/pkg/sink"
+	"github.com/github/deployment-tracker/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// runFlags are the flags of the run command.
+type runFlags struct {
+	common            commonFlags
+	workers           int
+	deleteWorkers     int
+	maxRetries        int
+	postBatchSize     int
+	postBatchInterval time.Duration
+	gracePeriod       time.Duration
+	coalesceWindow    time.Duration
+	apiRateLimit      float64
+	apiBurst          int
+	apiConcurrency    int
+	retryBackoff      deploymentrecord.Backoff
+	connPool          deploymentrecord.ConnPool
+	timeouts          deploymentrecord.Timeouts
+	queueLimits       controller.QueueLimits
+	chaos             deploymentrecord.Chaos
+	chaosResync       time.Duration
+	metricsPort       string
+	metricsAddr       string
+	adminAddr         string
+	envRecords        bool
+	nsDecommission    bool
+	statusResources   bool
+	ephemeral         bool
+	initialSync       bool
+	rolloutStatus     bool
+	partialRollouts   bool
+	verifyDigests     bool
+	checkSignatures   bool
+	platformDigests   bool
+	resolveImageIDs   bool
+	cacheConfigMap    string
+	cacheSize         int
+	cacheTTL          time.Duration
+	webhookTimeout    time.Duration
+	retryQueueDir     string
+	policy            string
+	auditLog          string
+	contexts          string
+}
+
+// register registers the flags on fs.
+func (f *runFlags) register(fs *flag.FlagSet) {
+	f.common.register(fs, true)
+	fs.IntVar(&f.workers, "workers", 2, "number of worker goroutines")
+	fs.IntVar(&f.deleteWorkers, "delete-workers", 1, "number of worker goroutines processing delete events from a separate queue (0 to process them with the other events)")
+	fs.IntVar(&f.maxRetries, "max-retries", 0, "number of times a failed event is retried before it is dropped (0 to retry forever)")
+	fs.IntVar(&f.postBatchSize, "post-batch-size", 1, "maximum number of records per batch post (1 disables batching)")
+	fs.DurationVar(&f.postBatchInterval, "post-batch-interval", time.Second, "maximum time a record waits for its batch to fill up")
+	fs.Float64Var(&f.apiRateLimit, "api-rate-limit", 20, "maximum number of API requests per second")
+	fs.IntVar(&f.apiBurst, "api-burst", 50, "maximum number of API requests sent in a burst above the rate limit")
+	fs.IntVar(&f.apiConcurrency, "api-max-concurrency", 0, "maximum number of API requests in flight (0 for no limit)")
+	fs.DurationVar(&f.retryBackoff.Base, "retry-backoff-base", deploymentrecord.DefaultBackoff.Base, "base of the exponential backoff between retries of failed API requests")
+	fs.Float64Var(&f.retryBackoff.Multiplier, "retry-backoff-multiplier", deploymentrecord.DefaultBackoff.Multiplier, "factor the backoff grows by with each retry")
+	fs.DurationVar(&f.retryBackoff.Max, "retry-backoff-max", deploymentrecord.DefaultBackoff.Max, "maximum backoff between retries")
+	fs.DurationVar(&f.retryBackoff.Jitter, "retry-backoff-jitter", deploymentrecord.DefaultBackoff.Jitter, "maximum random jitter added to the backoff")
+	fs.IntVar(&f.connPool.MaxIdleConnsPerHost, "api-max-idle-conns", deploymentrecord.DefaultConnPool.MaxIdleConnsPerHost, "number of idle connections kept open to the API")
+	fs.DurationVar(&f.connPool.IdleConnTimeout, "api-idle-conn-timeout", deploymentrecord.DefaultConnPool.IdleConnTimeout, "how long idle connections to the API are kept open (0 for no limit)")
+	fs.BoolVar(&f.connPool.ForceAttemptHTTP2, "api-http2", deploymentrecord.DefaultConnPool.ForceAttemptHTTP2, "negotiate HTTP/2 with the API")
+	fs.BoolVar(&f.connPool.DisableKeepAlives, "api-disable-keep-alives", deploymentrecord.DefaultConnPool.DisableKeepAlives, "open a new connection to the API for each request")
+	fs.DurationVar(&f.timeouts.Dial, "api-dial-timeout", deploymentrecord.DefaultTimeouts.Dial, "timeout to connect to the API")
+	fs.DurationVar(&f.timeouts.TLSHandshake, "api-tls-timeout", deploymentrecord.DefaultTimeouts.TLSHandshake, "timeout of the TLS handshake with the API")
+	fs.DurationVar(&f.timeouts.ResponseHeader, "api-response-timeout", deploymentrecord.DefaultTimeouts.ResponseHeader, "timeout waiting for the response headers of the API")
+	fs.DurationVar(&f.timeouts.Attempt, "api-attempt-timeout", deploymentrecord.DefaultTimeouts.Attempt, "timeout of each attempt of an API request, timed out attempts are retried")
+	fs.DurationVar(&f.queueLimits.BaseDelay, "queue-base-delay", controller.DefaultQueueBaseDelay, "delay before the first retry of a failed event, doubling with each retry")
+	fs.DurationVar(&f.queueLimits.MaxDelay, "queue-max-delay", controller.DefaultQueueMaxDelay, "maximum delay between retries of a failed event")
+	fs.Float64Var(&f.queueLimits.QPS, "queue-qps", controller.DefaultQueueQPS, "maximum number of failed events retried per second overall")
+	fs.IntVar(&f.queueLimits.Burst, "queue-burst", controller.DefaultQueueBurst, "maximum number of failed events retried in a burst above -queue-qps")
+	fs.DurationVar(&f.coalesceWindow, "coalesce-window", 0, "window in which the create events of pods posting the same records are coalesced into one (0 to disable)")
+	fs.DurationVar(&f.gracePeriod, "decommission-grace-period", 0, "time to wait after a pod is deleted before checking whether its deployment is decommissioned")
+	fs.DurationVar(&f.webhookTimeout, "webhook-timeout", sink.DefaultWebhookTimeout, "timeout of the webhook sink requests, above the -post-timeout of the relay it delivers to")
+	fs.StringVar(&f.metricsPort, "metrics-port", "9090", "port to listen to for metrics")
+	fs.StringVar(&f.metricsAddr, "metrics-addr", "", "address (host:port) to listen to for metrics, overrides -metrics-port")
+	fs.StringVar(&f.adminAddr, "admin-addr", "127.0.0.1:8081", "address (host:port) to listen to for health, readiness, snapshot, dead letter, cache and pprof endpoints")
+	fs.StringVar(&f.cacheConfigMap, "cache-configmap", "", "configmap (namespace/name) to persist the observation cache in (empty to disable)")
+	fs.IntVar(&f.cacheSize, "observed-cache-size", 100000, "maximum number of entries of the observation cache, evicting the least recently used (0 for no limit)")
+	fs.DurationVar(&f.cacheTTL, "observed-cache-ttl", 0, "time after which the observation cache entries of deployments no longer running are evicted (0 to disable)")
+	fs.StringVar(&f.policy, "policy", "", "TrackingPolicy to read the configuration from and watch for changes (empty to disable), instead of -config")
+	fs.StringVar(&f.retryQueueDir, "retry-queue-dir", "", "directory to keep records that failed to post in until they are replayed (empty to disable)")
+	fs.StringVar(&f.auditLog, "audit-log", "", "file to append a JSON line per posted or skipped record to, - for stdout (empty to disable)")
+	fs.BoolVar(&f.envRecords, "environment-records", false, "post environment records when tracked namespaces are created or deleted")
+	fs.BoolVar(&f.statusResources, "status-resources", false, "write the post results of the records of each workload to a TrackedDeployment status resource")
+	fs.BoolVar(&f.nsDecommission, "namespace-decommission", false, "decommission the records of a deleted namespace in batch requests, rather than per pod")
+	fs.BoolVar(&f.rolloutStatus, "rollout-status", false, "record the pods of Deployments once their rollout completed, instead of as each pod starts")
+	fs.BoolVar(&f.partialRollouts, "partial-rollouts", false, "record the pods of Deployments rolling out as partially deployed, and as deployed once the rollout completed")
+	fs.BoolVar(&f.initialSync, "initial-sync", true, "post the records of the pods already running on startup")
+	fs.BoolVar(&f.verifyDigests, "verify-digests", false, "resolve the images of deployed records against their registries to detect digest mismatches")
+	fs.BoolVar(&f.checkSignatures, "check-signatures", false, "look up the cosign signatures and attestations of the images of deployed records")
+	fs.BoolVar(&f.platformDigests, "platform-digests", false, "resolve the manifest digest of the platform of the node of multi-arch images; implies -node-platform")
+	fs.BoolVar(&f.resolveImageIDs, "resolve-image-ids", false, "resolve the digest of containers whose imageID is not a digest from their registries, instead of skipping them")
+	fs.BoolVar(&f.ephemeral, "ephemeral-containers", false, "record ephemeral containers, e.g. those added by kubectl debug")
+	fs.Float64Var(&f.chaos.FailureRate, "chaos-api-failure-rate", 0, "fraction (0 to 1) of API request attempts to fail, for soak testing")
+	fs.IntVar(&f.chaos.FailureStatus, "chaos-api-failure-status", http.StatusServiceUnavailable, "HTTP status code of the failed attempts (0 for connection errors)")
+	fs.DurationVar(&f.chaos.Latency, "chaos-api-latency", 0, "latency added to every API request attempt")
+	fs.DurationVar(&f.chaos.LatencyJitter, "chaos-api-latency-jitter", 0, "maximum random jitter added to the latency")
+	fs.DurationVar(&f.chaosResync, "chaos-resync-interval", 0, "interval at which all running pods are enqueued (0 to disable)")
+	hideFlags(fs, "chaos-api-failure-rate", "chaos-api-failure-status", "chaos-api-latency",
+		"chaos-api-latency-jitter", "chaos-resync-interval")
+	fs.StringVar(&f.contexts, "contexts", "", "comma separated list of kubeconfig contexts of the clusters to watch (empty for the single cluster of the kubeconfig or in-cluster config)")
+}
+
+// validate returns an error if the flags are invalid. It defaults the
+// metrics address to the metrics port.
+func (f *runFlags) validate() error {
+	if err := f.common.validate(); err != nil {
+		return err
+	}
+
+	if f.metricsAddr == "" {
+		f.metricsAddr = ":" + f.metricsPort
+	}
+	for _, addr := range []string{f.metricsAddr, f.adminAddr} {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listen address %s, must be host:port: %w", addr, err)
+		}
+	}
+
+	if err := f.retryBackoff.Validate(); err != nil {
+		return err
+	}
+	if f.apiConcurrency < 0 {
+		return fmt.Errorf("invalid maximum API concurrency %d, must not be negative", f.apiConcurrency)
+	}
+	if err := f.connPool.Validate(); err != nil {
+		return err
+	}
+	if err := f.timeouts.Validate(); err != nil {
+		return err
+	}
+	if err := f.queueLimits.Validate(); err != nil {
+		return err
+	}
+	if err := f.chaos.Validate(); err != nil {
+		return err
+	}
+	if f.cacheSize < 0 {
+		return fmt.Errorf("invalid observed cache size %d, must not be negative", f.cacheSize)
+	}
+	if f.cacheTTL < 0 {
+		return fmt.Errorf("invalid observed cache TTL %s, must not be negative", f.cacheTTL)
+	}
+	if f.chaosResync < 0 {
+		return fmt.Errorf("invalid chaos resync interval %s, must not be negative", f.chaosResync)
+	}
+
+	// Validate worker count
+	if f.workers < 1 || f.workers > 100 {
+		return fmt.Errorf("invalid worker count %d, must be between 1 and 100", f.workers)
+	}
+	if f.deleteWorkers < 0 || f.deleteWorkers > 100 {
+		return fmt.Errorf("invalid delete worker count %d, must be between 0 and 100", f.deleteWorkers)
+	}
+	if f.policy != "" && f.common.configFile != "" {
+		return errors.New("-policy and -config are mutually exclusive")
+	}
+
+	return nil
+}
+
+// loadConfig returns the controller configuration from the
+// environment, the flags and the config file, and the configuration
+// before the config file was applied, which is the base for reloads.
+func (f *runFlags) loadConfig() (controller.Config, controller.Config, error) {
+	cfg := configFromEnv()
+	cfg.MaxRetries = f.maxRetries
+	cfg.DeleteWorkers = f.deleteWorkers
+	cfg.PostBatchSize = f.postBatchSize
+	cfg.PostBatchInterval = f.postBatchInterval
+	cfg.DecommissionGracePeriod = f.gracePeriod
+	cfg.CoalesceWindow = f.coalesceWindow
+	cfg.WebhookTimeout = f.webhookTimeout
+	cfg.APIRateLimit = f.apiRateLimit
+	cfg.APIBurst = f.apiBurst
+	cfg.APIMaxConcurrency = f.apiConcurrency
+	cfg.RetryBackoff = f.retryBackoff
+	cfg.ConnPool = f.connPool
+	cfg.Timeouts = f.timeouts
+	cfg.QueueLimits = f.queueLimits
+	if f.chaos.Enabled() {
+		cfg.Chaos = f.chaos
+	}
+	cfg.ChaosResyncInterval = f.chaosResync
+	cfg.CacheConfigMap = f.cacheConfigMap
+	cfg.ObservedCacheSize = f.cacheSize
+	cfg.ObservedCacheTTL = f.cacheTTL
+	cfg.RetryQueueDir = f.retryQueueDir
+	cfg.AuditLog = f.auditLog
+	cfg.EnvironmentRecords = f.envRecords
+	cfg.NamespaceDecommission = f.nsDecommission
+	cfg.StatusResources = f.statusResources
+	cfg.EphemeralContainers = f.ephemeral
+	cfg.SkipInitialSync = !f.initialSync
+	cfg.RolloutStatus = f.rolloutStatus
+	cfg.PartialRollouts = f.partialRollouts
+	cfg.VerifyDigests = f.verifyDigests
+	cfg.CheckSignatures = f.checkSignatures
+	cfg.PlatformDigests = f.platformDigests
+	cfg.ResolveImageIDs = f.resolveImageIDs
+	cfg.Clusters = parseContexts(f.contexts)
+
+	base, err := f.common.loadConfig(&cfg)
+	return cfg, base, err
+}
+
+// newTrackerController creates the controller of the single cluster,
+// or the controllers of the clusters of cfg in multi-cluster mode.
+func newTrackerController(common commonFlags, cfg *controller.Config) (trackerController, error) {
+	if len(cfg.Clusters) > 0 {
+		return newClusterControllers(common.kubeconfig, common.namespace, common.excludeNamespaces, cfg)
+	}
+
+	k8sCfg, err := createK8sConfig(common.kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes config: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(k8sCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	opts, err := controllerOptions(k8sCfg, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return controller.New(clientset, common.namespace, common.excludeNamespaces, cfg, opts...)
+}
+
+// controllerOptions returns the options of the controller of the
+// cluster of k8sCfg: the dynamic client if status resources,
+// DeploymentConfigs or Knative are enabled.
+func controllerOptions(k8sCfg *rest.Config, cfg *controller.Config) ([]controller.Option, error) {
+	if !cfg.StatusResources && !cfg.DeploymentConfigs && !cfg.Knative {
+		return nil, nil
+	}
+	client, err := dynamic.NewForConfig(k8sCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return []controller.Option{controller.WithDynamicClient(client)}, nil
+}
+
+// runController runs the controller until it is interrupted. It
+// returns the exit code.
+func runController(args []string) int {
+	var flags runFlags
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.register(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := flags.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	setupLogging(os.Stdout)
+	metrics.BuildInfo.WithLabelValues(version.Get(), version.GetCommit(), runtime.Version()).Set(1)
+
+	// Tracing must be set up before the controller is created
+	shutdownTracing, err := tracing.Setup(version.Get())
+	if err != nil {
+		slog.Error("Failed to set up tracing",
+			"error", err)
+		return 1
+	}
+
+	cntrlCfg, baseCfg, err := flags.loadConfig()
+	if err != nil {
+		slog.Error("Failed to load config file",
+			"error", err)
+		return 1
+	}
+
+	var policyClient dynamic.Interface
+	if flags.policy != "" {
+		policyClient, err = newPolicyClient(flags.common.kubeconfig)
+		if err != nil {
+			slog.Error("Failed to create tracking policy client",
+				"error", err)
+			return 1
+		}
+		cntrlCfg, err = loadPolicy(context.Background(), policyClient, flags.policy, baseCfg)
+		if err != nil {
+			slog.Error("Failed to load tracking policy",
+				"error", err)
+			return 1
+		}
+	}
+
+	if err := flags.common.autodetectCluster(&cntrlCfg); err != nil {
+		slog.Error("Failed to detect the cluster name",
+			"error", err)
+		return 1
+	}
+	// Keep the detected name when the config file is reloaded
+	if baseCfg.Cluster == "" {
+		baseCfg.Cluster = cntrlCfg.Cluster
+	}
+	if !validateConfig(&cntrlCfg) {
+		return 1
+	}
+
+	cntrl, err := newTrackerController(flags.common, &cntrlCfg)
+	if err != nil {
+		slog.Error("Failed to create controller",
+			"error", err)
+		return 1
+	}
+
+	// Start the metrics server
+	var promSrv = &http.Server{
+		Addr:              flags.metricsAddr,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		Handler:           http.NewServeMux(),
+	}
+	// OpenMetrics is required for exemplars to be exposed
+	promSrv.Handler.(*http.ServeMux).Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}),
+	))
+	// The probes are served with the metrics, as the admin server is
+	// only reachable from the pod by default
+	promSrv.Handler.(*http.ServeMux).HandleFunc("/healthz", checkHandler(cntrl.Healthy))
+	promSrv.Handler.(*http.ServeMux).HandleFunc("/readyz", checkHandler(cntrl.Ready))
+
+	go func() {
+		slog.Info("starting Prometheus metrics server",
+			"url", promSrv.Addr)
+		if err := promSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("failed to start metrics server",
+				"error", err)
+		}
+	}()
+
+	// Start the admin server
+	adminSrv := newAdminServer(flags.adminAddr, cntrl.Healthy, cntrl.Ready, cntrl, os.Getenv("ADMIN_TOKEN"))
+
+	go func() {
+		slog.Info("starting admin server",
+			"url", adminSrv.Addr)
+		if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("failed to start admin server",
+				"error", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		slog.Info("Shutting down...")
+
+		// Gracefully shutdown the metrics and admin servers
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		if err := promSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown metrics server gracefully",
+				"error", err)
+		}
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown admin server gracefully",
+				"error", err)
+		}
+
+		cancel()
+	}()
+
+	if flags.common.configFile != "" {
+		go watchConfigFile(ctx, flags.common.configFile, baseCfg, cntrl.Reload)
+	}
+	if flags.policy != "" {
+		go func() {
+			if err := watchPolicy(ctx, policyClient, flags.policy, baseCfg, cntrl.Reload); err != nil {
+				slog.Error("Failed to watch tracking policy",
+					"policy", flags.policy,
+					"error", err)
+			}
+		}()
+	}
+
+	slog.Info("Starting deployment-tracker controller")
+	runErr := cntrl.Run(ctx, flags.workers)
+	cancel()
+
+	// Flush the remaining spans
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to shutdown tracing gracefully",
+			"error", err)
+	}
+	shutdownCancel()
+
+	if runErr != nil {
+		slog.Error("Error running controller",
+			"error", runErr)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/registry"
+)
+
+// runScan runs a one-shot scan of Kubernetes manifests: the records of
+// the workloads they define are printed and, unless -dry-run, posted
+// as deployed. It returns the exit code.
+func runScan(args []string) int {
+	var (
+		common    commonFlags
+		paths     []string
+		namespace string
+		resolve   bool
+		dryRun    bool
+		timeout   time.Duration
+	)
+
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	common.register(fs, false)
+	fs.Func("f", "manifest file or directory of .yaml, .yml and .json files to scan, - for stdin (repeatable)", func(s string) error {
+		paths = append(paths, s)
+		return nil
+	})
+	fs.StringVar(&namespace, "default-namespace", "default", "namespace of the objects without one")
+	fs.BoolVar(&resolve, "resolve-digests", true, "resolve the digests of images without one from their registry")
+	fs.BoolVar(&dryRun, "dry-run", false, "print the records without posting them")
+	fs.DurationVar(&timeout, "timeout", 5*time.Minute, "maximum duration of the scan")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "At least one manifest is required, set with -f")
+		return 2
+	}
+
+	// Keep stdout for the records
+	setupLogging(os.Stderr)
+
+	cfg, ok := common.loadDirectConfig()
+	if !ok {
+		return 1
+	}
+	if !validateConfig(&cfg) {
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+
+	var resolver controller.DigestResolver
+	if resolve {
+		resolver = registry.NewClient().Digest
+	}
+	records, err := scanPaths(ctx, &cfg, paths, namespace, resolver)
+	if err != nil {
+		slog.Error("Failed to scan manifests",
+			"error", err)
+		return 1
+	}
+	printRecords(os.Stdout, records)
+	if dryRun || len(records) == 0 {
+		return 0
+	}
+
+	apiClient, ok := newAPIClient(&cfg)
+	if !ok {
+		return 1
+	}
+	var failed int
+	for _, record := range records {
+		if err := apiClient.PostOne(ctx, record); err != nil {
+			slog.Error("Failed to post record",
+				"deployment_name", record.DeploymentName,
+				"digest", record.Digest,
+				"error", err)
+			failed++
+		}
+	}
+	slog.Info("Posted records",
+		"count", len(records)-failed,
+		"failed", failed,
+	)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// scanPaths scans the manifests in the files and directories, see
+// controller.ScanManifests. Records of the same deployment name and
+// digest are only returned once.
+func scanPaths(ctx context.Context, cfg *controller.Config, paths []string, namespace string, resolve controller.DigestResolver) ([]*deploymentrecord.DeploymentRecord, error) {
+	var files []string
+	for _, path := range paths {
+		if path == "-" {
+			files = append(files, path)
+			continue
+		}
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Files named explicitly are scanned whatever their
+			// extension
+			if p == path && !d.IsDir() {
+				files = append(files, p)
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".yaml", ".yml", ".json":
+				if !d.IsDir() {
+					files = append(files, p)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var res []*deploymentrecord.DeploymentRecord
+	seen := make(map[string]bool)
+	for _, file := range files {
+		records, err := scanFile(ctx, cfg, file, namespace, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, record := range records {
+			key := record.DeploymentName + "||" + record.Digest
+			if !seen[key] {
+				seen[key] = true
+				res = append(res, record)
+			}
+		}
+	}
+	return res, nil
+}
+
+// scanFile scans the manifests of the file, or of stdin for -.
+func scanFile(ctx context.Context, cfg *controller.Config, file, namespace string, resolve controller.DigestResolver) ([]*deploymentrecord.DeploymentRecord, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return controller.ScanManifests(ctx, cfg, r, namespace, resolve)
+}
+
+// printRecords writes the records as a table, followed by a summary.
+func printRecords(w io.Writer, records []*deploymentrecord.DeploymentRecord) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEPLOYMENT NAME\tIMAGE\tVERSION\tDIGEST")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.DeploymentName, r.Name, r.Version, r.Digest)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(w, "\n%d records\n", len(records))
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/github/deployment-tracker/internal/controller"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// runValidate checks the configuration the controller would run with:
+// the flags, environment variables, config file or tracking policy,
+// the template, and optionally the Kubernetes and API credentials and
+// the status resources. It takes the same flags as run. It returns the
+// exit code.
+func runValidate(args []string) int {
+	var (
+		flags        runFlags
+		checkCluster bool
+		checkAPI     bool
+		timeout      time.Duration
+	)
+
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.register(fs)
+	fs.BoolVar(&checkCluster, "check-cluster", true, "check that the Kubernetes API server can be reached")
+	fs.BoolVar(&checkAPI, "check-api", true, "check that the deployment record API accepts the credentials")
+	fs.DurationVar(&timeout, "timeout", 30*time.Second, "maximum duration of the checks")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	setupLogging(os.Stderr)
+
+	if err := flags.validate(); err != nil {
+		slog.Error("Invalid flags",
+			"error", err)
+		return 1
+	}
+
+	cfg, base, err := flags.loadConfig()
+	if err != nil {
+		slog.Error("Failed to load config file",
+			"error", err)
+		return 1
+	}
+	if flags.policy != "" {
+		policyClient, err := newPolicyClient(flags.common.kubeconfig)
+		if err != nil {
+			slog.Error("Failed to create tracking policy client",
+				"error", err)
+			return 1
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		cfg, err = loadPolicy(ctx, policyClient, flags.policy, base)
+		cancel()
+		if err != nil {
+			slog.Error("Failed to load tracking policy",
+				"error", err)
+			return 1
+		}
+	}
+	if err := flags.common.autodetectCluster(&cfg); err != nil {
+		slog.Error("Failed to detect the cluster name",
+			"error", err)
+		return 1
+	}
+	if !validateConfig(&cfg) {
+		return 1
+	}
+	// Validating must not create the retry queue directory
+	cfg.RetryQueueDir = ""
+
+	// Creating the controllers validates the remaining settings, e.g.
+	// the field mapping and webhook
+	var cntrl *controller.Controller
+	clientsets := make(map[string]kubernetes.Interface)
+	controllers := make(map[string]*controller.Controller)
+	if len(cfg.Clusters) > 0 {
+		clusters, err := newClusterControllers(flags.common.kubeconfig, flags.common.namespace, flags.common.excludeNamespaces, &cfg)
+		if err != nil {
+			slog.Error("Invalid configuration",
+				"error", err)
+			return 1
+		}
+		cntrl = clusters[0].Controller
+		for _, c := range clusters {
+			clientsets[c.cluster.ClusterName()] = c.clientset
+			controllers[c.cluster.ClusterName()] = c.Controller
+		}
+	} else {
+		k8sCfg, err := createK8sConfig(flags.common.kubeconfig)
+		if err != nil {
+			slog.Error("Failed to create Kubernetes config",
+				"error", err)
+			return 1
+		}
+		clientset, err := kubernetes.NewForConfig(k8sCfg)
+		if err != nil {
+			slog.Error("Failed to create Kubernetes client",
+				"error", err)
+			return 1
+		}
+		opts, err := controllerOptions(k8sCfg, &cfg)
+		if err != nil {
+			slog.Error("Invalid configuration",
+				"error", err)
+			return 1
+		}
+		cntrl, err = controller.New(clientset, flags.common.namespace, flags.common.excludeNamespaces, &cfg, opts...)
+		if err != nil {
+			slog.Error("Invalid configuration",
+				"error", err)
+			return 1
+		}
+		clientsets[cfg.Cluster] = clientset
+		controllers[cfg.Cluster] = cntrl
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if checkCluster {
+		for cluster, clientset := range clientsets {
+			info, err := clientset.Discovery().ServerVersion()
+			if err != nil {
+				slog.Error("Failed to reach the Kubernetes API server",
+					"cluster", cluster,
+					"error", err)
+				return 1
+			}
+			slog.Info("Kubernetes API server reachable",
+				"cluster", cluster,
+				"version", info.GitVersion)
+
+			if err := controllers[cluster].CheckStatusResources(ctx); err != nil {
+				slog.Error("Status resources check failed",
+					"cluster", cluster,
+					"error", err)
+				return 1
+			}
+		}
+	}
+
+	if checkAPI {
+		if err := cntrl.CheckAPI(ctx); err != nil {
+			slog.Error("Deployment record API check failed",
+				"error", err)
+			return 1
+		}
+		slog.Info("Deployment record API accepts the credentials",
+			"base_url", cfg.BaseURL,
+			"organization", cfg.Organization)
+	}
+
+	fmt.Println("Configuration is valid")
+	return 0
+}
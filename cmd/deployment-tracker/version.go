@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/github/deployment-tracker/internal/version"
+)
+
+// runVersion prints the version and build information. It returns
+// the exit code.
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	fmt.Printf("deployment-tracker %s\n", version.Get())
+	fmt.Printf("  commit:   %s\n", version.GetCommit())
+	fmt.Printf("  go:       %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.time":
+				fmt.Printf("  built:    %s\n", s.Value)
+			case "vcs.modified":
+				fmt.Printf("  modified: %s\n", s.Value)
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// Audit outcomes.
+const (
+	// auditPosted is a record accepted by the API.
+	auditPosted = "posted"
+	// auditFailed is a record that failed to post, and is retried.
+	auditFailed = "failed"
+	// auditRejected is a record rejected by the API with a client
+	// error, which is not retried.
+	auditRejected = "rejected"
+	// auditSkipped is a record that was not posted, see the reason.
+	auditSkipped = "skipped"
+)
+
+// Audit sources, the paths records are posted through.
+const (
+	auditSourceEvent      = "event"
+	auditSourceRetryQueue = "retry_queue"
+	auditSourceDeadLetter = "dead_letter"
+	auditSourceReconcile  = "reconcile"
+)
+
+// auditEntry is a line of the audit log.
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+	Source    string    `json:"source"`
+	EventType string    `json:"event_type,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	// LatencyMS is the time it took to post the record, zero for
+	// skipped records.
+	LatencyMS float64                            `json:"latency_ms"`
+	Error     string                             `json:"error,omitempty"`
+	Record    *deploymentrecord.DeploymentRecord `json:"record"`
+}
+
+// auditLog writes one JSON line per posted, failed or skipped record,
+// separate from the operational logs.
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// newAuditLog returns an audit log appending to the file at path, or
+// writing to stdout if path is "-".
+func newAuditLog(path string) (*auditLog, error) {
+	if path == "-" {
+		return &auditLog{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &auditLog{w: f}, nil
+}
+
+// write appends the entry. Failures are logged, but don't fail the
+// record.
+func (a *auditLog) write(entry auditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Warn("Failed to marshal audit log entry",
+			"error", err)
+		return
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(data); err != nil {
+		slog.Warn("Failed to write audit log entry",
+			"deployment_name", entry.Record.DeploymentName,
+			"error", err)
+	}
+}
+
+// audit writes the outcome of posting the record to the audit log, if
+// enabled. err classifies the outcome of posted records: nil is
+// posted, a client error rejected, any other error failed.
+func (c *Controller) audit(source, eventType, namespace string, record *deploymentrecord.DeploymentRecord, latency time.Duration, err error) {
+	if c.auditLog == nil {
+		return
+	}
+	entry := auditEntry{
+		Time:      time.Now().UTC(),
+		Outcome:   auditPosted,
+		Source:    source,
+		EventType: eventType,
+		Namespace: namespace,
+		LatencyMS: float64(latency) / float64(time.Millisecond),
+		Record:    record,
+	}
+	if err != nil {
+		entry.Outcome = auditFailed
+		var clientErr *deploymentrecord.ClientError
+		if errors.As(err, &clientErr) {
+			entry.Outcome = auditRejected
+		}
+		entry.Error = err.Error()
+	}
+	c.auditLog.write(entry)
+}
+
+// auditSkip writes a record that was not posted to the audit log, if
+// enabled. newRecord is only called if the audit log is enabled.
+func (c *Controller) auditSkip(eventType, namespace, reason string, newRecord func() *deploymentrecord.DeploymentRecord) {
+	if c.auditLog == nil {
+		return
+	}
+	c.auditLog.write(auditEntry{
+		Time:      time.Now().UTC(),
+		Outcome:   auditSkipped,
+		Reason:    reason,
+		Source:    auditSourceEvent,
+		EventType: eventType,
+		Namespace: namespace,
+		Record:    newRecord(),
+	})
+}
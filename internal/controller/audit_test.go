@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+func TestAuditReplayRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		outcome string
+	}{
+		{
+			name:    "posted",
+			status:  http.StatusOK,
+			outcome: auditPosted,
+		},
+		{
+			name:    "rejected",
+			status:  http.StatusBadRequest,
+			outcome: auditRejected,
+		},
+		{
+			name:    "failed",
+			status:  http.StatusNotImplemented,
+			outcome: auditFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+			q, err := newDiskQueue(t.TempDir(), retryQueueMaxRecords)
+			if err != nil {
+				t.Fatalf("newDiskQueue() unexpected error: %v", err)
+			}
+			var buf bytes.Buffer
+			c := &Controller{apiClient: client, retryQueue: q, auditLog: &auditLog{w: &buf}}
+			c.enqueueRetry(newTestRecord("ns/web/app", "sha256:a", deploymentrecord.StatusDeployed))
+
+			c.replayRetries(context.Background())
+
+			var entry auditEntry
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("failed to parse audit log %q: %v", buf.String(), err)
+			}
+			if entry.Outcome != tt.outcome || entry.Source != auditSourceRetryQueue {
+				t.Errorf("outcome, source = %s, %s, want %s, %s", entry.Outcome, entry.Source, tt.outcome, auditSourceRetryQueue)
+			}
+			if entry.Record == nil || entry.Record.DeploymentName != "ns/web/app" {
+				t.Errorf("record = %+v, want ns/web/app", entry.Record)
+			}
+			if (entry.Error != "") != (tt.outcome != auditPosted) {
+				t.Errorf("error = %q for outcome %s", entry.Error, entry.Outcome)
+			}
+		})
+	}
+}
+
+func TestAuditSkip(t *testing.T) {
+	// Without an audit log, the record is not even created
+	c := &Controller{}
+	c.auditSkip(EventCreated, "ns", "already_observed", func() *deploymentrecord.DeploymentRecord {
+		t.Error("record created without an audit log")
+		return nil
+	})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := newAuditLog(path)
+	if err != nil {
+		t.Fatalf("newAuditLog() unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	log.w = &buf
+	c.auditLog = log
+	for _, reason := range []string{"already_observed", "still_running"} {
+		c.auditSkip(EventDeleted, "ns", reason, func() *deploymentrecord.DeploymentRecord {
+			return newTestRecord("ns/web/app", "sha256:a", deploymentrecord.StatusDecommissioned)
+		})
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log has %d lines, want 2", len(lines))
+	}
+	var entry auditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("failed to parse audit log line: %v", err)
+	}
+	if entry.Outcome != auditSkipped || entry.Reason != "still_running" || entry.EventType != EventDeleted || entry.Namespace != "ns" {
+		t.Errorf("entry = %+v", entry)
+	}
+}
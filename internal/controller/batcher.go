@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// batchRequest is a record waiting to be posted as part of a batch.
+type batchRequest struct {
+	record *deploymentrecord.DeploymentRecord
+	result chan error
+}
+
+// batcher coalesces records submitted by concurrent workers into
+// batch posts. A batch is flushed when it reaches size records, or
+// when interval has passed since its first record was added.
+// Submitters block until their batch is posted, so error handling
+// stays the same as for single posts.
+type batcher struct {
+	client   *deploymentrecord.Client
+	size     int
+	interval time.Duration
+	requests chan batchRequest
+}
+
+// newBatcher creates a new batcher posting through client.
+func newBatcher(client *deploymentrecord.Client, size int, interval time.Duration) *batcher {
+	return &batcher{
+		client:   client,
+		size:     size,
+		interval: interval,
+		requests: make(chan batchRequest),
+	}
+}
+
+// submit adds the record to the current batch and waits for the
+// batch to be posted.
+func (b *batcher) submit(ctx context.Context, record *deploymentrecord.DeploymentRecord) error {
+	req := batchRequest{
+		record: record,
+		result: make(chan error, 1),
+	}
+
+	select {
+	case b.requests <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects and flushes batches until ctx is cancelled.
+func (b *batcher) run(ctx context.Context) {
+	var pending []batchRequest
+	timer := time.NewTimer(b.interval)
+	timer.Stop()
+
+	for {
+		select {
+		case req := <-b.requests:
+			pending = append(pending, req)
+			if len(pending) == 1 {
+				timer.Reset(b.interval)
+			}
+			if len(pending) >= b.size {
+				timer.Stop()
+				b.flush(ctx, pending)
+				pending = nil
+			}
+		case <-timer.C:
+			b.flush(ctx, pending)
+			pending = nil
+		case <-ctx.Done():
+			timer.Stop()
+			for _, req := range pending {
+				req.result <- ctx.Err()
+			}
+			return
+		}
+	}
+}
+
+// flush posts the pending records and reports the result to each
+// submitter. As a batch is rejected as a whole, the records of a batch
+// rejected with a client error are posted one at a time, so only the
+// invalid records are rejected.
+func (b *batcher) flush(ctx context.Context, pending []batchRequest) {
+	if len(pending) == 0 {
+		return
+	}
+
+	records := make([]*deploymentrecord.DeploymentRecord, 0, len(pending))
+	for _, req := range pending {
+		records = append(records, req.record)
+	}
+
+	err := b.client.PostBatch(ctx, records)
+	slog.Debug("Flushed record batch",
+		"count", len(records),
+		"error", err,
+	)
+	var clientErr *deploymentrecord.ClientError
+	if len(pending) > 1 && errors.As(err, &clientErr) {
+		slog.Warn("Record batch rejected, posting its records one at a time",
+			"count", len(records),
+			"error", err,
+		)
+		for _, req := range pending {
+			req.result <- b.client.PostOne(ctx, req.record)
+		}
+		return
+	}
+	for _, req := range pending {
+		req.result <- err
+	}
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+)
+
+func TestBatcher(t *testing.T) {
+	var requests atomic.Int32
+	var records atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Records []json.RawMessage `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests.Add(1)
+		records.Add(int32(len(body.Records)))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		size         int
+		interval     time.Duration
+		submit       int
+		wantRequests int32
+	}{
+		{
+			name:         "flush on size",
+			size:         4,
+			interval:     time.Minute,
+			submit:       4,
+			wantRequests: 1,
+		},
+		{
+			name:         "flush on interval",
+			size:         10,
+			interval:     50 * time.Millisecond,
+			submit:       3,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			records.Store(0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			b := newBatcher(client, tt.size, tt.interval)
+			go b.run(ctx)
+
+			var wg sync.WaitGroup
+			for range tt.submit {
+				wg.Go(func() {
+					record := deploymentrecord.NewDeploymentRecord("app", "sha256:abc", "v1",
+						"prod", "", "c1", deploymentrecord.StatusDeployed, "ns/app/web")
+					if err := b.submit(ctx, record); err != nil {
+						t.Errorf("submit() unexpected error: %v", err)
+					}
+				})
+			}
+			wg.Wait()
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			if got := records.Load(); got != int32(tt.submit) {
+				t.Errorf("records = %d, want %d", got, tt.submit)
+			}
+		})
+	}
+}
+
+func TestBatcherRejectedRecord(t *testing.T) {
+	srv := deploymentrecordtest.NewServer()
+	defer srv.Close()
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const good = 4
+	b := newBatcher(client, good+1, time.Minute)
+	go b.run(ctx)
+
+	// The record without a digest fails validation, rejecting the
+	// batch with a 422
+	records := deploymentrecordtest.Fixtures(good)
+	bad := deploymentrecordtest.Fixture(good)
+	bad.Digest = ""
+	records = append(records, bad)
+
+	errs := make([]error, len(records))
+	var wg sync.WaitGroup
+	for i, record := range records {
+		wg.Go(func() {
+			errs[i] = b.submit(ctx, record)
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs[:good] {
+		if err != nil {
+			t.Errorf("submit(%d) unexpected error: %v", i, err)
+		}
+	}
+	var clientErr *deploymentrecord.ClientError
+	if !errors.As(errs[good], &clientErr) {
+		t.Errorf("submit(bad) error = %v, expected a client error", errs[good])
+	}
+	if got := len(srv.Records()); got != good {
+		t.Errorf("posted %d records, expected %d", got, good)
+	}
+}
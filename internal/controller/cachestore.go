@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// cacheStoreKey is the ConfigMap data key holding the observed
+	// deployments.
+	cacheStoreKey = "observed.json"
+	// deploymentsStoreKey is the ConfigMap data key holding the
+	// records associated with each Deployment.
+	deploymentsStoreKey = "deployments.json"
+	// gzipSuffix is the suffix of the binary data keys holding the
+	// gzipped data of a key.
+	gzipSuffix = ".gz"
+	// maxCacheStoreBytes bounds the persisted data, below the 1 MiB
+	// limit of ConfigMaps.
+	maxCacheStoreBytes = 900 << 10
+)
+
+// configMapStore persists the observation cache in a ConfigMap, so a
+// restarted controller still decommissions deployments it observed
+// before the restart. The records associated with each Deployment are
+// persisted as well, so Deployments deleted while the controller was
+// down are decommissioned. Both are gzipped, and truncated to the
+// most recently used entries of the cache if they still don't fit in
+// the ConfigMap.
+type configMapStore struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// newConfigMapStore creates a store backed by the ConfigMap
+// namespace/name.
+func newConfigMapStore(clientset kubernetes.Interface, namespace, name string) *configMapStore {
+	return &configMapStore{
+		clientset: clientset,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// load returns the persisted cache keys. A missing ConfigMap is not
+// an error.
+func (s *configMapStore) load(ctx context.Context) ([]string, error) {
+	var keys []string
+	if err := s.loadKey(ctx, cacheStoreKey, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// loadDeployments returns the persisted records of each Deployment,
+// keyed by namespace/name. A missing ConfigMap is not an error.
+func (s *configMapStore) loadDeployments(ctx context.Context) (map[string][]*deploymentrecord.DeploymentRecord, error) {
+	var deployments map[string][]*deploymentrecord.DeploymentRecord
+	if err := s.loadKey(ctx, deploymentsStoreKey, &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+// loadKey unmarshals the ConfigMap data key into v, if present.
+func (s *configMapStore) loadKey(ctx context.Context, key string, v any) error {
+	cm, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get configmap: %w", err)
+	}
+
+	// ConfigMaps saved before the data was compressed hold plain JSON
+	var data []byte
+	if gz, ok := cm.BinaryData[key+gzipSuffix]; ok {
+		zr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			return fmt.Errorf("failed to decompress cache: %w", err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("failed to decompress cache: %w", err)
+		}
+	} else if s, ok := cm.Data[key]; ok {
+		data = []byte(s)
+	} else {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal cache: %w", err)
+	}
+
+	return nil
+}
+
+// save persists the cache keys, most recently used first, and the
+// records of each Deployment, creating the ConfigMap if needed. If
+// they don't fit in maxCacheStoreBytes, the least recently used keys
+// are left out, with the Deployments none of whose records are kept.
+func (s *configMapStore) save(ctx context.Context, keys []string, deployments map[string][]*deploymentrecord.DeploymentRecord) error {
+	n := len(keys)
+	var data, deploymentsData []byte
+	for {
+		kept := slices.Sorted(slices.Values(keys[:n]))
+		keptDeployments := deployments
+		if n < len(keys) {
+			keptDeployments = keptRecords(deployments, kept)
+		}
+
+		var err error
+		if data, err = gzipJSON(kept); err != nil {
+			return fmt.Errorf("failed to marshal cache: %w", err)
+		}
+		// Map keys are sorted by json.Marshal
+		if deploymentsData, err = gzipJSON(keptDeployments); err != nil {
+			return fmt.Errorf("failed to marshal deployments: %w", err)
+		}
+		size := len(data) + len(deploymentsData)
+		if size <= maxCacheStoreBytes || n == 0 {
+			break
+		}
+		// Shrink in proportion, with some headroom for the keys not
+		// compressing alike
+		n = min(n-1, n*maxCacheStoreBytes/size*9/10)
+	}
+	if dropped := len(keys) - n; dropped > 0 {
+		slog.Warn("Observation cache too large for its ConfigMap, persisting the most recently used entries",
+			"configmap", s.namespace+"/"+s.name,
+			"kept", n,
+			"dropped", dropped,
+		)
+	}
+	metrics.CacheStoreDroppedEntries.Set(float64(len(keys) - n))
+
+	cms := s.clientset.CoreV1().ConfigMaps(s.namespace)
+	cm, err := cms.Get(ctx, s.name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.name,
+				Namespace: s.namespace,
+			},
+			BinaryData: map[string][]byte{
+				cacheStoreKey + gzipSuffix:       data,
+				deploymentsStoreKey + gzipSuffix: deploymentsData,
+			},
+		}
+		if _, err := cms.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create configmap: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get configmap: %w", err)
+	}
+
+	delete(cm.Data, cacheStoreKey)
+	delete(cm.Data, deploymentsStoreKey)
+	if cm.BinaryData == nil {
+		cm.BinaryData = make(map[string][]byte)
+	}
+	cm.BinaryData[cacheStoreKey+gzipSuffix] = data
+	cm.BinaryData[deploymentsStoreKey+gzipSuffix] = deploymentsData
+	if _, err := cms.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update configmap: %w", err)
+	}
+
+	return nil
+}
+
+// keptRecords returns the records of the Deployments with at least one
+// record whose cache key is in the sorted keys.
+func keptRecords(deployments map[string][]*deploymentrecord.DeploymentRecord, keys []string) map[string][]*deploymentrecord.DeploymentRecord {
+	res := make(map[string][]*deploymentrecord.DeploymentRecord)
+	for key, records := range deployments {
+		for _, r := range records {
+			if _, ok := slices.BinarySearch(keys, getCacheKey(r.DeploymentName, r.Digest)); ok {
+				res[key] = records
+				break
+			}
+		}
+	}
+	return res
+}
+
+// gzipJSON returns the gzipped JSON encoding of v.
+func gzipJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(v); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapStore(t *testing.T) {
+	ctx := context.Background()
+	store := newConfigMapStore(fake.NewClientset(), "deployment-tracker", "cache")
+
+	keys, err := store.load(ctx)
+	if err != nil {
+		t.Fatalf("load() on missing configmap unexpected error: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("load() on missing configmap = %v, want empty", keys)
+	}
+
+	saves := [][]string{
+		{"ns/web/app||sha256:b", "ns/web/app||sha256:a"},
+		{"ns/db/postgres||sha256:c"},
+	}
+	for _, want := range saves {
+		if err := store.save(ctx, slices.Clone(want), nil); err != nil {
+			t.Fatalf("save() unexpected error: %v", err)
+		}
+
+		keys, err := store.load(ctx)
+		if err != nil {
+			t.Fatalf("load() unexpected error: %v", err)
+		}
+		slices.Sort(want)
+		if !slices.Equal(keys, want) {
+			t.Errorf("load() = %v, want %v", keys, want)
+		}
+	}
+}
+
+func TestConfigMapStoreDeployments(t *testing.T) {
+	ctx := context.Background()
+	store := newConfigMapStore(fake.NewClientset(), "deployment-tracker", "cache")
+
+	deployments, err := store.loadDeployments(ctx)
+	if err != nil {
+		t.Fatalf("loadDeployments() on missing configmap unexpected error: %v", err)
+	}
+	if len(deployments) != 0 {
+		t.Errorf("loadDeployments() on missing configmap = %v, want empty", deployments)
+	}
+
+	records := newDeploymentRecords()
+	for i, fixture := range deploymentrecordtest.Fixtures(3) {
+		key := "default/web"
+		if i == 2 {
+			key = "default/api"
+		}
+		records.add(key, getCacheKey(fixture.DeploymentName, fixture.Digest), func() *deploymentrecord.DeploymentRecord {
+			return fixture
+		})
+	}
+	if err := store.save(ctx, []string{"default/web/app||sha256:a"}, records.snapshot()); err != nil {
+		t.Fatalf("save() unexpected error: %v", err)
+	}
+
+	deployments, err = store.loadDeployments(ctx)
+	if err != nil {
+		t.Fatalf("loadDeployments() unexpected error: %v", err)
+	}
+	if len(deployments["default/web"]) != 2 || len(deployments["default/api"]) != 1 {
+		t.Fatalf("loadDeployments() = %v, want 2 records of web and 1 of api", deployments)
+	}
+	got, want := deployments["default/api"][0], deploymentrecordtest.Fixture(2)
+	if got.DeploymentName != want.DeploymentName || got.Digest != want.Digest || got.Name != want.Name {
+		t.Errorf("loaded record = %+v, want %+v", got, want)
+	}
+	// Only the fields needed to decommission are persisted
+	if !got.ObservedAt.IsZero() {
+		t.Errorf("loaded record = %+v, want only the decommission fields", got)
+	}
+
+	keys, err := store.load(ctx)
+	if err != nil || len(keys) != 1 {
+		t.Errorf("load() = %v, %v, want the saved key", keys, err)
+	}
+}
+
+func TestConfigMapStoreTruncated(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+	store := newConfigMapStore(clientset, "deployment-tracker", "cache")
+
+	// Random digests don't compress, so the cache is well over 1 MiB
+	// even compressed
+	var keys []string
+	var size int
+	deployments := make(map[string][]*deploymentrecord.DeploymentRecord)
+	for i := range 50000 {
+		sum := sha256.Sum256(fmt.Appendf(nil, "%d", i))
+		dn := fmt.Sprintf("default/web-%d/app", i)
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		keys = append(keys, getCacheKey(dn, digest))
+		size += len(keys[i])
+		deployments[fmt.Sprintf("default/web-%d", i)] = []*deploymentrecord.DeploymentRecord{
+			deploymentrecord.NewDeploymentRecord("ghcr.io/org/web", digest, "", "", "", "", deploymentrecord.StatusDeployed, dn),
+		}
+	}
+	if size < 1<<20 {
+		t.Fatalf("cache of %d bytes, want over 1 MiB", size)
+	}
+	if err := store.save(ctx, slices.Clone(keys), deployments); err != nil {
+		t.Fatalf("save() unexpected error: %v", err)
+	}
+
+	cm, err := clientset.CoreV1().ConfigMaps("deployment-tracker").Get(ctx, "cache", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get configmap: %v", err)
+	}
+	var stored int
+	for _, data := range cm.BinaryData {
+		stored += len(data)
+	}
+	if stored > maxCacheStoreBytes {
+		t.Errorf("stored %d bytes, want at most %d", stored, maxCacheStoreBytes)
+	}
+
+	loaded, err := store.load(ctx)
+	if err != nil {
+		t.Fatalf("load() unexpected error: %v", err)
+	}
+	if len(loaded) == 0 || len(loaded) >= len(keys) {
+		t.Fatalf("load() = %d keys, want a truncated cache of the %d saved", len(loaded), len(keys))
+	}
+	// The most recently used keys, first, are kept
+	want := slices.Sorted(slices.Values(keys[:len(loaded)]))
+	if !slices.Equal(loaded, want) {
+		t.Errorf("load() didn't keep the most recently used keys")
+	}
+	loadedDeployments, err := store.loadDeployments(ctx)
+	if err != nil {
+		t.Fatalf("loadDeployments() unexpected error: %v", err)
+	}
+	if len(loadedDeployments) != len(loaded) {
+		t.Errorf("loadDeployments() = %d deployments, want the %d of the kept keys", len(loadedDeployments), len(loaded))
+	}
+}
+
+func TestConfigMapStoreUncompressed(t *testing.T) {
+	ctx := context.Background()
+	// A ConfigMap saved before the cache was compressed
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "deployment-tracker"},
+		Data:       map[string]string{cacheStoreKey: `["ns/web/app||sha256:a"]`},
+	})
+	store := newConfigMapStore(clientset, "deployment-tracker", "cache")
+
+	keys, err := store.load(ctx)
+	if err != nil || !slices.Equal(keys, []string{"ns/web/app||sha256:a"}) {
+		t.Fatalf("load() = %v, %v, want the uncompressed key", keys, err)
+	}
+	if err := store.save(ctx, keys, nil); err != nil {
+		t.Fatalf("save() unexpected error: %v", err)
+	}
+	cm, err := clientset.CoreV1().ConfigMaps("deployment-tracker").Get(ctx, "cache", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get configmap: %v", err)
+	}
+	if _, ok := cm.Data[cacheStoreKey]; ok {
+		t.Error("save() kept the uncompressed cache")
+	}
+}
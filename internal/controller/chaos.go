@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/github/deployment-tracker/pkg/metrics"
+)
+
+// resyncStorm enqueues a created event for every running pod of a
+// tracked workload, as if all pods changed at once, to soak test the
+// work queue, the observation cache and the API rate limits. Records
+// already observed are not posted again.
+func (c *Controller) resyncStorm(_ context.Context) {
+	count, err := c.enqueueRunningPods()
+	if err != nil {
+		slog.Error("Failed to list pods for a chaos resync",
+			"error", err)
+		return
+	}
+	metrics.ChaosInjections.WithLabelValues("resync").Inc()
+	slog.Info("Chaos resync enqueued running pods",
+		"count", count)
+}
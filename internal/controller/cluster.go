@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ClusterConfig is one of several clusters watched by a single
+// deployment tracker, each through its own kubeconfig context and with
+// its own controller.
+type ClusterConfig struct {
+	// Name is the cluster name of the records of the cluster. It
+	// defaults to Context.
+	Name string `json:"name"`
+	// Context is the kubeconfig context of the cluster. Empty uses
+	// the current context of the kubeconfig.
+	Context string `json:"context"`
+	// Kubeconfig is the path of the kubeconfig holding Context.
+	// Empty uses the kubeconfig of the -kubeconfig flag.
+	Kubeconfig string `json:"kubeconfig"`
+	// PhysicalEnvironment overrides the physical environment of the
+	// records of the cluster.
+	PhysicalEnvironment string `json:"physicalEnvironment"`
+}
+
+// ClusterName returns the cluster name of the records of the cluster.
+func (cl ClusterConfig) ClusterName() string {
+	if cl.Name != "" {
+		return cl.Name
+	}
+	return cl.Context
+}
+
+// ValidateClusters returns an error if a cluster has no name, or if
+// two clusters share a name.
+func (c *Config) ValidateClusters() error {
+	seen := make(map[string]bool, len(c.Clusters))
+	for i, cl := range c.Clusters {
+		name := cl.ClusterName()
+		if name == "" {
+			return fmt.Errorf("cluster %d has neither a name nor a context", i)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate cluster %s", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// ForCluster returns the configuration of the controller of the
+// cluster: the cluster name and physical environment are overridden,
+// and the retry queue is kept in a subdirectory named after the
+// cluster.
+func (c *Config) ForCluster(cl ClusterConfig) Config {
+	res := *c
+	res.Clusters = nil
+	res.Cluster = cl.ClusterName()
+	if cl.PhysicalEnvironment != "" {
+		res.PhysicalEnvironment = cl.PhysicalEnvironment
+	}
+	if res.RetryQueueDir != "" {
+		res.RetryQueueDir = filepath.Join(res.RetryQueueDir, res.Cluster)
+	}
+	return res
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateClusters(t *testing.T) {
+	tests := []struct {
+		name     string
+		clusters []ClusterConfig
+		wantErr  bool
+	}{
+		{
+			name: "single cluster mode",
+		},
+		{
+			name: "contexts",
+			clusters: []ClusterConfig{
+				{Context: "edge-1"},
+				{Context: "edge-2"},
+			},
+		},
+		{
+			name: "named clusters",
+			clusters: []ClusterConfig{
+				{Name: "edge-1", Context: "admin@edge-1"},
+				{Name: "edge-2", Kubeconfig: "/etc/kube/edge-2"},
+			},
+		},
+		{
+			name: "no name",
+			clusters: []ClusterConfig{
+				{Kubeconfig: "/etc/kube/edge-2"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate name",
+			clusters: []ClusterConfig{
+				{Context: "edge-1"},
+				{Name: "edge-1", Context: "admin@edge-1"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Clusters: tt.clusters}
+			if err := cfg.ValidateClusters(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateClusters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForCluster(t *testing.T) {
+	cfg := &Config{
+		LogicalEnvironment:  "prod",
+		PhysicalEnvironment: "us-east",
+		Cluster:             "default",
+		RetryQueueDir:       "/var/lib/deployment-tracker",
+		Clusters: []ClusterConfig{
+			{Context: "edge-1"},
+			{Name: "edge-2", Context: "admin@edge-2", PhysicalEnvironment: "store-42"},
+		},
+	}
+
+	tests := []struct {
+		cluster       ClusterConfig
+		name          string
+		physical      string
+		retryQueueDir string
+	}{
+		{
+			cluster:       cfg.Clusters[0],
+			name:          "edge-1",
+			physical:      "us-east",
+			retryQueueDir: filepath.Join("/var/lib/deployment-tracker", "edge-1"),
+		},
+		{
+			cluster:       cfg.Clusters[1],
+			name:          "edge-2",
+			physical:      "store-42",
+			retryQueueDir: filepath.Join("/var/lib/deployment-tracker", "edge-2"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cfg.ForCluster(tt.cluster)
+			if got.Cluster != tt.name || got.PhysicalEnvironment != tt.physical || got.RetryQueueDir != tt.retryQueueDir {
+				t.Errorf("ForCluster() = cluster %q, physical environment %q, retry queue %q, want %q, %q, %q",
+					got.Cluster, got.PhysicalEnvironment, got.RetryQueueDir, tt.name, tt.physical, tt.retryQueueDir)
+			}
+			if got.LogicalEnvironment != "prod" || got.Clusters != nil {
+				t.Errorf("ForCluster() = %+v, want the logical environment kept and no clusters", got)
+			}
+		})
+	}
+}
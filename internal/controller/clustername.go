@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// eksctlClusterLabel is set on the nodes of clusters created by
+	// eksctl.
+	eksctlClusterLabel = "alpha.eksctl.io/cluster-name"
+	// kubeadmDefaultCluster is the cluster name of kubeadm clusters
+	// which weren't given one.
+	kubeadmDefaultCluster = "kubernetes"
+	// metadataTimeout bounds each request to a cloud metadata
+	// server, which is not reachable outside of the cloud.
+	metadataTimeout = 2 * time.Second
+)
+
+// errNoClusterName is returned by a cluster name source which doesn't
+// apply to the cluster.
+var errNoClusterName = errors.New("no cluster name found")
+
+// clusterNameDetector discovers the cluster name from well-known
+// sources. The metadata server URLs can be changed for tests.
+type clusterNameDetector struct {
+	clientset  kubernetes.Interface
+	httpClient *http.Client
+	gkeURL     string
+	eksURL     string
+	aksURL     string
+}
+
+// DetectClusterName discovers the name of the cluster, trying in
+// order: the cluster name label of the nodes, the cluster name of the
+// kube-system/kubeadm-config ConfigMap, and the GKE, EKS and AKS
+// metadata servers. It returns the name and its source.
+func DetectClusterName(ctx context.Context, clientset kubernetes.Interface) (string, string, error) {
+	d := &clusterNameDetector{
+		clientset:  clientset,
+		httpClient: &http.Client{Timeout: metadataTimeout},
+		gkeURL:     "http://metadata.google.internal",
+		eksURL:     "http://169.254.169.254",
+		aksURL:     "http://169.254.169.254",
+	}
+	return d.detect(ctx)
+}
+
+// detect returns the cluster name of the first source that has one,
+// and the source.
+func (d *clusterNameDetector) detect(ctx context.Context) (string, string, error) {
+	sources := []struct {
+		name   string
+		detect func(context.Context) (string, error)
+	}{
+		{"node-label", d.fromNodeLabels},
+		{"kubeadm-config", d.fromKubeadmConfig},
+		{"gke-metadata", d.fromGKEMetadata},
+		{"eks-metadata", d.fromEKSMetadata},
+		{"aks-metadata", d.fromAKSMetadata},
+	}
+
+	var errs []error
+	for _, source := range sources {
+		name, err := source.detect(ctx)
+		if err == nil && name != "" {
+			return name, source.name, nil
+		}
+		if err != nil && !errors.Is(err, errNoClusterName) {
+			errs = append(errs, fmt.Errorf("%s: %w", source.name, err))
+		}
+	}
+	return "", "", errors.Join(append([]error{errNoClusterName}, errs...)...)
+}
+
+// fromNodeLabels returns the cluster name label of a node.
+func (d *clusterNameDetector) fromNodeLabels(ctx context.Context) (string, error) {
+	nodes, err := d.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: eksctlClusterLabel,
+		Limit:         1,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes.Items {
+		if name := node.Labels[eksctlClusterLabel]; name != "" {
+			return name, nil
+		}
+	}
+	return "", errNoClusterName
+}
+
+// fromKubeadmConfig returns the cluster name kubeadm was configured
+// with, unless it is the default.
+func (d *clusterNameDetector) fromKubeadmConfig(ctx context.Context) (string, error) {
+	cm, err := d.clientset.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ctx, "kubeadm-config", metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return "", errNoClusterName
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var cfg struct {
+		ClusterName string `json:"clusterName"`
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data["ClusterConfiguration"]), &cfg); err != nil {
+		return "", fmt.Errorf("failed to parse cluster configuration: %w", err)
+	}
+	if cfg.ClusterName == "" || cfg.ClusterName == kubeadmDefaultCluster {
+		return "", errNoClusterName
+	}
+	return cfg.ClusterName, nil
+}
+
+// fromGKEMetadata returns the cluster-name attribute of the GKE node.
+func (d *clusterNameDetector) fromGKEMetadata(ctx context.Context) (string, error) {
+	body, err := d.getMetadata(ctx, http.MethodGet, d.gkeURL+"/computeMetadata/v1/instance/attributes/cluster-name",
+		map[string]string{"Metadata-Flavor": "Google"})
+	return strings.TrimSpace(string(body)), err
+}
+
+// fromEKSMetadata returns the eks:cluster-name tag of the EKS node,
+// which requires instance tags in the metadata to be enabled.
+func (d *clusterNameDetector) fromEKSMetadata(ctx context.Context) (string, error) {
+	token, err := d.getMetadata(ctx, http.MethodPut, d.eksURL+"/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return "", err
+	}
+	body, err := d.getMetadata(ctx, http.MethodGet, d.eksURL+"/latest/meta-data/tags/instance/eks:cluster-name",
+		map[string]string{"X-aws-ec2-metadata-token": string(token)})
+	return strings.TrimSpace(string(body)), err
+}
+
+// fromAKSMetadata returns the aks-managed-cluster-name tag of the AKS
+// node.
+func (d *clusterNameDetector) fromAKSMetadata(ctx context.Context) (string, error) {
+	body, err := d.getMetadata(ctx, http.MethodGet, d.aksURL+"/metadata/instance/compute/tagsList?api-version=2021-02-01",
+		map[string]string{"Metadata": "true"})
+	if err != nil {
+		return "", err
+	}
+
+	var tags []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return "", fmt.Errorf("failed to parse tags: %w", err)
+	}
+	for _, tag := range tags {
+		if tag.Name == "aks-managed-cluster-name" && tag.Value != "" {
+			return tag.Value, nil
+		}
+	}
+	return "", errNoClusterName
+}
+
+// getMetadata returns the body of a request to a metadata server.
+// Unreachable servers and missing entries return errNoClusterName, as
+// the node runs elsewhere.
+func (d *clusterNameDetector) getMetadata(ctx context.Context, method, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, errNoClusterName
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errNoClusterName
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetectClusterName(t *testing.T) {
+	kubeadmConfig := func(name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: metav1.NamespaceSystem},
+			Data: map[string]string{
+				"ClusterConfiguration": "apiVersion: kubeadm.k8s.io/v1beta3\nkind: ClusterConfiguration\nclusterName: " + name + "\n",
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		objects    []runtime.Object
+		metadata   http.HandlerFunc
+		wantName   string
+		wantSource string
+		wantErr    bool
+	}{
+		{
+			name: "node label",
+			objects: []runtime.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   "node-1",
+					Labels: map[string]string{eksctlClusterLabel: "edge-1"},
+				}},
+				kubeadmConfig("other"),
+			},
+			wantName:   "edge-1",
+			wantSource: "node-label",
+		},
+		{
+			name:       "kubeadm config",
+			objects:    []runtime.Object{kubeadmConfig("lab")},
+			wantName:   "lab",
+			wantSource: "kubeadm-config",
+		},
+		{
+			name:    "default kubeadm cluster name",
+			objects: []runtime.Object{kubeadmConfig("kubernetes")},
+			wantErr: true,
+		},
+		{
+			name: "gke metadata",
+			metadata: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/computeMetadata/v1/instance/attributes/cluster-name" || r.Header.Get("Metadata-Flavor") != "Google" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte("gke-prod\n"))
+			},
+			wantName:   "gke-prod",
+			wantSource: "gke-metadata",
+		},
+		{
+			name: "eks metadata",
+			metadata: func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+					_, _ = w.Write([]byte("token"))
+				case r.URL.Path == "/latest/meta-data/tags/instance/eks:cluster-name" &&
+					r.Header.Get("X-aws-ec2-metadata-token") == "token":
+					_, _ = w.Write([]byte("eks-prod"))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			wantName:   "eks-prod",
+			wantSource: "eks-metadata",
+		},
+		{
+			name: "aks metadata",
+			metadata: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/metadata/instance/compute/tagsList" || r.Header.Get("Metadata") != "true" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(`[{"name":"aks-managed-poolName","value":"system"},{"name":"aks-managed-cluster-name","value":"aks-prod"}]`))
+			},
+			wantName:   "aks-prod",
+			wantSource: "aks-metadata",
+		},
+		{
+			name:    "nothing found",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.metadata
+			if handler == nil {
+				handler = func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			d := &clusterNameDetector{
+				clientset:  fake.NewClientset(tt.objects...),
+				httpClient: srv.Client(),
+				gkeURL:     srv.URL,
+				eksURL:     srv.URL,
+				aksURL:     srv.URL,
+			}
+			name, source, err := d.detect(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("detect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if name != tt.wantName || source != tt.wantSource {
+				t.Errorf("detect() = %q, %q, want %q, %q", name, source, tt.wantName, tt.wantSource)
+			}
+		})
+	}
+}
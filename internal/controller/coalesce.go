@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maxCoalescedPods bounds the number of alternate pods kept per
+// coalescing key.
+const maxCoalescedPods = 100
+
+// coalescer drops the create events of pods running the same images of
+// the same workload within a window, e.g. the pods of a rollout, so
+// only the first pod is enqueued. The keys of the dropped pods are
+// kept as alternates, in case the enqueued pod is gone before it is
+// processed.
+type coalescer struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*coalesceEntry
+	reps      map[string]string // pod key -> coalescing key
+	lastSweep time.Time
+}
+
+type coalesceEntry struct {
+	expires time.Time
+	// rep is the key of the enqueued pod
+	rep        string
+	alternates []string
+}
+
+// newCoalescer creates a coalescer with the window.
+func newCoalescer(window time.Duration) *coalescer {
+	return &coalescer{
+		window:  window,
+		entries: make(map[string]*coalesceEntry),
+		reps:    make(map[string]string),
+	}
+}
+
+// add returns true if the create event of the pod with the key should
+// be enqueued, false if it was coalesced with the event of another pod
+// of the same coalescing key.
+func (c *coalescer) add(key, podKey string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+
+	if e, ok := c.entries[key]; ok {
+		if now.Before(e.expires) {
+			if podKey == e.rep {
+				return true
+			}
+			if len(e.alternates) < maxCoalescedPods && !slices.Contains(e.alternates, podKey) {
+				e.alternates = append(e.alternates, podKey)
+			}
+			return false
+		}
+		delete(c.reps, e.rep)
+	}
+
+	c.entries[key] = &coalesceEntry{
+		expires: now.Add(c.window),
+		rep:     podKey,
+	}
+	c.reps[podKey] = key
+	return true
+}
+
+// replace returns an alternate pod to enqueue instead of the pod with
+// the key, which is gone, if the pod's event coalesced others.
+func (c *coalescer) replace(podKey string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.reps[podKey]
+	if !ok {
+		return "", false
+	}
+	delete(c.reps, podKey)
+	e, ok := c.entries[key]
+	if !ok || len(e.alternates) == 0 {
+		return "", false
+	}
+
+	e.rep, e.alternates = e.alternates[0], e.alternates[1:]
+	c.reps[e.rep] = key
+	return e.rep, true
+}
+
+// sweep removes the expired entries, at most once per window. It must
+// be called with mu held.
+func (c *coalescer) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.window {
+		return
+	}
+	c.lastSweep = now
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+			delete(c.reps, e.rep)
+		}
+	}
+}
+
+// coalesceKey returns the key identifying the pods of the same workload
+// posting the same records: the namespace, the workload and the sorted
+// deployment names and digests of the containers. The pods of a
+// template with per-pod placeholders, e.g. {{podName}}, have different
+// deployment names, so they are never coalesced.
+func (c *Controller) coalesceKey(pod *corev1.Pod, wl workload) string {
+	cfg := c.cfg.Load()
+	tmpl := c.template(cfg, pod.Namespace)
+	containers := slices.Concat(pod.Spec.Containers, pod.Spec.InitContainers)
+	if cfg.EphemeralContainers {
+		for _, container := range pod.Spec.EphemeralContainers {
+			containers = append(containers, corev1.Container(container.EphemeralContainerCommon))
+		}
+	}
+	keys := make([]string, 0, len(containers))
+	for _, container := range containers {
+		dn := getARDeploymentName(pod, container, wl, tmpl, cfg.Cluster)
+		keys = append(keys, getCacheKey(dn, c.containerDigest(pod, container)))
+	}
+	slices.Sort(keys)
+	return pod.Namespace + "/" + wl.Kind + "/" + wl.Name + "@" + strings.Join(keys, ",")
+}
+
+// enqueueCreated enqueues a create event for the running pod with the
+// key, unless it is coalesced with the event of another pod.
+func (c *Controller) enqueueCreated(pod *corev1.Pod, wl workload, key string) {
+	if c.coalescer != nil && !c.coalescer.add(c.coalesceKey(pod, wl), key) {
+		metrics.EventsCoalesced.Inc()
+		return
+	}
+	c.workqueue.Add(PodEvent{
+		Key:       key,
+		EventType: EventCreated,
+	})
+}
+
+// replaceCoalesced enqueues an alternate of the pod with the key, which
+// is gone, so the records of the pods its event coalesced are posted.
+func (c *Controller) replaceCoalesced(key string) {
+	if c.coalescer == nil {
+		return
+	}
+	if alt, ok := c.coalescer.replace(key); ok {
+		c.workqueue.Add(PodEvent{
+			Key:       alt,
+			EventType: EventCreated,
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCoalescer(t *testing.T) {
+	c := newCoalescer(time.Hour)
+
+	if !c.add("web@sha256:a", "default/web-1") {
+		t.Error("add() of the first pod = false, want true")
+	}
+	if !c.add("web@sha256:a", "default/web-1") {
+		t.Error("add() of the enqueued pod again = false, want true")
+	}
+	for _, pod := range []string{"default/web-2", "default/web-3", "default/web-2"} {
+		if c.add("web@sha256:a", pod) {
+			t.Errorf("add() of %s = true, want false", pod)
+		}
+	}
+	if !c.add("web@sha256:b", "default/web-4") {
+		t.Error("add() of a pod with other images = false, want true")
+	}
+
+	// The alternates replace the enqueued pod in order, once each
+	if got, ok := c.replace("default/web-1"); !ok || got != "default/web-2" {
+		t.Errorf("replace() = %s, %v, want default/web-2, true", got, ok)
+	}
+	if got, ok := c.replace("default/web-2"); !ok || got != "default/web-3" {
+		t.Errorf("replace() = %s, %v, want default/web-3, true", got, ok)
+	}
+	if got, ok := c.replace("default/web-3"); ok {
+		t.Errorf("replace() without alternates = %s, want none", got)
+	}
+	if got, ok := c.replace("default/unknown"); ok {
+		t.Errorf("replace() of an unknown pod = %s, want none", got)
+	}
+}
+
+func TestCoalescerExpiry(t *testing.T) {
+	c := newCoalescer(time.Millisecond)
+	if !c.add("web@sha256:a", "default/web-1") {
+		t.Fatal("add() of the first pod = false, want true")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !c.add("web@sha256:a", "default/web-2") {
+		t.Error("add() after the window = false, want true")
+	}
+	if _, ok := c.reps["default/web-1"]; ok {
+		t.Error("expired pod still tracked")
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	pod := func(name string, digests ...string) *corev1.Pod {
+		pod := newTestPod("web-111")
+		pod.Name = name
+		for i, d := range digests {
+			cn := fmt.Sprintf("app-%d", i)
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: cn, Image: "ghcr.io/org/app:v1"})
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				Name:    cn,
+				ImageID: "docker-pullable://ghcr.io/org/app@" + d,
+			})
+		}
+		return pod
+	}
+	wl := workload{Kind: kindDeployment, Name: "web"}
+	c := &Controller{}
+	c.cfg.Store(&Config{Template: TmplNS + "/" + TmplDN + "/" + TmplCN})
+
+	a := c.coalesceKey(pod("web-111-aaaaa", "sha256:a", "sha256:b"), wl)
+	b := c.coalesceKey(pod("web-111-bbbbb", "sha256:a", "sha256:b"), wl)
+	if a != b {
+		t.Errorf("coalesceKey() of pods with the same images = %s and %s, want equal", a, b)
+	}
+	if k := c.coalesceKey(pod("web-111-ccccc", "sha256:a", "sha256:c"), wl); k == a {
+		t.Errorf("coalesceKey() of pods with other images = %s, want different", k)
+	}
+	if k := c.coalesceKey(pod("web-111-ddddd", "sha256:a", "sha256:b"), workload{Kind: kindDeployment, Name: "api"}); k == a {
+		t.Errorf("coalesceKey() of pods of other workloads = %s, want different", k)
+	}
+
+	c.cfg.Store(&Config{Template: TmplNS + "/" + TmplDN + "/" + TmplPN})
+	a = c.coalesceKey(pod("web-111-aaaaa", "sha256:a", "sha256:b"), wl)
+	if b := c.coalesceKey(pod("web-111-bbbbb", "sha256:a", "sha256:b"), wl); a == b {
+		t.Errorf("coalesceKey() of pods with per-pod deployment names = %s, want different", a)
+	}
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultCommitAnnotations are the annotations the source commit
+	// SHA is read from, if not configured otherwise.
+	defaultCommitAnnotations = "org.opencontainers.image.revision"
+	// repositoryAnnotation names the source repository of the pod's
+	// images, as owner/name.
+	repositoryAnnotation = "deployment-tracker.github.com/repository"
+	// imageSourceAnnotation is the OCI annotation holding the URL of
+	// the source repository, read when repositoryAnnotation is not
+	// set.
+	imageSourceAnnotation = "org.opencontainers.image.source"
+)
+
+// commitSHAPattern matches abbreviated and full SHA-1 and SHA-256
+// commit hashes.
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
+// commitSHA returns the source commit SHA of the pod, read from the
+// first configured annotation set on the pod or its owner. Values that
+// are not a commit hash are ignored.
+func (c *Controller) commitSHA(cfg *Config, pod *corev1.Pod) string {
+	keys := cfg.CommitAnnotations
+	if keys == "" {
+		keys = defaultCommitAnnotations
+	}
+
+	metas := c.podObjectMeta(pod)
+	for _, key := range splitList(keys) {
+		for _, m := range metas {
+			v := strings.ToLower(strings.TrimSpace(m.Annotations[key]))
+			if commitSHAPattern.MatchString(v) {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// sourceRepository returns the GitHub repository of the pod's source,
+// as owner/name, read from the repository annotation or the OCI source
+// annotation of the pod or its owner, or "" if neither is set. Source
+// URLs are only used if they point to a GitHub host.
+func (c *Controller) sourceRepository(pod *corev1.Pod) string {
+	metas := c.podObjectMeta(pod)
+	for _, m := range metas {
+		if repo := strings.TrimSpace(m.Annotations[repositoryAnnotation]); repo != "" {
+			return repo
+		}
+	}
+	for _, m := range metas {
+		if repo := githubRepository(m.Annotations[imageSourceAnnotation]); repo != "" {
+			return repo
+		}
+	}
+	return ""
+}
+
+// githubRepository returns the owner/name of the GitHub repository at
+// the source URL, e.g. https://github.com/org/repo.git, or "".
+func githubRepository(source string) string {
+	u, err := url.Parse(strings.TrimSpace(source))
+	if err != nil || !strings.Contains(u.Host, "github") {
+		return ""
+	}
+	owner, name, ok := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	name = strings.TrimSuffix(name, ".git")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return ""
+	}
+	return owner + "/" + name
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"testing"
+)
+
+func TestCommitSHA(t *testing.T) {
+	rs := newTestReplicaSet("web-111", "web", "1")
+	rs.Annotations["example.com/git-sha"] = "0123456789abcdef0123456789abcdef01234567"
+	c := &Controller{rsLister: newTestReplicaSetLister(t, rs)}
+
+	tests := []struct {
+		name        string
+		keys        string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:     "no annotation",
+			expected: "",
+		},
+		{
+			name:        "default annotation",
+			annotations: map[string]string{"org.opencontainers.image.revision": "ABC1234"},
+			expected:    "abc1234",
+		},
+		{
+			name:        "not a commit hash",
+			annotations: map[string]string{"org.opencontainers.image.revision": "main"},
+			expected:    "",
+		},
+		{
+			name:     "owner annotation",
+			keys:     "example.com/commit, example.com/git-sha",
+			expected: "0123456789abcdef0123456789abcdef01234567",
+		},
+		{
+			name:        "first key wins",
+			keys:        "example.com/commit,example.com/git-sha",
+			annotations: map[string]string{"example.com/commit": "fedcba9"},
+			expected:    "fedcba9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newTestPod("web-111")
+			pod.Annotations = tt.annotations
+			got := c.commitSHA(&Config{CommitAnnotations: tt.keys}, pod)
+			if got != tt.expected {
+				t.Errorf("commitSHA() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSourceRepository(t *testing.T) {
+	rs := newTestReplicaSet("web-111", "web", "1")
+	rs.Annotations[imageSourceAnnotation] = "https://github.com/org/web.git"
+	c := &Controller{rsLister: newTestReplicaSetLister(t, rs, newTestReplicaSet("api-222", "api", "1"))}
+
+	tests := []struct {
+		name        string
+		rsName      string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:     "owner source annotation",
+			rsName:   "web-111",
+			expected: "org/web",
+		},
+		{
+			name:        "repository annotation wins",
+			rsName:      "web-111",
+			annotations: map[string]string{repositoryAnnotation: "org/web-deploy"},
+			expected:    "org/web-deploy",
+		},
+		{
+			name:        "not a github source",
+			rsName:      "api-222",
+			annotations: map[string]string{imageSourceAnnotation: "https://gitlab.com/org/api"},
+			expected:    "",
+		},
+		{
+			name:        "not a repository url",
+			rsName:      "api-222",
+			annotations: map[string]string{imageSourceAnnotation: "https://github.com/org"},
+			expected:    "",
+		},
+		{
+			name:   "none",
+			rsName: "api-222",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newTestPod(tt.rsName)
+			pod.Annotations = tt.annotations
+			if got := c.sourceRepository(pod); got != tt.expected {
+				t.Errorf("sourceRepository() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,38 +1,417 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
 )
 
 const (
 	// TmplNS is the meta variable for the k8s namespace.
 	TmplNS = "{{namespace}}"
-	// TmplDN is the meta variable for the k8s deployment name.
+	// TmplDN is the meta variable for the k8s deployment name. For
+	// pods owned by other workloads (e.g. StatefulSets) it is the
+	// name of that workload.
 	TmplDN = "{{deploymentName}}"
 	// TmplCN is the meta variable for the container name.
 	TmplCN = "{{containerName}}"
+	// TmplWK is the meta variable for the k8s workload kind, e.g.
+	// Deployment or StatefulSet.
+	TmplWK = "{{workloadKind}}"
+	// TmplCluster is the meta variable for the configured cluster
+	// name.
+	TmplCluster = "{{cluster}}"
+	// TmplPN is the meta variable for the pod name. Every pod gets
+	// its own deployment name, so it is mostly useful for bare pods.
+	TmplPN = "{{podName}}"
+	// TmplNode is the meta variable for the name of the pod's node,
+	// e.g. to tell the static pods of control plane nodes apart.
+	TmplNode = "{{nodeName}}"
+	// TmplKnativeService is the meta variable for the Knative Service
+	// (or Configuration) of the pod's revision, empty for other pods.
+	TmplKnativeService = "{{knativeService}}"
+	// TmplKnativeRevision is the meta variable for the Knative
+	// Revision of the pod, empty for other pods.
+	TmplKnativeRevision = "{{knativeRevision}}"
+	// TmplLabelPrefix prefixes the key of a pod label, e.g.
+	// {{labels.team}}. Missing labels are replaced with an empty
+	// string.
+	TmplLabelPrefix = "labels."
+	// TmplAnnotationPrefix prefixes the key of a pod annotation, e.g.
+	// {{annotations.example.com/owner}}. Missing annotations are
+	// replaced with an empty string.
+	TmplAnnotationPrefix = "annotations."
 )
 
-// Config holds the global configuration for the controller.
+// TemplatePlaceholders lists the placeholders of the deployment name
+// template, for help and error messages.
+var TemplatePlaceholders = []string{
+	TmplNS, TmplDN, TmplCN, TmplWK, TmplCluster, TmplPN, TmplNode,
+	TmplKnativeService, TmplKnativeRevision,
+	"{{" + TmplLabelPrefix + "<key>}}",
+	"{{" + TmplAnnotationPrefix + "<key>}}",
+}
+
+// placeholderPattern matches the placeholders of a template.
+var placeholderPattern = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+// Config holds the global configuration for the controller. It can
+// be read from a YAML or JSON config file, see LoadConfigFile.
 type Config struct {
-	Template            string
-	LogicalEnvironment  string
-	PhysicalEnvironment string
-	Cluster             string
-	APIToken            string
-	BaseURL             string
-	GHAppID             string
-	GHInstallID         string
-	GHAppPrivateKey     string
-	Organization        string
+	Template            string `json:"template"`
+	LogicalEnvironment  string `json:"logicalEnvironment"`
+	PhysicalEnvironment string `json:"physicalEnvironment"`
+	Cluster             string `json:"cluster"`
+	APIToken            string `json:"apiToken"`
+	APITokenFile        string `json:"apiTokenFile"`
+	BaseURL             string `json:"baseURL"`
+	GHAppID             string `json:"ghAppID"`
+	GHInstallID         string `json:"ghInstallID"`
+	GHAppPrivateKey     string `json:"ghAppPrivateKey"`
+	Organization        string `json:"organization"`
+	// GHAppPrivateKeyPEM is the PEM encoded private key of the
+	// GitHub App, used instead of the file at GHAppPrivateKey.
+	GHAppPrivateKeyPEM string `json:"ghAppPrivateKeyPEM"`
+	// TokenExchangeURL enables authentication with API tokens
+	// obtained by exchanging the projected service account token at
+	// OIDCTokenPath at this endpoint. An empty OIDCTokenPath uses
+	// deploymentrecord.DefaultServiceAccountTokenPath.
+	TokenExchangeURL string `json:"tokenExchangeURL"`
+	OIDCTokenPath    string `json:"oidcTokenPath"`
+	// VaultAddr enables reading credentials from Vault, logging in
+	// with VaultToken or the Kubernetes auth method. VaultAPIToken
+	// and VaultGHAppKey reference the secrets holding the API token
+	// and the GitHub App private key as path#field.
+	VaultAddr      string `json:"vaultAddr"`
+	VaultNamespace string `json:"vaultNamespace"`
+	VaultToken     string `json:"vaultToken"`
+	VaultRole      string `json:"vaultRole"`
+	VaultAuthMount string `json:"vaultAuthMount"`
+	VaultAPIToken  string `json:"vaultAPIToken"`
+	VaultGHAppKey  string `json:"vaultGHAppKey"`
+	// NamespaceTemplates overrides Template for the pods of the
+	// namespaces it is keyed by.
+	NamespaceTemplates map[string]string `json:"namespaceTemplates"`
+	// TemplateAnnotations enables the template annotation on
+	// namespaces, which overrides Template and NamespaceTemplates.
+	TemplateAnnotations bool `json:"templateAnnotations"`
+	// MetadataLabels and MetadataAnnotations are comma separated
+	// lists of the label and annotation keys added to the metadata
+	// of records.
+	MetadataLabels      string `json:"metadataLabels"`
+	MetadataAnnotations string `json:"metadataAnnotations"`
+	// HelmReleases enables adding the release name, namespace and
+	// chart of the Helm release that installed the workload to the
+	// metadata of records.
+	HelmReleases bool `json:"helmReleases"`
+	// CommitAnnotations is a comma separated list of the annotation
+	// keys the source commit SHA is read from, in order of
+	// precedence. Empty uses org.opencontainers.image.revision.
+	CommitAnnotations string `json:"commitAnnotations"`
+	// NormalizeImageNames enables canonicalizing the image names of
+	// records, e.g. "nginx" to "docker.io/library/nginx", so images
+	// spelled differently across clusters have the same name.
+	NormalizeImageNames bool `json:"normalizeImageNames"`
+	// RecordResources enables adding the replica count of the owning
+	// Deployment and the resource requests and limits of the
+	// container to records.
+	RecordResources bool `json:"recordResources"`
+	// NodeTopology enables adding the node of the pod and its zone,
+	// region and instance type labels to records.
+	NodeTopology bool `json:"nodeTopology"`
+	// NodePlatform enables adding the OS and architecture of the node
+	// of the pod to records.
+	NodePlatform bool `json:"nodePlatform"`
+	// GitOpsApps enables adding the Argo CD or Flux application that
+	// applied the owning workload to records.
+	GitOpsApps bool `json:"gitOpsApps"`
+	// FieldProfile and FieldMapping control the field names of
+	// posted records, see deploymentrecord.NewFieldMapping.
+	FieldProfile string `json:"fieldProfile"`
+	FieldMapping string `json:"fieldMapping"`
+	// MaxRetries is the number of times a failed event is requeued
+	// before it is dropped. Zero means retry forever.
+	MaxRetries int `json:"maxRetries"`
+	// APIRateLimit and APIBurst configure the client side rate
+	// limiter of the API client, in requests per second. Zero keeps
+	// the client's defaults.
+	APIRateLimit float64 `json:"apiRateLimit"`
+	APIBurst     int     `json:"apiBurst"`
+	// PostBatchSize is the maximum number of records posted in a
+	// single batch request. Batching is disabled when it is 1 or
+	// less.
+	PostBatchSize int `json:"postBatchSize"`
+	// PostBatchInterval is the maximum time a record waits for its
+	// batch to fill up before it is posted. It can only be set with
+	// a flag.
+	PostBatchInterval time.Duration `json:"-"`
+	// CoalesceWindow is the window in which the create events of pods
+	// of the same workload running the same images are coalesced, so
+	// a rollout enqueues a single event. Zero disables coalescing. It
+	// can only be set with a flag.
+	CoalesceWindow time.Duration `json:"-"`
+	// DecommissionGracePeriod delays the processing of pod deletions,
+	// so the workload and replacement pods are checked again before
+	// records are decommissioned. It can only be set with a flag.
+	DecommissionGracePeriod time.Duration `json:"-"`
+	// CacheConfigMap is the ConfigMap (namespace/name) the
+	// observation cache is persisted in. Empty disables persistence.
+	CacheConfigMap string `json:"cacheConfigMap"`
+	// ObservedCacheSize is the maximum number of entries of the
+	// observation cache, the least recently used entry is evicted
+	// when it is full. ObservedCacheTTL evicts the entries of
+	// deployments not running for that long. Zero disables the
+	// bound. They can only be set with a flag.
+	ObservedCacheSize int           `json:"-"`
+	ObservedCacheTTL  time.Duration `json:"-"`
+	// AuditLog is the file the audit log of posted records is
+	// appended to, "-" for stdout. Empty disables the audit log.
+	AuditLog string `json:"auditLog"`
+	// RetryQueueDir is the directory records that failed to post
+	// are kept in until they are replayed. Empty disables the retry
+	// queue.
+	RetryQueueDir string `json:"retryQueueDir"`
+	// BatchWorkloads enables tracking of pods owned by Jobs and
+	// CronJobs.
+	BatchWorkloads bool `json:"batchWorkloads"`
+	// StaticPods enables tracking of the mirror pods of static pods,
+	// e.g. those of the control plane.
+	StaticPods bool `json:"staticPods"`
+	// DeploymentConfigs enables tracking of the pods of OpenShift
+	// DeploymentConfigs, owned by their ReplicationControllers.
+	DeploymentConfigs bool `json:"deploymentConfigs"`
+	// Knative records the pods of Knative revisions under their
+	// Service instead of the Deployment of each revision.
+	Knative bool `json:"knative"`
+	// EphemeralContainers enables recording of ephemeral containers,
+	// e.g. those added by kubectl debug.
+	EphemeralContainers bool `json:"ephemeralContainers"`
+	// DeleteWorkers is the number of workers processing delete
+	// events from a separate queue, so they aren't starved by create
+	// events. Zero processes them with the other events.
+	DeleteWorkers int `json:"-"`
+	// QueueLimits tunes the rate limiter of the workqueue retrying
+	// failed events.
+	QueueLimits QueueLimits `json:"-"`
+	// RetryBackoff is the backoff between retries of failed API
+	// requests, deploymentrecord.DefaultBackoff if zero.
+	RetryBackoff deploymentrecord.Backoff `json:"-"`
+	// ConnPool tunes the connection pool of the API client,
+	// deploymentrecord.DefaultConnPool if zero.
+	ConnPool deploymentrecord.ConnPool `json:"-"`
+	// APIMaxConcurrency limits the number of requests in flight to
+	// the API, 0 for no limit.
+	APIMaxConcurrency int `json:"-"`
+	// Timeouts bound the requests of the API client,
+	// deploymentrecord.DefaultTimeouts if zero.
+	Timeouts deploymentrecord.Timeouts `json:"-"`
+	// VerifyDigests enables resolving the images of deployed records
+	// against their registries, flagging records whose digest
+	// differs from the one the registry serves.
+	VerifyDigests bool `json:"verifyDigests"`
+	// CheckSignatures enables looking up the cosign signatures and
+	// attestations of the images of deployed records.
+	CheckSignatures bool `json:"checkSignatures"`
+	// PlatformDigests enables resolving the platform-specific manifest
+	// digest of the images of deployed records from their registries,
+	// for the platform of the node. It implies NodePlatform.
+	PlatformDigests bool `json:"platformDigests"`
+	// ResolveImageIDs enables resolving the digest of containers whose
+	// imageID is not a digest, e.g. a local image store ID, from the
+	// registries of their images. Otherwise they are skipped.
+	ResolveImageIDs bool `json:"resolveImageIDs"`
+	// RolloutStatus enables rollout mode: the pods of Deployments are
+	// recorded once the rollout of their revision completed, rather
+	// than as each pod starts, so failed rollouts are not recorded.
+	RolloutStatus bool `json:"rolloutStatus"`
+	// PartialRollouts records the pods of Deployments whose rollout
+	// is in progress, e.g. canaries or paused rollouts, as partially
+	// deployed, and as deployed once the rollout completed. Rollout
+	// mode takes precedence.
+	PartialRollouts bool `json:"partialRollouts"`
+	// SkipInitialSync disables posting the records of the pods
+	// already running when the controller starts, so only pods
+	// changing afterwards are recorded.
+	SkipInitialSync bool `json:"skipInitialSync"`
+	// EnvironmentRecords enables posting of environment records
+	// when tracked namespaces are created or deleted.
+	EnvironmentRecords bool `json:"environmentRecords"`
+	// StatusResources enables writing the post results of the
+	// records of each workload to the status of a TrackedDeployment
+	// in its namespace, which also restores the observation cache on
+	// restart.
+	StatusResources bool `json:"statusResources"`
+	// NamespaceDecommission enables decommissioning the records of a
+	// deleted namespace in batch requests when it starts terminating,
+	// rather than one pod delete at a time.
+	NamespaceDecommission bool `json:"namespaceDecommission"`
+	// OptIn limits tracking to pods and workloads annotated with
+	// the track annotation.
+	OptIn bool `json:"optIn"`
+	// ExcludeNamespaces lists namespaces or namespace patterns to
+	// ignore in addition to the ones excluded from the watch. These
+	// namespaces are still watched, so the list can be changed at
+	// runtime.
+	ExcludeNamespaces []string `json:"excludeNamespaces"`
+	// ExcludeContainers and ExcludeImagePrefixes are comma separated
+	// lists of container names and image prefixes that are not
+	// recorded, e.g. service mesh sidecars.
+	ExcludeContainers    string `json:"excludeContainers"`
+	ExcludeImagePrefixes string `json:"excludeImagePrefixes"`
+	// WebhookURL enables delivery of all posted records to a
+	// webhook. WebhookSecret signs the requests, and WebhookHeaders
+	// (Name=value, comma separated) are added to them.
+	WebhookURL     string `json:"webhookURL"`
+	WebhookSecret  string `json:"webhookSecret"`
+	WebhookHeaders string `json:"webhookHeaders"`
+	// WebhookTimeout is the timeout of the webhook requests, zero for
+	// sink.DefaultWebhookTimeout. It can only be set with a flag.
+	WebhookTimeout time.Duration `json:"-"`
+	// NotifyURL enables chat notifications of the records deployed
+	// or decommissioned to a Slack or Teams incoming webhook, see
+	// sink.NewNotifier. NotifyNamespaces (comma separated) and
+	// NotifySelector (a label selector) select the pods notified.
+	NotifyURL        string `json:"notifyURL"`
+	NotifyFormat     string `json:"notifyFormat"`
+	NotifyNamespaces string `json:"notifyNamespaces"`
+	NotifySelector   string `json:"notifySelector"`
+	NotifyTemplate   string `json:"notifyTemplate"`
+	// GHDeploymentsToken enables mirroring the posted deployment
+	// records to GitHub Deployments on their source repository,
+	// through the REST API at GHDeploymentsURL.
+	GHDeploymentsToken string `json:"ghDeploymentsToken"`
+	GHDeploymentsURL   string `json:"ghDeploymentsURL"`
+	// SQSQueueURL, SNSTopicARN and PubSubTopic enable delivery of all
+	// posted records to an Amazon SQS queue, an Amazon SNS topic or a
+	// Google Cloud Pub/Sub topic, authenticated with the workload
+	// identity of the pod.
+	SQSQueueURL string `json:"sqsQueueURL"`
+	SNSTopicARN string `json:"snsTopicARN"`
+	PubSubTopic string `json:"pubSubTopic"`
+	// WebhookFieldProfile and WebhookFieldMapping control the field
+	// names of the records delivered to the webhook, QueueFieldProfile
+	// and QueueFieldMapping those of the SQS, SNS and Pub/Sub sinks,
+	// and NotifyFieldProfile and NotifyFieldMapping those of the
+	// notifier template, like FieldProfile and FieldMapping. Empty
+	// keeps the canonical field names.
+	WebhookFieldProfile string `json:"webhookFieldProfile"`
+	WebhookFieldMapping string `json:"webhookFieldMapping"`
+	QueueFieldProfile   string `json:"queueFieldProfile"`
+	QueueFieldMapping   string `json:"queueFieldMapping"`
+	NotifyFieldProfile  string `json:"notifyFieldProfile"`
+	NotifyFieldMapping  string `json:"notifyFieldMapping"`
+	// SinksOnly delivers the records only to the sinks, without
+	// posting them to the API, for clusters that can't reach it. It
+	// can't be reloaded.
+	SinksOnly bool `json:"-"`
+	// APIHeaders (Name=value, comma separated) are added to the
+	// requests to the API, e.g. for routing through a front door.
+	// They can't be reloaded.
+	APIHeaders string `json:"apiHeaders"`
+	// ClientCert and ClientKey are the paths of a PEM client
+	// certificate and key, presented to the API and the webhook for
+	// mutual TLS. They can't be reloaded.
+	ClientCert string `json:"clientCert"`
+	ClientKey  string `json:"clientKey"`
+	// Clusters lists the clusters to watch in multi-cluster mode,
+	// one controller each. Empty watches the single cluster of the
+	// kubeconfig or in-cluster config. It can't be reloaded.
+	Clusters []ClusterConfig `json:"clusters"`
+	// Chaos injects failures and latency into the API requests, and
+	// ChaosResyncInterval enqueues all running pods at the interval,
+	// to soak test retries and queueing. Zero disables them. They are
+	// only set by the hidden -chaos-* flags.
+	Chaos               deploymentrecord.Chaos `json:"-"`
+	ChaosResyncInterval time.Duration          `json:"-"`
+}
+
+// LoadConfigFile reads the YAML or JSON config file at path into cfg.
+// Settings missing from the file keep their current value in cfg.
+func LoadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := ParseConfig(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// ParseConfig parses the YAML or JSON config data into cfg, with the
+// keys of the config file. Unknown keys are an error. Settings missing
+// from data keep their current value in cfg.
+func ParseConfig(data []byte, cfg *Config) error {
+	return yaml.UnmarshalStrict(data, cfg)
 }
 
 // ValidTemplate verifies that at least one placeholder is present
-// in the provided template t.
+// in the provided template t, and that all placeholders are known.
 func ValidTemplate(t string) bool {
-	hasPlaceholder := strings.Contains(t, TmplNS) ||
-		strings.Contains(t, TmplDN) ||
-		strings.Contains(t, TmplCN)
+	return ValidateTemplate(t) == nil
+}
+
+// TemplateHash returns a short hash of the template and namespace
+// templates, so configurations can be compared without exposing the
+// templates.
+func (c *Config) TemplateHash() string {
+	h := sha256.New()
+	h.Write([]byte(c.Template))
+	for _, ns := range slices.Sorted(maps.Keys(c.NamespaceTemplates)) {
+		fmt.Fprintf(h, "\x00%s=%s", ns, c.NamespaceTemplates[ns])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// ValidateTemplates returns an error if the template or any of the
+// namespace templates is invalid.
+func (c *Config) ValidateTemplates() error {
+	if err := ValidateTemplate(c.Template); err != nil {
+		return err
+	}
+	for ns, t := range c.NamespaceTemplates {
+		if err := ValidateTemplate(t); err != nil {
+			return fmt.Errorf("namespace %s: %w", ns, err)
+		}
+	}
+	return nil
+}
 
-	return hasPlaceholder
+// ValidateTemplate returns an error describing why the template t is
+// invalid: it has no placeholder, an unknown placeholder, or a label
+// or annotation placeholder with an invalid key.
+func ValidateTemplate(t string) error {
+	matches := placeholderPattern.FindAllStringSubmatch(t, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("template %q must contain at least one placeholder", t)
+	}
+	for _, m := range matches {
+		name := m[1]
+		switch {
+		case m[0] == TmplNS, m[0] == TmplDN, m[0] == TmplCN,
+			m[0] == TmplWK, m[0] == TmplCluster, m[0] == TmplPN, m[0] == TmplNode,
+			m[0] == TmplKnativeService, m[0] == TmplKnativeRevision:
+		case strings.HasPrefix(name, TmplLabelPrefix):
+			if errs := validation.IsQualifiedName(strings.TrimPrefix(name, TmplLabelPrefix)); len(errs) > 0 {
+				return fmt.Errorf("template %q has an invalid label key in %s: %s", t, m[0], strings.Join(errs, ", "))
+			}
+		case strings.HasPrefix(name, TmplAnnotationPrefix):
+			if errs := validation.IsQualifiedName(strings.TrimPrefix(name, TmplAnnotationPrefix)); len(errs) > 0 {
+				return fmt.Errorf("template %q has an invalid annotation key in %s: %s", t, m[0], strings.Join(errs, ", "))
+			}
+		default:
+			return fmt.Errorf("template %q has an unknown placeholder %s", t, m[0])
+		}
+	}
+	return nil
 }
@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -35,6 +38,11 @@ func TestValidTemplate(t *testing.T) {
 			template: "{{containerName}}",
 			expected: true,
 		},
+		{
+			name:     "workload kind placeholder only",
+			template: "{{workloadKind}}",
+			expected: true,
+		},
 		{
 			name:     "all three placeholders",
 			template: "{{namespace}}/{{deploymentName}}/{{containerName}}",
@@ -100,6 +108,36 @@ func TestValidTemplate(t *testing.T) {
 			template: "app-name_v1.2.3",
 			expected: false,
 		},
+		{
+			name:     "cluster and pod name placeholders",
+			template: "{{cluster}}/{{namespace}}/{{podName}}",
+			expected: true,
+		},
+		{
+			name:     "label placeholder",
+			template: "{{labels.team}}/{{deploymentName}}",
+			expected: true,
+		},
+		{
+			name:     "prefixed annotation placeholder",
+			template: "{{annotations.example.com/owner}}/{{deploymentName}}",
+			expected: true,
+		},
+		{
+			name:     "label placeholder without key",
+			template: "{{labels.}}/{{deploymentName}}",
+			expected: false,
+		},
+		{
+			name:     "label placeholder with invalid key",
+			template: "{{labels.team name}}/{{deploymentName}}",
+			expected: false,
+		},
+		{
+			name:     "unknown placeholder next to a valid one",
+			template: "{{namespace}}/{{team}}",
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -111,3 +149,101 @@ func TestValidTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected Config
+		wantErr  bool
+	}{
+		{
+			name: "yaml overrides set fields",
+			content: `template: "{{namespace}}/{{deploymentName}}"
+cluster: prod-1
+excludeNamespaces:
+  - kube-system
+  - monitoring
+`,
+			expected: Config{
+				Template:           "{{namespace}}/{{deploymentName}}",
+				LogicalEnvironment: "production",
+				Cluster:            "prod-1",
+				ExcludeNamespaces:  []string{"kube-system", "monitoring"},
+			},
+		},
+		{
+			name:    "json",
+			content: `{"logicalEnvironment": "staging", "optIn": true}`,
+			expected: Config{
+				Template:           TmplDN,
+				LogicalEnvironment: "staging",
+				Cluster:            "default",
+				OptIn:              true,
+			},
+		},
+		{
+			name:    "unknown field",
+			content: "clustr: prod-1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write config file: %v", err)
+			}
+
+			cfg := Config{
+				Template:           TmplDN,
+				LogicalEnvironment: "production",
+				Cluster:            "default",
+			}
+			err := LoadConfigFile(path, &cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if cfg.Template != tt.expected.Template ||
+				cfg.LogicalEnvironment != tt.expected.LogicalEnvironment ||
+				cfg.Cluster != tt.expected.Cluster ||
+				cfg.OptIn != tt.expected.OptIn ||
+				!slices.Equal(cfg.ExcludeNamespaces, tt.expected.ExcludeNamespaces) {
+				t.Errorf("LoadConfigFile() = %+v, expected %+v", cfg, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTemplateHash(t *testing.T) {
+	base := &Config{
+		Template: TmplNS + "/" + TmplDN,
+		NamespaceTemplates: map[string]string{
+			"payments": "payments/" + TmplDN,
+			"search":   TmplDN,
+		},
+	}
+	same := &Config{
+		Template: TmplNS + "/" + TmplDN,
+		NamespaceTemplates: map[string]string{
+			"search":   TmplDN,
+			"payments": "payments/" + TmplDN,
+		},
+	}
+	other := &Config{Template: TmplNS + "/" + TmplDN}
+
+	if len(base.TemplateHash()) != 12 {
+		t.Errorf("TemplateHash() = %q, expected 12 characters", base.TemplateHash())
+	}
+	if base.TemplateHash() != same.TemplateHash() {
+		t.Errorf("TemplateHash() differs for equal templates: %q != %q", base.TemplateHash(), same.TemplateHash())
+	}
+	if base.TemplateHash() == other.TemplateHash() {
+		t.Errorf("TemplateHash() = %q for different templates", base.TemplateHash())
+	}
+}
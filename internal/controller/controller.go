@@ -5,21 +5,39 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/github/deployment-tracker/internal/version"
 	"github.com/github/deployment-tracker/pkg/deploymentrecord"
 	"github.com/github/deployment-tracker/pkg/image"
 	"github.com/github/deployment-tracker/pkg/metrics"
-
+	"github.com/github/deployment-tracker/pkg/registry"
+	"github.com/github/deployment-tracker/pkg/sink"
+	"github.com/github/deployment-tracker/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -29,71 +47,334 @@ const (
 	EventCreated = "CREATED"
 	// EventDeleted indicates that a pod has been deleted.
 	EventDeleted = "DELETED"
+	// EventNamespaceCreated indicates that a namespace has been
+	// created.
+	EventNamespaceCreated = "NAMESPACE_CREATED"
+	// EventNamespaceDeleted indicates that a namespace has been
+	// deleted.
+	EventNamespaceDeleted = "NAMESPACE_DELETED"
+	// EventRolloutComplete indicates that the rollout of a
+	// Deployment completed, in rollout mode.
+	EventRolloutComplete = "ROLLOUT_COMPLETE"
+	// EventNamespaceTerminating indicates that a namespace is being
+	// deleted, so its records are decommissioned in bulk.
+	EventNamespaceTerminating = "NAMESPACE_TERMINATING"
+	// EventDeploymentDeleted indicates that a Deployment has been
+	// deleted, so all its records are decommissioned.
+	EventDeploymentDeleted = "DEPLOYMENT_DELETED"
+)
+
+// Workload kinds owning tracked pods.
+const (
+	kindDeployment  = "Deployment"
+	kindStatefulSet = "StatefulSet"
+	kindDaemonSet   = "DaemonSet"
+	kindJob         = "Job"
+	kindCronJob     = "CronJob"
+	kindStaticPod   = "StaticPod"
+	// kindDeploymentConfig is an OpenShift DeploymentConfig
+	kindDeploymentConfig = "DeploymentConfig"
+	// kindKnativeService is a Knative Service, or Configuration,
+	// whose revisions run the pod
+	kindKnativeService = "KnativeService"
+	// kindKnativeRevision is a Knative Revision. Pods are recorded
+	// under their Service, the Revision is only looked up to
+	// decommission them.
+	kindKnativeRevision = "KnativeRevision"
+)
+
+// tracerName is the instrumentation scope of the controller's spans.
+const tracerName = "github.com/github/deployment-tracker/internal/controller"
+
+// recordSource prefixes the version of the tracker in the source of
+// records.
+const recordSource = "deployment-tracker/"
+
+const (
+	// serverVersionRefresh is how often the cached Kubernetes server
+	// version is refreshed.
+	serverVersionRefresh = time.Hour
+
+	// stallTimeout is how long the workqueue may hold events without
+	// any progress before the controller is considered unhealthy.
+	stallTimeout = 5 * time.Minute
+
+	// cachePersistInterval is how often a changed observation cache
+	// is persisted.
+	cachePersistInterval = 30 * time.Second
+
+	// jobOwnerRetention is how long the owner of a deleted Job is
+	// remembered.
+	jobOwnerRetention = 10 * time.Minute
+
+	// decommissionedNsRetention is how long a deleted namespace whose
+	// records were decommissioned in bulk is remembered.
+	decommissionedNsRetention = 10 * time.Minute
+
+	// retryQueueReplayInterval is how often the records in the
+	// retry queue are replayed.
+	retryQueueReplayInterval = time.Minute
+
+	// trackedDeploymentsInterval is how often the tracked
+	// deployments gauge is updated.
+	trackedDeploymentsInterval = 30 * time.Second
+)
+
+const (
+	// revisionAnnotation is set by the Deployment controller on each
+	// ReplicaSet it manages, holding the rollout revision number.
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+
+	// ignoreAnnotation excludes a pod or workload from tracking when
+	// set to "true".
+	ignoreAnnotation = "deployment-tracker.github.com/ignore"
+
+	// trackAnnotation opts a pod or workload in to tracking when set
+	// to "true" and the controller runs in opt-in mode.
+	trackAnnotation = "deployment-tracker.github.com/track"
+)
+
+// podDeleteReason describes why a pod owned by a Deployment was
+// deleted.
+type podDeleteReason string
+
+const (
+	// deleteScaleDown means the owning ReplicaSet is still the newest
+	// revision of the Deployment and remains in place.
+	deleteScaleDown podDeleteReason = "scale_down"
+	// deleteRollover means the owning ReplicaSet has been superseded
+	// by a newer revision of the Deployment.
+	deleteRollover podDeleteReason = "rollover"
+	// deleteRemoved means no ReplicaSet of the Deployment remains, so
+	// the Deployment itself is most likely gone.
+	deleteRemoved podDeleteReason = "removed"
 )
 
-// PodEvent represents a pod event to be processed.
+// workload identifies the workload (e.g. a Deployment) owning a pod.
+type workload struct {
+	Kind string
+	Name string
+}
+
+// PodEvent represents a pod (or namespace) event to be processed.
+// For namespace events, the key is the namespace name.
 type PodEvent struct {
 	Key        string
 	EventType  string
 	DeletedPod *corev1.Pod // Only populated for delete events
+	UID        types.UID   // Only populated for namespace create and delete events
 }
 
 // Controller is the Kubernetes controller for tracking deployments.
 type Controller struct {
-	clientset   kubernetes.Interface
-	podInformer cache.SharedIndexInformer
-	workqueue   workqueue.TypedRateLimitingInterface[PodEvent]
-	apiClient   *deploymentrecord.Client
-	cfg         *Config
+	clientset kubernetes.Interface
+	// informers holds the pod, deployment, replicaset and job
+	// informers of all watched namespaces
+	informers        []cache.SharedIndexInformer
+	podLister        corelisters.PodLister
+	rsLister         appslisters.ReplicaSetLister
+	deploymentLister appslisters.DeploymentLister
+	includedNs       namespaceList
+	excludedNs       namespaceList
+	workqueue        workqueue.TypedRateLimitingInterface[PodEvent]
+	apiClient        *deploymentrecord.Client
+	// deleteQueue holds the delete events. With DeleteWorkers set, it
+	// is processed by a separate worker pool, so decommissions aren't
+	// starved by create events; otherwise it is the workqueue.
+	deleteQueue workqueue.TypedRateLimitingInterface[PodEvent]
+	// cfg is replaced as a whole when the configuration is reloaded
+	cfg atomic.Pointer[Config]
+	// reloadedNs holds the parsed ExcludeNamespaces of cfg
+	reloadedNs atomic.Pointer[namespaceList]
+	// nsInformer and nsLister are only set when environment records,
+	// namespace template annotations or namespace decommissions are
+	// enabled
+	nsInformer cache.SharedIndexInformer
+	nsLister   corelisters.NamespaceLister
+	// nodeInformer and nodeLister are only set when node topology or
+	// platform is enabled
+	nodeInformer cache.SharedIndexInformer
+	nodeLister   corelisters.NodeLister
+	// batcher is only set when batch posting is enabled
+	batcher *batcher
+	// sinks receive the records posted to the API, replaced as a
+	// whole when the sink settings are reloaded
+	sinks atomic.Pointer[[]sink.Sink]
+	// coalescer is only set when create events are coalesced
+	coalescer *coalescer
+	// auditLog is only set when the audit log is enabled
+	auditLog *auditLog
+	// registry resolves image digests and signatures when
+	// VerifyDigests, CheckSignatures, PlatformDigests or
+	// ResolveImageIDs is enabled
+	registry *registry.Client
+	// imageIDDigests holds the digests resolved from the registries for
+	// imageIDs without one (keyed by imageID and image)
+	imageIDDigests sync.Map
+	// jobLister is only set when batch workloads are enabled
+	jobLister batchlisters.JobLister
+	// jobOwners remembers the CronJob owning a Job (keyed by
+	// namespace/job), so pods can be resolved after their Job has
+	// been deleted
+	jobOwners sync.Map
+	// decommissionedNs holds the terminating namespaces whose records
+	// were decommissioned in bulk
+	decommissionedNs sync.Map
+	// serverVersion is the cached Kubernetes server version
+	serverVersion atomic.Pointer[string]
+	// synced is set once the informer caches are synced
+	synced atomic.Bool
+	// informersSynced holds the HasSynced funcs of all informers
+	informersSynced []cache.InformerSynced
+	// lastProgress is the time (unix nano) a worker last finished
+	// processing an event
+	lastProgress atomic.Int64
 	// best effort cache to avoid redundant posts
 	// post requests are idempotent, so if this cache fails due to
 	// restarts or other events, nothing will break.
-	observedDeployments sync.Map
+	// Records evicted from the bounded cache are posted again, but
+	// not decommissioned.
+	observedDeployments observedCache
+	// partialDeployments holds the cache keys of records posted as
+	// partially deployed, with partial rollouts enabled
+	partialDeployments sync.Map
+	// deploymentRecords associates Deployments with the records
+	// posted for their pods
+	deploymentRecords *deploymentRecords
+	// namespaceRecords associates namespaces with the records posted
+	// for their pods, only set with namespace decommission
+	namespaceRecords *deploymentRecords
+	// statusStore is only set when TrackedDeployment status
+	// resources are enabled
+	statusStore *statusStore
+	// dynamicClient is set by WithDynamicClient
+	dynamicClient dynamic.Interface
+	// cacheStore is only set when the observation cache is
+	// persisted
+	cacheStore *configMapStore
+	// cacheDirty is set when the observation cache has changed
+	// since it was last persisted
+	cacheDirty atomic.Bool
+	// retryQueue is only set when failed records are kept on disk
+	retryQueue *diskQueue
+	// deadLetters holds the records of events that exhausted their
+	// retries
+	deadLetters *deadLetterStore
+	// configInfo holds the labels of the exported config info, only
+	// set by New and Reload
+	configInfo []string
+	// trackedCluster is the cluster the tracked deployments were last
+	// exported for, only set by updateTrackedDeployments
+	trackedCluster string
 }
 
-// New creates a new deployment tracker controller.
-func New(clientset kubernetes.Interface, namespace string, excludeNamespaces string, cfg *Config) (*Controller, error) {
-	// Create informer factory
-	factory := createInformerFactory(clientset, namespace, excludeNamespaces)
+// Option configures a Controller.
+type Option func(*Controller)
 
-	podInformer := factory.Core().V1().Pods().Informer()
-
-	// Create work queue with rate limiting
-	queue := workqueue.NewTypedRateLimitingQueue(
-		workqueue.DefaultTypedControllerRateLimiter[PodEvent](),
-	)
+// WithDynamicClient sets the client of the TrackedDeployment status
+// resources, required when they are enabled. It is also used to look up
+// OpenShift DeploymentConfigs and Knative Revisions, which are never
+// decommissioned without it.
+func WithDynamicClient(client dynamic.Interface) Option {
+	return func(c *Controller) {
+		c.dynamicClient = client
+	}
+}
 
-	// Create API client with optional token
-	clientOpts := []deploymentrecord.ClientOption{}
-	if cfg.APIToken != "" {
-		clientOpts = append(clientOpts, deploymentrecord.WithAPIToken(cfg.APIToken))
+// New creates a new deployment tracker controller. The namespaces
+// to watch and to exclude from watching are given as comma separated
+// lists; exclusions take precedence.
+func New(clientset kubernetes.Interface, namespaces string, excludeNamespaces string, cfg *Config, opts ...Option) (*Controller, error) {
+	if err := ValidateNamespaces(namespaces, excludeNamespaces); err != nil {
+		return nil, err
 	}
-	if cfg.GHAppID != "" &&
-		cfg.GHInstallID != "" &&
-		cfg.GHAppPrivateKey != "" {
-		clientOpts = append(clientOpts, deploymentrecord.WithGHApp(cfg.GHAppID, cfg.GHInstallID, cfg.GHAppPrivateKey))
+	includedNs, _ := parseNamespaceList(namespaces)
+	excludedNs, _ := parseNamespaceList(excludeNamespaces)
+	reloadedNs, err := newNamespaceList(cfg.ExcludeNamespaces)
+	if err != nil {
+		return nil, err
 	}
 
-	apiClient, err := deploymentrecord.NewClient(
-		cfg.BaseURL,
-		cfg.Organization,
-		clientOpts...,
+	// Create informer factories, one per watched namespace
+	factories := createInformerFactories(clientset, includedNs, excludedNs)
+
+	var allInformers, podInformers, deploymentInformers, jobInformers []cache.SharedIndexInformer
+	podLister := podListers{}
+	rsLister := replicaSetListers{}
+	deploymentLister := deploymentListers{}
+	jobLister := jobListers{}
+	for ns, factory := range factories {
+		podInformers = append(podInformers, factory.InformerFor(&corev1.Pod{}, newPodInformer(ns, excludedNs)))
+		podLister[ns] = factory.Core().V1().Pods().Lister()
+		allInformers = append(allInformers, factory.Apps().V1().ReplicaSets().Informer())
+		rsLister[ns] = factory.Apps().V1().ReplicaSets().Lister()
+		deploymentInformers = append(deploymentInformers, factory.Apps().V1().Deployments().Informer())
+		deploymentLister[ns] = factory.Apps().V1().Deployments().Lister()
+		if cfg.BatchWorkloads {
+			jobInformers = append(jobInformers, factory.Batch().V1().Jobs().Informer())
+			jobLister[ns] = factory.Batch().V1().Jobs().Lister()
+		}
+	}
+	allInformers = slices.Concat(allInformers, deploymentInformers, podInformers, jobInformers)
+
+	// Create work queue with rate limiting
+	queue := workqueue.NewTypedRateLimitingQueueWithConfig(
+		newQueueRateLimiter(cfg.QueueLimits),
+		workqueue.TypedRateLimitingQueueConfig[PodEvent]{
+			Name:            "events",
+			MetricsProvider: metrics.WorkqueueProvider{},
+		},
 	)
+	deleteQueue := queue
+	if cfg.DeleteWorkers > 0 {
+		deleteQueue = workqueue.NewTypedRateLimitingQueueWithConfig(
+			newQueueRateLimiter(cfg.QueueLimits),
+			workqueue.TypedRateLimitingQueueConfig[PodEvent]{
+				Name:            "deletions",
+				MetricsProvider: metrics.WorkqueueProvider{},
+			},
+		)
+	}
+
+	apiClient, err := NewAPIClient(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create API client: %w", err)
+		return nil, err
 	}
 
 	cntrl := &Controller{
-		clientset:   clientset,
-		podInformer: podInformer,
-		workqueue:   queue,
-		apiClient:   apiClient,
-		cfg:         cfg,
+		clientset:         clientset,
+		informers:         allInformers,
+		podLister:         podLister,
+		rsLister:          rsLister,
+		deploymentLister:  deploymentLister,
+		includedNs:        includedNs,
+		excludedNs:        excludedNs,
+		workqueue:         queue,
+		deleteQueue:       deleteQueue,
+		apiClient:         apiClient,
+		deadLetters:       newDeadLetterStore(deadLetterCapacity),
+		deploymentRecords: newDeploymentRecords(),
+		registry:          registry.NewClient(),
+	}
+	for _, opt := range opts {
+		opt(cntrl)
+	}
+	cntrl.cfg.Store(cfg)
+	cntrl.reloadedNs.Store(&reloadedNs)
+	cntrl.observedDeployments.setBounds(cfg.ObservedCacheSize, cfg.ObservedCacheTTL)
+	cntrl.setConfigInfo(cfg)
+	if cfg.BatchWorkloads {
+		cntrl.jobLister = jobLister
 	}
 
-	// Add event handlers to the informer
-	_, err = podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj any) {
+	// Add event handlers to the pod informers
+	podHandlers := cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj any, isInInitialList bool) {
+			// The pods of the initial list are enqueued by the
+			// initial sync once the caches are synced
+			if isInInitialList {
+				return
+			}
 			pod, ok := obj.(*corev1.Pod)
 			if !ok {
 				slog.Error("Invalid object returned",
@@ -103,18 +384,18 @@ func New(clientset kubernetes.Interface, namespace string, excludeNamespaces str
 			}
 
 			// Only process pods that are running and belong
-			// to a deployment
-			if pod.Status.Phase == corev1.PodRunning && getDeploymentName(pod) != "" {
+			// to a tracked namespace and workload
+			if !cntrl.namespaceTracked(pod.Namespace) || !cntrl.podStarted(pod) {
+				return
+			}
+			if wl := cntrl.resolveWorkload(pod); wl.Name != "" {
 				key, err := cache.MetaNamespaceKeyFunc(obj)
 
 				// For our purposes, there are in practice
 				// no error event we care about, so don't
 				// bother with handling it.
 				if err == nil {
-					queue.Add(PodEvent{
-						Key:       key,
-						EventType: EventCreated,
-					})
+					cntrl.enqueueCreated(pod, wl, key)
 				}
 			}
 		},
@@ -135,28 +416,34 @@ func New(clientset kubernetes.Interface, namespace string, excludeNamespaces str
 			}
 
 			// Skip if pod is being deleted or doesn't belong
-			// to a deployment
-			if newPod.DeletionTimestamp != nil || getDeploymentName(newPod) == "" {
+			// to a tracked namespace and workload
+			if newPod.DeletionTimestamp != nil || !cntrl.namespaceTracked(newPod.Namespace) {
+				return
+			}
+			wl := cntrl.resolveWorkload(newPod)
+			if wl.Name == "" {
 				return
 			}
 
-			// Only process if pod just became running.
+			// Process the pod when it just became running.
 			// We need to process this as often when a container
 			// is created, the spec does not contain the digest
 			// so we need to wait for the status field to be
 			// populated from where we can get the digest.
-			if oldPod.Status.Phase != corev1.PodRunning &&
-				newPod.Status.Phase == corev1.PodRunning {
+			started := !cntrl.podStarted(oldPod) && cntrl.podStarted(newPod)
+			// Images of running pods can change in place, e.g.
+			// with kubectl set image, and ephemeral containers
+			// are added to running pods by kubectl debug
+			changed := cntrl.podStarted(newPod) &&
+				imageChanged(oldPod, newPod, cntrl.cfg.Load().EphemeralContainers)
+			if started || changed {
 				key, err := cache.MetaNamespaceKeyFunc(newObj)
 
 				// For our purposes, there are in practice
 				// no error event we care about, so don't
 				// bother with handling it.
 				if err == nil {
-					queue.Add(PodEvent{
-						Key:       key,
-						EventType: EventCreated,
-					})
+					cntrl.enqueueCreated(newPod, wl, key)
 				}
 			}
 		},
@@ -174,8 +461,9 @@ func New(clientset kubernetes.Interface, namespace string, excludeNamespaces str
 				}
 			}
 
-			// Only process pods that belong to a deployment
-			if getDeploymentName(pod) == "" {
+			// Only process pods that belong to a tracked
+			// namespace and workload
+			if !cntrl.namespaceTracked(pod.Namespace) || cntrl.resolveWorkload(pod).Name == "" {
 				return
 			}
 
@@ -184,326 +472,1625 @@ func New(clientset kubernetes.Interface, namespace string, excludeNamespaces str
 			// no error event we care about, so don't
 			// bother with handling it.
 			if err == nil {
-				queue.Add(PodEvent{
+				// Delay the decommission, so pods replaced
+				// during drains and rollouts don't flap
+				deleteQueue.AddAfter(PodEvent{
 					Key:        key,
 					EventType:  EventDeleted,
 					DeletedPod: pod,
-				})
+				}, cntrl.cfg.Load().DecommissionGracePeriod)
 			}
 		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to add event handlers: %w", err)
+	}
+	for _, podInformer := range podInformers {
+		if _, err := podInformer.AddEventHandler(podHandlers); err != nil {
+			return nil, fmt.Errorf("failed to add event handlers: %w", err)
+		}
 	}
 
-	return cntrl, nil
-}
+	if cfg.CacheConfigMap != "" {
+		ns, name, ok := strings.Cut(cfg.CacheConfigMap, "/")
+		if !ok || ns == "" || name == "" {
+			return nil, fmt.Errorf("invalid cache configmap: %s (expected namespace/name)", cfg.CacheConfigMap)
+		}
+		cntrl.cacheStore = newConfigMapStore(clientset, ns, name)
+	}
 
-// Run starts the controller.
-func (c *Controller) Run(ctx context.Context, workers int) error {
-	defer runtime.HandleCrash()
-	defer c.workqueue.ShutDown()
+	if cfg.StatusResources {
+		if cntrl.dynamicClient == nil {
+			return nil, errors.New("status resources require a dynamic client")
+		}
+		cntrl.statusStore = newStatusStore(cntrl.dynamicClient)
+	}
 
-	slog.Info("Starting pod informer")
+	if cfg.RetryQueueDir != "" {
+		cntrl.retryQueue, err = newDiskQueue(cfg.RetryQueueDir, retryQueueMaxRecords)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Start the informer
-	go c.podInformer.Run(ctx.Done())
+	sinks, err := newSinks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cntrl.sinks.Store(&sinks)
 
-	// Wait for the cache to be synced
-	slog.Info("Waiting for informer cache to sync")
-	if !cache.WaitForCacheSync(ctx.Done(), c.podInformer.HasSynced) {
-		return errors.New("timed out waiting for caches to sync")
+	if cfg.CoalesceWindow > 0 {
+		cntrl.coalescer = newCoalescer(cfg.CoalesceWindow)
 	}
 
-	slog.Info("Starting workers",
-		"count", workers,
-	)
+	if cfg.AuditLog != "" {
+		cntrl.auditLog, err = newAuditLog(cfg.AuditLog)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Start workers
-	for i := 0; i < workers; i++ {
-		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	if cfg.PostBatchSize > 1 {
+		cntrl.batcher = newBatcher(apiClient, cfg.PostBatchSize, cfg.PostBatchInterval)
 	}
 
-	slog.Info("Controller started")
+	jobHandlers := cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj any) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			// Keep the owner around for a while, so the pods
+			// garbage collected after the job can still be
+			// resolved
+			time.AfterFunc(jobOwnerRetention, func() {
+				cntrl.jobOwners.Delete(key)
+			})
+		},
+	}
+	for _, jobInformer := range jobInformers {
+		if _, err := jobInformer.AddEventHandler(jobHandlers); err != nil {
+			return nil, fmt.Errorf("failed to add job event handlers: %w", err)
+		}
+	}
 
-	<-ctx.Done()
-	slog.Info("Shutting down workers")
+	if cfg.EnvironmentRecords || cfg.TemplateAnnotations || cfg.NamespaceDecommission {
+		factory := informers.NewSharedInformerFactoryWithOptions(
+			clientset,
+			30*time.Second,
+			informers.WithTransform(stripObject),
+		)
+		cntrl.nsInformer = factory.Core().V1().Namespaces().Informer()
+		cntrl.nsLister = factory.Core().V1().Namespaces().Lister()
+	}
+	if cfg.NodeTopology || cfg.NodePlatform || cfg.PlatformDigests {
+		factory := informers.NewSharedInformerFactoryWithOptions(
+			clientset,
+			30*time.Second,
+			informers.WithTransform(stripObject),
+		)
+		cntrl.nodeInformer = factory.Core().V1().Nodes().Informer()
+		cntrl.nodeLister = factory.Core().V1().Nodes().Lister()
+	}
+	if cfg.EnvironmentRecords {
+		if err := cntrl.addNamespaceHandlers(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.NamespaceDecommission {
+		cntrl.namespaceRecords = newDeploymentRecords()
+		if err := cntrl.addNamespaceDecommissionHandlers(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RolloutStatus || cfg.PartialRollouts {
+		if err := cntrl.addRolloutHandlers(deploymentInformers); err != nil {
+			return nil, err
+		}
+	}
+	if err := cntrl.addDeploymentDeleteHandlers(deploymentInformers); err != nil {
+		return nil, err
+	}
 
-	return nil
+	return cntrl, nil
 }
 
-// runWorker runs a worker to process items from the work queue.
-func (c *Controller) runWorker(ctx context.Context) {
-	for c.processNextItem(ctx) {
+// userAgent returns the User-Agent of the API requests of the tracker
+// of the cluster, e.g. "deployment-tracker/v1.2.0 (cluster prod)".
+func userAgent(cluster string) string {
+	ua := recordSource + version.Get()
+	if cluster != "" {
+		ua += " (cluster " + cluster + ")"
 	}
+	return ua
 }
 
-// processNextItem processes the next item from the work queue.
-func (c *Controller) processNextItem(ctx context.Context) bool {
-	event, shutdown := c.workqueue.Get()
-	if shutdown {
-		return false
+// NewAPIClient creates the client of the deployment records API
+// configured in cfg, with its credentials, field mapping, limits and
+// headers.
+func NewAPIClient(cfg *Config) (*deploymentrecord.Client, error) {
+	clientOpts := []deploymentrecord.ClientOption{}
+	switch {
+	case cfg.APITokenFile != "":
+		clientOpts = append(clientOpts, deploymentrecord.WithAPITokenFile(cfg.APITokenFile))
+	case cfg.APIToken != "":
+		clientOpts = append(clientOpts, deploymentrecord.WithAPIToken(cfg.APIToken))
 	}
-	defer c.workqueue.Done(event)
-
-	start := time.Now()
-	err := c.processEvent(ctx, event)
-	dur := time.Since(start)
-
-	if err == nil {
-		metrics.EventsProcessedOk.WithLabelValues(event.EventType).Inc()
-		metrics.EventsProcessedTimer.WithLabelValues("ok").Observe(dur.Seconds())
-
-		c.workqueue.Forget(event)
-		return true
+	if cfg.TokenExchangeURL != "" {
+		clientOpts = append(clientOpts, deploymentrecord.WithTokenExchange(cfg.TokenExchangeURL, cfg.OIDCTokenPath))
+	}
+	vaultOpts, err := vaultClientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts = append(clientOpts, vaultOpts...)
+	if cfg.GHAppID != "" && cfg.GHInstallID != "" {
+		switch {
+		case cfg.GHAppPrivateKeyPEM != "":
+			clientOpts = append(clientOpts, deploymentrecord.WithGHAppKey(cfg.GHAppID, cfg.GHInstallID, []byte(cfg.GHAppPrivateKeyPEM)))
+		case cfg.GHAppPrivateKey != "":
+			clientOpts = append(clientOpts, deploymentrecord.WithGHApp(cfg.GHAppID, cfg.GHInstallID, cfg.GHAppPrivateKey))
+		}
+	}
+	fields, err := deploymentrecord.NewFieldMapping(cfg.FieldProfile, cfg.FieldMapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create field mapping: %w", err)
+	}
+	clientOpts = append(clientOpts, deploymentrecord.WithFieldMapping(fields))
+	if cfg.APIRateLimit > 0 {
+		clientOpts = append(clientOpts, deploymentrecord.WithRateLimiter(cfg.APIRateLimit, cfg.APIBurst))
+	}
+	if cfg.RetryBackoff != (deploymentrecord.Backoff{}) {
+		clientOpts = append(clientOpts, deploymentrecord.WithBackoff(cfg.RetryBackoff))
+	}
+	if cfg.ConnPool != (deploymentrecord.ConnPool{}) {
+		clientOpts = append(clientOpts, deploymentrecord.WithConnPool(cfg.ConnPool))
+	}
+	if cfg.APIMaxConcurrency > 0 {
+		clientOpts = append(clientOpts, deploymentrecord.WithMaxConcurrency(cfg.APIMaxConcurrency))
+	}
+	if cfg.Timeouts != (deploymentrecord.Timeouts{}) {
+		clientOpts = append(clientOpts, deploymentrecord.WithTimeouts(cfg.Timeouts))
+	}
+	if cfg.Chaos != (deploymentrecord.Chaos{}) {
+		slog.Warn("Chaos injection enabled for API requests, do not use in production",
+			"failure_rate", cfg.Chaos.FailureRate,
+			"failure_status", cfg.Chaos.FailureStatus,
+			"latency", cfg.Chaos.Latency,
+			"latency_jitter", cfg.Chaos.LatencyJitter,
+		)
+		clientOpts = append(clientOpts, deploymentrecord.WithChaos(cfg.Chaos))
+	}
+	if cfg.ClientCert != "" {
+		clientOpts = append(clientOpts, deploymentrecord.WithClientCertificate(cfg.ClientCert, cfg.ClientKey))
+	}
+	headers, err := sink.ParseHeaders(cfg.APIHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API headers: %w", err)
+	}
+	for k, v := range headers {
+		clientOpts = append(clientOpts, deploymentrecord.WithHeader(k, v))
+	}
+	clientOpts = append(clientOpts, deploymentrecord.WithUserAgent(userAgent(cfg.Cluster)))
+	if cfg.SinksOnly {
+		clientOpts = append(clientOpts, deploymentrecord.WithoutAPI())
 	}
-	metrics.EventsProcessedTimer.WithLabelValues("failed").Observe(dur.Seconds())
-	metrics.EventsProcessedFailed.WithLabelValues(event.EventType).Inc()
 
-	// Requeue on error with rate limiting
-	slog.Error("Failed to process event, requeuing",
-		"event_key", event.Key,
-		"error", err,
+	apiClient, err := deploymentrecord.NewClient(
+		cfg.BaseURL,
+		cfg.Organization,
+		clientOpts...,
 	)
-	c.workqueue.AddRateLimited(event)
-
-	return true
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %w", err)
+	}
+	return apiClient, nil
 }
 
-// processEvent processes a single pod event.
-func (c *Controller) processEvent(ctx context.Context, event PodEvent) error {
-	var pod *corev1.Pod
+// newSinks creates the additional sinks configured in cfg.
+func newSinks(cfg *Config) ([]sink.Sink, error) {
+	webhookFields, err := sinkFieldMapping(cfg.WebhookFieldProfile, cfg.WebhookFieldMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook field mapping: %w", err)
+	}
+	queueFields, err := sinkFieldMapping(cfg.QueueFieldProfile, cfg.QueueFieldMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue field mapping: %w", err)
+	}
+	notifyFields, err := sinkFieldMapping(cfg.NotifyFieldProfile, cfg.NotifyFieldMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification field mapping: %w", err)
+	}
 
-	if event.EventType == EventDeleted {
-		// For delete events, use the pod captured at deletion time
-		pod = event.DeletedPod
-		if pod == nil {
-			slog.Error("Delete event missing pod data",
-				"key", event.Key,
-			)
-			return nil
+	var sinks []sink.Sink
+	if cfg.WebhookURL != "" {
+		headers, err := sink.ParseHeaders(cfg.WebhookHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook headers: %w", err)
 		}
-
-		// Check if the parent deployment still exists
-		// If it does, this is just a scale-down event, skip it.
-		//
-		// If a deployment changes image versions, this will not
-		// fire delete/decommissioned events to the remote API.
-		// This is as intended, as the server will keep track of
-		// the (cluster unique) deployment name, and just update
-		// the referenced image digest to the newly observed (via
-		// the create event).
-		deploymentName := getDeploymentName(pod)
-		if deploymentName != "" && c.deploymentExists(ctx, pod.Namespace, deploymentName) {
-			slog.Debug("Deployment still exists, skipping pod delete (scale down)",
-				"namespace", pod.Namespace,
-				"deployment", deploymentName,
-				"pod", pod.Name,
-			)
-			return nil
+		webhookOpts := []sink.WebhookOption{sink.WithWebhookFieldMapping(webhookFields)}
+		if cfg.WebhookTimeout > 0 {
+			webhookOpts = append(webhookOpts, sink.WithTimeout(cfg.WebhookTimeout))
 		}
-	} else {
-		// For create events, get the pod from the informer's cache
-		obj, exists, err := c.podInformer.GetIndexer().GetByKey(event.Key)
+		if cfg.ClientCert != "" {
+			webhookOpts = append(webhookOpts, sink.WithClientCertificate(cfg.ClientCert, cfg.ClientKey))
+		}
+		webhook, err := sink.NewWebhook(cfg.WebhookURL, cfg.WebhookSecret, headers, webhookOpts...)
 		if err != nil {
-			slog.Error("Failed to get pod from cache",
-				"key", event.Key,
-				"error", err,
-			)
-			return nil
+			return nil, fmt.Errorf("failed to create webhook sink: %w", err)
 		}
-		if !exists {
-			// Pod no longer exists in cache, skip processing
-			return nil
+		sinks = append(sinks, webhook)
+	}
+	if cfg.NotifyURL != "" {
+		notifier, err := sink.NewNotifier(cfg.NotifyURL, cfg.NotifyFormat, cfg.NotifyNamespaces,
+			cfg.NotifySelector, cfg.NotifyTemplate, sink.WithFieldMapping(notifyFields))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create notification sink: %w", err)
 		}
-
-		var ok bool
-		pod, ok = obj.(*corev1.Pod)
-		if !ok {
-			slog.Error("Invalid object type in cache",
-				"key", event.Key,
-			)
-			return nil
+		sinks = append(sinks, notifier)
+	}
+	if cfg.GHDeploymentsToken != "" {
+		deployments, err := sink.NewGitHubDeployments(cfg.GHDeploymentsURL, cfg.GHDeploymentsToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GitHub Deployments sink: %w", err)
 		}
+		sinks = append(sinks, deployments)
 	}
-
-	status := deploymentrecord.StatusDeployed
-	if event.EventType == EventDeleted {
-		status = deploymentrecord.StatusDecommissioned
+	if cfg.SQSQueueURL != "" {
+		queue, err := sink.NewSQS(cfg.SQSQueueURL, sink.WithFieldMapping(queueFields))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SQS sink: %w", err)
+		}
+		sinks = append(sinks, queue)
+	}
+	if cfg.SNSTopicARN != "" {
+		topic, err := sink.NewSNS(cfg.SNSTopicARN, sink.WithFieldMapping(queueFields))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SNS sink: %w", err)
+		}
+		sinks = append(sinks, topic)
+	}
+	if cfg.PubSubTopic != "" {
+		topic, err := sink.NewPubSub(cfg.PubSubTopic, sink.WithFieldMapping(queueFields))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Pub/Sub sink: %w", err)
+		}
+		sinks = append(sinks, topic)
 	}
+	return sinks, nil
+}
 
-	var lastErr error
+// sinkFieldMapping returns the field mapping of a sink, nil to keep the
+// canonical field names if profile and renames are both empty.
+func sinkFieldMapping(profile, renames string) (*deploymentrecord.FieldMapping, error) {
+	if profile == "" && renames == "" {
+		return nil, nil
+	}
+	return deploymentrecord.NewFieldMapping(profile, renames)
+}
 
-	// Record info for each container in the pod
-	for _, container := range pod.Spec.Containers {
-		if err := c.recordContainer(ctx, pod, container, status, event.EventType); err != nil {
-			lastErr = err
+// addNamespaceHandlers adds the handlers of the namespace informer
+// used for environment records. Namespaces are cluster scoped, so the
+// namespace filtering is applied in the event handlers rather than
+// on the informer.
+func (c *Controller) addNamespaceHandlers() error {
+	enqueue := func(obj any, eventType string) {
+		ns, ok := obj.(*corev1.Namespace)
+		if !ok {
+			// Handle deleted final state unknown
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				return
+			}
+			ns, ok = tombstone.Obj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+		}
+		if !c.namespaceTracked(ns.Name) {
+			return
 		}
+		c.queueFor(eventType).Add(PodEvent{
+			Key:       ns.Name,
+			EventType: eventType,
+			UID:       ns.UID,
+		})
 	}
 
-	// Also record init containers
-	for _, container := range pod.Spec.InitContainers {
-		if err := c.recordContainer(ctx, pod, container, status, event.EventType); err != nil {
-			lastErr = err
-		}
+	_, err := c.nsInformer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj any, isInInitialList bool) {
+			// The namespaces of the initial list existed before
+			// the controller started, and were already recorded
+			if isInInitialList {
+				return
+			}
+			enqueue(obj, EventNamespaceCreated)
+		},
+		DeleteFunc: func(obj any) {
+			enqueue(obj, EventNamespaceDeleted)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add namespace event handlers: %w", err)
 	}
 
-	return lastErr
+	return nil
 }
 
-// deploymentExists checks if a deployment exists in the cluster.
-func (c *Controller) deploymentExists(ctx context.Context, namespace, name string) bool {
-	_, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
-	if err != nil {
-		if k8serrors.IsNotFound(err) {
-			return false
-		}
-		// On error, assume it exists to be safe
-		// (avoid false decommissions)
-		slog.Warn("Failed to check if deployment exists, assuming it does",
-			"namespace", namespace,
-			"deployment", name,
-			"error", err,
-		)
-		return true
+// namespaceTracked returns true if pods in the namespace are tracked
+// by the controller.
+func (c *Controller) namespaceTracked(ns string) bool {
+	if c.excludedNs.matches(ns) {
+		return false
+	}
+	if reloaded := c.reloadedNs.Load(); reloaded != nil && reloaded.matches(ns) {
+		return false
+	}
+	if !c.includedNs.empty() {
+		return c.includedNs.matches(ns)
 	}
 	return true
 }
 
+// Reload applies the settings of cfg that can be changed at runtime:
+// the templates, the environment and cluster names, opt-in mode, the
+// additional excluded namespaces, the container exclusion rules, the
+// metadata allowlists, the webhook, notification and GitHub Deployments
+// sinks and the retry limit. Changes
+// to other settings only take effect on restart.
+func (c *Controller) Reload(cfg *Config) error {
+	if err := cfg.ValidateTemplates(); err != nil {
+		return err
+	}
+	if cfg.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative: %d", cfg.MaxRetries)
+	}
+	reloadedNs, err := newNamespaceList(cfg.ExcludeNamespaces)
+	if err != nil {
+		return err
+	}
+
+	next := *c.cfg.Load()
+	sinksChanged := cfg.WebhookURL != next.WebhookURL ||
+		cfg.WebhookSecret != next.WebhookSecret ||
+		cfg.WebhookHeaders != next.WebhookHeaders ||
+		cfg.GHDeploymentsToken != next.GHDeploymentsToken ||
+		cfg.GHDeploymentsURL != next.GHDeploymentsURL ||
+		cfg.NotifyURL != next.NotifyURL ||
+		cfg.NotifyFormat != next.NotifyFormat ||
+		cfg.NotifyNamespaces != next.NotifyNamespaces ||
+		cfg.NotifySelector != next.NotifySelector ||
+		cfg.NotifyTemplate != next.NotifyTemplate ||
+		cfg.SQSQueueURL != next.SQSQueueURL ||
+		cfg.SNSTopicARN != next.SNSTopicARN ||
+		cfg.PubSubTopic != next.PubSubTopic ||
+		cfg.WebhookFieldProfile != next.WebhookFieldProfile ||
+		cfg.WebhookFieldMapping != next.WebhookFieldMapping ||
+		cfg.QueueFieldProfile != next.QueueFieldProfile ||
+		cfg.QueueFieldMapping != next.QueueFieldMapping ||
+		cfg.NotifyFieldProfile != next.NotifyFieldProfile ||
+		cfg.NotifyFieldMapping != next.NotifyFieldMapping
+	var sinks []sink.Sink
+	if sinksChanged {
+		next.WebhookURL = cfg.WebhookURL
+		next.WebhookSecret = cfg.WebhookSecret
+		next.WebhookHeaders = cfg.WebhookHeaders
+		next.GHDeploymentsToken = cfg.GHDeploymentsToken
+		next.GHDeploymentsURL = cfg.GHDeploymentsURL
+		next.NotifyURL = cfg.NotifyURL
+		next.NotifyFormat = cfg.NotifyFormat
+		next.NotifyNamespaces = cfg.NotifyNamespaces
+		next.NotifySelector = cfg.NotifySelector
+		next.NotifyTemplate = cfg.NotifyTemplate
+		next.SQSQueueURL = cfg.SQSQueueURL
+		next.SNSTopicARN = cfg.SNSTopicARN
+		next.PubSubTopic = cfg.PubSubTopic
+		next.WebhookFieldProfile = cfg.WebhookFieldProfile
+		next.WebhookFieldMapping = cfg.WebhookFieldMapping
+		next.QueueFieldProfile = cfg.QueueFieldProfile
+		next.QueueFieldMapping = cfg.QueueFieldMapping
+		next.NotifyFieldProfile = cfg.NotifyFieldProfile
+		next.NotifyFieldMapping = cfg.NotifyFieldMapping
+		if sinks, err = newSinks(&next); err != nil {
+			return err
+		}
+	}
+
+	next.Template = cfg.Template
+	next.NamespaceTemplates = cfg.NamespaceTemplates
+	next.LogicalEnvironment = cfg.LogicalEnvironment
+	next.PhysicalEnvironment = cfg.PhysicalEnvironment
+	next.Cluster = cfg.Cluster
+	next.OptIn = cfg.OptIn
+	next.ExcludeNamespaces = cfg.ExcludeNamespaces
+	next.ExcludeContainers = cfg.ExcludeContainers
+	next.ExcludeImagePrefixes = cfg.ExcludeImagePrefixes
+	next.MetadataLabels = cfg.MetadataLabels
+	next.MetadataAnnotations = cfg.MetadataAnnotations
+	next.MaxRetries = cfg.MaxRetries
+	c.cfg.Store(&next)
+	if sinksChanged {
+		c.sinks.Store(&sinks)
+	}
+	c.reloadedNs.Store(&reloadedNs)
+	c.setConfigInfo(&next)
+
+	slog.Info("Reloaded configuration",
+		"template", next.Template,
+		"logical_environment", next.LogicalEnvironment,
+		"physical_environment", next.PhysicalEnvironment,
+		"cluster", next.Cluster,
+		"opt_in", next.OptIn,
+		"exclude_namespaces", next.ExcludeNamespaces,
+		"max_retries", next.MaxRetries,
+		"sinks_changed", sinksChanged,
+	)
+	return nil
+}
+
+// Run starts the controller.
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	defer runtime.HandleCrash()
+	defer c.workqueue.ShutDown()
+	if c.deleteQueue != c.workqueue {
+		defer c.deleteQueue.ShutDown()
+	}
+
+	c.refreshServerVersion()
+	go func() {
+		ticker := time.NewTicker(serverVersionRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.refreshServerVersion()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if err := c.startInformers(ctx); err != nil {
+		return err
+	}
+	c.lastProgress.Store(time.Now().UnixNano())
+	c.synced.Store(true)
+
+	if c.batcher != nil {
+		go c.batcher.run(ctx)
+	}
+
+	if c.cacheStore != nil {
+		c.loadCache(ctx)
+		c.loadDeploymentRecords(ctx)
+		go c.persistCache(ctx)
+	}
+	if c.statusStore != nil {
+		c.loadStatusResources(ctx)
+	}
+
+	if c.retryQueue != nil {
+		go c.replayRetryQueue(ctx)
+	}
+
+	if c.cfg.Load().SkipInitialSync {
+		slog.Info("Skipping initial sync, only pods changing from now on are recorded")
+	} else {
+		c.initialSync()
+	}
+
+	go wait.UntilWithContext(ctx, c.updateTrackedDeployments, trackedDeploymentsInterval)
+	if interval := c.cfg.Load().ChaosResyncInterval; interval > 0 {
+		slog.Warn("Chaos resync storms enabled, all running pods are enqueued periodically",
+			"interval", interval,
+		)
+		go wait.UntilWithContext(ctx, c.resyncStorm, interval)
+	}
+
+	slog.Info("Starting workers",
+		"count", workers,
+	)
+
+	// Start workers
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, func(ctx context.Context) {
+			c.runWorker(ctx, c.workqueue)
+		}, time.Second)
+	}
+	if c.deleteQueue != c.workqueue {
+		deleteWorkers := c.cfg.Load().DeleteWorkers
+		slog.Info("Starting delete workers",
+			"count", deleteWorkers,
+		)
+		for i := 0; i < deleteWorkers; i++ {
+			go wait.UntilWithContext(ctx, func(ctx context.Context) {
+				c.runWorker(ctx, c.deleteQueue)
+			}, time.Second)
+		}
+	}
+
+	slog.Info("Controller started")
+
+	<-ctx.Done()
+	slog.Info("Shutting down workers")
+
+	return nil
+}
+
+// initialSync enqueues a created event for each running pod of a
+// tracked workload in the informer cache, so the records of the pods
+// running before the controller started are posted. Records already
+// in the observation cache are not posted again.
+func (c *Controller) initialSync() {
+	count, err := c.enqueueRunningPods()
+	if err != nil {
+		slog.Error("Failed to list pods for the initial sync",
+			"error", err)
+		return
+	}
+	slog.Info("Initial sync enqueued running pods",
+		"count", count)
+}
+
+// enqueueRunningPods enqueues a created event for each running pod of
+// a tracked workload in the informer cache, and returns their number.
+func (c *Controller) enqueueRunningPods() (int, error) {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || !c.namespaceTracked(pod.Namespace) ||
+			!c.podStarted(pod) || c.resolveWorkload(pod).Name == "" {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(pod)
+		if err != nil {
+			continue
+		}
+		c.workqueue.Add(PodEvent{
+			Key:       key,
+			EventType: EventCreated,
+		})
+		count++
+	}
+	return count, nil
+}
+
+// startInformers starts the informers, until ctx is cancelled, and
+// waits for their caches to sync.
+func (c *Controller) startInformers(ctx context.Context) error {
+	slog.Info("Starting informers",
+		"count", len(c.informers),
+	)
+
+	// Start the informers
+	var synced []cache.InformerSynced
+	for _, informer := range c.informers {
+		go informer.Run(ctx.Done())
+		synced = append(synced, informer.HasSynced)
+	}
+	if c.nsInformer != nil {
+		slog.Info("Starting namespace informer")
+		go c.nsInformer.Run(ctx.Done())
+		synced = append(synced, c.nsInformer.HasSynced)
+	}
+	if c.nodeInformer != nil {
+		slog.Info("Starting node informer")
+		go c.nodeInformer.Run(ctx.Done())
+		synced = append(synced, c.nodeInformer.HasSynced)
+	}
+
+	// Wait for the caches to be synced
+	slog.Info("Waiting for informer caches to sync")
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return errors.New("timed out waiting for caches to sync")
+	}
+	c.informersSynced = synced
+
+	return nil
+}
+
+// Healthy returns an error if the controller appears wedged, i.e.
+// events are queued but no worker has made progress for longer than
+// stallTimeout.
+func (c *Controller) Healthy() error {
+	if !c.synced.Load() {
+		// Still starting up, covered by readiness
+		return nil
+	}
+
+	last := time.Unix(0, c.lastProgress.Load())
+	queued := c.workqueue.Len()
+	if c.deleteQueue != nil && c.deleteQueue != c.workqueue {
+		queued += c.deleteQueue.Len()
+	}
+	if queued > 0 && time.Since(last) > stallTimeout {
+		return fmt.Errorf("workqueue stalled: %d events queued, no progress since %s",
+			queued, last.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Ready returns an error if the controller is not ready to process
+// events: the informer caches are not synced, or the API could not be
+// reached on the last attempt.
+func (c *Controller) Ready() error {
+	if !c.synced.Load() {
+		return errors.New("informer caches not synced")
+	}
+	for _, synced := range c.informersSynced {
+		if !synced() {
+			return errors.New("informer caches not synced")
+		}
+	}
+	if !c.apiClient.Reachable() {
+		return errors.New("deployment record API unreachable")
+	}
+
+	return nil
+}
+
+// CheckAPI verifies that the deployment record API is reachable and
+// accepts the configured credentials.
+func (c *Controller) CheckAPI(ctx context.Context) error {
+	return c.apiClient.Check(ctx)
+}
+
+// refreshServerVersion updates the cached Kubernetes server version.
+// On failure the previously cached version is kept.
+func (c *Controller) refreshServerVersion() {
+	info, err := c.clientset.Discovery().ServerVersion()
+	if err != nil {
+		slog.Warn("Failed to get Kubernetes server version",
+			"error", err,
+		)
+		return
+	}
+	v := info.GitVersion
+	c.serverVersion.Store(&v)
+}
+
+// getServerVersion returns the cached Kubernetes server version, or
+// an empty string if it is not known.
+func (c *Controller) getServerVersion() string {
+	if v := c.serverVersion.Load(); v != nil {
+		return *v
+	}
+	return ""
+}
+
+// queueFor returns the queue of events of the type.
+func (c *Controller) queueFor(eventType string) workqueue.TypedRateLimitingInterface[PodEvent] {
+	if c.deleteQueue != nil &&
+		(eventType == EventDeleted || eventType == EventNamespaceDeleted ||
+			eventType == EventNamespaceTerminating || eventType == EventDeploymentDeleted) {
+		return c.deleteQueue
+	}
+	return c.workqueue
+}
+
+// runWorker runs a worker to process items from the queue.
+func (c *Controller) runWorker(ctx context.Context, queue workqueue.TypedRateLimitingInterface[PodEvent]) {
+	for c.processNextItem(ctx, queue) {
+	}
+}
+
+// processNextItem processes the next item from the queue.
+func (c *Controller) processNextItem(ctx context.Context, queue workqueue.TypedRateLimitingInterface[PodEvent]) bool {
+	event, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(event)
+	defer func() {
+		c.lastProgress.Store(time.Now().UnixNano())
+	}()
+
+	ctx, span := tracing.Tracer(tracerName).Start(ctx, "processEvent", trace.WithAttributes(
+		attribute.String("event_type", event.EventType),
+		attribute.String("event_key", event.Key),
+	))
+	start := time.Now()
+	err := c.processEvent(ctx, event)
+	dur := time.Since(start)
+	tracing.End(span, err)
+
+	retries := queue.NumRequeues(event)
+	if err == nil {
+		metrics.EventsProcessedOk.WithLabelValues(event.EventType).Inc()
+		metrics.ObserveWithTrace(ctx, metrics.EventsProcessedTimer.WithLabelValues("ok"), dur.Seconds())
+		metrics.EventRetries.WithLabelValues(event.EventType).Observe(float64(retries))
+
+		queue.Forget(event)
+		return true
+	}
+	metrics.ObserveWithTrace(ctx, metrics.EventsProcessedTimer.WithLabelValues("failed"), dur.Seconds())
+	metrics.EventsProcessedFailed.WithLabelValues(event.EventType).Inc()
+
+	// Give up on events that keep failing
+	if maxRetries := c.cfg.Load().MaxRetries; maxRetries > 0 && retries >= maxRetries {
+		metrics.EventRetries.WithLabelValues(event.EventType).Observe(float64(retries))
+		c.deadLetter(event, retries, err)
+		queue.Forget(event)
+		return true
+	}
+
+	// Requeue on error with rate limiting
+	slog.Error("Failed to process event, requeuing",
+		"event_key", event.Key,
+		"retries", retries,
+		"error", err,
+	)
+	metrics.EventsRequeued.WithLabelValues(event.EventType).Inc()
+	queue.AddRateLimited(event)
+
+	return true
+}
+
+// deadLetter handles an event that has exhausted its retries. The
+// event is dropped from the work queue, the records that failed to
+// post are kept in the dead letter store.
+func (c *Controller) deadLetter(event PodEvent, retries int, err error) {
+	slog.Error("Failed to process event, retries exhausted, dropping",
+		"event_key", event.Key,
+		"event_type", event.EventType,
+		"retries", retries,
+		"error", err,
+	)
+	metrics.EventsDropped.WithLabelValues(event.EventType).Inc()
+
+	var letters []DeadLetter
+	for _, record := range failedRecords(err) {
+		letters = append(letters, DeadLetter{
+			Record:    record,
+			EventKey:  event.Key,
+			EventType: event.EventType,
+			Error:     err.Error(),
+			Time:      time.Now(),
+		})
+	}
+	c.deadLetters.add(letters...)
+}
+
+// processEvent processes a single pod event.
+func (c *Controller) processEvent(ctx context.Context, event PodEvent) error {
+	var pod *corev1.Pod
+
+	switch event.EventType {
+	case EventNamespaceCreated:
+		return c.recordEnvironment(ctx, event, deploymentrecord.StatusCreated)
+	case EventNamespaceDeleted:
+		return c.recordEnvironment(ctx, event, deploymentrecord.StatusDecommissioned)
+	case EventRolloutComplete:
+		return c.enqueueRollout(event.Key)
+	case EventNamespaceTerminating:
+		return c.decommissionNamespace(ctx, event.Key)
+	case EventDeploymentDeleted:
+		return c.decommissionDeployment(ctx, event.Key)
+	}
+
+	if event.EventType == EventDeleted {
+		// For delete events, use the pod captured at deletion time
+		pod = event.DeletedPod
+		if pod == nil {
+			slog.Error("Delete event missing pod data",
+				"key", event.Key,
+			)
+			return nil
+		}
+
+		// The records of a deleted namespace are decommissioned
+		// in bulk, without looking up each workload
+		if c.namespaceDecommissioned(pod.Namespace) {
+			slog.Debug("Namespace decommissioned, skipping pod delete",
+				"namespace", pod.Namespace,
+				"pod", pod.Name,
+			)
+			return nil
+		}
+
+		if !c.workloadRemoved(ctx, pod) {
+			return nil
+		}
+	} else {
+		// For create events, get the pod from the informer's cache
+		ns, name, err := cache.SplitMetaNamespaceKey(event.Key)
+		if err != nil {
+			slog.Error("Invalid pod key",
+				"key", event.Key,
+				"error", err,
+			)
+			return nil
+		}
+		pod, err = c.podLister.Pods(ns).Get(name)
+		if k8serrors.IsNotFound(err) {
+			// Pod no longer exists in cache, skip processing,
+			// but process a pod whose event it coalesced
+			c.replaceCoalesced(event.Key)
+			return nil
+		}
+		if err != nil {
+			slog.Error("Failed to get pod from cache",
+				"key", event.Key,
+				"error", err,
+			)
+			return nil
+		}
+
+		// In rollout mode, the pods of a Deployment are recorded
+		// once its rollout completed
+		if c.cfg.Load().RolloutStatus && !c.rolloutComplete(pod) {
+			slog.Debug("Deployment rollout in progress, skipping pod",
+				"namespace", pod.Namespace,
+				"pod", pod.Name,
+			)
+			return nil
+		}
+	}
+
+	status := deploymentrecord.StatusDeployed
+	if event.EventType == EventDeleted {
+		status = deploymentrecord.StatusDecommissioned
+	}
+
+	var errs []error
+
+	// Record info for each container in the pod
+	for _, container := range pod.Spec.Containers {
+		if err := c.recordContainer(ctx, pod, container, status, event.EventType); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Also record init containers
+	for _, container := range pod.Spec.InitContainers {
+		if err := c.recordContainer(ctx, pod, container, status, event.EventType); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.cfg.Load().EphemeralContainers {
+		for _, container := range pod.Spec.EphemeralContainers {
+			if err := c.recordContainer(ctx, pod, corev1.Container(container.EphemeralContainerCommon), status, event.EventType); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// workloadRemoved returns true if the workload owning the deleted pod
+// is gone, meaning that the pod's containers should be
+// decommissioned.
+func (c *Controller) workloadRemoved(ctx context.Context, pod *corev1.Pod) bool {
+	wl := c.resolveWorkload(pod)
+
+	switch wl.Kind {
+	case kindDeployment:
+		// Use the ReplicaSet cache to tell scale-downs and
+		// rollovers apart from real deletions, without calling
+		// the API server.
+		//
+		// If a deployment changes image versions, this will not
+		// fire delete/decommissioned events to the remote API.
+		// This is as intended, as the server will keep track of
+		// the (cluster unique) deployment name, and just update
+		// the referenced image digest to the newly observed (via
+		// the create event).
+		reason := c.classifyPodDelete(pod)
+		if reason != deleteRemoved {
+			slog.Debug("Deployment still has replicasets, skipping pod delete",
+				"namespace", pod.Namespace,
+				"deployment", wl.Name,
+				"pod", pod.Name,
+				"reason", reason,
+			)
+			return false
+		}
+	case kindStatefulSet, kindDaemonSet, kindJob, kindCronJob, kindDeploymentConfig:
+		// StatefulSet pods are deleted on scale-down and replaced
+		// in place on rolling updates, DaemonSet pods come and go
+		// with nodes, Job pods are pruned with their Job and the
+		// pods of a DeploymentConfig are replaced with a new
+		// ReplicationController on each rollout. Only the workload
+		// itself going away is a decommission.
+	case kindKnativeService:
+		// Revisions scale to zero and back, only the Revision
+		// being garbage collected is a decommission.
+		wl = knativeRevisionWorkload(pod)
+	default:
+		return false
+	}
+
+	// Confirm that the workload is really gone before
+	// decommissioning.
+	if c.workloadExists(ctx, pod.Namespace, wl) {
+		slog.Debug("Workload still exists, skipping pod delete (scale down)",
+			"namespace", pod.Namespace,
+			"kind", wl.Kind,
+			"workload", wl.Name,
+			"pod", pod.Name,
+		)
+		return false
+	}
+
+	return true
+}
+
+// workloadExists checks if a workload exists in the cluster.
+// Deployments are looked up in the informer cache first, so pod
+// deletes don't cost a request each; the API server is only asked on
+// a cache miss, as the cache may lag behind a deployment just
+// created.
+func (c *Controller) workloadExists(ctx context.Context, namespace string, wl workload) bool {
+	var obj metav1.Object
+	var err error
+
+	switch wl.Kind {
+	case kindDeployment:
+		if c.deploymentLister != nil {
+			if d, err := c.deploymentLister.Deployments(namespace).Get(wl.Name); err == nil {
+				return d.DeletionTimestamp == nil
+			}
+		}
+		obj, err = c.clientset.AppsV1().Deployments(namespace).Get(ctx, wl.Name, metav1.GetOptions{})
+	case kindStatefulSet:
+		obj, err = c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, wl.Name, metav1.GetOptions{})
+	case kindDaemonSet:
+		obj, err = c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, wl.Name, metav1.GetOptions{})
+	case kindJob:
+		obj, err = c.clientset.BatchV1().Jobs(namespace).Get(ctx, wl.Name, metav1.GetOptions{})
+	case kindCronJob:
+		obj, err = c.clientset.BatchV1().CronJobs(namespace).Get(ctx, wl.Name, metav1.GetOptions{})
+	case kindDeploymentConfig:
+		if c.dynamicClient == nil {
+			// Without a dynamic client DeploymentConfigs can't be
+			// looked up, so they are never decommissioned
+			return true
+		}
+		obj, err = c.dynamicClient.Resource(deploymentConfigGVR).Namespace(namespace).Get(ctx, wl.Name, metav1.GetOptions{})
+	case kindKnativeRevision:
+		if c.dynamicClient == nil {
+			return true
+		}
+		obj, err = c.dynamicClient.Resource(knativeRevisionGVR).Namespace(namespace).Get(ctx, wl.Name, metav1.GetOptions{})
+	default:
+		// Unknown kinds are never decommissioned
+		return true
+	}
+
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false
+		}
+		// On error, assume it exists to be safe
+		// (avoid false decommissions)
+		slog.Warn("Failed to check if workload exists, assuming it does",
+			"namespace", namespace,
+			"kind", wl.Kind,
+			"workload", wl.Name,
+			"error", err,
+		)
+		return true
+	}
+	// A workload being deleted (foreground cascading) still
+	// shows up until all its dependents are gone.
+	return obj.GetDeletionTimestamp() == nil
+}
+
+// classifyPodDelete uses the ReplicaSet lister to determine why a
+// deployment owned pod was deleted.
+func (c *Controller) classifyPodDelete(pod *corev1.Pod) podDeleteReason {
+	rsName := getReplicaSetName(pod)
+	if rsName == "" {
+		return deleteRemoved
+	}
+
+	rs, err := c.rsLister.ReplicaSets(pod.Namespace).Get(rsName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		// On error, assume the replicaset exists to be safe
+		// (avoid false decommissions)
+		slog.Warn("Failed to get replicaset from cache, assuming it exists",
+			"namespace", pod.Namespace,
+			"replicaset", rsName,
+			"error", err,
+		)
+		return deleteScaleDown
+	}
+
+	var owner string
+	var revision int64
+	rsAlive := err == nil && rs.DeletionTimestamp == nil
+	if rsAlive {
+		owner = getReplicaSetDeploymentName(rs)
+		revision = getReplicaSetRevision(rs)
+		if owner == "" {
+			// Bare replicaset, not part of a rollout
+			return deleteScaleDown
+		}
+	} else {
+		// The replicaset is gone (or going), look at its siblings
+		// to tell history pruning apart from a removed deployment.
+		owner = getDeploymentName(pod)
+	}
+
+	all, err := c.rsLister.ReplicaSets(pod.Namespace).List(labels.Everything())
+	if err != nil {
+		slog.Warn("Failed to list replicasets from cache, assuming deployment exists",
+			"namespace", pod.Namespace,
+			"deployment", owner,
+			"error", err,
+		)
+		return deleteScaleDown
+	}
+
+	var hasSibling bool
+	for _, s := range all {
+		if s.Name == rsName ||
+			s.DeletionTimestamp != nil ||
+			getReplicaSetDeploymentName(s) != owner {
+			continue
+		}
+		hasSibling = true
+		if getReplicaSetRevision(s) > revision {
+			return deleteRollover
+		}
+	}
+
+	switch {
+	case rsAlive:
+		// The pod's own replicaset is the newest revision
+		return deleteScaleDown
+	case hasSibling:
+		return deleteRollover
+	default:
+		return deleteRemoved
+	}
+}
+
 // recordContainer records a single container's deployment info.
-func (c *Controller) recordContainer(ctx context.Context, pod *corev1.Pod, container corev1.Container, status, eventType string) error {
-	dn := getARDeploymentName(pod, container, c.cfg.Template)
-	digest := getContainerDigest(pod, container.Name)
+func (c *Controller) recordContainer(ctx context.Context, pod *corev1.Pod, container corev1.Container, status, eventType string) (err error) {
+	cfg := c.cfg.Load()
+	if containerExcluded(cfg, container) {
+		slog.Debug("Skipping excluded container",
+			"namespace", pod.Namespace,
+			"pod", pod.Name,
+			"container", container.Name,
+			"image", container.Image,
+		)
+		return nil
+	}
+
+	wl := c.resolveWorkload(pod)
+	dn := getARDeploymentName(pod, container, wl, c.template(cfg, pod.Namespace), cfg.Cluster)
+	digest := c.containerDigest(pod, container)
+
+	ctx, span := tracing.Tracer(tracerName).Start(ctx, "recordContainer", trace.WithAttributes(
+		attribute.String("k8s.container.name", container.Name),
+		attribute.String("deployment_name", dn),
+		attribute.String("status", status),
+	))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if dn != "" && digest == "" {
+		digest = c.resolveImageID(ctx, cfg, pod, container)
+	}
+	if dn == "" || digest == "" {
+		slog.Debug("Skipping container: missing deployment name or digest",
+			"namespace", pod.Namespace,
+			"pod", pod.Name,
+			"container", container.Name,
+			"deployment_name", dn,
+			"has_digest", digest != "",
+		)
+		return nil
+	}
+
+	cacheKey := getCacheKey(dn, digest)
+
+	newRecord := func() *deploymentrecord.DeploymentRecord {
+		return c.newRecord(cfg, pod, container, dn, digest, status)
+	}
+
+	// Check if we've already recorded this deployment
+	var partial bool
+	switch status {
+	case deploymentrecord.StatusDeployed:
+		// With partial rollouts, a pod of an incomplete rollout is
+		// recorded as partially deployed, and as deployed once the
+		// rollout completed
+		partial = cfg.PartialRollouts && !c.rolloutComplete(pod)
+		_, exists := c.observedDeployments.load(cacheKey)
+		if !exists && partial {
+			_, exists = c.partialDeployments.Load(cacheKey)
+		}
+		if exists {
+			c.associateRecord(pod, wl, cacheKey, newRecord)
+			slog.Debug("Deployment already observed, skipping post",
+				"deployment_name", dn,
+				"digest", digest,
+			)
+			c.auditSkip(eventType, pod.Namespace, "already_observed", newRecord)
+			return nil
+		}
+	case deploymentrecord.StatusDecommissioned:
+		// For delete, check if we've seen it - if not, no need to decommission
+		if !c.recorded(cacheKey) {
+			slog.Debug("Deployment not in cache, skipping decommission",
+				"deployment_name", dn,
+				"digest", digest,
+			)
+			c.auditSkip(eventType, pod.Namespace, "not_observed", newRecord)
+			return nil
+		}
+		if c.deploymentRunning(cfg, pod, cacheKey) {
+			slog.Debug("Deployment still running in another pod, skipping decommission",
+				"deployment_name", dn,
+				"digest", digest,
+			)
+			c.auditSkip(eventType, pod.Namespace, "still_running", newRecord)
+			return nil
+		}
+	default:
+		return fmt.Errorf("invalid status: %s", status)
+	}
+
+	record := newRecord()
+	if partial {
+		c.setPartiallyDeployed(pod, record)
+	}
+	if status == deploymentrecord.StatusDeployed && cfg.VerifyDigests {
+		c.verifyDigest(ctx, pod.Namespace, container.Image, record)
+	}
+	if status == deploymentrecord.StatusDeployed && cfg.CheckSignatures {
+		c.checkSignatures(ctx, pod.Namespace, container.Image, record)
+	}
+	if status == deploymentrecord.StatusDeployed && cfg.PlatformDigests {
+		c.platformDigest(ctx, container.Image, record)
+	}
+
+	start := time.Now()
+	err = c.postRecord(ctx, record)
+	if err == nil {
+		err = c.sendToSinks(ctx, sink.Event{
+			Type:       sink.EventDeploymentRecord,
+			Record:     record,
+			Repository: c.sourceRepository(pod),
+			Namespace:  pod.Namespace,
+			Labels:     pod.Labels,
+		})
+	}
+	c.audit(auditSourceEvent, eventType, pod.Namespace, record, time.Since(start), err)
+	c.updateStatusResource(ctx, pod.Namespace, wl, container.Name, record, err)
+	if err != nil {
+		metrics.RecordsPostedFailed.WithLabelValues(pod.Namespace, record.Status).Inc()
+
+		// Make sure to not retry on client error messages
+		var clientErr *deploymentrecord.ClientError
+		if errors.As(err, &clientErr) {
+			c.dequeueRetry(record)
+			slog.Warn("Failed to post record",
+				"event_type", eventType,
+				"name", record.Name,
+				"deployment_name", record.DeploymentName,
+				"status", record.Status,
+				"digest", record.Digest,
+				"error", err,
+			)
+			return nil
+		}
+
+		slog.Error("Failed to post record",
+			"event_type", eventType,
+			"name", record.Name,
+			"deployment_name", record.DeploymentName,
+			"status", record.Status,
+			"digest", record.Digest,
+			"error", err,
+		)
+		c.enqueueRetry(record)
+		return &postError{record: record, err: err}
+	}
+	c.dequeueRetry(record)
+	metrics.RecordsPostedOk.WithLabelValues(pod.Namespace, record.Status).Inc()
+
+	slog.Info("Posted record",
+		"event_type", eventType,
+		"name", record.Name,
+		"deployment_name", record.DeploymentName,
+		"status", record.Status,
+		"digest", record.Digest,
+	)
+
+	// Update cache after successful post
+	c.observeRecord(record)
+	if status == deploymentrecord.StatusDeployed {
+		c.associateRecord(pod, wl, cacheKey, func() *deploymentrecord.DeploymentRecord {
+			return record
+		})
+	}
+
+	return nil
+}
+
+// newRecord creates the deployment record of a container.
+func (c *Controller) newRecord(cfg *Config, pod *corev1.Pod, container corev1.Container, dn, digest, status string) *deploymentrecord.DeploymentRecord {
+	// Extract image name and tag
+	imageName, tag := image.ExtractName(container.Image)
+	if cfg.NormalizeImageNames {
+		imageName = image.NormalizeName(imageName)
+	}
+
+	record := deploymentrecord.NewDeploymentRecord(
+		imageName,
+		digest,
+		tag,
+		cfg.LogicalEnvironment,
+		cfg.PhysicalEnvironment,
+		cfg.Cluster,
+		status,
+		dn,
+	)
+	record.TrackerVersion = version.Get()
+	record.KubernetesVersion = c.getServerVersion()
+	record.ObservedAt = time.Now().UTC()
+	record.Source = recordSource + version.Get()
+	record.PodUID = string(pod.UID)
+	record.PodTemplateHash = pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	record.Revision = c.podRevision(pod)
+	record.CommitSHA = c.commitSHA(cfg, pod)
+	record.Metadata = c.recordMetadata(cfg, pod)
+	if cfg.RecordResources {
+		record.Replicas = c.deploymentReplicas(pod)
+		record.Resources = containerResources(container)
+	}
+	if cfg.NodeTopology {
+		record.Topology = c.nodeTopology(pod)
+	}
+	if cfg.NodePlatform || cfg.PlatformDigests {
+		record.Platform = c.nodePlatform(pod)
+	}
+	if cfg.GitOpsApps {
+		record.GitOps = c.gitOpsApp(pod)
+	}
+
+	return record
+}
+
+// postRecord posts the record, through the batcher if batch posting
+// is enabled.
+func (c *Controller) postRecord(ctx context.Context, record *deploymentrecord.DeploymentRecord) error {
+	if c.batcher != nil {
+		return c.batcher.submit(ctx, record)
+	}
+	return c.apiClient.PostOne(ctx, record)
+}
+
+// enqueueRetry keeps a record that failed to post in the retry queue,
+// if enabled, so it survives the event being dropped or a restart.
+func (c *Controller) enqueueRetry(record *deploymentrecord.DeploymentRecord) {
+	if c.retryQueue == nil {
+		return
+	}
+	if err := c.retryQueue.put(record); err != nil {
+		slog.Warn("Failed to add record to the retry queue",
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+			"error", err,
+		)
+		return
+	}
+	c.updateRetryQueueSize()
+}
 
-	if dn == "" || digest == "" {
-		slog.Debug("Skipping container: missing deployment name or digest",
-			"namespace", pod.Namespace,
-			"pod", pod.Name,
-			"container", container.Name,
-			"deployment_name", dn,
-			"has_digest", digest != "",
+// dequeueRetry removes a record from the retry queue, if enabled.
+func (c *Controller) dequeueRetry(record *deploymentrecord.DeploymentRecord) {
+	if c.retryQueue == nil {
+		return
+	}
+	removed, err := c.retryQueue.remove(record)
+	if err != nil {
+		slog.Warn("Failed to remove record from the retry queue",
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+			"error", err,
 		)
-		return nil
+		return
 	}
+	if removed {
+		c.updateRetryQueueSize()
+	}
+}
 
-	cacheKey := getCacheKey(dn, digest)
+// updateRetryQueueSize updates the retry queue size metric.
+func (c *Controller) updateRetryQueueSize() {
+	if n, err := c.retryQueue.len(); err == nil {
+		metrics.RetryQueueRecords.Set(float64(n))
+	}
+}
 
-	// Check if we've already recorded this deployment
-	switch status {
-	case deploymentrecord.StatusDeployed:
-		if _, exists := c.observedDeployments.Load(cacheKey); exists {
-			slog.Debug("Deployment already observed, skipping post",
-				"deployment_name", dn,
-				"digest", digest,
+// replayRetryQueue replays the records in the retry queue at startup
+// and then periodically, until ctx is cancelled.
+func (c *Controller) replayRetryQueue(ctx context.Context) {
+	ticker := time.NewTicker(retryQueueReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		c.replayRetries(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// replayRetries posts the records in the retry queue, oldest first.
+// Posted records and records rejected by the API are removed from the
+// queue. The replay stops at the first other failure, as the API is
+// most likely still unavailable.
+func (c *Controller) replayRetries(ctx context.Context) {
+	records, err := c.retryQueue.list()
+	if err != nil {
+		slog.Warn("Failed to read the retry queue",
+			"error", err,
+		)
+		return
+	}
+	c.updateRetryQueueSize()
+	if len(records) == 0 {
+		return
+	}
+
+	slog.Info("Replaying retry queue",
+		"count", len(records),
+	)
+	for _, record := range records {
+		start := time.Now()
+		err := c.apiClient.PostOne(ctx, record)
+		if err == nil {
+			err = c.sendToSinks(ctx, sink.Event{
+				Type:   sink.EventDeploymentRecord,
+				Record: record,
+			})
+		}
+		c.audit(auditSourceRetryQueue, "", "", record, time.Since(start), err)
+		var clientErr *deploymentrecord.ClientError
+		switch {
+		case err == nil:
+			metrics.RetryQueueReplayed.Inc()
+			c.observeRecord(record)
+		case errors.As(err, &clientErr):
+			slog.Warn("Dropping record rejected by the API from the retry queue",
+				"deployment_name", record.DeploymentName,
+				"digest", record.Digest,
+				"error", err,
 			)
-			return nil
+		default:
+			slog.Warn("Failed to replay retry queue, retrying later",
+				"error", err,
+			)
+			return
 		}
+		c.dequeueRetry(record)
+	}
+}
+
+// recorded returns true if a record of the cache key was posted as
+// deployed or partially deployed, and not decommissioned since.
+func (c *Controller) recorded(cacheKey string) bool {
+	if _, ok := c.observedDeployments.load(cacheKey); ok {
+		return true
+	}
+	_, ok := c.partialDeployments.Load(cacheKey)
+	return ok
+}
+
+// observeRecord updates the observation cache with a record posted
+// outside of event processing.
+func (c *Controller) observeRecord(record *deploymentrecord.DeploymentRecord) {
+	cacheKey := getCacheKey(record.DeploymentName, record.Digest)
+	switch record.Status {
 	case deploymentrecord.StatusDecommissioned:
-		// For delete, check if we've seen it - if not, no need to decommission
-		if _, exists := c.observedDeployments.Load(cacheKey); !exists {
-			slog.Debug("Deployment not in cache, skipping decommission",
-				"deployment_name", dn,
-				"digest", digest,
-			)
-			return nil
+		c.observedDeployments.remove(cacheKey)
+		c.partialDeployments.Delete(cacheKey)
+		if c.deploymentRecords != nil {
+			c.deploymentRecords.forget(cacheKey)
+		}
+		if c.namespaceRecords != nil {
+			c.namespaceRecords.forget(cacheKey)
 		}
+	case deploymentrecord.StatusPartiallyDeployed:
+		c.partialDeployments.Store(cacheKey, time.Now())
+		return
 	default:
-		return fmt.Errorf("invalid status: %s", status)
+		c.observedDeployments.store(cacheKey, time.Now())
+		c.partialDeployments.Delete(cacheKey)
 	}
+	c.cacheDirty.Store(true)
+}
 
-	// Extract image name and tag
-	imageName, version := image.ExtractName(container.Image)
+// sendToSinks delivers the event to the additional sinks. Delivery is
+// best effort: failures are logged and counted, but do not fail the
+// event, as the record has already been posted to the API. With
+// sinks only, the sinks are the only delivery path, so the failures
+// are returned, to be handled like a failed post.
+func (c *Controller) sendToSinks(ctx context.Context, event sink.Event) error {
+	sinks := c.sinks.Load()
+	if sinks == nil {
+		return nil
+	}
+	var errs []error
+	for _, s := range *sinks {
+		if err := s.Send(ctx, event); err != nil {
+			slog.Warn("Failed to send record to sink",
+				"sink", s.Name(),
+				"type", event.Type,
+				"error", err,
+			)
+			metrics.SinkSendFailed.WithLabelValues(s.Name()).Inc()
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.Name(), err))
+			continue
+		}
+		metrics.SinkSendOk.WithLabelValues(s.Name()).Inc()
+	}
+	if !c.cfg.Load().SinksOnly {
+		return nil
+	}
+	return errors.Join(errs...)
+}
 
-	// Create deployment record
-	record := deploymentrecord.NewDeploymentRecord(
-		imageName,
-		digest,
-		version,
-		c.cfg.LogicalEnvironment,
-		c.cfg.PhysicalEnvironment,
-		c.cfg.Cluster,
+// recordEnvironment records the namespace lifecycle change of the
+// event as an environment record.
+func (c *Controller) recordEnvironment(ctx context.Context, event PodEvent, status string) error {
+	eventType := event.EventType
+	cfg := c.cfg.Load()
+	record := deploymentrecord.NewEnvironmentRecord(
+		event.Key,
+		cfg.LogicalEnvironment,
+		cfg.PhysicalEnvironment,
+		cfg.Cluster,
 		status,
-		dn,
 	)
+	record.TrackerVersion = version.Get()
+	record.KubernetesVersion = c.getServerVersion()
+	record.NamespaceUID = string(event.UID)
 
-	if err := c.apiClient.PostOne(ctx, record); err != nil {
+	err := c.apiClient.PostEnvironment(ctx, record)
+	if err == nil {
+		err = c.sendToSinks(ctx, sink.Event{
+			Type:   sink.EventEnvironmentRecord,
+			Record: record,
+		})
+	}
+	if err != nil {
 		// Make sure to not retry on client error messages
 		var clientErr *deploymentrecord.ClientError
 		if errors.As(err, &clientErr) {
-			slog.Warn("Failed to post record",
+			slog.Warn("Failed to post environment record",
 				"event_type", eventType,
 				"name", record.Name,
-				"deployment_name", record.DeploymentName,
 				"status", record.Status,
-				"digest", record.Digest,
 				"error", err,
 			)
 			return nil
 		}
 
-		slog.Error("Failed to post record",
+		slog.Error("Failed to post environment record",
 			"event_type", eventType,
 			"name", record.Name,
-			"deployment_name", record.DeploymentName,
 			"status", record.Status,
-			"digest", record.Digest,
 			"error", err,
 		)
 		return err
 	}
 
-	slog.Info("Posted record",
+	slog.Info("Posted environment record",
 		"event_type", eventType,
 		"name", record.Name,
-		"deployment_name", record.DeploymentName,
 		"status", record.Status,
-		"digest", record.Digest,
 	)
 
-	// Update cache after successful post
-	switch status {
-	case deploymentrecord.StatusDeployed:
-		c.observedDeployments.Store(cacheKey, true)
-	case deploymentrecord.StatusDecommissioned:
-		c.observedDeployments.Delete(cacheKey)
-	default:
-		return fmt.Errorf("invalid status: %s", status)
+	return nil
+}
+
+// loadCache populates the observation cache from the cache store.
+// Failing to load is not fatal, as the cache is best effort.
+func (c *Controller) loadCache(ctx context.Context) {
+	keys, err := c.cacheStore.load(ctx)
+	if err != nil {
+		slog.Warn("Failed to load observation cache",
+			"error", err,
+		)
+		return
 	}
+	// The time of the posts is not persisted
+	for _, k := range keys {
+		c.observedDeployments.store(k, time.Time{})
+	}
+	slog.Info("Loaded observation cache",
+		"count", len(keys),
+	)
+}
 
-	return nil
+// persistCache periodically writes the observation cache to the cache
+// store when it has changed, and a final time on shutdown.
+func (c *Controller) persistCache(ctx context.Context) {
+	ticker := time.NewTicker(cachePersistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.saveCache(ctx)
+		case <-ctx.Done():
+			// Use a fresh context, as ctx is already cancelled
+			saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			c.saveCache(saveCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// saveCache writes the observation cache to the cache store, if it
+// has changed.
+func (c *Controller) saveCache(ctx context.Context) {
+	if !c.cacheDirty.Swap(false) {
+		return
+	}
+
+	keys := make([]string, 0)
+	c.observedDeployments.each(func(k string, _ time.Time) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	if err := c.cacheStore.save(ctx, keys, c.deploymentRecords.snapshot()); err != nil {
+		// Try again on the next tick
+		c.cacheDirty.Store(true)
+		slog.Warn("Failed to persist observation cache",
+			"error", err,
+		)
+	}
 }
 
+// getCacheKey returns the observation cache key. The key is derived
+// from the deployment name rather than the pod, so all pods of a
+// workload running the same digest (e.g. one per node for a
+// DaemonSet) share a single entry and are only posted once.
 func getCacheKey(dn, digest string) string {
 	return dn + "||" + digest
 }
 
-// createInformerFactory creates a shared informer factory with the given resync period.
-// If excludeNamespaces is non-empty, it will exclude those namespaces from being watched.
-// If namespace is non-empty, it will only watch that namespace.
-func createInformerFactory(clientset kubernetes.Interface, namespace string, excludeNamespaces string) informers.SharedInformerFactory {
-	var factory informers.SharedInformerFactory
+// createInformerFactories creates the shared informer factories for
+// the watched namespaces, keyed by namespace.
+// If namespaces are included by name, there is one factory per
+// included namespace which is not excluded. Otherwise a single
+// factory, keyed by metav1.NamespaceAll, watches all namespaces except
+// those excluded by name; patterns are matched by namespaceTracked.
+func createInformerFactories(clientset kubernetes.Interface, included, excluded namespaceList) map[string]informers.SharedInformerFactory {
+	factories := make(map[string]informers.SharedInformerFactory)
 	switch {
-	case namespace != "":
-		slog.Info("Namespace to watch",
-			"namespace",
-			namespace,
-		)
-		factory = informers.NewSharedInformerFactoryWithOptions(
-			clientset,
-			30*time.Second,
-			informers.WithNamespace(namespace),
-		)
-	case excludeNamespaces != "":
-		seenNamespaces := make(map[string]bool)
-		fieldSelectorParts := make([]string, 0)
-
-		for _, ns := range strings.Split(excludeNamespaces, ",") {
-			ns = strings.TrimSpace(ns)
-			if ns != "" && !seenNamespaces[ns] {
-				seenNamespaces[ns] = true
-				fieldSelectorParts = append(fieldSelectorParts, fmt.Sprintf("metadata.namespace!=%s", ns))
-			}
+	case len(included.names) > 0 && len(included.patterns) == 0:
+		for _, ns := range watchedNamespaces(included, excluded) {
+			slog.Info("Namespace to watch",
+				"namespace",
+				ns,
+			)
+			factories[ns] = informers.NewSharedInformerFactoryWithOptions(
+				clientset,
+				30*time.Second,
+				informers.WithNamespace(ns),
+				informers.WithTransform(stripObject),
+			)
 		}
+	case len(excluded.names) > 0:
+		fieldSelectorParts := excludeFieldSelector(excluded)
 
 		slog.Info("Excluding namespaces from watch",
 			"field_selector",
@@ -513,18 +2100,68 @@ func createInformerFactory(clientset kubernetes.Interface, namespace string, exc
 			options.FieldSelector = strings.Join(fieldSelectorParts, ",")
 		}
 
-		factory = informers.NewSharedInformerFactoryWithOptions(
+		factories[metav1.NamespaceAll] = informers.NewSharedInformerFactoryWithOptions(
 			clientset,
 			30*time.Second,
 			informers.WithTweakListOptions(tweakListOptions),
+			informers.WithTransform(stripObject),
 		)
 	default:
-		factory = informers.NewSharedInformerFactory(clientset,
+		factories[metav1.NamespaceAll] = informers.NewSharedInformerFactoryWithOptions(
+			clientset,
 			30*time.Second,
+			informers.WithTransform(stripObject),
 		)
 	}
 
-	return factory
+	return factories
+}
+
+// podFieldSelector returns the field selector of the pod informers.
+// Pending pods have no resolved image digests yet, so they are
+// filtered out by the API server: they are neither listed nor
+// watched, and a pod is added to the cache once it leaves the pending
+// phase. The namespace exclusions apply to the informer watching all
+// namespaces.
+func podFieldSelector(ns string, excluded namespaceList) string {
+	selector := []string{"status.phase!=" + string(corev1.PodPending)}
+	if ns == metav1.NamespaceAll {
+		selector = append(selector, excludeFieldSelector(excluded)...)
+	}
+	return strings.Join(selector, ",")
+}
+
+// newPodInformer returns the constructor of the pod informer of the
+// informer factory watching namespace ns, see podFieldSelector.
+func newPodInformer(ns string, excluded namespaceList) func(kubernetes.Interface, time.Duration) cache.SharedIndexInformer {
+	selector := podFieldSelector(ns, excluded)
+	tweakListOptions := func(options *metav1.ListOptions) {
+		options.FieldSelector = selector
+	}
+
+	return func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return coreinformers.NewFilteredPodInformer(client, ns, resync,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+			tweakListOptions,
+		)
+	}
+}
+
+// splitList splits a comma separated list,
+// trimming whitespace and dropping empty and duplicate entries.
+func splitList(list string) []string {
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+
+	for _, ns := range strings.Split(list, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			res = append(res, ns)
+		}
+	}
+
+	return res
 }
 
 // getARDeploymentName converts the pod's metadata into the correct format
@@ -532,51 +2169,372 @@ func createInformerFactory(clientset kubernetes.Interface, namespace string, exc
 // as the K8s deployment's name!
 // The deployment name must unique within logical, physical environment and
 // the cluster.
-func getARDeploymentName(p *corev1.Pod, c corev1.Container, tmpl string) string {
-	res := tmpl
-	res = strings.ReplaceAll(res, TmplNS, p.Namespace)
-	res = strings.ReplaceAll(res, TmplDN, getDeploymentName(p))
-	res = strings.ReplaceAll(res, TmplCN, c.Name)
-	return res
+func getARDeploymentName(p *corev1.Pod, c corev1.Container, wl workload, tmpl, cluster string) string {
+	return placeholderPattern.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := m[2 : len(m)-2]
+		switch {
+		case m == TmplNS:
+			return p.Namespace
+		case m == TmplDN:
+			return wl.Name
+		case m == TmplCN:
+			return c.Name
+		case m == TmplWK:
+			return wl.Kind
+		case m == TmplCluster:
+			return cluster
+		case m == TmplPN:
+			return p.Name
+		case m == TmplNode:
+			return p.Spec.NodeName
+		case m == TmplKnativeService:
+			return knativeServiceName(p)
+		case m == TmplKnativeRevision:
+			return p.Labels[knativeRevisionLabel]
+		case strings.HasPrefix(name, TmplLabelPrefix):
+			return p.Labels[strings.TrimPrefix(name, TmplLabelPrefix)]
+		case strings.HasPrefix(name, TmplAnnotationPrefix):
+			return p.Annotations[strings.TrimPrefix(name, TmplAnnotationPrefix)]
+		default:
+			return m
+		}
+	})
 }
 
 // getContainerDigest extracts the image digest from the container status.
 // The spec only contains the desired state, so any resolved digests must
 // be pulled from the status field.
 func getContainerDigest(pod *corev1.Pod, containerName string) string {
+	return image.ExtractDigest(getContainerImageID(pod, containerName))
+}
+
+// getContainerImageID returns the imageID of the container status.
+func getContainerImageID(pod *corev1.Pod, containerName string) string {
 	// Check regular container statuses
 	for _, status := range pod.Status.ContainerStatuses {
 		if status.Name == containerName {
-			return image.ExtractDigest(status.ImageID)
+			return status.ImageID
 		}
 	}
 
 	// Check init container statuses
 	for _, status := range pod.Status.InitContainerStatuses {
 		if status.Name == containerName {
-			return image.ExtractDigest(status.ImageID)
+			return status.ImageID
+		}
+	}
+
+	// Check ephemeral container statuses
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		if status.Name == containerName {
+			return status.ImageID
 		}
 	}
 
 	return ""
 }
 
-// getDeploymentName returns the deployment name for a pod, if it belongs
-// to one.
-func getDeploymentName(pod *corev1.Pod) string {
-	// Pods created by Deployments are owned by ReplicaSets
-	// The ReplicaSet name follows the pattern: <deployment-name>-<hash>
+// imageChanged returns true if a container of newPod has resolved an
+// image digest it did not have in oldPod, e.g. because its image was
+// updated in place. Ephemeral containers are only considered if
+// ephemeral is set.
+func imageChanged(oldPod, newPod *corev1.Pod, ephemeral bool) bool {
+	statuses := slices.Concat(newPod.Status.ContainerStatuses, newPod.Status.InitContainerStatuses)
+	if ephemeral {
+		statuses = append(statuses, newPod.Status.EphemeralContainerStatuses...)
+	}
+	for _, status := range statuses {
+		digest := image.ExtractDigest(status.ImageID)
+		if digest != "" && digest != getContainerDigest(oldPod, status.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// getWorkload returns the workload owning the pod. If the pod is not
+// owned by a tracked workload, the returned workload is empty.
+func getWorkload(pod *corev1.Pod) workload {
 	for _, owner := range pod.OwnerReferences {
-		if owner.Kind == "ReplicaSet" {
-			// Extract deployment name by removing the hash suffix
-			// ReplicaSet name format: <deployment-name>-<hash>
-			rsName := owner.Name
-			lastDash := strings.LastIndex(rsName, "-")
-			if lastDash > 0 {
-				return rsName[:lastDash]
+		switch owner.Kind {
+		case "ReplicaSet":
+			return workload{
+				Kind: kindDeployment,
+				Name: getDeploymentName(pod),
 			}
-			return rsName
+		case kindStatefulSet, kindDaemonSet, kindJob:
+			return workload{
+				Kind: owner.Kind,
+				Name: owner.Name,
+			}
+		case "ReplicationController":
+			return workload{
+				Kind: kindDeploymentConfig,
+				Name: deploymentConfigName(pod),
+			}
+		}
+	}
+	return workload{}
+}
+
+// resolveWorkload returns the workload owning the pod, taking the
+// controller configuration into account. The owner references of
+// intermediate objects are followed, so ReplicaSets are resolved to
+// their Deployment and Jobs created by a CronJob to the CronJob. Jobs
+// are only tracked when batch workloads are enabled, the mirror pods
+// of static pods when static pods are, and the pods of OpenShift
+// DeploymentConfigs when DeploymentConfigs are. With Knative enabled,
+// the Deployments of Knative revisions are resolved to their Service.
+func (c *Controller) resolveWorkload(pod *corev1.Pod) workload {
+	wl := getWorkload(pod)
+	switch wl.Kind {
+	case kindDeployment:
+		wl = c.resolveReplicaSetOwner(pod, wl)
+		if svc := knativeServiceName(pod); wl.Name != "" && svc != "" && c.cfg.Load().Knative {
+			wl = workload{Kind: kindKnativeService, Name: svc}
+		}
+	case kindJob:
+		wl = c.resolveJobOwner(pod, wl)
+	case kindDeploymentConfig:
+		if !c.cfg.Load().DeploymentConfigs {
+			wl = workload{}
+		}
+	case "":
+		if c.cfg.Load().StaticPods && isMirrorPod(pod) {
+			wl = staticPodWorkload(pod)
+		}
+	}
+	if wl.Name == "" || !c.trackingEnabled(pod) {
+		return workload{}
+	}
+	return wl
+}
+
+// trackingEnabled returns true if the pod should be tracked according
+// to the ignore and track annotations on the pod and its owner. The
+// Deployment controller copies a Deployment's annotations to its
+// ReplicaSets, and Jobs inherit the annotations of their CronJob's
+// job template, so annotating the workload is enough.
+func (c *Controller) trackingEnabled(pod *corev1.Pod) bool {
+	var tracked bool
+	for _, m := range c.podObjectMeta(pod) {
+		if isTrue(m.Annotations[ignoreAnnotation]) {
+			return false
+		}
+		if isTrue(m.Annotations[trackAnnotation]) {
+			tracked = true
+		}
+	}
+	return tracked || !c.cfg.Load().OptIn
+}
+
+// podObjectMeta returns the metadata of the pod, followed by that of
+// its cached owner, i.e. its ReplicaSet or Job.
+func (c *Controller) podObjectMeta(pod *corev1.Pod) []*metav1.ObjectMeta {
+	res := []*metav1.ObjectMeta{&pod.ObjectMeta}
+	if rsName := getReplicaSetName(pod); rsName != "" {
+		if rs, err := c.rsLister.ReplicaSets(pod.Namespace).Get(rsName); err == nil {
+			res = append(res, &rs.ObjectMeta)
+		}
+	}
+	if jobName := getJobName(pod); jobName != "" && c.jobLister != nil {
+		if job, err := c.jobLister.Jobs(pod.Namespace).Get(jobName); err == nil {
+			res = append(res, &job.ObjectMeta)
+		}
+	}
+	return res
+}
+
+// workloadObjectMeta returns the metadata of the pod and its cached
+// owner, see podObjectMeta, followed by that of its cached Deployment.
+// Tools such as Helm and GitOps controllers label the workload they
+// apply rather than its pods, and the Deployment controller doesn't
+// copy the labels of a Deployment to its ReplicaSets.
+func (c *Controller) workloadObjectMeta(pod *corev1.Pod) []*metav1.ObjectMeta {
+	res := c.podObjectMeta(pod)
+	if wl := getWorkload(pod); wl.Kind == kindDeployment && c.deploymentLister != nil {
+		if wl = c.resolveReplicaSetOwner(pod, wl); wl.Name != "" {
+			if d, err := c.deploymentLister.Deployments(pod.Namespace).Get(wl.Name); err == nil {
+				res = append(res, &d.ObjectMeta)
+			}
+		}
+	}
+	return res
+}
+
+// isTrue returns true if the annotation value v parses as a true
+// boolean.
+func isTrue(v string) bool {
+	b, err := strconv.ParseBool(v)
+	return err == nil && b
+}
+
+// resolveReplicaSetOwner returns the Deployment owning the pod's
+// ReplicaSet. If the ReplicaSet is no longer cached, wl (derived from
+// the ReplicaSet name) is returned. ReplicaSets not owned by a
+// Deployment are not tracked.
+func (c *Controller) resolveReplicaSetOwner(pod *corev1.Pod, wl workload) workload {
+	rs, err := c.rsLister.ReplicaSets(pod.Namespace).Get(getReplicaSetName(pod))
+	if err != nil {
+		return wl
+	}
+
+	name := getReplicaSetDeploymentName(rs)
+	if name == "" {
+		return workload{}
+	}
+	return workload{Kind: kindDeployment, Name: name}
+}
+
+// resolveJobOwner returns the CronJob owning the pod's Job, or wl if
+// the Job was not created by a CronJob.
+func (c *Controller) resolveJobOwner(pod *corev1.Pod, wl workload) workload {
+	if !c.cfg.Load().BatchWorkloads {
+		return workload{}
+	}
+
+	key := pod.Namespace + "/" + wl.Name
+	job, err := c.jobLister.Jobs(pod.Namespace).Get(wl.Name)
+	if err != nil {
+		// The job may already be deleted, use the last known
+		// owner
+		if owner, ok := c.jobOwners.Load(key); ok {
+			return workload{Kind: kindCronJob, Name: owner.(string)}
+		}
+		return wl
+	}
+
+	for _, owner := range job.OwnerReferences {
+		if owner.Kind == kindCronJob {
+			c.jobOwners.Store(key, owner.Name)
+			return workload{Kind: kindCronJob, Name: owner.Name}
+		}
+	}
+	return wl
+}
+
+// podStarted returns true if the pod's containers have started, and
+// so their image digests are resolved. Pods of batch workloads may
+// complete before a running state is observed, so finished pods
+// count as started for those.
+func (c *Controller) podStarted(pod *corev1.Pod) bool {
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+		return true
+	case corev1.PodSucceeded, corev1.PodFailed:
+		return c.cfg.Load().BatchWorkloads && getWorkload(pod).Kind == kindJob
+	default:
+		return false
+	}
+}
+
+// getJobName returns the name of the Job owning the pod, if any.
+func getJobName(pod *corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == kindJob {
+			return owner.Name
+		}
+	}
+	return ""
+}
+
+// getReplicaSetName returns the name of the ReplicaSet owning the pod,
+// if any.
+func getReplicaSetName(pod *corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "ReplicaSet" {
+			return owner.Name
 		}
 	}
 	return ""
 }
+
+// getReplicaSetDeploymentName returns the name of the Deployment
+// owning the ReplicaSet, if any.
+func getReplicaSetDeploymentName(rs *appsv1.ReplicaSet) string {
+	for _, owner := range rs.OwnerReferences {
+		if owner.Kind == "Deployment" {
+			return owner.Name
+		}
+	}
+	return ""
+}
+
+// getReplicaSetRevision returns the rollout revision of the
+// ReplicaSet, or 0 if it is unknown.
+func getReplicaSetRevision(rs *appsv1.ReplicaSet) int64 {
+	v, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// getDeploymentName returns the deployment name for a pod, if it belongs
+// to one, derived from the name of the owning ReplicaSet. This is only
+// a fallback for when the ReplicaSet itself is not available, see
+// resolveWorkload.
+func getDeploymentName(pod *corev1.Pod) string {
+	rsName := getReplicaSetName(pod)
+	if rsName == "" {
+		return ""
+	}
+
+	// ReplicaSet name format: <deployment-name>-<pod-template-hash>
+	if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; hash != "" {
+		if name, ok := strings.CutSuffix(rsName, "-"+hash); ok {
+			return name
+		}
+	}
+	lastDash := strings.LastIndex(rsName, "-")
+	if lastDash > 0 {
+		return rsName[:lastDash]
+	}
+	return rsName
+}
+
+// updateTrackedDeployments sets the tracked deployments gauge to the
+// number of distinct deployment names and digests running in each
+// namespace, and keeps their entries of the observation cache from
+// expiring.
+func (c *Controller) updateTrackedDeployments(_ context.Context) {
+	counts := make(map[string]map[string]bool)
+	err := c.forEachRunningContainer(c.cfg.Load(), func(pod *corev1.Pod, _ corev1.Container, dn, digest string) {
+		if counts[pod.Namespace] == nil {
+			counts[pod.Namespace] = make(map[string]bool)
+		}
+		cacheKey := getCacheKey(dn, digest)
+		counts[pod.Namespace][cacheKey] = true
+		// Running deployments don't expire from the observation
+		// cache
+		c.observedDeployments.touch(cacheKey)
+	})
+	if err != nil {
+		slog.Warn("Failed to count tracked deployments",
+			"error", err,
+		)
+		return
+	}
+	c.observedDeployments.expire()
+
+	metrics.TrackedDeployments.DeletePartialMatch(prometheus.Labels{"cluster": c.trackedCluster})
+	c.trackedCluster = c.cfg.Load().Cluster
+	for ns, keys := range counts {
+		metrics.TrackedDeployments.WithLabelValues(c.trackedCluster, ns).Set(float64(len(keys)))
+	}
+}
+
+// setConfigInfo exports the non-secret settings of cfg as the config
+// info metric, replacing the settings exported before.
+func (c *Controller) setConfigInfo(cfg *Config) {
+	if c.configInfo != nil {
+		metrics.ConfigInfo.DeleteLabelValues(c.configInfo...)
+	}
+	c.configInfo = []string{
+		cfg.Cluster,
+		cfg.LogicalEnvironment,
+		cfg.PhysicalEnvironment,
+		cfg.TemplateHash(),
+	}
+	metrics.ConfigInfo.WithLabelValues(c.configInfo...).Set(1)
+}
@@ -0,0 +1,1179 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/github/deployment-tracker/pkg/sink"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func newTestReplicaSet(name, deployment, revision string) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Annotations: map[string]string{
+				revisionAnnotation: revision,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Deployment", Name: deployment},
+			},
+		},
+	}
+}
+
+func newTestPod(rsName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rsName + "-abcde",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: rsName},
+			},
+		},
+	}
+}
+
+func TestClassifyPodDelete(t *testing.T) {
+	now := metav1.Now()
+	deleting := newTestReplicaSet("web-111", "web", "1")
+	deleting.DeletionTimestamp = &now
+
+	tests := []struct {
+		name        string
+		replicaSets []*appsv1.ReplicaSet
+		pod         *corev1.Pod
+		expected    podDeleteReason
+	}{
+		{
+			name: "replicaset is newest revision",
+			replicaSets: []*appsv1.ReplicaSet{
+				newTestReplicaSet("web-111", "web", "1"),
+			},
+			pod:      newTestPod("web-111"),
+			expected: deleteScaleDown,
+		},
+		{
+			name: "replicaset superseded by newer revision",
+			replicaSets: []*appsv1.ReplicaSet{
+				newTestReplicaSet("web-111", "web", "1"),
+				newTestReplicaSet("web-222", "web", "2"),
+			},
+			pod:      newTestPod("web-111"),
+			expected: deleteRollover,
+		},
+		{
+			name: "newer revision of other deployment is ignored",
+			replicaSets: []*appsv1.ReplicaSet{
+				newTestReplicaSet("web-111", "web", "1"),
+				newTestReplicaSet("api-222", "api", "2"),
+			},
+			pod:      newTestPod("web-111"),
+			expected: deleteScaleDown,
+		},
+		{
+			name: "replicaset pruned but sibling remains",
+			replicaSets: []*appsv1.ReplicaSet{
+				newTestReplicaSet("web-222", "web", "2"),
+			},
+			pod:      newTestPod("web-111"),
+			expected: deleteRollover,
+		},
+		{
+			name:        "no replicasets left",
+			replicaSets: nil,
+			pod:         newTestPod("web-111"),
+			expected:    deleteRemoved,
+		},
+		{
+			name: "replicaset being deleted",
+			replicaSets: []*appsv1.ReplicaSet{
+				deleting,
+			},
+			pod:      newTestPod("web-111"),
+			expected: deleteRemoved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, rs := range tt.replicaSets {
+				if err := indexer.Add(rs); err != nil {
+					t.Fatalf("failed to add replicaset: %v", err)
+				}
+			}
+			c := &Controller{
+				rsLister: appslisters.NewReplicaSetLister(indexer),
+			}
+
+			result := c.classifyPodDelete(tt.pod)
+			if result != tt.expected {
+				t.Errorf("classifyPodDelete() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNamespaceTracked(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		exclude   string
+		reloaded  []string
+		ns        string
+		expected  bool
+	}{
+		{
+			name:     "no filters",
+			ns:       "default",
+			expected: true,
+		},
+		{
+			name:      "watched namespace",
+			namespace: "prod",
+			ns:        "prod",
+			expected:  true,
+		},
+		{
+			name:      "other namespace",
+			namespace: "prod",
+			ns:        "dev",
+			expected:  false,
+		},
+		{
+			name:      "one of several watched namespaces",
+			namespace: "prod, staging",
+			ns:        "staging",
+			expected:  true,
+		},
+		{
+			name:      "not one of several watched namespaces",
+			namespace: "prod,staging",
+			ns:        "dev",
+			expected:  false,
+		},
+		{
+			name:     "excluded namespace",
+			exclude:  "kube-system, dev",
+			ns:       "dev",
+			expected: false,
+		},
+		{
+			name:     "excluded at runtime",
+			reloaded: []string{"dev"},
+			ns:       "dev",
+			expected: false,
+		},
+		{
+			name:      "excluded at runtime from watched namespaces",
+			namespace: "prod,dev",
+			reloaded:  []string{"dev"},
+			ns:        "dev",
+			expected:  false,
+		},
+		{
+			name:     "not excluded namespace",
+			exclude:  "kube-system,dev",
+			ns:       "prod",
+			expected: true,
+		},
+		{
+			name:      "watched and excluded namespace",
+			namespace: "prod,dev",
+			exclude:   "dev",
+			ns:        "dev",
+			expected:  false,
+		},
+		{
+			name:      "watched and not excluded namespace",
+			namespace: "prod,dev",
+			exclude:   "dev",
+			ns:        "prod",
+			expected:  true,
+		},
+		{
+			name:     "excluded by pattern",
+			exclude:  "^kube-, ^openshift-",
+			ns:       "openshift-monitoring",
+			expected: false,
+		},
+		{
+			name:     "not excluded by pattern",
+			exclude:  "^kube-",
+			ns:       "prod-kube",
+			expected: true,
+		},
+		{
+			name:      "watched by pattern",
+			namespace: "^team-.*-prod$",
+			ns:        "team-a-prod",
+			expected:  true,
+		},
+		{
+			name:      "not watched by pattern",
+			namespace: "^team-.*-prod$",
+			ns:        "team-a-dev",
+			expected:  false,
+		},
+		{
+			name:      "watched by pattern and excluded",
+			namespace: "^team-",
+			exclude:   "team-legacy",
+			ns:        "team-legacy",
+			expected:  false,
+		},
+		{
+			name:     "excluded at runtime by pattern",
+			reloaded: []string{"^dev-"},
+			ns:       "dev-a",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{
+				includedNs: newTestNamespaceList(t, tt.namespace),
+				excludedNs: newTestNamespaceList(t, tt.exclude),
+			}
+			reloaded, err := newNamespaceList(tt.reloaded)
+			if err != nil {
+				t.Fatalf("newNamespaceList() unexpected error: %v", err)
+			}
+			c.reloadedNs.Store(&reloaded)
+
+			result := c.namespaceTracked(tt.ns)
+			if result != tt.expected {
+				t.Errorf("namespaceTracked(%q) = %v, expected %v", tt.ns, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPodFieldSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		ns      string
+		exclude string
+		want    string
+	}{
+		{
+			name: "all namespaces",
+			want: "status.phase!=Pending",
+		},
+		{
+			name:    "excluded namespaces",
+			exclude: "kube-system, kube-public",
+			want:    "status.phase!=Pending,metadata.namespace!=kube-public,metadata.namespace!=kube-system",
+		},
+		{
+			name:    "excluded patterns",
+			exclude: "kube-system, ^openshift-",
+			want:    "status.phase!=Pending,metadata.namespace!=kube-system",
+		},
+		{
+			name:    "watched namespace ignores exclusions",
+			ns:      "default",
+			exclude: "kube-system",
+			want:    "status.phase!=Pending",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podFieldSelector(tt.ns, newTestNamespaceList(t, tt.exclude)); got != tt.want {
+				t.Errorf("podFieldSelector() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkloadExists(t *testing.T) {
+	deleting := metav1.Now()
+	tests := []struct {
+		name     string
+		cached   *appsv1.Deployment
+		live     *appsv1.Deployment
+		want     bool
+		liveGets int
+	}{
+		{
+			name:   "cached",
+			cached: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+			want:   true,
+		},
+		{
+			name: "cached and being deleted",
+			cached: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Name:              "web",
+				Namespace:         "default",
+				DeletionTimestamp: &deleting,
+				Finalizers:        []string{"foregroundDeletion"},
+			}},
+			want: false,
+		},
+		{
+			name:     "cache miss, found live",
+			live:     &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+			want:     true,
+			liveGets: 1,
+		},
+		{
+			name:     "gone",
+			want:     false,
+			liveGets: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientset()
+			if tt.live != nil {
+				clientset = fake.NewClientset(tt.live)
+			}
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if tt.cached != nil {
+				if err := indexer.Add(tt.cached); err != nil {
+					t.Fatalf("failed to add deployment: %v", err)
+				}
+			}
+			c := &Controller{
+				clientset:        clientset,
+				deploymentLister: appslisters.NewDeploymentLister(indexer),
+			}
+
+			got := c.workloadExists(context.Background(), "default", workload{Kind: kindDeployment, Name: "web"})
+			if got != tt.want {
+				t.Errorf("workloadExists() = %v, want %v", got, tt.want)
+			}
+			var gets int
+			for _, a := range clientset.Actions() {
+				if a.GetVerb() == "get" {
+					gets++
+				}
+			}
+			if gets != tt.liveGets {
+				t.Errorf("live gets = %d, want %d", gets, tt.liveGets)
+			}
+		})
+	}
+}
+
+func TestGetWorkload(t *testing.T) {
+	tests := []struct {
+		name     string
+		owners   []metav1.OwnerReference
+		labels   map[string]string
+		expected workload
+	}{
+		{
+			name:     "no owner",
+			expected: workload{},
+		},
+		{
+			name: "replicaset owner",
+			owners: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "web-5d8f7c9b4"},
+			},
+			expected: workload{Kind: kindDeployment, Name: "web"},
+		},
+		{
+			name: "replicaset owner with pod-template-hash",
+			owners: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "web-5d8f7c9b4"},
+			},
+			labels: map[string]string{
+				appsv1.DefaultDeploymentUniqueLabelKey: "5d8f7c9b4",
+			},
+			expected: workload{Kind: kindDeployment, Name: "web"},
+		},
+		{
+			name: "statefulset owner",
+			owners: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: "db"},
+			},
+			expected: workload{Kind: kindStatefulSet, Name: "db"},
+		},
+		{
+			name: "daemonset owner",
+			owners: []metav1.OwnerReference{
+				{Kind: "DaemonSet", Name: "node-exporter"},
+			},
+			expected: workload{Kind: kindDaemonSet, Name: "node-exporter"},
+		},
+		{
+			name: "untracked owner",
+			owners: []metav1.OwnerReference{
+				{Kind: "Node", Name: "node-1"},
+			},
+			expected: workload{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "pod",
+					Namespace:       "default",
+					Labels:          tt.labels,
+					OwnerReferences: tt.owners,
+				},
+			}
+
+			result := getWorkload(pod)
+			if result != tt.expected {
+				t.Errorf("getWorkload() = %+v, expected %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetARDeploymentName(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db-0",
+			Namespace: "prod",
+			Labels:    map[string]string{"team": "storage"},
+			Annotations: map[string]string{
+				"example.com/owner": "dba",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: "db"},
+			},
+		},
+	}
+	container := corev1.Container{Name: "postgres"}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "workload placeholders",
+			template: "{{namespace}}/{{workloadKind}}/{{deploymentName}}/{{containerName}}",
+			expected: "prod/StatefulSet/db/postgres",
+		},
+		{
+			name:     "cluster and pod name",
+			template: "{{cluster}}/{{podName}}",
+			expected: "kube-1/db-0",
+		},
+		{
+			name:     "label and annotation",
+			template: "{{labels.team}}/{{annotations.example.com/owner}}/{{deploymentName}}",
+			expected: "storage/dba/db",
+		},
+		{
+			name:     "missing label",
+			template: "{{labels.tier}}/{{deploymentName}}",
+			expected: "/db",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := getARDeploymentName(pod, container, getWorkload(pod), tt.template, "kube-1")
+			if result != tt.expected {
+				t.Errorf("getARDeploymentName() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestImageChanged(t *testing.T) {
+	withStatuses := func(app, debugger string) *corev1.Pod {
+		pod := newTestPod("web-111")
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{Name: "app", ImageID: app},
+		}
+		if debugger != "" {
+			pod.Status.EphemeralContainerStatuses = []corev1.ContainerStatus{
+				{Name: "debugger-x7k2p", ImageID: debugger},
+			}
+		}
+		return pod
+	}
+	v1 := "ghcr.io/org/app@sha256:1111111111111111"
+	v2 := "ghcr.io/org/app@sha256:2222222222222222"
+	busybox := "docker.io/library/busybox@sha256:0123456789abcdef"
+
+	tests := []struct {
+		name      string
+		oldPod    *corev1.Pod
+		newPod    *corev1.Pod
+		ephemeral bool
+		expected  bool
+	}{
+		{
+			name:     "unchanged",
+			oldPod:   withStatuses(v1, ""),
+			newPod:   withStatuses(v1, ""),
+			expected: false,
+		},
+		{
+			name:     "image updated in place",
+			oldPod:   withStatuses(v1, ""),
+			newPod:   withStatuses(v2, ""),
+			expected: true,
+		},
+		{
+			name:     "container restarting",
+			oldPod:   withStatuses(v1, ""),
+			newPod:   withStatuses("", ""),
+			expected: false,
+		},
+		{
+			name:      "ephemeral container started",
+			oldPod:    withStatuses(v1, ""),
+			newPod:    withStatuses(v1, busybox),
+			ephemeral: true,
+			expected:  true,
+		},
+		{
+			name:     "ephemeral containers disabled",
+			oldPod:   withStatuses(v1, ""),
+			newPod:   withStatuses(v1, busybox),
+			expected: false,
+		},
+		{
+			name:      "ephemeral container already running",
+			oldPod:    withStatuses(v1, busybox),
+			newPod:    withStatuses(v1, busybox),
+			ephemeral: true,
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageChanged(tt.oldPod, tt.newPod, tt.ephemeral); got != tt.expected {
+				t.Errorf("imageChanged() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveWorkload(t *testing.T) {
+	cronJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "report-28977120",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "CronJob", Name: "report"},
+			},
+		},
+	}
+	bareJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "migrate",
+			Namespace: "default",
+		},
+	}
+	jobPod := func(job string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      job + "-abcde",
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "Job", Name: job},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		batch    bool
+		owners   map[string]string
+		pod      *corev1.Pod
+		expected workload
+	}{
+		{
+			name:     "batch workloads disabled",
+			pod:      jobPod("migrate"),
+			expected: workload{},
+		},
+		{
+			name:     "bare job",
+			batch:    true,
+			pod:      jobPod("migrate"),
+			expected: workload{Kind: kindJob, Name: "migrate"},
+		},
+		{
+			name:     "job owned by cronjob",
+			batch:    true,
+			pod:      jobPod("report-28977120"),
+			expected: workload{Kind: kindCronJob, Name: "report"},
+		},
+		{
+			name:  "deleted job with known owner",
+			batch: true,
+			owners: map[string]string{
+				"default/report-28977060": "report",
+			},
+			pod:      jobPod("report-28977060"),
+			expected: workload{Kind: kindCronJob, Name: "report"},
+		},
+		{
+			name:     "deleted job with unknown owner",
+			batch:    true,
+			pod:      jobPod("gone"),
+			expected: workload{Kind: kindJob, Name: "gone"},
+		},
+		{
+			name:     "replicaset owned by deployment",
+			pod:      newTestPod("web-111"),
+			expected: workload{Kind: kindDeployment, Name: "web"},
+		},
+		{
+			name:     "replicaset name not derived from deployment",
+			pod:      newTestPod("legacy-222"),
+			expected: workload{Kind: kindDeployment, Name: "frontend"},
+		},
+		{
+			name:     "bare replicaset",
+			pod:      newTestPod("bare-333"),
+			expected: workload{},
+		},
+		{
+			name:     "deleted replicaset",
+			pod:      newTestPod("api-444"),
+			expected: workload{Kind: kindDeployment, Name: "api"},
+		},
+	}
+
+	bareReplicaSet := newTestReplicaSet("bare-333", "", "1")
+	bareReplicaSet.OwnerReferences = nil
+	replicaSets := []*appsv1.ReplicaSet{
+		newTestReplicaSet("web-111", "web", "1"),
+		newTestReplicaSet("legacy-222", "frontend", "1"),
+		bareReplicaSet,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, job := range []*batchv1.Job{cronJob, bareJob} {
+				if err := indexer.Add(job); err != nil {
+					t.Fatalf("failed to add job: %v", err)
+				}
+			}
+			rsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, rs := range replicaSets {
+				if err := rsIndexer.Add(rs); err != nil {
+					t.Fatalf("failed to add replicaset: %v", err)
+				}
+			}
+			c := &Controller{
+				rsLister:  appslisters.NewReplicaSetLister(rsIndexer),
+				jobLister: batchlisters.NewJobLister(indexer),
+			}
+			c.cfg.Store(&Config{BatchWorkloads: tt.batch})
+			for k, v := range tt.owners {
+				c.jobOwners.Store(k, v)
+			}
+
+			result := c.resolveWorkload(tt.pod)
+			if result != tt.expected {
+				t.Errorf("resolveWorkload() = %+v, expected %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrackingEnabled(t *testing.T) {
+	ignored := newTestReplicaSet("ignored-111", "ignored", "1")
+	ignored.Annotations[ignoreAnnotation] = "true"
+	opted := newTestReplicaSet("opted-111", "opted", "1")
+	opted.Annotations[trackAnnotation] = "true"
+
+	annotatedPod := func(rsName, key, value string) *corev1.Pod {
+		pod := newTestPod(rsName)
+		pod.Annotations = map[string]string{key: value}
+		return pod
+	}
+
+	tests := []struct {
+		name     string
+		optIn    bool
+		pod      *corev1.Pod
+		expected bool
+	}{
+		{
+			name:     "no annotations",
+			pod:      newTestPod("web-111"),
+			expected: true,
+		},
+		{
+			name:     "pod ignored",
+			pod:      annotatedPod("web-111", ignoreAnnotation, "true"),
+			expected: false,
+		},
+		{
+			name:     "pod ignore annotation false",
+			pod:      annotatedPod("web-111", ignoreAnnotation, "false"),
+			expected: true,
+		},
+		{
+			name:     "workload ignored",
+			pod:      newTestPod("ignored-111"),
+			expected: false,
+		},
+		{
+			name:     "opt-in without annotations",
+			optIn:    true,
+			pod:      newTestPod("web-111"),
+			expected: false,
+		},
+		{
+			name:     "opt-in with pod annotation",
+			optIn:    true,
+			pod:      annotatedPod("web-111", trackAnnotation, "true"),
+			expected: true,
+		},
+		{
+			name:     "opt-in with workload annotation",
+			optIn:    true,
+			pod:      newTestPod("opted-111"),
+			expected: true,
+		},
+		{
+			name:     "ignore takes precedence over track",
+			optIn:    true,
+			pod:      annotatedPod("ignored-111", trackAnnotation, "true"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, rs := range []*appsv1.ReplicaSet{
+				newTestReplicaSet("web-111", "web", "1"),
+				ignored,
+				opted,
+			} {
+				if err := indexer.Add(rs); err != nil {
+					t.Fatalf("failed to add replicaset: %v", err)
+				}
+			}
+			c := &Controller{
+				rsLister: appslisters.NewReplicaSetLister(indexer),
+			}
+			c.cfg.Store(&Config{OptIn: tt.optIn})
+
+			result := c.trackingEnabled(tt.pod)
+			if result != tt.expected {
+				t.Errorf("trackingEnabled() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	tests := []struct {
+		name         string
+		synced       bool
+		queued       bool
+		lastProgress time.Duration
+		wantErr      bool
+	}{
+		{
+			name:   "not synced",
+			queued: true,
+		},
+		{
+			name:         "empty queue without recent progress",
+			synced:       true,
+			lastProgress: time.Hour,
+		},
+		{
+			name:         "queued events with recent progress",
+			synced:       true,
+			queued:       true,
+			lastProgress: time.Second,
+		},
+		{
+			name:         "queued events without recent progress",
+			synced:       true,
+			queued:       true,
+			lastProgress: time.Hour,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := workqueue.NewTypedRateLimitingQueue(
+				workqueue.DefaultTypedControllerRateLimiter[PodEvent](),
+			)
+			defer queue.ShutDown()
+			if tt.queued {
+				queue.Add(PodEvent{Key: "default/pod", EventType: EventCreated})
+			}
+			c := &Controller{workqueue: queue}
+			c.synced.Store(tt.synced)
+			c.lastProgress.Store(time.Now().Add(-tt.lastProgress).UnixNano())
+
+			err := c.Healthy()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Healthy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateTrackedDeployments(t *testing.T) {
+	runningPod := func(name, ns, imageID string) *corev1.Pod {
+		pod := newTestPod("web-111")
+		pod.Name = name
+		pod.Namespace = ns
+		pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/web:v1"}}
+		pod.Status = corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", ImageID: imageID}},
+		}
+		return pod
+	}
+
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range []*corev1.Pod{
+		runningPod("web-111-aaaaa", "payments", "ghcr.io/org/web@sha256:abc"),
+		runningPod("web-111-bbbbb", "payments", "ghcr.io/org/web@sha256:abc"),
+		runningPod("web-111-ccccc", "payments", "ghcr.io/org/web@sha256:def"),
+		runningPod("web-111-ddddd", "search", "ghcr.io/org/web@sha256:abc"),
+	} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Fatalf("failed to add pod: %v", err)
+		}
+	}
+	rsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, ns := range []string{"payments", "search"} {
+		rs := newTestReplicaSet("web-111", "web", "1")
+		rs.Namespace = ns
+		if err := rsIndexer.Add(rs); err != nil {
+			t.Fatalf("failed to add replicaset: %v", err)
+		}
+	}
+
+	c := &Controller{
+		podLister: corelisters.NewPodLister(podIndexer),
+		rsLister:  appslisters.NewReplicaSetLister(rsIndexer),
+	}
+	c.cfg.Store(&Config{Template: TmplNS + "/" + TmplDN + "/" + TmplCN, Cluster: "prod"})
+	c.trackedCluster = "prod"
+
+	// Series of gone namespaces are removed, those of other clusters
+	// are kept
+	metrics.TrackedDeployments.WithLabelValues("prod", "gone").Set(1)
+	metrics.TrackedDeployments.WithLabelValues("edge", "payments").Set(1)
+	c.updateTrackedDeployments(context.Background())
+
+	for ns, expected := range map[string]float64{"payments": 2, "search": 1} {
+		if got := testutil.ToFloat64(metrics.TrackedDeployments.WithLabelValues("prod", ns)); got != expected {
+			t.Errorf("tracked deployments in %s = %v, expected %v", ns, got, expected)
+		}
+	}
+	if n := testutil.CollectAndCount(metrics.TrackedDeployments); n != 3 {
+		t.Errorf("tracked deployments series = %d, expected 3", n)
+	}
+}
+
+func TestInitialSync(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, deleting bool) *corev1.Pod {
+		pod := newTestPod("web-111")
+		pod.Name = name
+		pod.Status.Phase = phase
+		if deleting {
+			pod.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		}
+		return pod
+	}
+
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, p := range []*corev1.Pod{
+		pod("web-111-aaaaa", corev1.PodRunning, false),
+		pod("web-111-bbbbb", corev1.PodRunning, false),
+		pod("web-111-ccccc", corev1.PodRunning, true),
+		pod("web-111-ddddd", corev1.PodSucceeded, false),
+	} {
+		if err := podIndexer.Add(p); err != nil {
+			t.Fatalf("failed to add pod: %v", err)
+		}
+	}
+	rsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := rsIndexer.Add(newTestReplicaSet("web-111", "web", "1")); err != nil {
+		t.Fatalf("failed to add replicaset: %v", err)
+	}
+
+	queue := workqueue.NewTypedRateLimitingQueue(
+		workqueue.DefaultTypedControllerRateLimiter[PodEvent](),
+	)
+	defer queue.ShutDown()
+	c := &Controller{
+		podLister: corelisters.NewPodLister(podIndexer),
+		rsLister:  appslisters.NewReplicaSetLister(rsIndexer),
+		workqueue: queue,
+	}
+	c.cfg.Store(&Config{})
+
+	c.initialSync()
+
+	if queue.Len() != 2 {
+		t.Fatalf("queued events = %d, expected 2", queue.Len())
+	}
+	for range 2 {
+		event, _ := queue.Get()
+		if event.EventType != EventCreated {
+			t.Errorf("event type of %s = %s, expected %s", event.Key, event.EventType, EventCreated)
+		}
+		if event.Key != "default/web-111-aaaaa" && event.Key != "default/web-111-bbbbb" {
+			t.Errorf("unexpected event for %s", event.Key)
+		}
+		queue.Done(event)
+	}
+}
+
+func TestQueueFor(t *testing.T) {
+	newQueue := func() workqueue.TypedRateLimitingInterface[PodEvent] {
+		q := workqueue.NewTypedRateLimitingQueue(
+			workqueue.DefaultTypedControllerRateLimiter[PodEvent](),
+		)
+		t.Cleanup(q.ShutDown)
+		return q
+	}
+	queue, deleteQueue := newQueue(), newQueue()
+
+	c := &Controller{workqueue: queue, deleteQueue: deleteQueue}
+	for eventType, expected := range map[string]workqueue.TypedRateLimitingInterface[PodEvent]{
+		EventCreated:           queue,
+		EventNamespaceCreated:  queue,
+		EventDeleted:           deleteQueue,
+		EventNamespaceDeleted:  deleteQueue,
+		EventDeploymentDeleted: deleteQueue,
+	} {
+		if c.queueFor(eventType) != expected {
+			t.Errorf("queueFor(%s) returned the wrong queue", eventType)
+		}
+	}
+
+	// Queued deletions count towards a stall
+	deleteQueue.Add(PodEvent{Key: "default/pod", EventType: EventDeleted})
+	c.synced.Store(true)
+	c.lastProgress.Store(time.Now().Add(-time.Hour).UnixNano())
+	if err := c.Healthy(); err == nil {
+		t.Error("Healthy() expected an error with stalled deletions")
+	}
+
+	c = &Controller{workqueue: queue}
+	if c.queueFor(EventDeleted) != queue {
+		t.Error("queueFor(DELETED) without a delete queue returned the wrong queue")
+	}
+}
+
+func TestNamespaceHandlers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clientset := fake.NewClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing", UID: "uid-1"},
+	})
+	queue := workqueue.NewTypedRateLimitingQueue(
+		workqueue.DefaultTypedControllerRateLimiter[PodEvent](),
+	)
+	defer queue.ShutDown()
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	c := &Controller{
+		workqueue:  queue,
+		nsInformer: factory.Core().V1().Namespaces().Informer(),
+	}
+	c.cfg.Store(&Config{})
+	if err := c.addNamespaceHandlers(); err != nil {
+		t.Fatalf("addNamespaceHandlers() unexpected error: %v", err)
+	}
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	// Only namespaces created after the start are recorded
+	_, err := clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "created", UID: "uid-2"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+	event, _ := queue.Get()
+	expected := PodEvent{Key: "created", EventType: EventNamespaceCreated, UID: "uid-2"}
+	if event != expected {
+		t.Errorf("queued event = %+v, expected %+v", event, expected)
+	}
+	queue.Done(event)
+	if queue.Len() != 0 {
+		t.Errorf("queue length = %d, expected the namespaces of the initial list to be skipped", queue.Len())
+	}
+}
+
+func TestReloadSinksAndMetadata(t *testing.T) {
+	c := &Controller{}
+	current := &Config{Template: TmplNS + "/" + TmplDN + "/" + TmplCN}
+	c.cfg.Store(current)
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		wantErr   bool
+		wantSinks int
+	}{
+		{
+			name: "webhook and metadata",
+			cfg: Config{
+				Template:       current.Template,
+				MetadataLabels: "team",
+				WebhookURL:     "https://hooks.example.com",
+			},
+			wantSinks: 1,
+		},
+		{
+			name: "invalid webhook headers",
+			cfg: Config{
+				Template:       current.Template,
+				WebhookURL:     "https://hooks.example.com",
+				WebhookHeaders: "invalid",
+			},
+			wantErr:   true,
+			wantSinks: 1,
+		},
+		{
+			name: "colliding webhook field mapping",
+			cfg: Config{
+				Template:            current.Template,
+				WebhookURL:          "https://hooks.example.com",
+				WebhookFieldMapping: "name=digest",
+			},
+			wantErr:   true,
+			wantSinks: 1,
+		},
+		{
+			name: "webhook removed",
+			cfg: Config{
+				Template:       current.Template,
+				MetadataLabels: "team",
+			},
+			wantSinks: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := c.cfg.Load()
+			err := c.Reload(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var sinks int
+			if s := c.sinks.Load(); s != nil {
+				sinks = len(*s)
+			}
+			if sinks != tt.wantSinks {
+				t.Errorf("sinks = %d, want %d", sinks, tt.wantSinks)
+			}
+
+			got := c.cfg.Load()
+			if tt.wantErr {
+				if got != before {
+					t.Errorf("config changed on failed reload")
+				}
+				return
+			}
+			if got.MetadataLabels != tt.cfg.MetadataLabels || got.WebhookURL != tt.cfg.WebhookURL {
+				t.Errorf("config = %+v, want metadata labels %q and webhook %q", got, tt.cfg.MetadataLabels, tt.cfg.WebhookURL)
+			}
+		})
+	}
+}
+
+// fakeSink records the events sent to it, failing with err.
+type fakeSink struct {
+	events []sink.Event
+	err    error
+}
+
+func (s *fakeSink) Name() string { return "fake" }
+
+func (s *fakeSink) Send(_ context.Context, event sink.Event) error {
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestSendToSinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		sinksOnly bool
+		err       error
+		wantErr   bool
+	}{
+		{
+			name: "sent",
+		},
+		{
+			name: "failed, best effort",
+			err:  errors.New("unavailable"),
+		},
+		{
+			name:      "sent, sinks only",
+			sinksOnly: true,
+		},
+		{
+			name:      "failed, sinks only",
+			sinksOnly: true,
+			err:       errors.New("unavailable"),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeSink{err: tt.err}
+			c := &Controller{}
+			c.cfg.Store(&Config{SinksOnly: tt.sinksOnly})
+			c.sinks.Store(&[]sink.Sink{s})
+
+			err := c.sendToSinks(context.Background(), sink.Event{
+				Type:   sink.EventDeploymentRecord,
+				Record: deploymentrecord.NewDeploymentRecord("app", "sha256:abc", "v1", "prod", "", "c1", deploymentrecord.StatusDeployed, "ns/app/web"),
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sendToSinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(s.events) != 1 {
+				t.Errorf("sent %d events, expected 1", len(s.events))
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/github/deployment-tracker/pkg/sink"
+)
+
+// deadLetterCapacity bounds the number of dead letters kept in
+// memory. The oldest dead letters are evicted first.
+const deadLetterCapacity = 1000
+
+// postError is returned when a record could not be posted, so the
+// record can be dead lettered once the event exhausts its retries.
+type postError struct {
+	record *deploymentrecord.DeploymentRecord
+	err    error
+}
+
+func (e *postError) Error() string {
+	return e.err.Error()
+}
+
+func (e *postError) Unwrap() error {
+	return e.err
+}
+
+// failedRecords returns the records of all postErrors in err's tree.
+func failedRecords(err error) []*deploymentrecord.DeploymentRecord {
+	if pe, ok := err.(*postError); ok { //nolint:errorlint
+		return []*deploymentrecord.DeploymentRecord{pe.record}
+	}
+	var res []*deploymentrecord.DeploymentRecord
+	switch e := err.(type) { //nolint:errorlint
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			res = append(res, failedRecords(inner)...)
+		}
+	case interface{ Unwrap() error }:
+		res = failedRecords(e.Unwrap())
+	}
+	return res
+}
+
+// DeadLetter is a record that could not be posted before its event
+// exhausted its retries.
+type DeadLetter struct {
+	Record    *deploymentrecord.DeploymentRecord `json:"record"`
+	EventKey  string                             `json:"event_key"`
+	EventType string                             `json:"event_type"`
+	Error     string                             `json:"error"`
+	Time      time.Time                          `json:"time"`
+}
+
+// deadLetterStore is a bounded in-memory store of dead letters.
+type deadLetterStore struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+	max     int
+}
+
+// newDeadLetterStore creates a store holding up to maxLetters dead
+// letters.
+func newDeadLetterStore(maxLetters int) *deadLetterStore {
+	return &deadLetterStore{max: maxLetters}
+}
+
+// add stores the dead letters, evicting the oldest ones if the store
+// is full.
+func (s *deadLetterStore) add(letters ...DeadLetter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.letters = append(s.letters, letters...)
+	if n := len(s.letters) - s.max; n > 0 {
+		s.letters = append([]DeadLetter(nil), s.letters[n:]...)
+	}
+	metrics.DeadLetterRecords.Set(float64(len(s.letters)))
+}
+
+// list returns a copy of the stored dead letters, oldest first.
+func (s *deadLetterStore) list() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeadLetter(nil), s.letters...)
+}
+
+// take removes and returns all stored dead letters.
+func (s *deadLetterStore) take() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := s.letters
+	s.letters = nil
+	metrics.DeadLetterRecords.Set(0)
+	return letters
+}
+
+// DeadLetters returns the records that could not be posted before
+// their events exhausted their retries, oldest first.
+func (c *Controller) DeadLetters() []DeadLetter {
+	return c.deadLetters.list()
+}
+
+// FlushDeadLetters posts the dead-lettered records again. Records
+// rejected by the API are dropped, records failing otherwise are kept
+// for a later flush. It returns the number of posted and remaining
+// dead letters.
+func (c *Controller) FlushDeadLetters(ctx context.Context) (int, int) {
+	var posted int
+	var remaining []DeadLetter
+	for _, letter := range c.deadLetters.take() {
+		start := time.Now()
+		err := c.apiClient.PostOne(ctx, letter.Record)
+		if err == nil {
+			err = c.sendToSinks(ctx, sink.Event{
+				Type:   sink.EventDeploymentRecord,
+				Record: letter.Record,
+			})
+		}
+		c.audit(auditSourceDeadLetter, letter.EventType, "", letter.Record, time.Since(start), err)
+		var clientErr *deploymentrecord.ClientError
+		switch {
+		case err == nil:
+			posted++
+			c.observeRecord(letter.Record)
+			c.dequeueRetry(letter.Record)
+		case errors.As(err, &clientErr):
+			slog.Warn("Dropping dead letter rejected by the API",
+				"deployment_name", letter.Record.DeploymentName,
+				"digest", letter.Record.Digest,
+				"error", err,
+			)
+			c.dequeueRetry(letter.Record)
+		default:
+			letter.Error = err.Error()
+			letter.Time = time.Now()
+			remaining = append(remaining, letter)
+		}
+	}
+	c.deadLetters.add(remaining...)
+
+	slog.Info("Flushed dead letters",
+		"posted", posted,
+		"remaining", len(remaining),
+	)
+	return posted, len(remaining)
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+func TestFailedRecords(t *testing.T) {
+	web := newTestRecord("ns/web/app", "sha256:a", deploymentrecord.StatusDeployed)
+	sidecar := newTestRecord("ns/web/sidecar", "sha256:b", deploymentrecord.StatusDeployed)
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "plain error",
+			err:      errors.New("boom"),
+			expected: 0,
+		},
+		{
+			name:     "post error",
+			err:      &postError{record: web, err: errors.New("boom")},
+			expected: 1,
+		},
+		{
+			name: "joined errors",
+			err: errors.Join(
+				&postError{record: web, err: errors.New("boom")},
+				errors.New("other"),
+				fmt.Errorf("wrapped: %w", &postError{record: sidecar, err: errors.New("boom")}),
+			),
+			expected: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failedRecords(tt.err); len(got) != tt.expected {
+				t.Errorf("failedRecords() returned %d records, expected %d", len(got), tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeadLetterStore(t *testing.T) {
+	s := newDeadLetterStore(2)
+	for _, key := range []string{"ns/a", "ns/b", "ns/c"} {
+		s.add(DeadLetter{EventKey: key})
+	}
+
+	letters := s.list()
+	if len(letters) != 2 || letters[0].EventKey != "ns/b" || letters[1].EventKey != "ns/c" {
+		t.Errorf("list() = %v, expected the two newest dead letters", letters)
+	}
+
+	if taken := s.take(); len(taken) != 2 {
+		t.Errorf("take() returned %d dead letters, expected 2", len(taken))
+	}
+	if letters := s.list(); len(letters) != 0 {
+		t.Errorf("list() after take() = %v, expected empty", letters)
+	}
+}
+
+func TestFlushDeadLetters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			DeploymentName string `json:"deployment_name"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch body.DeploymentName {
+		case "ns/rejected/app":
+			w.WriteHeader(http.StatusBadRequest)
+		case "ns/failing/app":
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	c := &Controller{apiClient: client, deadLetters: newDeadLetterStore(deadLetterCapacity)}
+	for _, dn := range []string{"ns/web/app", "ns/rejected/app", "ns/failing/app"} {
+		c.deadLetters.add(DeadLetter{
+			Record: newTestRecord(dn, "sha256:a", deploymentrecord.StatusDeployed),
+		})
+	}
+
+	posted, remaining := c.FlushDeadLetters(context.Background())
+	if posted != 1 || remaining != 1 {
+		t.Errorf("FlushDeadLetters() = %d, %d, expected 1, 1", posted, remaining)
+	}
+	letters := c.DeadLetters()
+	if len(letters) != 1 || letters[0].Record.DeploymentName != "ns/failing/app" {
+		t.Errorf("DeadLetters() = %v, expected only ns/failing/app", letters)
+	}
+	if _, ok := c.observedDeployments.load(getCacheKey("ns/web/app", "sha256:a")); !ok {
+		t.Error("posted dead letter not added to the observation cache")
+	}
+}
@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/github/deployment-tracker/pkg/sink"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// deploymentRunning returns true if a started pod of the namespace,
+// other than the deleted pod, still runs a container with the
+// deployment name and digest of cacheKey, e.g. because a replacement
+// pod came up while the decommission was delayed.
+func (c *Controller) deploymentRunning(cfg *Config, deleted *corev1.Pod, cacheKey string) bool {
+	return c.runningExcept(cfg, deleted.Namespace, cacheKey, func(pod *corev1.Pod, _ workload) bool {
+		return pod.UID == deleted.UID
+	})
+}
+
+// runningExcept returns true if a started pod of the namespace, for
+// which skip returns false, runs a container with the deployment name
+// and digest of cacheKey.
+func (c *Controller) runningExcept(cfg *Config, ns, cacheKey string, skip func(*corev1.Pod, workload) bool) bool {
+	pods, err := c.podLister.Pods(ns).List(labels.Everything())
+	if err != nil {
+		slog.Warn("Failed to list pods, assuming deployment is gone",
+			"namespace", ns,
+			"error", err,
+		)
+		return false
+	}
+
+	tmpl := c.template(cfg, ns)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || !c.podStarted(pod) {
+			continue
+		}
+		wl := c.resolveWorkload(pod)
+		if wl.Name == "" || skip(pod, wl) {
+			continue
+		}
+		for _, container := range slices.Concat(pod.Spec.Containers, pod.Spec.InitContainers) {
+			dn := getARDeploymentName(pod, container, wl, tmpl, cfg.Cluster)
+			if getCacheKey(dn, c.containerDigest(pod, container)) == cacheKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decommissionBatchSize is the maximum number of records
+// decommissioned in a single batch request when a namespace or a
+// Deployment is deleted.
+const decommissionBatchSize = 100
+
+// addNamespaceDecommissionHandlers adds the handlers of the namespace
+// informer used to decommission the records of deleted namespaces in
+// bulk. A namespace being deleted enqueues a terminating event while
+// its pods are still cached; a namespace created with the name of a
+// decommissioned one is tracked per pod again.
+func (c *Controller) addNamespaceDecommissionHandlers() error {
+	enqueue := func(ns *corev1.Namespace) {
+		if !c.namespaceTracked(ns.Name) {
+			return
+		}
+		c.queueFor(EventNamespaceTerminating).Add(PodEvent{
+			Key:       ns.Name,
+			EventType: EventNamespaceTerminating,
+		})
+	}
+
+	_, err := c.nsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			ns, ok := obj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			if ns.DeletionTimestamp != nil {
+				// Already terminating when the controller started
+				enqueue(ns)
+				return
+			}
+			c.decommissionedNs.Delete(ns.Name)
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldNs, ok := oldObj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			newNs, ok := newObj.(*corev1.Namespace)
+			if !ok || oldNs.DeletionTimestamp != nil || newNs.DeletionTimestamp == nil {
+				return
+			}
+			enqueue(newNs)
+		},
+		DeleteFunc: func(obj any) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			// Keep the namespace around for a while, so the
+			// delete events of its pods still queued are skipped
+			time.AfterFunc(decommissionedNsRetention, func() {
+				c.decommissionedNs.Delete(key)
+			})
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add namespace event handlers: %w", err)
+	}
+	return nil
+}
+
+// namespaceDecommissioned returns true if the records of the
+// namespace were decommissioned in bulk, so the delete events of its
+// pods need no processing.
+func (c *Controller) namespaceDecommissioned(ns string) bool {
+	_, ok := c.decommissionedNs.Load(ns)
+	return ok
+}
+
+// decommissionNamespace decommissions the cached records of the pods
+// of the terminating namespace, in batch requests rather than one
+// request per pod delete. The records of a failed batch are posted one
+// at a time, see postDecommission. The namespace is only marked as
+// decommissioned once all its records are, so until then the delete
+// events of its pods are processed as usual.
+func (c *Controller) decommissionNamespace(ctx context.Context, ns string) error {
+	var records []*deploymentrecord.DeploymentRecord
+	if c.namespaceRecords != nil {
+		cached := c.namespaceRecords.get(ns)
+		for _, cacheKey := range slices.Sorted(maps.Keys(cached)) {
+			if c.recorded(cacheKey) {
+				records = append(records, decommissionedRecord(cached[cacheKey]))
+			}
+		}
+	}
+
+	var errs []error
+	for batch := range slices.Chunk(records, decommissionBatchSize) {
+		start := time.Now()
+		err := c.apiClient.PostBatch(ctx, batch)
+		if err != nil {
+			slog.Warn("Failed to decommission namespace records in bulk, posting them one at a time",
+				"namespace", ns,
+				"count", len(batch),
+				"error", err,
+			)
+			for _, record := range batch {
+				errs = append(errs, c.postDecommission(ctx, EventNamespaceTerminating, ns, record))
+			}
+			continue
+		}
+		for _, record := range batch {
+			errs = append(errs, c.decommissioned(ctx, EventNamespaceTerminating, ns, record, start, nil))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to decommission records of namespace %s: %w", ns, err)
+	}
+
+	c.decommissionedNs.Store(ns, true)
+	if c.namespaceRecords != nil {
+		c.namespaceRecords.remove(ns)
+	}
+	slog.Info("Decommissioned namespace records",
+		"namespace", ns,
+		"count", len(records),
+	)
+	return nil
+}
+
+// decommissionedRecord returns a copy of the cached record r with the
+// decommissioned status.
+func decommissionedRecord(r *deploymentrecord.DeploymentRecord) *deploymentrecord.DeploymentRecord {
+	record := *r
+	record.Status = deploymentrecord.StatusDecommissioned
+	record.ObservedAt = time.Now().UTC()
+	record.Replicas = nil
+	record.RevisionReplicas = nil
+	return &record
+}
+
+// postDecommission posts a decommissioned record of a deleted
+// namespace or Deployment like the records of pod events: a record
+// rejected by the API is dropped, a record that failed to post is kept
+// in the retry queue and returned in a postError, to be dead lettered
+// once the event exhausts its retries.
+func (c *Controller) postDecommission(ctx context.Context, eventType, ns string, record *deploymentrecord.DeploymentRecord) error {
+	start := time.Now()
+	return c.decommissioned(ctx, eventType, ns, record, start, c.postRecord(ctx, record))
+}
+
+// decommissioned handles err, the result of the post of a
+// decommissioned record of the namespace started at start, see
+// postDecommission. Once posted, the record is sent to the sinks.
+func (c *Controller) decommissioned(ctx context.Context, eventType, ns string, record *deploymentrecord.DeploymentRecord, start time.Time, err error) error {
+	if err == nil {
+		err = c.sendToSinks(ctx, sink.Event{
+			Type:      sink.EventDeploymentRecord,
+			Record:    record,
+			Namespace: ns,
+		})
+	}
+	c.audit(auditSourceEvent, eventType, ns, record, time.Since(start), err)
+	if err != nil {
+		metrics.RecordsPostedFailed.WithLabelValues(ns, deploymentrecord.StatusDecommissioned).Inc()
+
+		// Make sure to not retry on client error messages
+		var clientErr *deploymentrecord.ClientError
+		if errors.As(err, &clientErr) {
+			c.dequeueRetry(record)
+			slog.Warn("Failed to decommission record",
+				"event_type", eventType,
+				"namespace", ns,
+				"deployment_name", record.DeploymentName,
+				"digest", record.Digest,
+				"error", err,
+			)
+			return nil
+		}
+
+		slog.Error("Failed to decommission record",
+			"event_type", eventType,
+			"namespace", ns,
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+			"error", err,
+		)
+		c.enqueueRetry(record)
+		return &postError{record: record, err: err}
+	}
+	c.dequeueRetry(record)
+	metrics.RecordsPostedOk.WithLabelValues(ns, deploymentrecord.StatusDecommissioned).Inc()
+	c.observeRecord(record)
+	return nil
+}
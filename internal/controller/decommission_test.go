@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+	"github.com/github/deployment-tracker/pkg/sink"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDeploymentRunning(t *testing.T) {
+	runningPod := func(name string, uid types.UID, imageID string) *corev1.Pod {
+		pod := newTestPod("web-111")
+		pod.Name = name
+		pod.UID = uid
+		pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/web:v1"}}
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", ImageID: imageID},
+			},
+		}
+		return pod
+	}
+	deleted := runningPod("web-111-aaaaa", "a", "ghcr.io/org/web@sha256:abc")
+	key := getCacheKey("default/web/app", "sha256:abc")
+
+	terminating := runningPod("web-111-ccccc", "c", "ghcr.io/org/web@sha256:abc")
+	terminating.DeletionTimestamp = &metav1.Time{}
+
+	tests := []struct {
+		name     string
+		pods     []*corev1.Pod
+		expected bool
+	}{
+		{
+			name:     "only the deleted pod",
+			pods:     []*corev1.Pod{deleted},
+			expected: false,
+		},
+		{
+			name:     "replacement running",
+			pods:     []*corev1.Pod{deleted, runningPod("web-111-bbbbb", "b", "ghcr.io/org/web@sha256:abc")},
+			expected: true,
+		},
+		{
+			name:     "replacement with another digest",
+			pods:     []*corev1.Pod{deleted, runningPod("web-111-bbbbb", "b", "ghcr.io/org/web@sha256:def")},
+			expected: false,
+		},
+		{
+			name:     "other pod terminating",
+			pods:     []*corev1.Pod{deleted, terminating},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, pod := range tt.pods {
+				if err := indexer.Add(pod); err != nil {
+					t.Fatalf("failed to add pod: %v", err)
+				}
+			}
+			c := &Controller{
+				podLister: corelisters.NewPodLister(indexer),
+				rsLister:  newTestReplicaSetLister(t, newTestReplicaSet("web-111", "web", "1")),
+			}
+			cfg := &Config{Template: TmplNS + "/" + TmplDN + "/" + TmplCN}
+			c.cfg.Store(cfg)
+
+			if got := c.deploymentRunning(cfg, deleted, key); got != tt.expected {
+				t.Errorf("deploymentRunning() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecommissionNamespace(t *testing.T) {
+	web := newTestRecord("default/web/app", "sha256:abc", deploymentrecord.StatusDeployed)
+	db := newTestRecord("default/db/postgres", "sha256:def", deploymentrecord.StatusDeployed)
+	// Decommissioned with its last pod, so not decommissioned again
+	old := newTestRecord("default/web/app", "sha256:old", deploymentrecord.StatusDeployed)
+
+	tests := []struct {
+		name           string
+		failNext       int
+		failStatus     int
+		wantErr        bool
+		wantRequests   int
+		wantPosted     int
+		decommissioned bool
+	}{
+		{
+			name:           "posted",
+			wantRequests:   1,
+			wantPosted:     2,
+			decommissioned: true,
+		},
+		{
+			name:           "batch failed, posted one at a time",
+			failNext:       1,
+			failStatus:     http.StatusInternalServerError,
+			wantRequests:   3,
+			wantPosted:     2,
+			decommissioned: true,
+		},
+		{
+			name:           "failed",
+			failNext:       3,
+			failStatus:     http.StatusInternalServerError,
+			wantErr:        true,
+			wantRequests:   3,
+			decommissioned: false,
+		},
+		{
+			name:           "one record failed",
+			failNext:       2,
+			failStatus:     http.StatusInternalServerError,
+			wantErr:        true,
+			wantRequests:   3,
+			wantPosted:     1,
+			decommissioned: false,
+		},
+		{
+			name:           "rejected",
+			failNext:       3,
+			failStatus:     http.StatusUnprocessableEntity,
+			wantRequests:   3,
+			decommissioned: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := deploymentrecordtest.NewServer()
+			defer srv.Close()
+			srv.FailNext(tt.failNext, tt.failStatus)
+			client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+
+			// The pods are gone from the informer cache, the
+			// records are taken from the cached records
+			c := &Controller{
+				apiClient:        client,
+				namespaceRecords: newDeploymentRecords(),
+			}
+			c.cfg.Store(&Config{Template: TmplNS + "/" + TmplDN + "/" + TmplCN})
+			for _, r := range []*deploymentrecord.DeploymentRecord{web, db, old} {
+				c.namespaceRecords.add("default", getCacheKey(r.DeploymentName, r.Digest), func() *deploymentrecord.DeploymentRecord {
+					return r
+				})
+			}
+			for _, r := range []*deploymentrecord.DeploymentRecord{web, db} {
+				c.observedDeployments.store(getCacheKey(r.DeploymentName, r.Digest), time.Time{})
+			}
+
+			err = c.decommissionNamespace(context.Background(), "default")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decommissionNamespace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := srv.Requests(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			posted := srv.Find("", deploymentrecord.StatusDecommissioned)
+			if len(posted) != tt.wantPosted || len(srv.Records()) != tt.wantPosted {
+				t.Errorf("posted %d records, %d decommissioned, want %d", len(srv.Records()), len(posted), tt.wantPosted)
+			}
+			if got := c.namespaceDecommissioned("default"); got != tt.decommissioned {
+				t.Errorf("namespaceDecommissioned() = %v, want %v", got, tt.decommissioned)
+			}
+			if tt.wantErr && len(failedRecords(err)) != 2-tt.wantPosted {
+				t.Errorf("failed records = %d, want %d", len(failedRecords(err)), 2-tt.wantPosted)
+			}
+		})
+	}
+}
+
+func TestPostDecommissionSinksOnly(t *testing.T) {
+	client, err := deploymentrecord.NewClient("https://api.example.com", "my-org", deploymentrecord.WithoutAPI())
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	s := &fakeSink{err: errors.New("unavailable")}
+	c := &Controller{apiClient: client}
+	c.cfg.Store(&Config{SinksOnly: true})
+	c.sinks.Store(&[]sink.Sink{s})
+	record := newTestRecord("default/web/app", "sha256:abc", deploymentrecord.StatusDeployed)
+	cacheKey := getCacheKey(record.DeploymentName, record.Digest)
+	c.observedDeployments.store(cacheKey, time.Time{})
+
+	// The sinks are the only delivery path, so the record is retried
+	err = c.postDecommission(context.Background(), EventDeploymentDeleted, "default", decommissionedRecord(record))
+	if len(failedRecords(err)) != 1 {
+		t.Errorf("postDecommission() error = %v, expected a failed record", err)
+	}
+	if !c.recorded(cacheKey) {
+		t.Error("record no longer observed after a failed sink send")
+	}
+
+	s.err = nil
+	if err := c.postDecommission(context.Background(), EventDeploymentDeleted, "default", decommissionedRecord(record)); err != nil {
+		t.Errorf("postDecommission() unexpected error: %v", err)
+	}
+	if c.recorded(cacheKey) {
+		t.Error("record still observed after decommission")
+	}
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// deploymentConfigAnnotation is set by OpenShift on the
+	// ReplicationControllers of a DeploymentConfig and their pods,
+	// holding the name of the DeploymentConfig.
+	deploymentConfigAnnotation = "openshift.io/deployment-config.name"
+	// deploymentConfigLabel is set by OpenShift on the pods of a
+	// DeploymentConfig, holding its name.
+	deploymentConfigLabel = "deploymentconfig"
+)
+
+// deploymentConfigGVR is the resource of OpenShift
+// DeploymentConfigs, read with the dynamic client as they are not part
+// of the Kubernetes API.
+var deploymentConfigGVR = schema.GroupVersionResource{
+	Group:    "apps.openshift.io",
+	Version:  "v1",
+	Resource: "deploymentconfigs",
+}
+
+// deploymentConfigName returns the name of the DeploymentConfig
+// owning the pod's ReplicationController, or "" if the pod is not
+// part of a DeploymentConfig, e.g. for bare ReplicationControllers.
+func deploymentConfigName(pod *corev1.Pod) string {
+	return cmp.Or(pod.Annotations[deploymentConfigAnnotation], pod.Labels[deploymentConfigLabel])
+}
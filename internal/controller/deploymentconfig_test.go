@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestResolveDeploymentConfig(t *testing.T) {
+	newRCPod := func(annotations, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "web-3-abcde",
+				Namespace:   "default",
+				Annotations: annotations,
+				Labels:      labels,
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicationController", Name: "web-3"},
+				},
+			},
+		}
+	}
+	web := workload{Kind: kindDeploymentConfig, Name: "web"}
+
+	tests := []struct {
+		name              string
+		deploymentConfigs bool
+		pod               *corev1.Pod
+		expected          workload
+	}{
+		{
+			name:              "annotation",
+			deploymentConfigs: true,
+			pod:               newRCPod(map[string]string{deploymentConfigAnnotation: "web"}, nil),
+			expected:          web,
+		},
+		{
+			name:              "label",
+			deploymentConfigs: true,
+			pod:               newRCPod(nil, map[string]string{deploymentConfigLabel: "web"}),
+			expected:          web,
+		},
+		{
+			name:              "bare replicationcontroller",
+			deploymentConfigs: true,
+			pod:               newRCPod(nil, nil),
+			expected:          workload{},
+		},
+		{
+			name:     "disabled",
+			pod:      newRCPod(map[string]string{deploymentConfigAnnotation: "web"}, nil),
+			expected: workload{},
+		},
+		{
+			name:              "ignored",
+			deploymentConfigs: true,
+			pod: newRCPod(map[string]string{
+				deploymentConfigAnnotation: "web",
+				ignoreAnnotation:           "true",
+			}, nil),
+			expected: workload{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{}
+			c.cfg.Store(&Config{DeploymentConfigs: tt.deploymentConfigs})
+
+			if got := c.resolveWorkload(tt.pod); got != tt.expected {
+				t.Errorf("resolveWorkload() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeploymentConfigExists(t *testing.T) {
+	dc := &unstructured.Unstructured{}
+	dc.SetAPIVersion("apps.openshift.io/v1")
+	dc.SetKind("DeploymentConfig")
+	dc.SetNamespace("default")
+	dc.SetName("web")
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentConfigGVR: "DeploymentConfigList"}, dc)
+
+	tests := []struct {
+		name     string
+		client   dynamic.Interface
+		wl       string
+		expected bool
+	}{
+		{
+			name:     "exists",
+			client:   client,
+			wl:       "web",
+			expected: true,
+		},
+		{
+			name:     "deleted",
+			client:   client,
+			wl:       "api",
+			expected: false,
+		},
+		{
+			// Never decommissioned without a dynamic client
+			name:     "no dynamic client",
+			wl:       "api",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{dynamicClient: tt.client}
+			wl := workload{Kind: kindDeploymentConfig, Name: tt.wl}
+			if got := c.workloadExists(context.Background(), "default", wl); got != tt.expected {
+				t.Errorf("workloadExists() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+)
+
+// deploymentRecords associates the Deployments (keyed by
+// namespace/name) with the records posted for their pods, so all
+// records of a Deployment, including those of previous revisions, can
+// be decommissioned when the Deployment is deleted. The records of
+// previous revisions are dropped once decommissioned, when the last
+// pod of their ReplicaSet is gone. It also associates namespaces with
+// the records of their pods, see Controller.namespaceRecords.
+type deploymentRecords struct {
+	mu      sync.Mutex
+	records map[string]map[string]*deploymentrecord.DeploymentRecord // deployment key -> cache key -> record
+}
+
+// newDeploymentRecords creates an empty deploymentRecords.
+func newDeploymentRecords() *deploymentRecords {
+	return &deploymentRecords{
+		records: make(map[string]map[string]*deploymentrecord.DeploymentRecord),
+	}
+}
+
+// add associates the record with the cache key with the Deployment
+// with the key, and returns true if it wasn't associated yet.
+// newRecord is only called if the record isn't associated yet.
+func (d *deploymentRecords) add(key, cacheKey string, newRecord func() *deploymentrecord.DeploymentRecord) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	records, ok := d.records[key]
+	if !ok {
+		records = make(map[string]*deploymentrecord.DeploymentRecord)
+		d.records[key] = records
+	}
+	if _, ok := records[cacheKey]; ok {
+		return false
+	}
+	records[cacheKey] = newRecord()
+	return true
+}
+
+// get returns the records associated with the Deployment with the key,
+// keyed by cache key.
+func (d *deploymentRecords) get(key string) map[string]*deploymentrecord.DeploymentRecord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return maps.Clone(d.records[key])
+}
+
+// remove drops the records associated with the Deployment with the
+// key.
+func (d *deploymentRecords) remove(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.records, key)
+}
+
+// forget drops the associations of the record with the cache key, and
+// the Deployments left without records. It returns true if the record
+// was associated.
+func (d *deploymentRecords) forget(cacheKey string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var found bool
+	for key, records := range d.records {
+		if _, ok := records[cacheKey]; !ok {
+			continue
+		}
+		found = true
+		delete(records, cacheKey)
+		if len(records) == 0 {
+			delete(d.records, key)
+		}
+	}
+	return found
+}
+
+// snapshot returns the records associated with each Deployment, with
+// only the fields needed to decommission them, to be persisted.
+func (d *deploymentRecords) snapshot() map[string][]*deploymentrecord.DeploymentRecord {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := make(map[string][]*deploymentrecord.DeploymentRecord, len(d.records))
+	for key, records := range d.records {
+		for _, cacheKey := range slices.Sorted(maps.Keys(records)) {
+			r := records[cacheKey]
+			res[key] = append(res[key], deploymentrecord.NewDeploymentRecord(r.Name, r.Digest, r.Version,
+				r.LogicalEnvironment, r.PhysicalEnvironment, r.Cluster, r.Status, r.DeploymentName))
+		}
+	}
+	return res
+}
+
+// load associates the persisted records with their Deployments, unless
+// already associated.
+func (d *deploymentRecords) load(deployments map[string][]*deploymentrecord.DeploymentRecord) {
+	for key, records := range deployments {
+		for _, r := range records {
+			d.add(key, getCacheKey(r.DeploymentName, r.Digest), func() *deploymentrecord.DeploymentRecord {
+				return r
+			})
+		}
+	}
+}
+
+// associateRecord associates the record of a container of the pod with
+// the pod's namespace, with namespace decommission, and with the pod's
+// Deployment, if the pod is owned by one. The pods of Knative
+// revisions are associated with the Deployment of their revision, which
+// is deleted when the Revision is garbage collected.
+func (c *Controller) associateRecord(pod *corev1.Pod, wl workload, cacheKey string, newRecord func() *deploymentrecord.DeploymentRecord) {
+	if c.namespaceRecords != nil {
+		c.namespaceRecords.add(pod.Namespace, cacheKey, newRecord)
+	}
+	if c.deploymentRecords == nil {
+		return
+	}
+	switch wl.Kind {
+	case kindDeployment:
+	case kindKnativeService:
+		wl = c.resolveReplicaSetOwner(pod, workload{Kind: kindDeployment, Name: getDeploymentName(pod)})
+	default:
+		return
+	}
+	if c.deploymentRecords.add(pod.Namespace+"/"+wl.Name, cacheKey, newRecord) {
+		c.cacheDirty.Store(true)
+	}
+}
+
+// loadDeploymentRecords restores the persisted records of each
+// Deployment from the cache store, and enqueues a delete event for the
+// Deployments deleted while the controller was down, whose delete
+// events were missed. It must be called once the caches are synced.
+func (c *Controller) loadDeploymentRecords(ctx context.Context) {
+	deployments, err := c.cacheStore.loadDeployments(ctx)
+	if err != nil {
+		slog.Warn("Failed to load deployment records",
+			"error", err,
+		)
+		return
+	}
+	c.deploymentRecords.load(deployments)
+
+	var missing int
+	for key := range deployments {
+		ns, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil || !c.namespaceTracked(ns) {
+			continue
+		}
+		if _, err := c.deploymentLister.Deployments(ns).Get(name); !k8serrors.IsNotFound(err) {
+			continue
+		}
+		c.queueFor(EventDeploymentDeleted).Add(PodEvent{
+			Key:       key,
+			EventType: EventDeploymentDeleted,
+		})
+		missing++
+	}
+	slog.Info("Loaded deployment records",
+		"count", len(deployments),
+		"deleted", missing,
+	)
+}
+
+// addDeploymentDeleteHandlers adds the handlers of the Deployment
+// informers used to decommission the records of deleted Deployments.
+// The event is delayed by the decommission grace period, like pod
+// deletes, so a Deployment deleted and recreated doesn't flap.
+func (c *Controller) addDeploymentDeleteHandlers(informers []cache.SharedIndexInformer) error {
+	handlers := cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj any) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			ns, _, err := cache.SplitMetaNamespaceKey(key)
+			if err != nil || !c.namespaceTracked(ns) {
+				return
+			}
+			c.queueFor(EventDeploymentDeleted).AddAfter(PodEvent{
+				Key:       key,
+				EventType: EventDeploymentDeleted,
+			}, c.cfg.Load().DecommissionGracePeriod)
+		},
+	}
+	for _, informer := range informers {
+		if _, err := informer.AddEventHandler(handlers); err != nil {
+			return fmt.Errorf("failed to add deployment event handlers: %w", err)
+		}
+	}
+	return nil
+}
+
+// decommissionDeployment decommissions the records associated with the
+// deleted Deployment with the key, whatever the revision they were
+// posted for, unless another workload still runs them, e.g. because
+// the Deployment was recreated under another name with a template
+// without the Deployment name. The records are posted like those of
+// pods, through the batcher if batch posting is enabled. If posting
+// fails, the records left are kept and the event is retried; records
+// rejected by the API are not retried.
+func (c *Controller) decommissionDeployment(ctx context.Context, key string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	if c.namespaceDecommissioned(ns) {
+		c.deploymentRecords.remove(key)
+		return nil
+	}
+	wl := workload{Kind: kindDeployment, Name: name}
+	if c.workloadExists(ctx, ns, wl) {
+		slog.Debug("Deployment recreated, skipping decommission",
+			"namespace", ns,
+			"deployment", name,
+		)
+		return nil
+	}
+
+	cfg := c.cfg.Load()
+	associated := c.deploymentRecords.get(key)
+	var records []*deploymentrecord.DeploymentRecord
+	for _, cacheKey := range slices.Sorted(maps.Keys(associated)) {
+		if !c.recorded(cacheKey) {
+			// Already decommissioned with the last pod
+			continue
+		}
+		running := c.runningExcept(cfg, ns, cacheKey, func(pod *corev1.Pod, other workload) bool {
+			// The pods of a Knative revision are resolved to
+			// their Service, not the deleted Deployment
+			return other == wl || getDeploymentName(pod) == name
+		})
+		if running {
+			continue
+		}
+		records = append(records, decommissionedRecord(associated[cacheKey]))
+	}
+
+	var errs []error
+	for _, record := range records {
+		errs = append(errs, c.postDecommission(ctx, EventDeploymentDeleted, ns, record))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to decommission records of deployment %s: %w", key, err)
+	}
+
+	c.deploymentRecords.remove(key)
+	c.cacheDirty.Store(true)
+	if len(records) > 0 {
+		slog.Info("Decommissioned deployment records",
+			"namespace", ns,
+			"deployment", name,
+			"count", len(records),
+		)
+	}
+	return nil
+}
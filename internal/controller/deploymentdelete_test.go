@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestDeploymentRecords(t *testing.T) {
+	d := newDeploymentRecords()
+	calls := 0
+	newRecord := func() *deploymentrecord.DeploymentRecord {
+		calls++
+		return deploymentrecordtest.Fixture(calls)
+	}
+
+	d.add("default/web", "a", newRecord)
+	d.add("default/web", "a", newRecord)
+	d.add("default/web", "b", newRecord)
+	d.add("default/api", "a", newRecord)
+	if calls != 3 {
+		t.Errorf("newRecord() calls = %d, expected 3", calls)
+	}
+	if got := d.get("default/web"); len(got) != 2 {
+		t.Errorf("get() = %d records, expected 2", len(got))
+	}
+
+	d.remove("default/web")
+	if got := d.get("default/web"); len(got) != 0 {
+		t.Errorf("get() after remove() = %d records, expected 0", len(got))
+	}
+	if got := d.get("default/api"); len(got) != 1 {
+		t.Errorf("get() of another deployment = %d records, expected 1", len(got))
+	}
+
+	d.add("default/web", "b", newRecord)
+	if !d.forget("a") {
+		t.Errorf("forget() of an associated record = false, expected true")
+	}
+	if _, ok := d.records["default/api"]; ok {
+		t.Errorf("deployment without records kept after forget()")
+	}
+	if got := d.get("default/web"); len(got) != 1 {
+		t.Errorf("get() of an unrelated deployment after forget() = %d records, expected 1", len(got))
+	}
+	if d.forget("a") {
+		t.Errorf("forget() of a forgotten record = true, expected false")
+	}
+}
+
+func TestObserveDecommissionedRecord(t *testing.T) {
+	c := &Controller{deploymentRecords: newDeploymentRecords()}
+	old := newTestRecord("default/web/app", "sha256:aaa", deploymentrecord.StatusDeployed)
+	current := newTestRecord("default/web/app", "sha256:bbb", deploymentrecord.StatusDeployed)
+	for _, r := range []*deploymentrecord.DeploymentRecord{old, current} {
+		c.observeRecord(r)
+		c.deploymentRecords.add("default/web", getCacheKey(r.DeploymentName, r.Digest), func() *deploymentrecord.DeploymentRecord {
+			return r
+		})
+	}
+
+	// The last pod of the previous revision is gone
+	decommissioned := *old
+	decommissioned.Status = deploymentrecord.StatusDecommissioned
+	c.observeRecord(&decommissioned)
+
+	got := c.deploymentRecords.get("default/web")
+	if len(got) != 1 || got[getCacheKey(current.DeploymentName, current.Digest)] == nil {
+		t.Errorf("associated records = %v, expected only the current revision", got)
+	}
+}
+
+func TestDecommissionDeployment(t *testing.T) {
+	const (
+		digestOld = "sha256:aaa"
+		digestNew = "sha256:bbb"
+	)
+	runningPod := func(name, rsName, digest string) *corev1.Pod {
+		pod := newTestPod(rsName)
+		pod.Name = name
+		pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/web:v1"}}
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", ImageID: "ghcr.io/org/web@" + digest},
+			},
+		}
+		return pod
+	}
+	record := func(dn, digest string) *deploymentrecord.DeploymentRecord {
+		return deploymentrecord.NewDeploymentRecord("ghcr.io/org/web", digest, "v1",
+			"", "", "", deploymentrecord.StatusDeployed, dn)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		objects  []runtime.Object
+		pods     []*corev1.Pod
+		status   int
+		wantErr  bool
+		// decommissioned are the digests expected to be
+		// decommissioned
+		decommissioned []string
+		// kept is true if the associations are kept
+		kept bool
+		// requests is the number of requests to the API
+		requests int
+	}{
+		{
+			name:     "all revisions",
+			template: TmplNS + "/" + TmplDN + "/" + TmplCN,
+			// The pods of the deleted deployment are still
+			// running, garbage collection comes after
+			pods:           []*corev1.Pod{runningPod("web-222-aaaaa", "web-222", digestNew)},
+			decommissioned: []string{digestOld, digestNew},
+			requests:       2,
+		},
+		{
+			name:     "recreated",
+			template: TmplNS + "/" + TmplDN + "/" + TmplCN,
+			objects: []runtime.Object{&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			}},
+			kept: true,
+		},
+		{
+			name: "running under another name",
+			// Without the deployment name, the renamed
+			// deployment shares the records
+			template: TmplNS + "/" + TmplCN,
+			pods: []*corev1.Pod{
+				runningPod("web-222-aaaaa", "web-222", digestNew),
+				runningPod("web2-333-aaaaa", "web2-333", digestNew),
+			},
+			decommissioned: []string{digestOld},
+			requests:       1,
+		},
+		{
+			name:     "failed",
+			template: TmplNS + "/" + TmplDN + "/" + TmplCN,
+			// The failed record is retried with the event, the
+			// others are decommissioned
+			status:         http.StatusInternalServerError,
+			wantErr:        true,
+			decommissioned: []string{digestNew},
+			kept:           true,
+			requests:       2,
+		},
+		{
+			name:     "rejected",
+			template: TmplNS + "/" + TmplDN + "/" + TmplCN,
+			// The rejected record is not retried, the others
+			// are decommissioned
+			status:         http.StatusUnprocessableEntity,
+			decommissioned: []string{digestNew},
+			requests:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := deploymentrecordtest.NewServer()
+			defer srv.Close()
+			if tt.status != 0 {
+				srv.FailNext(1, tt.status)
+			}
+			client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, pod := range tt.pods {
+				if err := indexer.Add(pod); err != nil {
+					t.Fatalf("failed to add pod: %v", err)
+				}
+			}
+			c := &Controller{
+				clientset: fake.NewClientset(tt.objects...),
+				apiClient: client,
+				podLister: corelisters.NewPodLister(indexer),
+				rsLister: newTestReplicaSetLister(t,
+					newTestReplicaSet("web-222", "web", "2"),
+					newTestReplicaSet("web2-333", "web2", "1"),
+				),
+				deploymentRecords: newDeploymentRecords(),
+			}
+			c.cfg.Store(&Config{Template: tt.template})
+
+			dn := "default/web/app"
+			if tt.template == TmplNS+"/"+TmplCN {
+				dn = "default/app"
+			}
+			for _, digest := range []string{digestOld, digestNew} {
+				cacheKey := getCacheKey(dn, digest)
+				c.observedDeployments.store(cacheKey, time.Time{})
+				c.deploymentRecords.add("default/web", cacheKey, func() *deploymentrecord.DeploymentRecord {
+					return record(dn, digest)
+				})
+			}
+
+			err = c.decommissionDeployment(context.Background(), "default/web")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decommissionDeployment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			posted := srv.Find(dn, deploymentrecord.StatusDecommissioned)
+			if len(posted) != len(tt.decommissioned) {
+				t.Fatalf("decommissioned records = %d, expected %d", len(posted), len(tt.decommissioned))
+			}
+			for i, digest := range tt.decommissioned {
+				if posted[i].Digest != digest {
+					t.Errorf("decommissioned digest = %s, expected %s", posted[i].Digest, digest)
+				}
+				if c.recorded(getCacheKey(dn, digest)) {
+					t.Errorf("record of %s still observed after decommission", digest)
+				}
+			}
+			if got := srv.Requests(); got != tt.requests {
+				t.Errorf("requests = %d, expected %d", got, tt.requests)
+			}
+			if got := len(c.deploymentRecords.get("default/web")) > 0; got != tt.kept {
+				t.Errorf("associations kept = %v, expected %v", got, tt.kept)
+			}
+		})
+	}
+}
+
+func TestLoadDeploymentRecords(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+	store := newConfigMapStore(clientset, "deployment-tracker", "cache")
+	persisted := newDeploymentRecords()
+	for _, name := range []string{"web", "api"} {
+		dn := "default/" + name + "/app"
+		persisted.add("default/"+name, getCacheKey(dn, "sha256:abc"), func() *deploymentrecord.DeploymentRecord {
+			return deploymentrecord.NewDeploymentRecord("ghcr.io/org/"+name, "sha256:abc", "v1",
+				"", "", "", deploymentrecord.StatusDeployed, dn)
+		})
+	}
+	if err := store.save(ctx, nil, persisted.snapshot()); err != nil {
+		t.Fatalf("save() unexpected error: %v", err)
+	}
+
+	// Only web still exists
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := indexer.Add(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	}); err != nil {
+		t.Fatalf("failed to add deployment: %v", err)
+	}
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[PodEvent]())
+	defer queue.ShutDown()
+	c := &Controller{
+		workqueue:         queue,
+		deploymentLister:  appslisters.NewDeploymentLister(indexer),
+		deploymentRecords: newDeploymentRecords(),
+		cacheStore:        store,
+	}
+	c.cfg.Store(&Config{})
+	c.reloadedNs.Store(&namespaceList{})
+
+	c.loadDeploymentRecords(ctx)
+	if got := len(c.deploymentRecords.get("default/web")) + len(c.deploymentRecords.get("default/api")); got != 2 {
+		t.Errorf("loaded records = %d, expected 2", got)
+	}
+	if queue.Len() != 1 {
+		t.Fatalf("queue length = %d, expected 1", queue.Len())
+	}
+	event, _ := queue.Get()
+	if event.Key != "default/api" || event.EventType != EventDeploymentDeleted {
+		t.Errorf("event = %+v, expected the delete of default/api", event)
+	}
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// argoCDTrackingAnnotation is set by Argo CD on the resources it
+	// applies with annotation tracking, holding
+	// <app>:<group>/<kind>:<namespace>/<name>.
+	argoCDTrackingAnnotation = "argocd.argoproj.io/tracking-id"
+	// argoCDInstanceLabel is set by Argo CD on the resources it
+	// applies with label tracking, holding the Application name.
+	argoCDInstanceLabel = "argocd.argoproj.io/instance"
+	// fluxKustomizationLabel and fluxKustomizationNamespaceLabel are
+	// set by Flux on the resources applied by a Kustomization.
+	fluxKustomizationLabel          = "kustomize.toolkit.fluxcd.io/name"
+	fluxKustomizationNamespaceLabel = "kustomize.toolkit.fluxcd.io/namespace"
+	// fluxHelmReleaseLabel and fluxHelmReleaseNamespaceLabel are set
+	// by Flux on the resources installed by a HelmRelease.
+	fluxHelmReleaseLabel          = "helm.toolkit.fluxcd.io/name"
+	fluxHelmReleaseNamespaceLabel = "helm.toolkit.fluxcd.io/namespace"
+)
+
+// gitOpsApp returns the GitOps application that applied the pod's
+// workload, read from the well-known Argo CD and Flux labels and
+// annotations of the pod, its ReplicaSet or Job, or its Deployment, or
+// nil if none is set.
+func (c *Controller) gitOpsApp(pod *corev1.Pod) *deploymentrecord.GitOps {
+	for _, m := range c.workloadObjectMeta(pod) {
+		if app := objectGitOpsApp(m); app != nil {
+			return app
+		}
+	}
+	return nil
+}
+
+// objectGitOpsApp returns the GitOps application of the object, or nil
+// if it has no GitOps labels or annotations.
+func objectGitOpsApp(m *metav1.ObjectMeta) *deploymentrecord.GitOps {
+	if app, _, ok := strings.Cut(m.Annotations[argoCDTrackingAnnotation], ":"); ok && app != "" {
+		return &deploymentrecord.GitOps{Tool: "argocd", Kind: "Application", Name: app}
+	}
+	if app := m.Labels[argoCDInstanceLabel]; app != "" {
+		return &deploymentrecord.GitOps{Tool: "argocd", Kind: "Application", Name: app}
+	}
+	if name := m.Labels[fluxKustomizationLabel]; name != "" {
+		return &deploymentrecord.GitOps{Tool: "flux", Kind: "Kustomization",
+			Name: fluxName(m.Labels[fluxKustomizationNamespaceLabel], name)}
+	}
+	if name := m.Labels[fluxHelmReleaseLabel]; name != "" {
+		return &deploymentrecord.GitOps{Tool: "flux", Kind: "HelmRelease",
+			Name: fluxName(m.Labels[fluxHelmReleaseNamespaceLabel], name)}
+	}
+	return nil
+}
+
+// fluxName returns the name of a Flux object prefixed with its
+// namespace, if known.
+func fluxName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
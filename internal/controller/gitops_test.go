@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGitOpsApp(t *testing.T) {
+	newDeployment := func(name string, labels, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Labels:      labels,
+				Annotations: annotations,
+			},
+		}
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, d := range []*appsv1.Deployment{
+		newDeployment("tracked", nil, map[string]string{
+			argoCDTrackingAnnotation: "shop:apps/Deployment:default/tracked",
+		}),
+		newDeployment("labeled", map[string]string{argoCDInstanceLabel: "shop"}, nil),
+		newDeployment("kustomized", map[string]string{
+			fluxKustomizationLabel:          "apps",
+			fluxKustomizationNamespaceLabel: "flux-system",
+		}, nil),
+		newDeployment("released", map[string]string{fluxHelmReleaseLabel: "redis"}, nil),
+		newDeployment("manual", nil, nil),
+	} {
+		if err := indexer.Add(d); err != nil {
+			t.Fatalf("failed to add deployment: %v", err)
+		}
+	}
+	c := &Controller{
+		rsLister: newTestReplicaSetLister(t,
+			newTestReplicaSet("tracked-1", "tracked", "1"),
+			newTestReplicaSet("labeled-1", "labeled", "1"),
+			newTestReplicaSet("kustomized-1", "kustomized", "1"),
+			newTestReplicaSet("released-1", "released", "1"),
+			newTestReplicaSet("manual-1", "manual", "1"),
+		),
+		deploymentLister: appslisters.NewDeploymentLister(indexer),
+	}
+
+	podLabeled := newTestPod("manual-1")
+	podLabeled.Labels = map[string]string{argoCDInstanceLabel: "pods"}
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected *deploymentrecord.GitOps
+	}{
+		{
+			name:     "argo cd annotation tracking",
+			pod:      newTestPod("tracked-1"),
+			expected: &deploymentrecord.GitOps{Tool: "argocd", Kind: "Application", Name: "shop"},
+		},
+		{
+			name:     "argo cd label tracking",
+			pod:      newTestPod("labeled-1"),
+			expected: &deploymentrecord.GitOps{Tool: "argocd", Kind: "Application", Name: "shop"},
+		},
+		{
+			name:     "flux kustomization",
+			pod:      newTestPod("kustomized-1"),
+			expected: &deploymentrecord.GitOps{Tool: "flux", Kind: "Kustomization", Name: "flux-system/apps"},
+		},
+		{
+			name:     "flux helm release",
+			pod:      newTestPod("released-1"),
+			expected: &deploymentrecord.GitOps{Tool: "flux", Kind: "HelmRelease", Name: "redis"},
+		},
+		{
+			name:     "pod label",
+			pod:      podLabeled,
+			expected: &deploymentrecord.GitOps{Tool: "argocd", Kind: "Application", Name: "pods"},
+		},
+		{
+			name: "not gitops",
+			pod:  newTestPod("manual-1"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.gitOpsApp(tt.pod); !equalPtr(got, tt.expected) {
+				t.Errorf("gitOpsApp() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
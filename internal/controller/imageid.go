@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/github/deployment-tracker/pkg/image"
+	"github.com/github/deployment-tracker/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// containerDigest returns the image digest of the container: the one
+// of its imageID, else the one its image reference is pinned to, else
+// the one resolveImageID resolved for its imageID. The imageIDs of some
+// CRI-O nodes are local image store IDs without a registry digest.
+func (c *Controller) containerDigest(pod *corev1.Pod, container corev1.Container) string {
+	if digest := getContainerDigest(pod, container.Name); digest != "" {
+		return digest
+	}
+	imageID := getContainerImageID(pod, container.Name)
+	if imageID == "" {
+		return ""
+	}
+	if ref, err := image.ParseReference(container.Image); err == nil && ref.Digest != "" {
+		return ref.Digest
+	}
+	if digest, ok := c.imageIDDigests.Load(imageIDKey(imageID, container.Image)); ok {
+		return digest.(string)
+	}
+	return ""
+}
+
+// resolveImageID resolves the digest of the image of a container whose
+// imageID has no digest from the registry of its image reference, if
+// ResolveImageIDs is enabled, and remembers it for containerDigest.
+// Otherwise, or if the registry fails, the container is skipped rather
+// than recorded with the imageID as its digest.
+func (c *Controller) resolveImageID(ctx context.Context, cfg *Config, pod *corev1.Pod, container corev1.Container) string {
+	imageID := getContainerImageID(pod, container.Name)
+	if imageID == "" {
+		return ""
+	}
+	if !cfg.ResolveImageIDs {
+		slog.Warn("Skipping container with an imageID without a digest",
+			"namespace", pod.Namespace,
+			"pod", pod.Name,
+			"container", container.Name,
+			"image", container.Image,
+			"image_id", imageID,
+		)
+		metrics.NonDigestImageIDs.WithLabelValues(pod.Namespace, "skipped").Inc()
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	digest, err := c.registry.Digest(ctx, container.Image)
+	if err != nil {
+		slog.Warn("Failed to resolve the digest of an imageID from the registry",
+			"namespace", pod.Namespace,
+			"pod", pod.Name,
+			"container", container.Name,
+			"image", container.Image,
+			"image_id", imageID,
+			"error", err,
+		)
+		metrics.NonDigestImageIDs.WithLabelValues(pod.Namespace, "failed").Inc()
+		return ""
+	}
+	c.imageIDDigests.Store(imageIDKey(imageID, container.Image), digest)
+	metrics.NonDigestImageIDs.WithLabelValues(pod.Namespace, "resolved").Inc()
+	return digest
+}
+
+// imageIDKey is the key of the digest resolved for an imageID. The
+// image reference is part of it, as a local image may be tagged as
+// several images.
+func imageIDKey(imageID, ref string) string {
+	return imageID + " " + ref
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/registry"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestContainerDigest(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/org/app/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:a")
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	const storeID = "6f7b1c5fbd0d3e5b0c6a8e4f0c1f4a2e9b8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b"
+	tests := []struct {
+		name     string
+		image    string
+		imageID  string
+		resolve  bool
+		expected string
+	}{
+		{
+			name:     "digest",
+			image:    host + "/org/app:v1",
+			imageID:  host + "/org/app@sha256:b",
+			expected: "sha256:b",
+		},
+		{
+			name:    "not started",
+			image:   host + "/org/app:v1",
+			resolve: true,
+		},
+		{
+			name:     "pinned image",
+			image:    host + "/org/app@sha256:c",
+			imageID:  storeID,
+			expected: "sha256:c",
+		},
+		{
+			name:    "skipped",
+			image:   host + "/org/app:v1",
+			imageID: storeID,
+		},
+		{
+			name:     "resolved",
+			image:    host + "/org/app:v1",
+			imageID:  storeID,
+			resolve:  true,
+			expected: "sha256:a",
+		},
+		{
+			name:    "unknown tag",
+			image:   host + "/org/app:v2",
+			imageID: storeID,
+			resolve: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{registry: registry.NewClient(registry.WithHTTPClient(srv.Client()))}
+			container := corev1.Container{Name: "app", Image: tt.image}
+			pod := &corev1.Pod{Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "app", ImageID: tt.imageID}},
+			}}
+
+			got := c.containerDigest(pod, container)
+			if got == "" {
+				got = c.resolveImageID(context.Background(), &Config{ResolveImageIDs: tt.resolve}, pod, container)
+			}
+			if got != tt.expected {
+				t.Errorf("digest = %q, expected %q", got, tt.expected)
+			}
+			// The resolved digest is remembered for decommissioning
+			if got := c.containerDigest(pod, container); got != tt.expected {
+				t.Errorf("containerDigest() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	integrationTimeout = 5 * time.Second
+	// integrationSettle is how long the harness waits for an event
+	// to be processed before asserting that nothing was posted
+	integrationSettle = 300 * time.Millisecond
+
+	digestV1 = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	digestV2 = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// integrationHarness runs a controller against a fake clientset and a
+// fake record API.
+type integrationHarness struct {
+	t         *testing.T
+	ctx       context.Context
+	clientset *fake.Clientset
+	api       *deploymentrecordtest.Server
+	c         *Controller
+}
+
+// newIntegrationHarness starts a controller with cfg, pointed at a
+// fake record API, and waits for its caches to sync. A single worker
+// is run, so events are processed in order and the observation cache
+// deduplicates deterministically.
+func newIntegrationHarness(t *testing.T, cfg *Config) *integrationHarness {
+	t.Helper()
+
+	api := deploymentrecordtest.NewServer()
+	t.Cleanup(api.Close)
+
+	cfg.BaseURL = api.URL
+	cfg.Organization = "my-org"
+	if cfg.Template == "" {
+		cfg.Template = TmplNS + "/" + TmplDN + "/" + TmplCN
+	}
+
+	clientset := fake.NewClientset()
+	c, err := New(clientset, "", "", cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		if err := c.Run(ctx, 1); err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	}()
+
+	h := &integrationHarness{t: t, ctx: ctx, clientset: clientset, api: api, c: c}
+	h.eventually("caches synced", c.synced.Load)
+	return h
+}
+
+// eventually fails the test if cond doesn't hold within
+// integrationTimeout.
+func (h *integrationHarness) eventually(what string, cond func() bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(integrationTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// find returns the posted records with the status and digest.
+func (h *integrationHarness) find(status, digest string) []*deploymentrecord.DeploymentRecord {
+	var res []*deploymentrecord.DeploymentRecord
+	for _, r := range h.api.Find("", status) {
+		if r.Digest == digest {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// expectPosted waits for a record with the status and digest.
+func (h *integrationHarness) expectPosted(status, digest string) *deploymentrecord.DeploymentRecord {
+	h.t.Helper()
+	h.eventually(status+" record of "+digest, func() bool {
+		return len(h.find(status, digest)) > 0
+	})
+	return h.find(status, digest)[0]
+}
+
+// expectNotPosted fails the test if a record with the status and
+// digest is posted within integrationSettle.
+func (h *integrationHarness) expectNotPosted(status, digest string) {
+	h.t.Helper()
+	time.Sleep(integrationSettle)
+	if n := len(h.find(status, digest)); n > 0 {
+		h.t.Fatalf("%d %s records of %s posted, expected none", n, status, digest)
+	}
+}
+
+// createDeployment creates a Deployment at the revision.
+func (h *integrationHarness) createDeployment(name, revision string) {
+	h.t.Helper()
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{revisionAnnotation: revision},
+		},
+	}
+	if _, err := h.clientset.AppsV1().Deployments("default").Create(h.ctx, d, metav1.CreateOptions{}); err != nil {
+		h.t.Fatalf("failed to create deployment: %v", err)
+	}
+}
+
+// createReplicaSet creates a ReplicaSet of the Deployment.
+func (h *integrationHarness) createReplicaSet(name, deployment, revision string) {
+	h.t.Helper()
+	rs := newTestReplicaSet(name, deployment, revision)
+	if _, err := h.clientset.AppsV1().ReplicaSets("default").Create(h.ctx, rs, metav1.CreateOptions{}); err != nil {
+		h.t.Fatalf("failed to create replicaset: %v", err)
+	}
+	h.eventually("replicaset "+name+" cached", func() bool {
+		_, err := h.c.rsLister.ReplicaSets("default").Get(name)
+		return err == nil
+	})
+}
+
+// createPod creates a running pod of the ReplicaSet, whose app
+// container runs the digest.
+func (h *integrationHarness) createPod(name, rsName, digest string) {
+	h.t.Helper()
+	pod := newTestPod(rsName)
+	pod.Name = name
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/app:latest"}}
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", ImageID: "ghcr.io/org/app@" + digest},
+	}
+	if _, err := h.clientset.CoreV1().Pods("default").Create(h.ctx, pod, metav1.CreateOptions{}); err != nil {
+		h.t.Fatalf("failed to create pod: %v", err)
+	}
+}
+
+// deletePod deletes the pod.
+func (h *integrationHarness) deletePod(name string) {
+	h.t.Helper()
+	if err := h.clientset.CoreV1().Pods("default").Delete(h.ctx, name, metav1.DeleteOptions{}); err != nil {
+		h.t.Fatalf("failed to delete pod: %v", err)
+	}
+}
+
+// deleteWorkload deletes the Deployment and its ReplicaSets, and waits
+// for the ReplicaSets to leave the cache, as the garbage collector
+// deletes the owners of pods before the pods.
+func (h *integrationHarness) deleteWorkload(deployment string, replicaSets ...string) {
+	h.t.Helper()
+	if err := h.clientset.AppsV1().Deployments("default").Delete(h.ctx, deployment, metav1.DeleteOptions{}); err != nil {
+		h.t.Fatalf("failed to delete deployment: %v", err)
+	}
+	for _, name := range replicaSets {
+		if err := h.clientset.AppsV1().ReplicaSets("default").Delete(h.ctx, name, metav1.DeleteOptions{}); err != nil {
+			h.t.Fatalf("failed to delete replicaset: %v", err)
+		}
+		h.eventually("replicaset "+name+" removed from the cache", func() bool {
+			_, err := h.c.rsLister.ReplicaSets("default").Get(name)
+			return k8serrors.IsNotFound(err)
+		})
+	}
+}
+
+// TestIntegration walks a Deployment through its lifecycle against a
+// fake cluster and record API: creation, scale-down, rollover to a new
+// image and deletion.
+func TestIntegration(t *testing.T) {
+	h := newIntegrationHarness(t, &Config{})
+
+	// Create: the first pod posts a deployed record, the second is
+	// deduplicated
+	h.createDeployment("web", "1")
+	h.createReplicaSet("web-111", "web", "1")
+	h.createPod("web-111-aaaaa", "web-111", digestV1)
+	h.createPod("web-111-bbbbb", "web-111", digestV1)
+	record := h.expectPosted(deploymentrecord.StatusDeployed, digestV1)
+	if record.DeploymentName != "default/web/app" {
+		t.Errorf("deployment name = %q, expected %q", record.DeploymentName, "default/web/app")
+	}
+	if n := len(h.find(deploymentrecord.StatusDeployed, digestV1)); n != 1 {
+		t.Errorf("deployed records of v1 = %d, expected 1", n)
+	}
+
+	// Scale-down: the Deployment still runs the image
+	h.deletePod("web-111-bbbbb")
+	h.expectNotPosted(deploymentrecord.StatusDecommissioned, digestV1)
+
+	// Rollover: the new revision posts a deployed record, the old
+	// pods going away don't decommission the deployment
+	h.createReplicaSet("web-222", "web", "2")
+	h.createPod("web-222-ccccc", "web-222", digestV2)
+	h.expectPosted(deploymentrecord.StatusDeployed, digestV2)
+	h.deletePod("web-111-aaaaa")
+	h.expectNotPosted(deploymentrecord.StatusDecommissioned, digestV1)
+
+	// Delete: the last pod of the removed Deployment decommissions it
+	h.deleteWorkload("web", "web-111", "web-222")
+	h.deletePod("web-222-ccccc")
+	record = h.expectPosted(deploymentrecord.StatusDecommissioned, digestV2)
+	if record.DeploymentName != "default/web/app" {
+		t.Errorf("deployment name = %q, expected %q", record.DeploymentName, "default/web/app")
+	}
+}
+
+// TestIntegrationRenamedDeployment recreates a Deployment under a new
+// name with the same image: deleting the old Deployment decommissions
+// its records while its pods are still running.
+func TestIntegrationRenamedDeployment(t *testing.T) {
+	h := newIntegrationHarness(t, &Config{})
+
+	h.createDeployment("web", "1")
+	h.createReplicaSet("web-111", "web", "1")
+	h.createPod("web-111-aaaaa", "web-111", digestV1)
+	h.expectPosted(deploymentrecord.StatusDeployed, digestV1)
+
+	h.createDeployment("web-v2", "1")
+	h.createReplicaSet("web-v2-222", "web-v2", "1")
+	h.createPod("web-v2-222-bbbbb", "web-v2-222", digestV1)
+	h.eventually("deployed record of web-v2", func() bool {
+		return len(h.api.Find("default/web-v2/app", deploymentrecord.StatusDeployed)) > 0
+	})
+
+	if err := h.clientset.AppsV1().Deployments("default").Delete(h.ctx, "web", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete deployment: %v", err)
+	}
+	record := h.expectPosted(deploymentrecord.StatusDecommissioned, digestV1)
+	if record.DeploymentName != "default/web/app" {
+		t.Errorf("deployment name = %q, expected %q", record.DeploymentName, "default/web/app")
+	}
+	if n := len(h.api.Find("default/web-v2/app", deploymentrecord.StatusDecommissioned)); n != 0 {
+		t.Errorf("decommissioned records of web-v2 = %d, expected 0", n)
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// knativeServiceLabel is set by Knative Serving on the pods of a
+	// Service's revisions, holding the name of the Service.
+	knativeServiceLabel = "serving.knative.dev/service"
+	// knativeConfigurationLabel is set by Knative Serving on the pods
+	// of a Configuration's revisions, holding the name of the
+	// Configuration. Services create a Configuration of the same name.
+	knativeConfigurationLabel = "serving.knative.dev/configuration"
+	// knativeRevisionLabel is set by Knative Serving on the pods of a
+	// revision, holding the name of the Revision.
+	knativeRevisionLabel = "serving.knative.dev/revision"
+)
+
+// knativeRevisionGVR is the resource of Knative Revisions, read with
+// the dynamic client as they are not part of the Kubernetes API.
+var knativeRevisionGVR = schema.GroupVersionResource{
+	Group:    "serving.knative.dev",
+	Version:  "v1",
+	Resource: "revisions",
+}
+
+// knativeServiceName returns the name of the Knative Service running
+// the pod, falling back to its Configuration for revisions not created
+// by a Service, or "" if the pod doesn't run a Knative revision.
+func knativeServiceName(pod *corev1.Pod) string {
+	if pod.Labels[knativeRevisionLabel] == "" {
+		return ""
+	}
+	return cmp.Or(pod.Labels[knativeServiceLabel], pod.Labels[knativeConfigurationLabel])
+}
+
+// knativeRevisionWorkload returns the workload of the Knative Revision
+// running the pod. Revisions scale to zero and back, so their pods
+// going away doesn't tell anything; the records of a revision are only
+// decommissioned once Knative garbage collects the Revision.
+func knativeRevisionWorkload(pod *corev1.Pod) workload {
+	return workload{Kind: kindKnativeRevision, Name: pod.Labels[knativeRevisionLabel]}
+}
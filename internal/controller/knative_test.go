@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// newKnativePod returns a pod of the revision, whose Deployment is
+// named <revision>-deployment like Knative Serving does.
+func newKnativePod(revision string, labels map[string]string) *corev1.Pod {
+	pod := newTestPod(revision + "-deployment-7d9f8")
+	pod.Labels = map[string]string{knativeRevisionLabel: revision}
+	for k, v := range labels {
+		pod.Labels[k] = v
+	}
+	return pod
+}
+
+func TestResolveKnative(t *testing.T) {
+	tests := []struct {
+		name     string
+		knative  bool
+		pod      *corev1.Pod
+		expected workload
+	}{
+		{
+			name:     "service",
+			knative:  true,
+			pod:      newKnativePod("hello-00042", map[string]string{knativeServiceLabel: "hello"}),
+			expected: workload{Kind: kindKnativeService, Name: "hello"},
+		},
+		{
+			name:     "configuration",
+			knative:  true,
+			pod:      newKnativePod("hello-00042", map[string]string{knativeConfigurationLabel: "hello"}),
+			expected: workload{Kind: kindKnativeService, Name: "hello"},
+		},
+		{
+			name:     "disabled",
+			pod:      newKnativePod("hello-00042", map[string]string{knativeServiceLabel: "hello"}),
+			expected: workload{Kind: kindDeployment, Name: "hello-00042-deployment"},
+		},
+		{
+			name:     "not knative",
+			knative:  true,
+			pod:      newTestPod("web-7d9f8"),
+			expected: workload{Kind: kindDeployment, Name: "web"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{rsLister: newTestReplicaSetLister(t,
+				newTestReplicaSet("hello-00042-deployment-7d9f8", "hello-00042-deployment", "1"),
+				newTestReplicaSet("web-7d9f8", "web", "1"),
+			)}
+			c.cfg.Store(&Config{Knative: tt.knative})
+
+			if got := c.resolveWorkload(tt.pod); got != tt.expected {
+				t.Errorf("resolveWorkload() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKnativePlaceholders(t *testing.T) {
+	pod := newKnativePod("hello-00042", map[string]string{knativeServiceLabel: "hello"})
+	wl := workload{Kind: kindDeployment, Name: "hello-00042-deployment"}
+	tmpl := TmplNS + "/" + TmplKnativeService + "/" + TmplKnativeRevision
+	if err := ValidateTemplate(tmpl); err != nil {
+		t.Fatalf("ValidateTemplate() unexpected error: %v", err)
+	}
+
+	got := getARDeploymentName(pod, corev1.Container{Name: "app"}, wl, tmpl, "")
+	if expected := "default/hello/hello-00042"; got != expected {
+		t.Errorf("getARDeploymentName() = %s, expected %s", got, expected)
+	}
+	got = getARDeploymentName(newTestPod("web-7d9f8"), corev1.Container{Name: "app"}, wl, tmpl, "")
+	if expected := "default//"; got != expected {
+		t.Errorf("getARDeploymentName() of another pod = %s, expected %s", got, expected)
+	}
+}
+
+func TestKnativeWorkloadRemoved(t *testing.T) {
+	revision := &unstructured.Unstructured{}
+	revision.SetAPIVersion("serving.knative.dev/v1")
+	revision.SetKind("Revision")
+	revision.SetNamespace("default")
+	revision.SetName("hello-00042")
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{knativeRevisionGVR: "RevisionList"}, revision)
+
+	tests := []struct {
+		name     string
+		client   dynamic.Interface
+		revision string
+		expected bool
+	}{
+		{
+			// Scaled to zero
+			name:     "revision exists",
+			client:   client,
+			revision: "hello-00042",
+			expected: false,
+		},
+		{
+			name:     "revision garbage collected",
+			client:   client,
+			revision: "hello-00041",
+			expected: true,
+		},
+		{
+			name:     "no dynamic client",
+			revision: "hello-00041",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{
+				dynamicClient: tt.client,
+				rsLister:      newTestReplicaSetLister(t),
+			}
+			c.cfg.Store(&Config{Knative: true})
+
+			pod := newKnativePod(tt.revision, map[string]string{knativeServiceLabel: "hello"})
+			if got := c.workloadRemoved(context.Background(), pod); got != tt.expected {
+				t.Errorf("workloadRemoved() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAssociateKnativeRecord(t *testing.T) {
+	c := &Controller{
+		rsLister: newTestReplicaSetLister(t,
+			newTestReplicaSet("hello-00042-deployment-7d9f8", "hello-00042-deployment", "1"),
+		),
+		deploymentRecords: newDeploymentRecords(),
+		namespaceRecords:  newDeploymentRecords(),
+	}
+	pod := newKnativePod("hello-00042", map[string]string{knativeServiceLabel: "hello"})
+	wl := workload{Kind: kindKnativeService, Name: "hello"}
+
+	c.associateRecord(pod, wl, getCacheKey("default/hello/app", "sha256:abc"), func() *deploymentrecord.DeploymentRecord {
+		return deploymentrecordtest.Fixture(1)
+	})
+	// The revision's Deployment is deleted when the Revision is
+	// garbage collected
+	if got := c.deploymentRecords.get("default/hello-00042-deployment"); len(got) != 1 {
+		t.Errorf("records of the revision's deployment = %d, expected 1", len(got))
+	}
+	if got := c.namespaceRecords.get("default"); len(got) != 1 {
+		t.Errorf("records of the namespace = %d, expected 1", len(got))
+	}
+}
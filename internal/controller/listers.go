@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The listers below span the informers of several watched namespaces,
+// keyed by namespace. A lister keyed by metav1.NamespaceAll serves all
+// namespaces without a lister of their own. Lookups in namespaces that
+// are not watched behave like lookups in an empty cache.
+
+// forNamespace returns the entry of m serving the namespace.
+func forNamespace[T any](m map[string]T, namespace string) (T, bool) {
+	if v, ok := m[namespace]; ok {
+		return v, true
+	}
+	v, ok := m[metav1.NamespaceAll]
+	return v, ok
+}
+
+// emptyIndexer returns an indexer without any objects.
+func emptyIndexer() cache.Indexer {
+	return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+}
+
+// podListers is a PodLister spanning several namespaces.
+type podListers map[string]corelisters.PodLister
+
+// List lists the pods of all watched namespaces.
+func (l podListers) List(selector labels.Selector) ([]*corev1.Pod, error) {
+	var res []*corev1.Pod
+	for _, lister := range l {
+		pods, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		res = slices.Concat(res, pods)
+	}
+	return res, nil
+}
+
+// Pods returns a lister for the pods in the namespace.
+func (l podListers) Pods(namespace string) corelisters.PodNamespaceLister {
+	if lister, ok := forNamespace(l, namespace); ok {
+		return lister.Pods(namespace)
+	}
+	return corelisters.NewPodLister(emptyIndexer()).Pods(namespace)
+}
+
+// replicaSetListers is a ReplicaSetLister spanning several
+// namespaces.
+type replicaSetListers map[string]appslisters.ReplicaSetLister
+
+// List lists the replicasets of all watched namespaces.
+func (l replicaSetListers) List(selector labels.Selector) ([]*appsv1.ReplicaSet, error) {
+	var res []*appsv1.ReplicaSet
+	for _, lister := range l {
+		rs, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		res = slices.Concat(res, rs)
+	}
+	return res, nil
+}
+
+// ReplicaSets returns a lister for the replicasets in the namespace.
+func (l replicaSetListers) ReplicaSets(namespace string) appslisters.ReplicaSetNamespaceLister {
+	if lister, ok := forNamespace(l, namespace); ok {
+		return lister.ReplicaSets(namespace)
+	}
+	return appslisters.NewReplicaSetLister(emptyIndexer()).ReplicaSets(namespace)
+}
+
+// GetPodReplicaSets returns the replicasets potentially matching the
+// pod.
+func (l replicaSetListers) GetPodReplicaSets(pod *corev1.Pod) ([]*appsv1.ReplicaSet, error) {
+	if lister, ok := forNamespace(l, pod.Namespace); ok {
+		return lister.GetPodReplicaSets(pod)
+	}
+	return appslisters.NewReplicaSetLister(emptyIndexer()).GetPodReplicaSets(pod)
+}
+
+// deploymentListers is a DeploymentLister spanning several
+// namespaces.
+type deploymentListers map[string]appslisters.DeploymentLister
+
+// List lists the deployments of all watched namespaces.
+func (l deploymentListers) List(selector labels.Selector) ([]*appsv1.Deployment, error) {
+	var res []*appsv1.Deployment
+	for _, lister := range l {
+		deployments, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		res = slices.Concat(res, deployments)
+	}
+	return res, nil
+}
+
+// Deployments returns a lister for the deployments in the namespace.
+func (l deploymentListers) Deployments(namespace string) appslisters.DeploymentNamespaceLister {
+	if lister, ok := forNamespace(l, namespace); ok {
+		return lister.Deployments(namespace)
+	}
+	return appslisters.NewDeploymentLister(emptyIndexer()).Deployments(namespace)
+}
+
+// jobListers is a JobLister spanning several namespaces.
+type jobListers map[string]batchlisters.JobLister
+
+// List lists the jobs of all watched namespaces.
+func (l jobListers) List(selector labels.Selector) ([]*batchv1.Job, error) {
+	var res []*batchv1.Job
+	for _, lister := range l {
+		jobs, err := lister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		res = slices.Concat(res, jobs)
+	}
+	return res, nil
+}
+
+// Jobs returns a lister for the jobs in the namespace.
+func (l jobListers) Jobs(namespace string) batchlisters.JobNamespaceLister {
+	if lister, ok := forNamespace(l, namespace); ok {
+		return lister.Jobs(namespace)
+	}
+	return batchlisters.NewJobLister(emptyIndexer()).Jobs(namespace)
+}
+
+// GetPodJobs returns the jobs potentially matching the pod.
+func (l jobListers) GetPodJobs(pod *corev1.Pod) ([]batchv1.Job, error) {
+	if lister, ok := forNamespace(l, pod.Namespace); ok {
+		return lister.GetPodJobs(pod)
+	}
+	return batchlisters.NewJobLister(emptyIndexer()).GetPodJobs(pod)
+}
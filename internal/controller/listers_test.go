@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestReplicaSetLister(t *testing.T, replicaSets ...*appsv1.ReplicaSet) appslisters.ReplicaSetLister {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, rs := range replicaSets {
+		if err := indexer.Add(rs); err != nil {
+			t.Fatalf("failed to add replicaset: %v", err)
+		}
+	}
+	return appslisters.NewReplicaSetLister(indexer)
+}
+
+func TestReplicaSetListers(t *testing.T) {
+	prod := newTestReplicaSet("web-111", "web", "1")
+	prod.Namespace = "prod"
+	staging := newTestReplicaSet("web-222", "web", "2")
+	staging.Namespace = "staging"
+
+	tests := []struct {
+		name      string
+		listers   replicaSetListers
+		namespace string
+		rsName    string
+		found     bool
+		listed    int
+	}{
+		{
+			name: "watched namespace",
+			listers: replicaSetListers{
+				"prod":    newTestReplicaSetLister(t, prod),
+				"staging": newTestReplicaSetLister(t, staging),
+			},
+			namespace: "staging",
+			rsName:    "web-222",
+			found:     true,
+			listed:    2,
+		},
+		{
+			name: "unwatched namespace",
+			listers: replicaSetListers{
+				"prod": newTestReplicaSetLister(t, prod),
+			},
+			namespace: "staging",
+			rsName:    "web-222",
+			found:     false,
+			listed:    1,
+		},
+		{
+			name: "all namespaces",
+			listers: replicaSetListers{
+				metav1.NamespaceAll: newTestReplicaSetLister(t, prod, staging),
+			},
+			namespace: "prod",
+			rsName:    "web-111",
+			found:     true,
+			listed:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.listers.ReplicaSets(tt.namespace).Get(tt.rsName)
+			if found := err == nil; found != tt.found {
+				t.Errorf("Get() error = %v, expected found %v", err, tt.found)
+			}
+
+			all, err := tt.listers.List(labels.Everything())
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(all) != tt.listed {
+				t.Errorf("List() returned %d replicasets, expected %d", len(all), tt.listed)
+			}
+		})
+	}
+}
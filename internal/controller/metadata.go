@@ -0,0 +1,63 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// helmReleaseNameAnnotation and helmReleaseNamespaceAnnotation
+	// are set by Helm on the resources of a release.
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	// helmChartLabel is set by the charts following the Helm
+	// labelling conventions, holding <chart>-<version>.
+	helmChartLabel = "helm.sh/chart"
+)
+
+// recordMetadata returns the allowlisted labels and annotations of the
+// pod and its owner, keyed by label or annotation key. The pod's
+// values take precedence over the owner's. Labels take precedence
+// over annotations with the same key. With Helm releases enabled, the
+// release and chart of the Helm release that installed the workload
+// are added, read from its Deployment as well. It returns nil if none
+// are set.
+func (c *Controller) recordMetadata(cfg *Config, pod *corev1.Pod) map[string]string {
+	labelKeys := splitList(cfg.MetadataLabels)
+	annotationKeys := splitList(cfg.MetadataAnnotations)
+	if len(labelKeys) == 0 && len(annotationKeys) == 0 && !cfg.HelmReleases {
+		return nil
+	}
+
+	var res map[string]string
+	set := func(key string, values map[string]string) {
+		if _, ok := res[key]; ok {
+			return
+		}
+		if v, ok := values[key]; ok {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[key] = v
+		}
+	}
+
+	metas := c.podObjectMeta(pod)
+	for _, key := range labelKeys {
+		for _, m := range metas {
+			set(key, m.Labels)
+		}
+	}
+	for _, key := range annotationKeys {
+		for _, m := range metas {
+			set(key, m.Annotations)
+		}
+	}
+	if cfg.HelmReleases {
+		for _, m := range c.workloadObjectMeta(pod) {
+			set(helmReleaseNameAnnotation, m.Annotations)
+			set(helmReleaseNamespaceAnnotation, m.Annotations)
+			set(helmChartLabel, m.Labels)
+		}
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"maps"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRecordMetadata(t *testing.T) {
+	rs := newTestReplicaSet("web-111", "web", "1")
+	rs.Annotations["example.com/commit"] = "abc123"
+	rs.Annotations["example.com/tier"] = "gold"
+	c := &Controller{rsLister: newTestReplicaSetLister(t, rs)}
+
+	pod := newTestPod("web-111")
+	pod.Labels = map[string]string{"team": "payments"}
+	pod.Annotations = map[string]string{"example.com/tier": "silver"}
+
+	tests := []struct {
+		name        string
+		labels      string
+		annotations string
+		expected    map[string]string
+	}{
+		{
+			name: "no allowlist",
+		},
+		{
+			name:        "missing keys",
+			labels:      "tier",
+			annotations: "example.com/owner",
+		},
+		{
+			name:        "pod and owner values",
+			labels:      "team",
+			annotations: "example.com/commit, example.com/tier",
+			expected: map[string]string{
+				"team":               "payments",
+				"example.com/commit": "abc123",
+				"example.com/tier":   "silver",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{MetadataLabels: tt.labels, MetadataAnnotations: tt.annotations}
+			got := c.recordMetadata(cfg, pod)
+			if !maps.Equal(got, tt.expected) {
+				t.Errorf("recordMetadata() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRecordMetadataHelm(t *testing.T) {
+	// Helm sets its annotations on the Deployment, which are copied
+	// to the ReplicaSet, while the chart label stays on the Deployment
+	rs := newTestReplicaSet("web-111", "web", "1")
+	rs.Annotations[helmReleaseNameAnnotation] = "shop"
+	rs.Annotations[helmReleaseNamespaceAnnotation] = "prod"
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := indexer.Add(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+			Labels:    map[string]string{helmChartLabel: "web-1.4.2"},
+		},
+	}); err != nil {
+		t.Fatalf("failed to add deployment: %v", err)
+	}
+	c := &Controller{
+		rsLister:         newTestReplicaSetLister(t, rs, newTestReplicaSet("api-222", "api", "1")),
+		deploymentLister: appslisters.NewDeploymentLister(indexer),
+	}
+
+	tests := []struct {
+		name     string
+		helm     bool
+		labels   string
+		rsName   string
+		expected map[string]string
+	}{
+		{
+			name:   "disabled",
+			rsName: "web-111",
+		},
+		{
+			name:   "release",
+			helm:   true,
+			labels: "team",
+			rsName: "web-111",
+			expected: map[string]string{
+				"team":                         "payments",
+				helmReleaseNameAnnotation:      "shop",
+				helmReleaseNamespaceAnnotation: "prod",
+				helmChartLabel:                 "web-1.4.2",
+			},
+		},
+		{
+			name:   "not a release",
+			helm:   true,
+			rsName: "api-222",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newTestPod(tt.rsName)
+			pod.Labels = map[string]string{"team": "payments"}
+			cfg := &Config{MetadataLabels: tt.labels, HelmReleases: tt.helm}
+			if got := c.recordMetadata(cfg, pod); !maps.Equal(got, tt.expected) {
+				t.Errorf("recordMetadata() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
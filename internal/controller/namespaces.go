@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The namespaces to watch are given as a comma separated list of
+// namespaces to include and one to exclude. An empty include list
+// includes all namespaces. Exclusions take precedence: a namespace
+// listed in both is not watched.
+//
+// Entries which are not valid namespace names are regular
+// expressions, e.g. ^kube-. Field selectors can't express patterns, so
+// these are matched client side: an include pattern watches all
+// namespaces and drops the events of those not matching, and exclude
+// patterns drop the events of matching namespaces.
+
+// namespaceList is a parsed list of namespaces and namespace
+// patterns.
+type namespaceList struct {
+	names    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// newNamespaceList parses the entries into a namespace list. It
+// returns an error if a pattern doesn't compile.
+func newNamespaceList(entries []string) (namespaceList, error) {
+	res := namespaceList{names: make(map[string]bool)}
+	for _, entry := range entries {
+		if len(validation.IsDNS1123Label(entry)) == 0 {
+			res.names[entry] = true
+			continue
+		}
+		re, err := regexp.Compile(entry)
+		if err != nil {
+			return namespaceList{}, fmt.Errorf("invalid namespace pattern %q: %w", entry, err)
+		}
+		res.patterns = append(res.patterns, re)
+	}
+	return res, nil
+}
+
+// parseNamespaceList parses the comma separated list of namespaces
+// and namespace patterns.
+func parseNamespaceList(list string) (namespaceList, error) {
+	return newNamespaceList(splitList(list))
+}
+
+// empty returns true if the list has no namespaces nor patterns.
+func (l namespaceList) empty() bool {
+	return len(l.names) == 0 && len(l.patterns) == 0
+}
+
+// matches returns true if the namespace is listed or matches one of
+// the patterns.
+func (l namespaceList) matches(ns string) bool {
+	if l.names[ns] {
+		return true
+	}
+	for _, re := range l.patterns {
+		if re.MatchString(ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedNamespaces returns the included namespaces which are not
+// excluded. It returns nil if all namespaces are watched, i.e. if no
+// namespaces are included or if the includes have patterns.
+func watchedNamespaces(included, excluded namespaceList) []string {
+	if len(included.patterns) > 0 {
+		return nil
+	}
+	var res []string
+	for ns := range included.names {
+		if !excluded.matches(ns) {
+			res = append(res, ns)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// excludeFieldSelector returns the field selector requirements
+// excluding the listed namespaces. Patterns are left out.
+func excludeFieldSelector(excluded namespaceList) []string {
+	res := make([]string, 0, len(excluded.names))
+	for ns := range excluded.names {
+		res = append(res, fmt.Sprintf("metadata.namespace!=%s", ns))
+	}
+	slices.Sort(res)
+	return res
+}
+
+// ValidateNamespaces returns an error if a namespace pattern doesn't
+// compile, or if the namespaces to include and exclude leave no
+// namespace to watch.
+func ValidateNamespaces(namespaces, excludeNamespaces string) error {
+	included, err := parseNamespaceList(namespaces)
+	if err != nil {
+		return err
+	}
+	excluded, err := parseNamespaceList(excludeNamespaces)
+	if err != nil {
+		return err
+	}
+	if len(included.names) > 0 && len(included.patterns) == 0 &&
+		len(watchedNamespaces(included, excluded)) == 0 {
+		return errors.New("all namespaces to monitor are excluded")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+)
+
+func newTestNamespaceList(t *testing.T, list string) namespaceList {
+	t.Helper()
+	res, err := parseNamespaceList(list)
+	if err != nil {
+		t.Fatalf("parseNamespaceList() unexpected error: %v", err)
+	}
+	return res
+}
+
+func TestParseNamespaceList(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     string
+		names    int
+		patterns int
+		wantErr  bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "names",
+			list:  "prod, kube-system",
+			names: 2,
+		},
+		{
+			name:     "names and patterns",
+			list:     "prod,^kube-,^openshift-.*",
+			names:    1,
+			patterns: 2,
+		},
+		{
+			name:    "invalid pattern",
+			list:    "prod,^team-(",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNamespaceList(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNamespaceList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got.names) != tt.names || len(got.patterns) != tt.patterns {
+				t.Errorf("parseNamespaceList() = %d names, %d patterns, want %d, %d",
+					len(got.names), len(got.patterns), tt.names, tt.patterns)
+			}
+		})
+	}
+}
+
+func TestWatchedNamespaces(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces string
+		exclude    string
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name: "all namespaces",
+		},
+		{
+			name:    "all namespaces with exclusions",
+			exclude: "kube-system",
+		},
+		{
+			name:       "included namespaces",
+			namespaces: "prod, staging",
+			want:       []string{"prod", "staging"},
+		},
+		{
+			name:       "exclusions take precedence",
+			namespaces: "prod,staging,dev",
+			exclude:    "dev,kube-system",
+			want:       []string{"prod", "staging"},
+		},
+		{
+			name:       "excluded by pattern",
+			namespaces: "prod,staging,dev-a",
+			exclude:    "^dev-",
+			want:       []string{"prod", "staging"},
+		},
+		{
+			name:       "included patterns watch all namespaces",
+			namespaces: "prod,^team-",
+			exclude:    "prod",
+		},
+		{
+			name:       "all included namespaces excluded",
+			namespaces: "prod,dev",
+			exclude:    "dev, prod",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid pattern",
+			namespaces: "prod",
+			exclude:    "^dev-[",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNamespaces(tt.namespaces, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNamespaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			included := newTestNamespaceList(t, tt.namespaces)
+			excluded := newTestNamespaceList(t, tt.exclude)
+			if got := watchedNamespaces(included, excluded); !slices.Equal(got, tt.want) {
+				t.Errorf("watchedNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CacheEntry is an entry of the observation cache: a deployment name
+// and digest whose deployed record was posted.
+type CacheEntry struct {
+	Cluster        string `json:"cluster"`
+	DeploymentName string `json:"deployment_name"`
+	Digest         string `json:"digest"`
+}
+
+// CachedDeployments returns the entries of the observation cache,
+// ordered by deployment name and digest.
+func (c *Controller) CachedDeployments() []CacheEntry {
+	cluster := c.cfg.Load().Cluster
+	var res []CacheEntry
+	c.observedDeployments.each(func(key string, _ time.Time) bool {
+		dn, digest, _ := strings.Cut(key, "||")
+		res = append(res, CacheEntry{
+			Cluster:        cluster,
+			DeploymentName: dn,
+			Digest:         digest,
+		})
+		return true
+	})
+	slices.SortFunc(res, func(a, b CacheEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.DeploymentName, b.DeploymentName),
+			cmp.Compare(a.Digest, b.Digest),
+		)
+	})
+	return res
+}
+
+// EvictCached removes the deployment name from the observation cache,
+// e.g. after the record was deleted from the API, and enqueues the
+// running pods of the deployment name so its record is posted again.
+// Only the entry and pods of the digest are considered if it is set.
+// It returns the number of evicted entries and enqueued pods.
+func (c *Controller) EvictCached(deploymentName, digest string) (int, int) {
+	var evicted int
+	c.observedDeployments.each(func(key string, _ time.Time) bool {
+		dn, d, _ := strings.Cut(key, "||")
+		if dn == deploymentName && (digest == "" || d == digest) {
+			c.observedDeployments.remove(key)
+			evicted++
+		}
+		return true
+	})
+	if evicted > 0 {
+		c.cacheDirty.Store(true)
+	}
+	enqueued := c.enqueueMatchingPods(func(dn, d string) bool {
+		return dn == deploymentName && (digest == "" || d == digest)
+	})
+	slog.Info("Evicted observation cache entries",
+		"deployment_name", deploymentName,
+		"digest", digest,
+		"count", evicted,
+		"enqueued", enqueued,
+	)
+	return evicted, enqueued
+}
+
+// ClearCache removes all entries of the observation cache and
+// enqueues all running pods, so their records are posted again. It
+// returns the number of removed entries and enqueued pods.
+func (c *Controller) ClearCache() (int, int) {
+	var cleared int
+	c.observedDeployments.each(func(key string, _ time.Time) bool {
+		c.observedDeployments.remove(key)
+		cleared++
+		return true
+	})
+	c.cacheDirty.Store(true)
+	enqueued := c.enqueueMatchingPods(func(string, string) bool {
+		return true
+	})
+	slog.Info("Cleared observation cache",
+		"count", cleared,
+		"enqueued", enqueued,
+	)
+	return cleared, enqueued
+}
+
+// enqueueMatchingPods enqueues a created event for each running pod of
+// a tracked workload with a container whose deployment name and digest
+// match, and returns their number.
+func (c *Controller) enqueueMatchingPods(match func(deploymentName, digest string) bool) int {
+	keys := make(map[string]bool)
+	err := c.forEachRunningContainer(c.cfg.Load(), func(pod *corev1.Pod, _ corev1.Container, dn, digest string) {
+		if !match(dn, digest) {
+			return
+		}
+		if key, err := cache.MetaNamespaceKeyFunc(pod); err == nil {
+			keys[key] = true
+		}
+	})
+	if err != nil {
+		slog.Error("Failed to enqueue pods of evicted cache entries",
+			"error", err,
+		)
+		return 0
+	}
+	for key := range keys {
+		c.workqueue.Add(PodEvent{
+			Key:       key,
+			EventType: EventCreated,
+		})
+	}
+	return len(keys)
+}
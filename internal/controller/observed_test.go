@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestObservationCacheAdmin(t *testing.T) {
+	runningPod := func(rsName, name, imageID string) *corev1.Pod {
+		pod := newTestPod(rsName)
+		pod.Name = name
+		pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/app:v1"}}
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", ImageID: imageID},
+			},
+		}
+		return pod
+	}
+
+	newController := func(t *testing.T) *Controller {
+		t.Helper()
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		for _, pod := range []*corev1.Pod{
+			runningPod("web-111", "web-111-aaaaa", "ghcr.io/org/app@sha256:a"),
+			runningPod("web-111", "web-111-bbbbb", "ghcr.io/org/app@sha256:a"),
+			runningPod("web-222", "web-222-aaaaa", "ghcr.io/org/app@sha256:b"),
+			runningPod("api-333", "api-333-aaaaa", "ghcr.io/org/app@sha256:c"),
+		} {
+			if err := indexer.Add(pod); err != nil {
+				t.Fatalf("failed to add pod: %v", err)
+			}
+		}
+		queue := workqueue.NewTypedRateLimitingQueue(
+			workqueue.DefaultTypedControllerRateLimiter[PodEvent](),
+		)
+		t.Cleanup(queue.ShutDown)
+		c := &Controller{
+			workqueue: queue,
+			podLister: corelisters.NewPodLister(indexer),
+			rsLister: newTestReplicaSetLister(t,
+				newTestReplicaSet("web-111", "web", "1"),
+				newTestReplicaSet("web-222", "web", "2"),
+				newTestReplicaSet("api-333", "api", "1"),
+			),
+		}
+		c.cfg.Store(&Config{Template: TmplNS + "/" + TmplDN + "/" + TmplCN, Cluster: "c1"})
+		for _, key := range []string{
+			getCacheKey("default/web/app", "sha256:b"),
+			getCacheKey("default/web/app", "sha256:a"),
+			getCacheKey("default/api/app", "sha256:c"),
+		} {
+			c.observedDeployments.store(key, time.Time{})
+		}
+		return c
+	}
+
+	t.Run("list", func(t *testing.T) {
+		c := newController(t)
+		expected := []CacheEntry{
+			{Cluster: "c1", DeploymentName: "default/api/app", Digest: "sha256:c"},
+			{Cluster: "c1", DeploymentName: "default/web/app", Digest: "sha256:a"},
+			{Cluster: "c1", DeploymentName: "default/web/app", Digest: "sha256:b"},
+		}
+		if got := c.CachedDeployments(); !slices.Equal(got, expected) {
+			t.Errorf("CachedDeployments() = %v, expected %v", got, expected)
+		}
+	})
+
+	tests := []struct {
+		name           string
+		deploymentName string
+		digest         string
+		expected       int
+		enqueued       int
+		remaining      int
+	}{
+		{
+			name:           "all digests",
+			deploymentName: "default/web/app",
+			expected:       2,
+			enqueued:       3,
+			remaining:      1,
+		},
+		{
+			name:           "one digest",
+			deploymentName: "default/web/app",
+			digest:         "sha256:a",
+			expected:       1,
+			enqueued:       2,
+			remaining:      2,
+		},
+		{
+			name:           "unknown deployment",
+			deploymentName: "default/other/app",
+			expected:       0,
+			remaining:      3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run("evict "+tt.name, func(t *testing.T) {
+			c := newController(t)
+			evicted, enqueued := c.EvictCached(tt.deploymentName, tt.digest)
+			if evicted != tt.expected || enqueued != tt.enqueued {
+				t.Errorf("EvictCached() = %d, %d, expected %d, %d", evicted, enqueued, tt.expected, tt.enqueued)
+			}
+			if got := c.workqueue.Len(); got != tt.enqueued {
+				t.Errorf("queue length = %d, expected %d", got, tt.enqueued)
+			}
+			if got := len(c.CachedDeployments()); got != tt.remaining {
+				t.Errorf("remaining entries = %d, expected %d", got, tt.remaining)
+			}
+			if c.cacheDirty.Load() != (tt.expected > 0) {
+				t.Errorf("cacheDirty = %v after evicting %d entries", c.cacheDirty.Load(), tt.expected)
+			}
+		})
+	}
+
+	t.Run("clear", func(t *testing.T) {
+		c := newController(t)
+		if cleared, enqueued := c.ClearCache(); cleared != 3 || enqueued != 4 {
+			t.Errorf("ClearCache() = %d, %d, expected 3, 4", cleared, enqueued)
+		}
+		if got := c.CachedDeployments(); len(got) != 0 {
+			t.Errorf("CachedDeployments() = %v after clear", got)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/metrics"
+)
+
+// observedCache is the observation cache: the cache keys of the records
+// posted, with the time they were posted. It holds at most size
+// entries, evicting the least recently used one when full, and evicts
+// the entries not used for ttl. Zero size or ttl disable the bound.
+// The zero value is an unbounded, empty cache.
+//
+// Entries are used when they are stored, looked up or touched, e.g.
+// because a pod still runs the deployment, so only the entries of
+// deployments no longer running, such as the digests of old rollouts,
+// expire.
+type observedCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *observedEntry, most recently used first
+}
+
+type observedEntry struct {
+	key string
+	// posted is the time the record was posted, zero if it was
+	// loaded from a store
+	posted time.Time
+	used   time.Time
+}
+
+// setBounds bounds the cache to size entries, whose entries expire
+// after ttl. It must be called before the cache is used.
+func (o *observedCache) setBounds(size int, ttl time.Duration) {
+	o.size = size
+	o.ttl = ttl
+}
+
+// load returns the time the record with the cache key was posted, and
+// true if it is cached. The entry is used.
+func (o *observedCache) load(key string) (time.Time, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.get(key, time.Now())
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.posted, true
+}
+
+// touch uses the entry of the cache key, if any, so it doesn't expire.
+func (o *observedCache) touch(key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.get(key, time.Now())
+}
+
+// store caches the record with the cache key, posted at the time.
+func (o *observedCache) store(key string, posted time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	if o.entries == nil {
+		o.entries = make(map[string]*list.Element)
+		o.lru = list.New()
+	}
+	o.sweep(now)
+
+	if el, ok := o.entries[key]; ok {
+		e := el.Value.(*observedEntry)
+		e.posted, e.used = posted, now
+		o.lru.MoveToFront(el)
+		return
+	}
+	o.entries[key] = o.lru.PushFront(&observedEntry{key: key, posted: posted, used: now})
+	if o.size > 0 && o.lru.Len() > o.size {
+		o.evict(o.lru.Back(), "size")
+	}
+	metrics.ObservedCacheEntries.Set(float64(o.lru.Len()))
+}
+
+// remove drops the entry of the cache key, and returns true if it was
+// cached.
+func (o *observedCache) remove(key string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	el, ok := o.entries[key]
+	if !ok {
+		return false
+	}
+	o.lru.Remove(el)
+	delete(o.entries, key)
+	metrics.ObservedCacheEntries.Set(float64(o.lru.Len()))
+	return true
+}
+
+// each calls fn with the cache key and post time of each entry, until
+// fn returns false. fn may modify the cache.
+func (o *observedCache) each(fn func(key string, posted time.Time) bool) {
+	o.mu.Lock()
+	var entries []observedEntry
+	for el := o.front(); el != nil; el = el.Next() {
+		entries = append(entries, *el.Value.(*observedEntry))
+	}
+	o.mu.Unlock()
+
+	for _, e := range entries {
+		if !fn(e.key, e.posted) {
+			return
+		}
+	}
+}
+
+// expire evicts the expired entries.
+func (o *observedCache) expire() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.lru == nil {
+		return
+	}
+	o.sweep(time.Now())
+	metrics.ObservedCacheEntries.Set(float64(o.lru.Len()))
+}
+
+// len returns the number of entries.
+func (o *observedCache) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// front returns the most recently used element, if any. It must be
+// called with mu held.
+func (o *observedCache) front() *list.Element {
+	if o.lru == nil {
+		return nil
+	}
+	return o.lru.Front()
+}
+
+// get returns the unexpired entry of the cache key, and uses it. It
+// must be called with mu held.
+func (o *observedCache) get(key string, now time.Time) (*observedEntry, bool) {
+	el, ok := o.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*observedEntry)
+	if o.expired(e, now) {
+		o.evict(el, "ttl")
+		metrics.ObservedCacheEntries.Set(float64(o.lru.Len()))
+		return nil, false
+	}
+	e.used = now
+	o.lru.MoveToFront(el)
+	return e, true
+}
+
+// sweep evicts the expired entries. The least recently used entries
+// are at the back, so only the expired entries are visited. It must be
+// called with mu held.
+func (o *observedCache) sweep(now time.Time) {
+	if o.ttl <= 0 {
+		return
+	}
+	for el := o.lru.Back(); el != nil && o.expired(el.Value.(*observedEntry), now); el = o.lru.Back() {
+		o.evict(el, "ttl")
+	}
+}
+
+// expired returns true if the entry wasn't used for ttl.
+func (o *observedCache) expired(e *observedEntry, now time.Time) bool {
+	return o.ttl > 0 && now.Sub(e.used) > o.ttl
+}
+
+// evict drops the entry of the element, for the reason. It must be
+// called with mu held.
+func (o *observedCache) evict(el *list.Element, reason string) {
+	o.lru.Remove(el)
+	delete(o.entries, el.Value.(*observedEntry).key)
+	metrics.ObservedCacheEvictions.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObservedCacheSize(t *testing.T) {
+	var o observedCache
+	o.setBounds(2, 0)
+
+	posted := time.Now()
+	o.store("a", posted)
+	o.store("b", time.Time{})
+	// Using a makes b the least recently used entry
+	if got, ok := o.load("a"); !ok || !got.Equal(posted) {
+		t.Errorf("load(a) = %v, %v, expected %v, true", got, ok, posted)
+	}
+	o.store("c", time.Time{})
+
+	if o.len() != 2 {
+		t.Errorf("len() = %d, expected 2", o.len())
+	}
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := o.load(key); ok != want {
+			t.Errorf("load(%s) cached = %v, expected %v", key, ok, want)
+		}
+	}
+}
+
+func TestObservedCacheTTL(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	var o observedCache
+	o.setBounds(0, ttl)
+
+	o.store("running", time.Time{})
+	o.store("stale", time.Time{})
+	o.store("swept", time.Time{})
+	time.Sleep(ttl / 2)
+	o.touch("running")
+	time.Sleep(ttl/2 + 5*time.Millisecond)
+
+	if _, ok := o.load("stale"); ok {
+		t.Error("load(stale) expected the entry to be expired")
+	}
+	o.expire()
+	if o.len() != 1 {
+		t.Errorf("len() = %d, expected only the running entry", o.len())
+	}
+	if _, ok := o.load("running"); !ok {
+		t.Error("load(running) expected the touched entry to be cached")
+	}
+}
+
+func TestObservedCacheEach(t *testing.T) {
+	var o observedCache
+	o.each(func(string, time.Time) bool {
+		t.Error("each() called fn on an empty cache")
+		return true
+	})
+	if o.remove("a") {
+		t.Error("remove() on an empty cache returned true")
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		o.store(key, time.Time{})
+	}
+	// The cache may be modified while iterating
+	var seen int
+	o.each(func(key string, _ time.Time) bool {
+		seen++
+		return o.remove(key)
+	})
+	if seen != 3 || o.len() != 0 {
+		t.Errorf("each() visited %d entries, left %d, expected 3 and 0", seen, o.len())
+	}
+}
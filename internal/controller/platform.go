@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/registry"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodePlatform returns the OS and architecture of the pod's node, from
+// the kubernetes.io/os and kubernetes.io/arch labels of the node, and
+// its Windows build. If the node is not cached, they are taken from
+// the OS and node selector of the pod. It returns nil if neither is
+// known.
+func (c *Controller) nodePlatform(pod *corev1.Pod) *deploymentrecord.Platform {
+	var labels map[string]string
+	if c.nodeLister != nil && pod.Spec.NodeName != "" {
+		if node, err := c.nodeLister.Get(pod.Spec.NodeName); err == nil {
+			labels = node.Labels
+		}
+	}
+
+	platform := &deploymentrecord.Platform{
+		OS:           cmp.Or(labels[corev1.LabelOSStable], pod.Spec.NodeSelector[corev1.LabelOSStable]),
+		Architecture: cmp.Or(labels[corev1.LabelArchStable], pod.Spec.NodeSelector[corev1.LabelArchStable]),
+		OSVersion:    labels[corev1.LabelWindowsBuild],
+	}
+	if platform.OS == "" && pod.Spec.OS != nil {
+		platform.OS = string(pod.Spec.OS.Name)
+	}
+	if platform.OS == "" && platform.Architecture == "" {
+		return nil
+	}
+	return platform
+}
+
+// platformDigest resolves the manifest digest of the platform of the
+// record in the image index of its digest, from the registry of the
+// image reference. Registry failures and records without a known
+// platform leave it unset, the record is posted anyway.
+func (c *Controller) platformDigest(ctx context.Context, ref string, record *deploymentrecord.DeploymentRecord) {
+	p := record.Platform
+	if p == nil || p.OS == "" || p.Architecture == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	digest, err := c.registry.PlatformDigest(ctx, ref, record.Digest, registry.Platform{
+		OS:           p.OS,
+		Architecture: p.Architecture,
+		OSVersion:    p.OSVersion,
+	})
+	if err != nil {
+		slog.Warn("Failed to resolve the platform digest of the image",
+			"image", ref,
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+			"os", p.OS,
+			"architecture", p.Architecture,
+			"error", err,
+		)
+		return
+	}
+	p.ManifestDigest = digest
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/registry"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNodePlatform(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range []*corev1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "linux-arm",
+				Labels: map[string]string{
+					corev1.LabelOSStable:   "linux",
+					corev1.LabelArchStable: "arm64",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "windows",
+				Labels: map[string]string{
+					corev1.LabelOSStable:     "windows",
+					corev1.LabelArchStable:   "amd64",
+					corev1.LabelWindowsBuild: "10.0.20348",
+				},
+			},
+		},
+	} {
+		if err := indexer.Add(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &Controller{nodeLister: corelisters.NewNodeLister(indexer)}
+
+	tests := []struct {
+		name     string
+		spec     corev1.PodSpec
+		expected *deploymentrecord.Platform
+	}{
+		{
+			name:     "node labels",
+			spec:     corev1.PodSpec{NodeName: "linux-arm"},
+			expected: &deploymentrecord.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:     "windows build",
+			spec:     corev1.PodSpec{NodeName: "windows"},
+			expected: &deploymentrecord.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348"},
+		},
+		{
+			name: "uncached node",
+			spec: corev1.PodSpec{
+				NodeName:     "unknown",
+				OS:           &corev1.PodOS{Name: corev1.Windows},
+				NodeSelector: map[string]string{corev1.LabelArchStable: "amd64"},
+			},
+			expected: &deploymentrecord.Platform{OS: "windows", Architecture: "amd64"},
+		},
+		{
+			name: "unknown",
+			spec: corev1.PodSpec{NodeName: "unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.nodePlatform(&corev1.Pod{Spec: tt.spec})
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("nodePlatform() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPlatformDigest(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/org/app/manifests/sha256:index" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"manifests":[
+			{"digest":"sha256:amd64","platform":{"os":"linux","architecture":"amd64"}},
+			{"digest":"sha256:arm64","platform":{"os":"linux","architecture":"arm64","variant":"v8"}}]}`))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	tests := []struct {
+		name     string
+		digest   string
+		platform *deploymentrecord.Platform
+		expected string
+	}{
+		{
+			name:     "arm64",
+			digest:   "sha256:index",
+			platform: &deploymentrecord.Platform{OS: "linux", Architecture: "arm64"},
+			expected: "sha256:arm64",
+		},
+		{
+			name:     "unknown digest",
+			digest:   "sha256:other",
+			platform: &deploymentrecord.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:   "unknown platform",
+			digest: "sha256:index",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{registry: registry.NewClient(registry.WithHTTPClient(srv.Client()))}
+			record := newTestRecord("ns/web/app", tt.digest, deploymentrecord.StatusDeployed)
+			record.Platform = tt.platform
+
+			c.platformDigest(context.Background(), host+"/org/app:v1", record)
+
+			var got string
+			if record.Platform != nil {
+				got = record.Platform.ManifestDigest
+			}
+			if got != tt.expected {
+				t.Errorf("ManifestDigest = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// The defaults of the workqueue rate limiter match those of
+// workqueue.DefaultTypedControllerRateLimiter.
+const (
+	// DefaultQueueBaseDelay is the delay before the first retry of
+	// a failed event.
+	DefaultQueueBaseDelay = 5 * time.Millisecond
+	// DefaultQueueMaxDelay caps the delay between retries of a
+	// failed event.
+	DefaultQueueMaxDelay = 1000 * time.Second
+	// DefaultQueueQPS is the rate at which events are added to the
+	// queue again after failing.
+	DefaultQueueQPS = 10
+	// DefaultQueueBurst is the burst size of DefaultQueueQPS.
+	DefaultQueueBurst = 100
+)
+
+// QueueLimits tunes the rate limiter of the workqueue. Failed events
+// are retried after an exponential per-event delay, from BaseDelay up
+// to MaxDelay, and no more than QPS events per second, with bursts of
+// Burst, are retried overall. Zero values use the defaults.
+type QueueLimits struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	QPS       float64
+	Burst     int
+}
+
+// Validate returns an error if the limits are invalid.
+func (l QueueLimits) Validate() error {
+	switch {
+	case l.BaseDelay < 0 || l.MaxDelay < 0 || l.QPS < 0 || l.Burst < 0:
+		return fmt.Errorf("workqueue limits must not be negative")
+	case l.MaxDelay > 0 && l.MaxDelay < l.withDefaults().BaseDelay:
+		return fmt.Errorf("workqueue max delay %s must be at least the base delay %s", l.MaxDelay, l.withDefaults().BaseDelay)
+	}
+	return nil
+}
+
+// withDefaults returns the limits with zero values set to the
+// defaults.
+func (l QueueLimits) withDefaults() QueueLimits {
+	if l.BaseDelay == 0 {
+		l.BaseDelay = DefaultQueueBaseDelay
+	}
+	if l.MaxDelay == 0 {
+		l.MaxDelay = DefaultQueueMaxDelay
+	}
+	if l.QPS == 0 {
+		l.QPS = DefaultQueueQPS
+	}
+	if l.Burst == 0 {
+		l.Burst = DefaultQueueBurst
+	}
+	return l
+}
+
+// newQueueRateLimiter returns the rate limiter of the workqueue: the
+// larger delay of the per-event exponential backoff and the overall
+// token bucket.
+func newQueueRateLimiter(l QueueLimits) workqueue.TypedRateLimiter[PodEvent] {
+	l = l.withDefaults()
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[PodEvent](l.BaseDelay, l.MaxDelay),
+		&workqueue.TypedBucketRateLimiter[PodEvent]{Limiter: rate.NewLimiter(rate.Limit(l.QPS), l.Burst)},
+	)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewQueueRateLimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits QueueLimits
+		want   []time.Duration
+	}{
+		{
+			name: "defaults",
+			want: []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond},
+		},
+		{
+			name:   "slow retries",
+			limits: QueueLimits{BaseDelay: time.Second, MaxDelay: 3 * time.Second},
+			want:   []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newQueueRateLimiter(tt.limits)
+			event := PodEvent{Key: "default/pod", EventType: EventCreated}
+			for i, want := range tt.want {
+				if got := limiter.When(event); got != want {
+					t.Errorf("When() #%d = %v, want %v", i+1, got, want)
+				}
+			}
+			if n := limiter.NumRequeues(event); n != len(tt.want) {
+				t.Errorf("NumRequeues() = %d, want %d", n, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestQueueLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  QueueLimits
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "tuned", limits: QueueLimits{BaseDelay: time.Second, MaxDelay: time.Minute, QPS: 1, Burst: 5}},
+		{name: "negative qps", limits: QueueLimits{QPS: -1}, wantErr: true},
+		{name: "max below base", limits: QueueLimits{BaseDelay: time.Minute, MaxDelay: time.Second}, wantErr: true},
+		{name: "max below default base", limits: QueueLimits{MaxDelay: time.Millisecond}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limits.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Reconcile action types.
+const (
+	// ActionCreate posts a record for a running container the API
+	// has no record of.
+	ActionCreate = "create"
+	// ActionUpdate posts a record for a running container whose
+	// record in the API differs, e.g. in its image name or version.
+	ActionUpdate = "update"
+	// ActionDecommission decommissions a record of the API without
+	// a running container.
+	ActionDecommission = "decommission"
+)
+
+// ReconcileAction is a change needed to bring the records of the API
+// in line with the running pods.
+type ReconcileAction struct {
+	Type   string
+	Record *deploymentrecord.DeploymentRecord
+}
+
+// Reconcile compares the running pods with the deployed records the
+// API holds for the configured cluster and environments, and returns
+// the actions needed to bring the API in line. The informers are
+// started and run until ctx is cancelled.
+//
+// The records of the cluster are assumed to be owned by this tracker:
+// records without a running container in the watched namespaces are
+// decommissioned.
+func (c *Controller) Reconcile(ctx context.Context) ([]ReconcileAction, error) {
+	c.refreshServerVersion()
+	if err := c.startInformers(ctx); err != nil {
+		return nil, err
+	}
+
+	desired, err := c.desiredRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := c.cfg.Load()
+	existing, err := c.apiClient.List(ctx, deploymentrecord.ListFilter{
+		LogicalEnvironment:  cfg.LogicalEnvironment,
+		PhysicalEnvironment: cfg.PhysicalEnvironment,
+		Cluster:             cfg.Cluster,
+		Status:              deploymentrecord.StatusDeployed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+
+	return diffRecords(desired, existing), nil
+}
+
+// ApplyActions posts the records of the actions. Records of applied
+// actions are added to or removed from the observation cache. It
+// returns the failures of all actions.
+func (c *Controller) ApplyActions(ctx context.Context, actions []ReconcileAction) error {
+	var errs []error
+	for _, action := range actions {
+		record := *action.Record
+		if action.Type == ActionDecommission {
+			record.Status = deploymentrecord.StatusDecommissioned
+		}
+		start := time.Now()
+		err := c.apiClient.PostOne(ctx, &record)
+		c.audit(auditSourceReconcile, action.Type, "", &record, time.Since(start), err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", action.Type, record.DeploymentName, err))
+			continue
+		}
+		c.observeRecord(&record)
+		slog.Info("Applied reconcile action",
+			"action", action.Type,
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// desiredRecords returns the records of the containers of all running
+// pods of tracked workloads, keyed by observation cache key.
+func (c *Controller) desiredRecords() (map[string]*deploymentrecord.DeploymentRecord, error) {
+	cfg := c.cfg.Load()
+	res := make(map[string]*deploymentrecord.DeploymentRecord)
+	err := c.forEachRunningContainer(cfg, func(pod *corev1.Pod, container corev1.Container, dn, digest string) {
+		res[getCacheKey(dn, digest)] = c.newRecord(cfg, pod, container, dn, digest, deploymentrecord.StatusDeployed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// forEachRunningContainer calls fn for each recorded container of all
+// running pods of tracked workloads.
+func (c *Controller) forEachRunningContainer(cfg *Config, fn func(pod *corev1.Pod, container corev1.Container, dn, digest string)) error {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || !c.namespaceTracked(pod.Namespace) || !c.podStarted(pod) {
+			continue
+		}
+		wl := c.resolveWorkload(pod)
+		if wl.Name == "" {
+			continue
+		}
+		tmpl := c.template(cfg, pod.Namespace)
+		for _, container := range slices.Concat(pod.Spec.Containers, pod.Spec.InitContainers) {
+			if containerExcluded(cfg, container) {
+				continue
+			}
+			dn := getARDeploymentName(pod, container, wl, tmpl, cfg.Cluster)
+			digest := c.containerDigest(pod, container)
+			if dn == "" || digest == "" {
+				continue
+			}
+			fn(pod, container, dn, digest)
+		}
+	}
+	return nil
+}
+
+// diffRecords returns the actions turning the existing records into
+// the desired ones, ordered by type and deployment name.
+func diffRecords(desired map[string]*deploymentrecord.DeploymentRecord, existing []*deploymentrecord.DeploymentRecord) []ReconcileAction {
+	var actions []ReconcileAction
+
+	seen := make(map[string]bool, len(existing))
+	for _, record := range existing {
+		key := getCacheKey(record.DeploymentName, record.Digest)
+		seen[key] = true
+
+		want, ok := desired[key]
+		switch {
+		case !ok:
+			actions = append(actions, ReconcileAction{Type: ActionDecommission, Record: record})
+		case !sameRecord(want, record):
+			actions = append(actions, ReconcileAction{Type: ActionUpdate, Record: want})
+		}
+	}
+	for key, record := range desired {
+		if !seen[key] {
+			actions = append(actions, ReconcileAction{Type: ActionCreate, Record: record})
+		}
+	}
+
+	slices.SortFunc(actions, func(a, b ReconcileAction) int {
+		return cmp.Or(
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Record.DeploymentName, b.Record.DeploymentName),
+			cmp.Compare(a.Record.Digest, b.Record.Digest),
+		)
+	})
+	return actions
+}
+
+// sameRecord returns true if the records describe the same
+// deployment. The tracker and Kubernetes versions are ignored, as
+// they change without the deployment changing.
+func sameRecord(a, b *deploymentrecord.DeploymentRecord) bool {
+	return a.Name == b.Name &&
+		a.Version == b.Version &&
+		a.LogicalEnvironment == b.LogicalEnvironment &&
+		a.PhysicalEnvironment == b.PhysicalEnvironment &&
+		a.Cluster == b.Cluster &&
+		a.CommitSHA == b.CommitSHA &&
+		maps.Equal(a.Metadata, b.Metadata) &&
+		equalPtr(a.GitOps, b.GitOps)
+}
+
+// equalPtr returns true if a and b are both nil, or point to equal
+// values.
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
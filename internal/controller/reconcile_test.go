@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDiffRecords(t *testing.T) {
+	web := newTestRecord("default/web/app", "sha256:a", deploymentrecord.StatusDeployed)
+	api := newTestRecord("default/api/app", "sha256:b", deploymentrecord.StatusDeployed)
+	apiOld := newTestRecord("default/api/app", "sha256:b", deploymentrecord.StatusDeployed)
+	apiOld.Version = "v0"
+	gone := newTestRecord("default/gone/app", "sha256:c", deploymentrecord.StatusDeployed)
+
+	desired := map[string]*deploymentrecord.DeploymentRecord{
+		getCacheKey(web.DeploymentName, web.Digest): web,
+		getCacheKey(api.DeploymentName, api.Digest): api,
+	}
+
+	tests := []struct {
+		name     string
+		existing []*deploymentrecord.DeploymentRecord
+		expected []string
+	}{
+		{
+			name:     "in sync",
+			existing: []*deploymentrecord.DeploymentRecord{web, api},
+		},
+		{
+			name:     "nothing recorded",
+			expected: []string{"create default/api/app", "create default/web/app"},
+		},
+		{
+			name:     "drift",
+			existing: []*deploymentrecord.DeploymentRecord{apiOld, gone},
+			expected: []string{"create default/web/app", "decommission default/gone/app", "update default/api/app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions := diffRecords(desired, tt.existing)
+			if len(actions) != len(tt.expected) {
+				t.Fatalf("diffRecords() returned %d actions, expected %d", len(actions), len(tt.expected))
+			}
+			for i, a := range actions {
+				if got := a.Type + " " + a.Record.DeploymentName; got != tt.expected[i] {
+					t.Errorf("action %d = %q, expected %q", i, got, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDesiredRecords(t *testing.T) {
+	running := newTestPod("web-111")
+	running.UID = "uid-111"
+	running.Labels = map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "111"}
+	running.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/web:v1"}}
+	running.Status = corev1.PodStatus{
+		Phase: corev1.PodRunning,
+		ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", ImageID: "ghcr.io/org/web@sha256:abc"},
+		},
+	}
+	pending := newTestPod("api-222")
+	pending.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/api:v1"}}
+
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range []*corev1.Pod{running, pending} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Fatalf("failed to add pod: %v", err)
+		}
+	}
+	rsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, rs := range []*appsv1.ReplicaSet{newTestReplicaSet("web-111", "web", "1")} {
+		if err := rsIndexer.Add(rs); err != nil {
+			t.Fatalf("failed to add replicaset: %v", err)
+		}
+	}
+
+	c := &Controller{
+		podLister: corelisters.NewPodLister(podIndexer),
+		rsLister:  appslisters.NewReplicaSetLister(rsIndexer),
+	}
+	c.cfg.Store(&Config{
+		Template:           TmplNS + "/" + TmplDN + "/" + TmplCN,
+		LogicalEnvironment: "prod",
+		Cluster:            "cluster",
+	})
+
+	records, err := c.desiredRecords()
+	if err != nil {
+		t.Fatalf("desiredRecords() unexpected error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("desiredRecords() returned %d records, expected 1", len(records))
+	}
+	record, ok := records[getCacheKey("default/web/app", "sha256:abc")]
+	if !ok {
+		t.Fatalf("desiredRecords() = %v, expected a record for default/web/app", records)
+	}
+	if record.Name != "ghcr.io/org/web" || record.Version != "v1" || record.Status != deploymentrecord.StatusDeployed {
+		t.Errorf("record = %+v, expected the running container", record)
+	}
+	if record.PodUID != "uid-111" || !strings.HasPrefix(record.Source, recordSource) || record.ObservedAt.IsZero() {
+		t.Errorf("record pod UID = %q, source = %q, observed at %v, expected them set",
+			record.PodUID, record.Source, record.ObservedAt)
+	}
+	if record.PodTemplateHash != "111" || record.Revision != "1" {
+		t.Errorf("record pod template hash = %q, revision = %q, expected %q and %q",
+			record.PodTemplateHash, record.Revision, "111", "1")
+	}
+}
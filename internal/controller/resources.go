@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// deploymentReplicas returns the desired replica count of the
+// Deployment owning the pod, or nil if the pod isn't owned by a cached
+// Deployment.
+func (c *Controller) deploymentReplicas(pod *corev1.Pod) *int32 {
+	wl := getWorkload(pod)
+	if wl.Kind != kindDeployment {
+		return nil
+	}
+	wl = c.resolveReplicaSetOwner(pod, wl)
+	if wl.Name == "" {
+		return nil
+	}
+
+	d, err := c.deploymentLister.Deployments(pod.Namespace).Get(wl.Name)
+	if err != nil || d.Spec.Replicas == nil {
+		return nil
+	}
+	replicas := *d.Spec.Replicas
+	return &replicas
+}
+
+// containerResources returns the resource requests and limits of the
+// container, or nil if it has none.
+func containerResources(container corev1.Container) *deploymentrecord.Resources {
+	requests := resourceList(container.Resources.Requests)
+	limits := resourceList(container.Resources.Limits)
+	if requests == nil && limits == nil {
+		return nil
+	}
+	return &deploymentrecord.Resources{
+		Requests: requests,
+		Limits:   limits,
+	}
+}
+
+// resourceList formats the quantities of the list, e.g. "500m" for a
+// CPU request.
+func resourceList(list corev1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(list))
+	for name, q := range list {
+		res[string(name)] = q.String()
+	}
+	return res
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDeploymentReplicas(t *testing.T) {
+	replicas := int32(3)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := indexer.Add(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}); err != nil {
+		t.Fatalf("failed to add deployment: %v", err)
+	}
+	c := &Controller{
+		rsLister: newTestReplicaSetLister(t,
+			newTestReplicaSet("web-111", "web", "1"),
+			newTestReplicaSet("gone-111", "gone", "1"),
+		),
+		deploymentLister: appslisters.NewDeploymentLister(indexer),
+	}
+
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected *int32
+	}{
+		{
+			name:     "deployment",
+			pod:      newTestPod("web-111"),
+			expected: &replicas,
+		},
+		{
+			name: "deployment not cached",
+			pod:  newTestPod("gone-111"),
+		},
+		{
+			name: "statefulset",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "db-0",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "StatefulSet", Name: "db"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.deploymentReplicas(tt.pod)
+			switch {
+			case tt.expected == nil && got != nil:
+				t.Errorf("deploymentReplicas() = %d, expected nil", *got)
+			case tt.expected != nil && (got == nil || *got != *tt.expected):
+				t.Errorf("deploymentReplicas() = %v, expected %d", got, *tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainerResources(t *testing.T) {
+	if got := containerResources(corev1.Container{}); got != nil {
+		t.Errorf("containerResources() without resources = %+v, expected nil", got)
+	}
+
+	got := containerResources(corev1.Container{
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			},
+		},
+	})
+	if got == nil {
+		t.Fatal("containerResources() = nil, expected requests")
+	}
+	if got.Requests["cpu"] != "500m" || got.Requests["memory"] != "256Mi" {
+		t.Errorf("containerResources() requests = %v, expected cpu 500m and memory 256Mi", got.Requests)
+	}
+	if got.Limits != nil {
+		t.Errorf("containerResources() limits = %v, expected nil", got.Limits)
+	}
+}
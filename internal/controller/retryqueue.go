@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// retryQueueMaxRecords bounds the number of records kept in the
+// retry queue.
+const retryQueueMaxRecords = 10000
+
+// errRetryQueueFull is returned when a record does not fit into the
+// retry queue.
+var errRetryQueueFull = errors.New("retry queue full")
+
+// diskQueue persists deployment records that failed to post in a
+// directory, one JSON file per record, so they can be replayed once
+// the API recovers or after a restart. Records are keyed by deployment
+// name and digest: a newer record for the same deployment and digest
+// (e.g. its decommission) replaces the older one.
+type diskQueue struct {
+	dir string
+	max int
+}
+
+// newDiskQueue creates a queue in dir, creating the directory if
+// needed.
+func newDiskQueue(dir string, maxRecords int) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create retry queue directory: %w", err)
+	}
+	return &diskQueue{
+		dir: dir,
+		max: maxRecords,
+	}, nil
+}
+
+// path returns the file holding the record.
+func (q *diskQueue) path(record *deploymentrecord.DeploymentRecord) string {
+	sum := sha256.Sum256([]byte(getCacheKey(record.DeploymentName, record.Digest)))
+	return filepath.Join(q.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// put stores the record. The file is written to a temporary file
+// first, so a crash never leaves a partial record behind.
+func (q *diskQueue) put(record *deploymentrecord.DeploymentRecord) error {
+	path := q.path(record)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		n, err := q.len()
+		if err != nil {
+			return err
+		}
+		if n >= q.max {
+			return errRetryQueueFull
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	tmp, err := os.CreateTemp(q.dir, ".record-*")
+	if err != nil {
+		return fmt.Errorf("failed to create record file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write record file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write record file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store record file: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the record and returns true if it was stored.
+func (q *diskQueue) remove(record *deploymentrecord.DeploymentRecord) (bool, error) {
+	err := os.Remove(q.path(record))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to remove record file: %w", err)
+	}
+	return true, nil
+}
+
+// list returns the stored records, oldest first. Unreadable files are
+// skipped.
+func (q *diskQueue) list() ([]*deploymentrecord.DeploymentRecord, error) {
+	entries, err := q.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	type stored struct {
+		record  *deploymentrecord.DeploymentRecord
+		modTime time.Time
+	}
+	var res []stored
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var record deploymentrecord.DeploymentRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			continue
+		}
+		res = append(res, stored{record: &record, modTime: info.ModTime()})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].modTime.Before(res[j].modTime)
+	})
+
+	records := make([]*deploymentrecord.DeploymentRecord, 0, len(res))
+	for _, s := range res {
+		records = append(records, s.record)
+	}
+	return records, nil
+}
+
+// len returns the number of stored records.
+func (q *diskQueue) len() (int, error) {
+	entries, err := q.entries()
+	return len(entries), err
+}
+
+// entries returns the record files in the queue directory.
+func (q *diskQueue) entries() ([]os.DirEntry, error) {
+	all, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retry queue directory: %w", err)
+	}
+	var res []os.DirEntry
+	for _, e := range all {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".json") && !strings.HasPrefix(e.Name(), ".") {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+func newTestRecord(dn, digest, status string) *deploymentrecord.DeploymentRecord {
+	return deploymentrecord.NewDeploymentRecord("ghcr.io/org/app", digest, "v1", "prod", "", "cluster", status, dn)
+}
+
+func TestDiskQueue(t *testing.T) {
+	q, err := newDiskQueue(t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("newDiskQueue() unexpected error: %v", err)
+	}
+
+	web := newTestRecord("ns/web/app", "sha256:a", deploymentrecord.StatusDeployed)
+	db := newTestRecord("ns/db/postgres", "sha256:b", deploymentrecord.StatusDeployed)
+	for _, r := range []*deploymentrecord.DeploymentRecord{web, db} {
+		if err := q.put(r); err != nil {
+			t.Fatalf("put() unexpected error: %v", err)
+		}
+	}
+
+	// A newer record for the same deployment and digest replaces the
+	// stored one, even when the queue is full
+	webGone := newTestRecord("ns/web/app", "sha256:a", deploymentrecord.StatusDecommissioned)
+	if err := q.put(webGone); err != nil {
+		t.Fatalf("put() replacing a record unexpected error: %v", err)
+	}
+
+	other := newTestRecord("ns/other/app", "sha256:c", deploymentrecord.StatusDeployed)
+	if err := q.put(other); !errors.Is(err, errRetryQueueFull) {
+		t.Errorf("put() on full queue error = %v, want %v", err, errRetryQueueFull)
+	}
+
+	records, err := q.list()
+	if err != nil {
+		t.Fatalf("list() unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("list() returned %d records, want 2", len(records))
+	}
+	for _, r := range records {
+		if r.DeploymentName == webGone.DeploymentName && r.Status != deploymentrecord.StatusDecommissioned {
+			t.Errorf("list() status of %s = %s, want %s", r.DeploymentName, r.Status, deploymentrecord.StatusDecommissioned)
+		}
+	}
+
+	removed, err := q.remove(db)
+	if err != nil || !removed {
+		t.Errorf("remove() = %v, %v, want true, nil", removed, err)
+	}
+	removed, err = q.remove(db)
+	if err != nil || removed {
+		t.Errorf("remove() of missing record = %v, %v, want false, nil", removed, err)
+	}
+	if n, _ := q.len(); n != 1 {
+		t.Errorf("len() = %d, want 1", n)
+	}
+}
+
+func TestReplayRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		remaining int
+		observed  bool
+	}{
+		{
+			name:      "posted",
+			status:    http.StatusOK,
+			remaining: 0,
+			observed:  true,
+		},
+		{
+			name:      "rejected",
+			status:    http.StatusBadRequest,
+			remaining: 0,
+		},
+		{
+			name:      "api unavailable",
+			status:    http.StatusNotImplemented,
+			remaining: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+			q, err := newDiskQueue(t.TempDir(), retryQueueMaxRecords)
+			if err != nil {
+				t.Fatalf("newDiskQueue() unexpected error: %v", err)
+			}
+			c := &Controller{apiClient: client, retryQueue: q}
+
+			records := []*deploymentrecord.DeploymentRecord{
+				newTestRecord("ns/web/app", "sha256:a", deploymentrecord.StatusDeployed),
+				newTestRecord("ns/db/postgres", "sha256:b", deploymentrecord.StatusDeployed),
+			}
+			for _, r := range records {
+				c.enqueueRetry(r)
+			}
+
+			c.replayRetries(context.Background())
+
+			if n, _ := q.len(); n != tt.remaining {
+				t.Errorf("records remaining = %d, want %d", n, tt.remaining)
+			}
+			_, observed := c.observedDeployments.load(getCacheKey("ns/web/app", "sha256:a"))
+			if observed != tt.observed {
+				t.Errorf("observed = %v, want %v", observed, tt.observed)
+			}
+		})
+	}
+}
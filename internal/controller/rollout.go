@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// addRolloutHandlers adds the handlers of the Deployment informers
+// used in rollout mode: a Deployment whose rollout just completed
+// enqueues a rollout event.
+func (c *Controller) addRolloutHandlers(informers []cache.SharedIndexInformer) error {
+	handlers := cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			oldD, ok := oldObj.(*appsv1.Deployment)
+			if !ok {
+				return
+			}
+			newD, ok := newObj.(*appsv1.Deployment)
+			if !ok || !c.namespaceTracked(newD.Namespace) {
+				return
+			}
+
+			// Enqueue each completed rollout once: when the
+			// Deployment becomes rolled out, or a new revision is
+			// rolled out without the status passing through an
+			// incomplete state
+			if !rolledOut(newD) ||
+				(rolledOut(oldD) && oldD.Annotations[revisionAnnotation] == newD.Annotations[revisionAnnotation]) {
+				return
+			}
+			key, err := cache.MetaNamespaceKeyFunc(newD)
+			if err != nil {
+				return
+			}
+			c.workqueue.Add(PodEvent{
+				Key:       key,
+				EventType: EventRolloutComplete,
+			})
+		},
+	}
+	for _, informer := range informers {
+		if _, err := informer.AddEventHandler(handlers); err != nil {
+			return fmt.Errorf("failed to add deployment event handlers: %w", err)
+		}
+	}
+	return nil
+}
+
+// rolledOut returns true if the rollout of the Deployment completed:
+// the latest spec was observed, and all replicas are updated and
+// available, with no old replicas left. This matches kubectl rollout
+// status.
+func rolledOut(d *appsv1.Deployment) bool {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.UpdatedReplicas == replicas &&
+		d.Status.Replicas == replicas &&
+		d.Status.AvailableReplicas == replicas
+}
+
+// rolloutComplete returns true if the pod may be recorded in rollout
+// mode: its Deployment is rolled out and the pod belongs to the
+// current revision. Pods not owned by a Deployment are always
+// recorded.
+func (c *Controller) rolloutComplete(pod *corev1.Pod) bool {
+	rsName := getReplicaSetName(pod)
+	if rsName == "" {
+		return true
+	}
+	rs, err := c.rsLister.ReplicaSets(pod.Namespace).Get(rsName)
+	if err != nil {
+		return false
+	}
+	name := getReplicaSetDeploymentName(rs)
+	if name == "" {
+		return true
+	}
+	d, err := c.deploymentLister.Deployments(pod.Namespace).Get(name)
+	if err != nil {
+		return false
+	}
+	return rolledOut(d) && rs.Annotations[revisionAnnotation] == d.Annotations[revisionAnnotation]
+}
+
+// enqueueRollout enqueues the running pods of the current ReplicaSet
+// of the Deployment with the key, so their records are posted once
+// its rollout completed.
+func (c *Controller) enqueueRollout(key string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	d, err := c.deploymentLister.Deployments(ns).Get(name)
+	if err != nil || !rolledOut(d) {
+		// The Deployment was deleted or is rolling out another
+		// revision since, nothing to record
+		return nil
+	}
+
+	replicaSets, err := c.rsLister.ReplicaSets(ns).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list replicasets: %w", err)
+	}
+	var current *appsv1.ReplicaSet
+	for _, rs := range replicaSets {
+		if getReplicaSetDeploymentName(rs) == name &&
+			rs.Annotations[revisionAnnotation] == d.Annotations[revisionAnnotation] {
+			current = rs
+			break
+		}
+	}
+	if current == nil || current.Spec.Selector == nil {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(current.Spec.Selector)
+	if err != nil {
+		return nil
+	}
+	pods, err := c.podLister.Pods(ns).List(selector)
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var count int
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || getReplicaSetName(pod) != current.Name || !c.podStarted(pod) {
+			continue
+		}
+		podKey, err := cache.MetaNamespaceKeyFunc(pod)
+		if err != nil {
+			continue
+		}
+		c.workqueue.Add(PodEvent{
+			Key:       podKey,
+			EventType: EventCreated,
+		})
+		count++
+	}
+	slog.Info("Deployment rolled out",
+		"namespace", ns,
+		"deployment", name,
+		"revision", d.Annotations[revisionAnnotation],
+		"pods", count,
+	)
+	return nil
+}
+
+// podRevision returns the rollout revision of the pod's ReplicaSet, or
+// "" if the pod is not owned by a ReplicaSet of a Deployment.
+func (c *Controller) podRevision(pod *corev1.Pod) string {
+	rsName := getReplicaSetName(pod)
+	if rsName == "" {
+		return ""
+	}
+	rs, err := c.rsLister.ReplicaSets(pod.Namespace).Get(rsName)
+	if err != nil {
+		return ""
+	}
+	return rs.Annotations[revisionAnnotation]
+}
+
+// setPartiallyDeployed marks the record of a pod of a rollout in
+// progress as partially deployed, with the replicas of the pod's
+// revision out of the replicas of its Deployment, if known.
+func (c *Controller) setPartiallyDeployed(pod *corev1.Pod, record *deploymentrecord.DeploymentRecord) {
+	record.Status = deploymentrecord.StatusPartiallyDeployed
+
+	rs, err := c.rsLister.ReplicaSets(pod.Namespace).Get(getReplicaSetName(pod))
+	if err != nil {
+		return
+	}
+	revisionReplicas := rs.Status.Replicas
+	record.RevisionReplicas = &revisionReplicas
+
+	d, err := c.deploymentLister.Deployments(pod.Namespace).Get(getReplicaSetDeploymentName(rs))
+	if err != nil || d.Spec.Replicas == nil {
+		return
+	}
+	replicas := *d.Spec.Replicas
+	record.Replicas = &replicas
+}
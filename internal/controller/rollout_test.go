@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func newTestDeployment(revision string, replicas, updated, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Generation:  2,
+			Annotations: map[string]string{revisionAnnotation: revision},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           max(replicas, updated),
+			UpdatedReplicas:    updated,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+func TestRolledOut(t *testing.T) {
+	unobserved := newTestDeployment("2", 3, 3, 3)
+	unobserved.Generation = 3
+
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		expected   bool
+	}{
+		{
+			name:       "rolled out",
+			deployment: newTestDeployment("2", 3, 3, 3),
+			expected:   true,
+		},
+		{
+			name:       "updating",
+			deployment: newTestDeployment("2", 3, 1, 3),
+		},
+		{
+			name:       "unavailable",
+			deployment: newTestDeployment("2", 3, 3, 2),
+		},
+		{
+			name:       "spec not observed",
+			deployment: unobserved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rolledOut(tt.deployment); got != tt.expected {
+				t.Errorf("rolledOut() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRollout(t *testing.T) {
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		complete   map[string]bool
+		queued     []string
+	}{
+		{
+			name:       "rolled out",
+			deployment: newTestDeployment("2", 1, 1, 1),
+			complete: map[string]bool{
+				"web-111-abcde": false,
+				"web-222-abcde": true,
+				"db-0":          true,
+			},
+			queued: []string{"default/web-222-abcde"},
+		},
+		{
+			name:       "in progress",
+			deployment: newTestDeployment("2", 1, 1, 0),
+			complete: map[string]bool{
+				"web-111-abcde": false,
+				"web-222-abcde": false,
+				"db-0":          true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newRS := newTestReplicaSet("web-222", "web", "2")
+			newRS.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"pod-template-hash": "222"}}
+			rsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, rs := range []*appsv1.ReplicaSet{newTestReplicaSet("web-111", "web", "1"), newRS} {
+				if err := rsIndexer.Add(rs); err != nil {
+					t.Fatalf("failed to add replicaset: %v", err)
+				}
+			}
+			dIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if err := dIndexer.Add(tt.deployment); err != nil {
+				t.Fatalf("failed to add deployment: %v", err)
+			}
+
+			oldPod := newTestPod("web-111")
+			oldPod.Status.Phase = corev1.PodRunning
+			newPod := newTestPod("web-222")
+			newPod.Labels = map[string]string{"pod-template-hash": "222"}
+			newPod.Status.Phase = corev1.PodRunning
+			statefulPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "db-0",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "StatefulSet", Name: "db"},
+					},
+				},
+			}
+			podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, p := range []*corev1.Pod{oldPod, newPod, statefulPod} {
+				if err := podIndexer.Add(p); err != nil {
+					t.Fatalf("failed to add pod: %v", err)
+				}
+			}
+
+			queue := workqueue.NewTypedRateLimitingQueue(
+				workqueue.DefaultTypedControllerRateLimiter[PodEvent](),
+			)
+			defer queue.ShutDown()
+			c := &Controller{
+				podLister:        corelisters.NewPodLister(podIndexer),
+				rsLister:         appslisters.NewReplicaSetLister(rsIndexer),
+				deploymentLister: appslisters.NewDeploymentLister(dIndexer),
+				workqueue:        queue,
+			}
+			c.cfg.Store(&Config{RolloutStatus: true})
+
+			for _, p := range []*corev1.Pod{oldPod, newPod, statefulPod} {
+				if got := c.rolloutComplete(p); got != tt.complete[p.Name] {
+					t.Errorf("rolloutComplete(%s) = %v, expected %v", p.Name, got, tt.complete[p.Name])
+				}
+			}
+
+			if err := c.enqueueRollout("default/web"); err != nil {
+				t.Fatalf("enqueueRollout() unexpected error: %v", err)
+			}
+			if queue.Len() != len(tt.queued) {
+				t.Fatalf("queued events = %d, expected %d", queue.Len(), len(tt.queued))
+			}
+			for _, key := range tt.queued {
+				event, _ := queue.Get()
+				if event.Key != key || event.EventType != EventCreated {
+					t.Errorf("queued event = %+v, expected %s for %s", event, EventCreated, key)
+				}
+				queue.Done(event)
+			}
+		})
+	}
+}
+
+func TestPartialRollout(t *testing.T) {
+	var posted []deploymentrecord.DeploymentRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var record deploymentrecord.DeploymentRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		posted = append(posted, record)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+
+	rs := newTestReplicaSet("web-222", "web", "2")
+	rs.Status.Replicas = 1
+	dIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	// A canary: one of four replicas updated
+	if err := dIndexer.Add(newTestDeployment("2", 4, 1, 0)); err != nil {
+		t.Fatalf("failed to add deployment: %v", err)
+	}
+
+	pod := newTestPod("web-222")
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/web:v2"}}
+	pod.Status = corev1.PodStatus{
+		Phase: corev1.PodRunning,
+		ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", ImageID: "ghcr.io/org/web@sha256:abc"},
+		},
+	}
+
+	c := &Controller{
+		apiClient:        client,
+		rsLister:         newTestReplicaSetLister(t, rs),
+		deploymentLister: appslisters.NewDeploymentLister(dIndexer),
+	}
+	c.cfg.Store(&Config{
+		Template:        TmplNS + "/" + TmplDN + "/" + TmplCN,
+		PartialRollouts: true,
+	})
+	key := getCacheKey("default/web/app", "sha256:abc")
+
+	record := func() {
+		t.Helper()
+		if err := c.recordContainer(context.Background(), pod, pod.Spec.Containers[0],
+			deploymentrecord.StatusDeployed, EventCreated); err != nil {
+			t.Fatalf("recordContainer() unexpected error: %v", err)
+		}
+	}
+
+	// Partially deployed, posted once
+	record()
+	record()
+	if len(posted) != 1 {
+		t.Fatalf("posted %d records during the rollout, want 1", len(posted))
+	}
+	got := posted[0]
+	if got.Status != deploymentrecord.StatusPartiallyDeployed ||
+		got.RevisionReplicas == nil || *got.RevisionReplicas != 1 ||
+		got.Replicas == nil || *got.Replicas != 4 {
+		t.Errorf("record = %+v, want partially deployed with 1 of 4 replicas", got)
+	}
+	if _, ok := c.observedDeployments.load(key); ok {
+		t.Error("partially deployed record observed as deployed")
+	}
+
+	// Deployed once the rollout completed
+	if err := dIndexer.Update(newTestDeployment("2", 4, 4, 4)); err != nil {
+		t.Fatalf("failed to update deployment: %v", err)
+	}
+	record()
+	if len(posted) != 2 || posted[1].Status != deploymentrecord.StatusDeployed {
+		t.Fatalf("posted records = %+v, want a deployed record after the rollout", posted)
+	}
+	if _, ok := c.observedDeployments.load(key); !ok {
+		t.Error("deployed record not observed")
+	}
+	if _, ok := c.partialDeployments.Load(key); ok {
+		t.Error("deployed record still partially observed")
+	}
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/image"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// DigestResolver resolves the digest of an image reference, e.g. with
+// registry.Client.Digest.
+type DigestResolver func(ctx context.Context, ref string) (string, error)
+
+// podTemplatePaths are the paths of the pod template of the workload
+// kinds recorded from manifests.
+var podTemplatePaths = map[string][]string{
+	kindDeployment:       {"spec", "template"},
+	kindStatefulSet:      {"spec", "template"},
+	kindDaemonSet:        {"spec", "template"},
+	kindJob:              {"spec", "template"},
+	kindCronJob:          {"spec", "jobTemplate", "spec", "template"},
+	kindDeploymentConfig: {"spec", "template"},
+}
+
+// ScanManifests returns the deployed records of the containers of the
+// workloads in the Kubernetes manifests read from r, YAML or JSON
+// documents, e.g. rendered by helm template. Objects without a
+// namespace are in namespace. The records are named with the template
+// of cfg, as if the workloads ran, and their images must have a
+// digest, or else it is resolved with resolve, if not nil. Containers
+// whose digest can't be determined are logged and skipped.
+//
+// The tracking annotations, excluded containers and workload kinds of
+// cfg apply, as in the controller. Pods don't exist yet, so
+// placeholders of the pod name and node are empty, and the metadata of
+// records is taken from the pod templates only.
+func ScanManifests(ctx context.Context, cfg *Config, r io.Reader, namespace string, resolve DigestResolver) ([]*deploymentrecord.DeploymentRecord, error) {
+	var objs []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			// Empty document
+			continue
+		}
+		// Unstructured keeps integers, unlike decoding into a map
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		objs = append(objs, flattenList(obj)...)
+	}
+
+	// Without informers, the controller only provides the record
+	// helpers working on the pod itself
+	c := &Controller{}
+	var records []*deploymentrecord.DeploymentRecord
+	for _, obj := range objs {
+		pod, wl, err := manifestPod(cfg, obj, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if pod == nil {
+			continue
+		}
+		tmpl := c.template(cfg, pod.Namespace)
+		for _, container := range slices.Concat(pod.Spec.Containers, pod.Spec.InitContainers) {
+			if containerExcluded(cfg, container) {
+				continue
+			}
+			dn := getARDeploymentName(pod, container, wl, tmpl, cfg.Cluster)
+			digest, err := manifestDigest(ctx, container.Image, resolve)
+			if err != nil || digest == "" {
+				slog.Warn("Skipping container without a digest",
+					"deployment_name", dn,
+					"image", container.Image,
+					"error", err,
+				)
+				continue
+			}
+			record := c.newRecord(cfg, pod, container, dn, digest, deploymentrecord.StatusDeployed)
+			if cfg.RecordResources && wl.Kind == kindDeployment {
+				if replicas, ok, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); ok {
+					//nolint:gosec
+					r := int32(replicas)
+					record.Replicas = &r
+				}
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// flattenList returns the items of a List, or else the object.
+func flattenList(obj *unstructured.Unstructured) []*unstructured.Unstructured {
+	if !obj.IsList() {
+		return []*unstructured.Unstructured{obj}
+	}
+	var res []*unstructured.Unstructured
+	_ = obj.EachListItem(func(item runtime.Object) error {
+		if u, ok := item.(*unstructured.Unstructured); ok {
+			res = append(res, flattenList(u)...)
+		}
+		return nil
+	})
+	return res
+}
+
+// manifestPod returns a pod of the workload manifest obj, with the
+// metadata and spec of its pod template, and the workload. It returns
+// a nil pod for objects that are not recorded.
+func manifestPod(cfg *Config, obj *unstructured.Unstructured, namespace string) (*corev1.Pod, workload, error) {
+	kind := obj.GetKind()
+	path, ok := podTemplatePaths[kind]
+	switch {
+	case !ok:
+		return nil, workload{}, nil
+	case (kind == kindJob || kind == kindCronJob) && !cfg.BatchWorkloads:
+		return nil, workload{}, nil
+	case kind == kindDeploymentConfig && !cfg.DeploymentConfigs:
+		return nil, workload{}, nil
+	}
+
+	raw, found, err := unstructured.NestedMap(obj.Object, path...)
+	if err != nil || !found {
+		return nil, workload{}, fmt.Errorf("invalid pod template of %s %s", kind, obj.GetName())
+	}
+	var template corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &template); err != nil {
+		return nil, workload{}, fmt.Errorf("invalid pod template of %s %s: %w", kind, obj.GetName(), err)
+	}
+
+	// The ignore and track annotations apply to the workload and its
+	// pods, as in trackingEnabled
+	var tracked bool
+	for _, anns := range []map[string]string{obj.GetAnnotations(), template.Annotations} {
+		if isTrue(anns[ignoreAnnotation]) {
+			return nil, workload{}, nil
+		}
+		if isTrue(anns[trackAnnotation]) {
+			tracked = true
+		}
+	}
+	if cfg.OptIn && !tracked {
+		return nil, workload{}, nil
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: template.ObjectMeta,
+		Spec:       template.Spec,
+	}
+	pod.Namespace = obj.GetNamespace()
+	if pod.Namespace == "" {
+		pod.Namespace = namespace
+	}
+	return pod, workload{Kind: kind, Name: obj.GetName()}, nil
+}
+
+// manifestDigest returns the digest of the image reference ref, from
+// the reference or else resolved with resolve.
+func manifestDigest(ctx context.Context, ref string, resolve DigestResolver) (string, error) {
+	parsed, err := image.ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Digest != "" {
+		return parsed.Digest, nil
+	}
+	if resolve == nil {
+		return "", nil
+	}
+	return resolve(ctx, ref)
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testManifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      initContainers:
+      - name: migrate
+        image: ghcr.io/org/migrate@sha256:111
+      containers:
+      - name: app
+        image: ghcr.io/org/web:v1
+      - name: istio-proxy
+        image: docker.io/istio/proxyv2:1.20.0
+---
+# Rendered by helm template
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: batch/v1
+  kind: CronJob
+  metadata:
+    name: report
+  spec:
+    jobTemplate:
+      spec:
+        template:
+          spec:
+            containers:
+            - name: report
+              image: ghcr.io/org/report:v2@sha256:222
+- apiVersion: apps/v1
+  kind: StatefulSet
+  metadata:
+    name: db
+    annotations:
+      deployment-tracker.github.com/ignore: "true"
+  spec:
+    template:
+      spec:
+        containers:
+        - name: db
+          image: ghcr.io/org/db@sha256:333
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: web
+`
+
+// scanTestTemplate is the default deployment name template.
+const scanTestTemplate = TmplNS + "/" + TmplDN + "/" + TmplCN
+
+func TestScanManifests(t *testing.T) {
+	resolve := func(_ context.Context, ref string) (string, error) {
+		if ref == "ghcr.io/org/web:v1" {
+			return "sha256:444", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		resolve  DigestResolver
+		expected []string
+	}{
+		{
+			name:    "resolved",
+			cfg:     Config{ExcludeContainers: "istio-proxy"},
+			resolve: resolve,
+			expected: []string{
+				"shop/web/app sha256:444 ghcr.io/org/web:v1",
+				"shop/web/migrate sha256:111 ghcr.io/org/migrate:",
+			},
+		},
+		{
+			name: "digests only",
+			cfg:  Config{ExcludeContainers: "istio-proxy"},
+			expected: []string{
+				"shop/web/migrate sha256:111 ghcr.io/org/migrate:",
+			},
+		},
+		{
+			name: "batch workloads",
+			cfg:  Config{ExcludeContainers: "istio-proxy", BatchWorkloads: true},
+			expected: []string{
+				"shop/web/migrate sha256:111 ghcr.io/org/migrate:",
+				"default/report/report sha256:222 ghcr.io/org/report:v2",
+			},
+		},
+		{
+			name: "opt in",
+			cfg:  Config{OptIn: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Template = scanTestTemplate
+			records, err := ScanManifests(context.Background(), &tt.cfg, strings.NewReader(testManifests), "default", tt.resolve)
+			if err != nil {
+				t.Fatalf("ScanManifests() unexpected error: %v", err)
+			}
+			var got []string
+			for _, r := range records {
+				got = append(got, r.DeploymentName+" "+r.Digest+" "+r.Name+":"+r.Version)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("records = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScanManifestsReplicas(t *testing.T) {
+	cfg := Config{Template: scanTestTemplate, RecordResources: true}
+	records, err := ScanManifests(context.Background(), &cfg, strings.NewReader(testManifests), "default", nil)
+	if err != nil {
+		t.Fatalf("ScanManifests() unexpected error: %v", err)
+	}
+	if len(records) == 0 || records[0].Replicas == nil || *records[0].Replicas != 3 {
+		t.Errorf("expected 3 replicas, got %v", records)
+	}
+}
+
+func TestScanManifestsInvalid(t *testing.T) {
+	cfg := Config{Template: scanTestTemplate}
+	manifest := "kind: Deployment\nmetadata:\n  name: web\nspec: {}\n"
+	if _, err := ScanManifests(context.Background(), &cfg, strings.NewReader(manifest), "default", nil); err == nil {
+		t.Error("ScanManifests() expected an error for a Deployment without a pod template")
+	}
+}
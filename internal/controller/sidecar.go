@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/github/deployment-tracker/pkg/image"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Default sidecar exclusion rules, covering the proxies and init
+// containers injected by common service meshes.
+const (
+	// DefaultExcludeContainers is the default comma separated list of
+	// excluded container names.
+	DefaultExcludeContainers = "istio-proxy,istio-init,istio-validation,linkerd-proxy,linkerd-init,linkerd-network-validator"
+	// DefaultExcludeImagePrefixes is the default comma separated list
+	// of excluded image prefixes.
+	DefaultExcludeImagePrefixes = "docker.io/istio/proxyv2,gcr.io/istio-release/proxyv2,cr.l5d.io/linkerd/proxy"
+)
+
+// containerExcluded returns true if the container matches an
+// exclusion rule: its name is listed in ExcludeContainers, or its
+// image starts with one of ExcludeImagePrefixes. Prefixes match the
+// image as spelled in the pod spec or normalized, so
+// "docker.io/istio/proxyv2" matches "istio/proxyv2:1.22".
+func containerExcluded(cfg *Config, container corev1.Container) bool {
+	for _, name := range splitList(cfg.ExcludeContainers) {
+		if container.Name == name {
+			return true
+		}
+	}
+	prefixes := splitList(cfg.ExcludeImagePrefixes)
+	if len(prefixes) == 0 {
+		return false
+	}
+	normalized := container.Image
+	if ref, err := image.ParseReference(container.Image); err == nil {
+		normalized = ref.Normalize().String()
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(container.Image, prefix) || strings.HasPrefix(normalized, prefix) {
+			return true
+		}
+	}
+	return false
+}
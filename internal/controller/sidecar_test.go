@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestContainerExcluded(t *testing.T) {
+	defaults := &Config{
+		ExcludeContainers:    DefaultExcludeContainers,
+		ExcludeImagePrefixes: DefaultExcludeImagePrefixes,
+	}
+
+	tests := []struct {
+		name      string
+		cfg       *Config
+		container corev1.Container
+		expected  bool
+	}{
+		{
+			name:      "application container",
+			cfg:       defaults,
+			container: corev1.Container{Name: "app", Image: "ghcr.io/org/app:v1"},
+			expected:  false,
+		},
+		{
+			name:      "istio sidecar by name",
+			cfg:       defaults,
+			container: corev1.Container{Name: "istio-proxy", Image: "registry.internal/proxyv2:1.22"},
+			expected:  true,
+		},
+		{
+			name:      "linkerd sidecar by image",
+			cfg:       defaults,
+			container: corev1.Container{Name: "proxy", Image: "cr.l5d.io/linkerd/proxy:stable-2.14"},
+			expected:  true,
+		},
+		{
+			name:      "istio sidecar by docker hub image without registry",
+			cfg:       defaults,
+			container: corev1.Container{Name: "proxy", Image: "istio/proxyv2:1.22"},
+			expected:  true,
+		},
+		{
+			name: "custom rules",
+			cfg: &Config{
+				ExcludeContainers:    "log-shipper",
+				ExcludeImagePrefixes: "ghcr.io/org/fluent-bit",
+			},
+			container: corev1.Container{Name: "logs", Image: "ghcr.io/org/fluent-bit:3.0"},
+			expected:  true,
+		},
+		{
+			name:      "rules disabled",
+			cfg:       &Config{},
+			container: corev1.Container{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.22"},
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containerExcluded(tt.cfg, tt.container); got != tt.expected {
+				t.Errorf("containerExcluded() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+)
+
+// checkSignatures looks up the cosign signatures and attestations of
+// the digest of the record in the repository of its image, and adds
+// them to the record, so it reflects the supply chain posture of the
+// image at deploy time. Registry failures leave the fields unset, the
+// record is posted anyway.
+func (c *Controller) checkSignatures(ctx context.Context, namespace, ref string, record *deploymentrecord.DeploymentRecord) {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	sigs, err := c.registry.CosignSignatures(ctx, ref, record.Digest)
+	if err != nil {
+		slog.Warn("Failed to look up image signatures",
+			"image", ref,
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+			"error", err,
+		)
+		metrics.SignatureChecks.WithLabelValues(namespace, "error").Inc()
+		return
+	}
+
+	record.Signed = &sigs.Signed
+	record.Attested = &sigs.Attested
+	record.SignerIdentity = sigs.Identity
+	record.SignerIssuer = sigs.Issuer
+
+	result := "unsigned"
+	if sigs.Signed {
+		result = "signed"
+	}
+	metrics.SignatureChecks.WithLabelValues(namespace, result).Inc()
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/registry"
+)
+
+func TestCheckSignatures(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/org/app/manifests/sha256-a.sig":
+			_, _ = w.Write([]byte(`{"layers":[]}`))
+		case "/v2/org/broken/manifests/sha256-a.sig":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	signed, unsigned := true, false
+
+	tests := []struct {
+		name   string
+		image  string
+		digest string
+		signed *bool
+	}{
+		{
+			name:   "signed",
+			image:  host + "/org/app:v1",
+			digest: "sha256:a",
+			signed: &signed,
+		},
+		{
+			name:   "unsigned",
+			image:  host + "/org/app:v1",
+			digest: "sha256:b",
+			signed: &unsigned,
+		},
+		{
+			name:   "registry error",
+			image:  host + "/org/broken:v1",
+			digest: "sha256:a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{registry: registry.NewClient(registry.WithHTTPClient(srv.Client()))}
+			record := newTestRecord("ns/web/app", tt.digest, deploymentrecord.StatusDeployed)
+
+			c.checkSignatures(context.Background(), "ns", tt.image, record)
+
+			switch {
+			case tt.signed == nil && record.Signed != nil:
+				t.Errorf("Signed = %v, want unset", *record.Signed)
+			case tt.signed != nil && (record.Signed == nil || *record.Signed != *tt.signed):
+				t.Errorf("Signed = %v, want %v", record.Signed, *tt.signed)
+			}
+		})
+	}
+}
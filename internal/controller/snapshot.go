@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Snapshot entry statuses.
+const (
+	// SnapshotPosted is the status of a running deployment whose
+	// deployed record was posted.
+	SnapshotPosted = "posted"
+	// SnapshotPending is the status of a running deployment whose
+	// record was not posted (yet), e.g. because posting failed or
+	// its rollout is in progress in rollout mode.
+	SnapshotPending = "pending"
+	// SnapshotStale is the status of a deployment in the observation
+	// cache without a running container, e.g. because its
+	// decommission was skipped.
+	SnapshotStale = "stale"
+)
+
+// SnapshotEntry is a deployment as currently seen by the controller.
+type SnapshotEntry struct {
+	Cluster        string `json:"cluster"`
+	Namespace      string `json:"namespace,omitempty"`
+	DeploymentName string `json:"deployment_name"`
+	Image          string `json:"image,omitempty"`
+	Version        string `json:"version,omitempty"`
+	Digest         string `json:"digest"`
+	Status         string `json:"status"`
+	// Pods is the number of running pods of the deployment
+	Pods int `json:"pods"`
+	// LastPosted is the time the deployed record was last posted,
+	// unset if not known, e.g. for records posted before a restart
+	LastPosted *time.Time `json:"last_posted,omitempty"`
+}
+
+// Snapshot returns the deployments of the running pods of tracked
+// workloads and of the observation cache, ordered by deployment name
+// and digest, so operators can tell what the cluster is reporting.
+func (c *Controller) Snapshot() ([]SnapshotEntry, error) {
+	if !c.synced.Load() {
+		return nil, errors.New("informer caches not synced")
+	}
+
+	cfg := c.cfg.Load()
+	entries := make(map[string]*SnapshotEntry)
+	err := c.forEachRunningContainer(cfg, func(pod *corev1.Pod, container corev1.Container, dn, digest string) {
+		key := getCacheKey(dn, digest)
+		if e, ok := entries[key]; ok {
+			e.Pods++
+			return
+		}
+		record := c.newRecord(cfg, pod, container, dn, digest, "")
+		entries[key] = &SnapshotEntry{
+			Cluster:        cfg.Cluster,
+			Namespace:      pod.Namespace,
+			DeploymentName: dn,
+			Image:          record.Name,
+			Version:        record.Version,
+			Digest:         digest,
+			Status:         SnapshotPending,
+			Pods:           1,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.observedDeployments.each(func(key string, posted time.Time) bool {
+		e, ok := entries[key]
+		if !ok {
+			dn, digest, _ := strings.Cut(key, "||")
+			e = &SnapshotEntry{
+				Cluster:        cfg.Cluster,
+				DeploymentName: dn,
+				Digest:         digest,
+				Status:         SnapshotStale,
+			}
+			entries[key] = e
+		} else {
+			e.Status = SnapshotPosted
+		}
+		if !posted.IsZero() {
+			e.LastPosted = &posted
+		}
+		return true
+	})
+
+	res := make([]SnapshotEntry, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, *e)
+	}
+	slices.SortFunc(res, func(a, b SnapshotEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.DeploymentName, b.DeploymentName),
+			cmp.Compare(a.Digest, b.Digest),
+		)
+	})
+	return res, nil
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSnapshot(t *testing.T) {
+	runningPod := func(rsName, name, imageID string) *corev1.Pod {
+		pod := newTestPod(rsName)
+		pod.Name = name
+		pod.Spec.Containers = []corev1.Container{{Name: "app", Image: "ghcr.io/org/app:v1"}}
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", ImageID: imageID},
+			},
+		}
+		return pod
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range []*corev1.Pod{
+		runningPod("web-111", "web-111-aaaaa", "ghcr.io/org/app@sha256:a"),
+		runningPod("web-111", "web-111-bbbbb", "ghcr.io/org/app@sha256:a"),
+		runningPod("api-222", "api-222-aaaaa", "ghcr.io/org/app@sha256:b"),
+	} {
+		if err := indexer.Add(pod); err != nil {
+			t.Fatalf("failed to add pod: %v", err)
+		}
+	}
+	c := &Controller{
+		podLister: corelisters.NewPodLister(indexer),
+		rsLister: newTestReplicaSetLister(t,
+			newTestReplicaSet("web-111", "web", "1"),
+			newTestReplicaSet("api-222", "api", "1"),
+		),
+	}
+	c.cfg.Store(&Config{Template: TmplNS + "/" + TmplDN + "/" + TmplCN, Cluster: "c1"})
+
+	// Not synced yet
+	if _, err := c.Snapshot(); err == nil {
+		t.Fatal("Snapshot() expected error before sync")
+	}
+	c.synced.Store(true)
+
+	posted := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.observedDeployments.store(getCacheKey("default/web/app", "sha256:a"), posted)
+	c.observedDeployments.store(getCacheKey("default/old/app", "sha256:c"), time.Time{})
+
+	got, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() unexpected error: %v", err)
+	}
+
+	expected := []SnapshotEntry{
+		{DeploymentName: "default/api/app", Digest: "sha256:b", Status: SnapshotPending, Pods: 1},
+		{DeploymentName: "default/old/app", Digest: "sha256:c", Status: SnapshotStale},
+		{DeploymentName: "default/web/app", Digest: "sha256:a", Status: SnapshotPosted, Pods: 2},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Snapshot() = %+v, expected %d entries", got, len(expected))
+	}
+	for i, e := range expected {
+		g := got[i]
+		if g.DeploymentName != e.DeploymentName || g.Digest != e.Digest || g.Status != e.Status || g.Pods != e.Pods {
+			t.Errorf("entry %d = %+v, expected %+v", i, g, e)
+		}
+		if g.Cluster != "c1" {
+			t.Errorf("entry %d cluster = %q, expected c1", i, g.Cluster)
+		}
+	}
+	if got[0].Image != "ghcr.io/org/app" || got[0].Version != "v1" || got[0].Namespace != "default" {
+		t.Errorf("running entry = %+v, expected image, version and namespace", got[0])
+	}
+	if got[2].LastPosted == nil || !got[2].LastPosted.Equal(posted) {
+		t.Errorf("posted entry last posted = %v, expected %v", got[2].LastPosted, posted)
+	}
+	if got[1].LastPosted != nil {
+		t.Errorf("stale entry last posted = %v, expected unset", got[1].LastPosted)
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// mirrorPodAnnotation is set by the kubelet on the mirror pods of
+// static pods.
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// isMirrorPod returns true if the pod is the mirror pod of a static
+// pod: it has the mirror annotation, or is owned by its Node.
+func isMirrorPod(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return true
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "Node" {
+			return true
+		}
+	}
+	return false
+}
+
+// staticPodWorkload returns the workload of a mirror pod. The kubelet
+// names mirror pods after the static pod with the node name appended,
+// e.g. kube-apiserver-node-1, so the suffix is trimmed and the static
+// pods of all control plane nodes share a deployment name.
+func staticPodWorkload(pod *corev1.Pod) workload {
+	name := pod.Name
+	if node := pod.Spec.NodeName; node != "" {
+		name = strings.TrimSuffix(name, "-"+node)
+	}
+	return workload{Kind: kindStaticPod, Name: name}
+}
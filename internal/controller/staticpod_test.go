@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveStaticPod(t *testing.T) {
+	newMirrorPod := func(owners []metav1.OwnerReference, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "kube-apiserver-cp-1",
+				Namespace:       "kube-system",
+				Annotations:     annotations,
+				OwnerReferences: owners,
+			},
+			Spec: corev1.PodSpec{NodeName: "cp-1"},
+		}
+	}
+	nodeOwner := []metav1.OwnerReference{{Kind: "Node", Name: "cp-1"}}
+	mirrorAnnotation := map[string]string{mirrorPodAnnotation: "3f2a"}
+	apiserver := workload{Kind: kindStaticPod, Name: "kube-apiserver"}
+
+	tests := []struct {
+		name       string
+		staticPods bool
+		pod        *corev1.Pod
+		expected   workload
+	}{
+		{
+			name:       "node owner",
+			staticPods: true,
+			pod:        newMirrorPod(nodeOwner, nil),
+			expected:   apiserver,
+		},
+		{
+			name:       "mirror annotation",
+			staticPods: true,
+			pod:        newMirrorPod(nil, mirrorAnnotation),
+			expected:   apiserver,
+		},
+		{
+			name:     "disabled",
+			pod:      newMirrorPod(nodeOwner, mirrorAnnotation),
+			expected: workload{},
+		},
+		{
+			name:       "bare pod",
+			staticPods: true,
+			pod:        newMirrorPod(nil, nil),
+			expected:   workload{},
+		},
+		{
+			name:       "ignored",
+			staticPods: true,
+			pod: newMirrorPod(nodeOwner, map[string]string{
+				ignoreAnnotation: "true",
+			}),
+			expected: workload{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{}
+			c.cfg.Store(&Config{StaticPods: tt.staticPods})
+
+			if got := c.resolveWorkload(tt.pod); got != tt.expected {
+				t.Errorf("resolveWorkload() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStaticPodDeploymentName(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "etcd-cp-2",
+			Namespace: "kube-system",
+		},
+		Spec: corev1.PodSpec{NodeName: "cp-2"},
+	}
+	container := corev1.Container{Name: "etcd"}
+
+	got := getARDeploymentName(pod, container, staticPodWorkload(pod),
+		"{{namespace}}/{{workloadKind}}/{{deploymentName}}/{{nodeName}}/{{containerName}}", "kube-1")
+	if want := "kube-system/StaticPod/etcd/cp-2/etcd"; got != want {
+		t.Errorf("getARDeploymentName() = %q, expected %q", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// trackedDeploymentGVR is the resource of the TrackedDeployment status
+// resources, see deploy/crd.yaml.
+var trackedDeploymentGVR = schema.GroupVersionResource{
+	Group:    "deployment-tracker.github.com",
+	Version:  "v1alpha1",
+	Resource: "trackeddeployments",
+}
+
+// Results of the posts of TrackedDeployment containers.
+const (
+	statusResultPosted   = "Posted"
+	statusResultRejected = "Rejected"
+	statusResultFailed   = "Failed"
+)
+
+// trackedDeploymentSpec identifies the workload of a TrackedDeployment.
+type trackedDeploymentSpec struct {
+	Workload trackedWorkload `json:"workload"`
+}
+
+type trackedWorkload struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// trackedDeploymentStatus is the tracking state of the containers of
+// a workload. Result and LastPostTime are those of the latest post of
+// any container, for kubectl get.
+type trackedDeploymentStatus struct {
+	Result       string                   `json:"result,omitempty"`
+	LastPostTime *metav1.Time             `json:"lastPostTime,omitempty"`
+	Containers   []trackedContainerStatus `json:"containers,omitempty"`
+}
+
+// trackedContainerStatus is the result of the latest post of the
+// record of a container.
+type trackedContainerStatus struct {
+	Name           string      `json:"name"`
+	DeploymentName string      `json:"deploymentName"`
+	Digest         string      `json:"digest"`
+	Status         string      `json:"status"`
+	Result         string      `json:"result"`
+	Error          string      `json:"error,omitempty"`
+	LastPostTime   metav1.Time `json:"lastPostTime"`
+}
+
+// statusStore writes the post results of the records of each
+// workload to the status of a TrackedDeployment in the workload's
+// namespace, so teams can see the tracking state with kubectl, and a
+// restarted controller knows which records it posted.
+type statusStore struct {
+	client dynamic.Interface
+}
+
+// newStatusStore creates a status store writing through client.
+func newStatusStore(client dynamic.Interface) *statusStore {
+	return &statusStore{client: client}
+}
+
+// trackedDeploymentName returns the name of the TrackedDeployment of
+// the workload, e.g. "deployment-web".
+func trackedDeploymentName(wl workload) string {
+	return strings.ToLower(wl.Kind) + "-" + wl.Name
+}
+
+// update sets the status of the container in the TrackedDeployment of
+// the workload, creating it if needed. Once all containers of the
+// workload are decommissioned, the TrackedDeployment is deleted.
+func (s *statusStore) update(ctx context.Context, ns string, wl workload, cs trackedContainerStatus) error {
+	res := s.client.Resource(trackedDeploymentGVR).Namespace(ns)
+	name := trackedDeploymentName(wl)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := res.Get(ctx, name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			if cs.Status == deploymentrecord.StatusDecommissioned {
+				return nil
+			}
+			obj, err = res.Create(ctx, newTrackedDeployment(ns, name, wl), metav1.CreateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+
+		status, err := trackedStatus(obj)
+		if err != nil {
+			return err
+		}
+		status.set(cs)
+		if status.decommissioned() {
+			rv := obj.GetResourceVersion()
+			err := res.Delete(ctx, name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &rv},
+			})
+			if k8serrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+		if err != nil {
+			return fmt.Errorf("failed to convert status: %w", err)
+		}
+		obj.Object["status"] = u
+		_, err = res.UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// posted returns the cache keys of the deployed records posted
+// according to the TrackedDeployments of the namespaces for which
+// tracked returns true, with the time they were posted.
+func (s *statusStore) posted(ctx context.Context, tracked func(ns string) bool) (map[string]time.Time, error) {
+	list, err := s.client.Resource(trackedDeploymentGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trackeddeployments: %w", err)
+	}
+
+	res := make(map[string]time.Time)
+	var errs []error
+	for i := range list.Items {
+		if !tracked(list.Items[i].GetNamespace()) {
+			continue
+		}
+		status, err := trackedStatus(&list.Items[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, cs := range status.Containers {
+			if cs.Status == deploymentrecord.StatusDeployed && cs.Result == statusResultPosted {
+				res[getCacheKey(cs.DeploymentName, cs.Digest)] = cs.LastPostTime.Time
+			}
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+// newTrackedDeployment returns a TrackedDeployment of the workload
+// without status.
+func newTrackedDeployment(ns, name string, wl workload) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"workload": map[string]any{
+				"kind": wl.Kind,
+				"name": wl.Name,
+			},
+		},
+	}}
+	obj.SetAPIVersion(trackedDeploymentGVR.GroupVersion().String())
+	obj.SetKind("TrackedDeployment")
+	obj.SetNamespace(ns)
+	obj.SetName(name)
+	obj.SetLabels(map[string]string{
+		"app.kubernetes.io/managed-by": "deployment-tracker",
+	})
+	return obj
+}
+
+// trackedStatus returns the status of the TrackedDeployment.
+func trackedStatus(obj *unstructured.Unstructured) (trackedDeploymentStatus, error) {
+	var status trackedDeploymentStatus
+	u, ok := obj.Object["status"].(map[string]any)
+	if !ok {
+		return status, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, &status); err != nil {
+		return status, fmt.Errorf("invalid status of trackeddeployment %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return status, nil
+}
+
+// set replaces the status of the container, and updates the summary.
+func (s *trackedDeploymentStatus) set(cs trackedContainerStatus) {
+	i := slices.IndexFunc(s.Containers, func(c trackedContainerStatus) bool {
+		return c.Name == cs.Name
+	})
+	if i < 0 {
+		s.Containers = append(s.Containers, cs)
+		slices.SortFunc(s.Containers, func(a, b trackedContainerStatus) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	} else {
+		s.Containers[i] = cs
+	}
+	s.Result = cs.Result
+	s.LastPostTime = &cs.LastPostTime
+}
+
+// decommissioned returns true if the records of all containers were
+// decommissioned.
+func (s *trackedDeploymentStatus) decommissioned() bool {
+	for _, cs := range s.Containers {
+		if cs.Status != deploymentrecord.StatusDecommissioned || cs.Result != statusResultPosted {
+			return false
+		}
+	}
+	return len(s.Containers) > 0
+}
+
+// CheckStatusResources returns an error if status resources are
+// enabled but the TrackedDeployments can't be listed, e.g. because
+// the CRD is not installed or the permissions are missing.
+func (c *Controller) CheckStatusResources(ctx context.Context) error {
+	if c.statusStore == nil {
+		return nil
+	}
+	_, err := c.statusStore.client.Resource(trackedDeploymentGVR).List(ctx, metav1.ListOptions{Limit: 1})
+	if k8serrors.IsNotFound(err) {
+		return fmt.Errorf("%s.%s is not served, is the CRD of deploy/crd.yaml installed?",
+			trackedDeploymentGVR.Resource, trackedDeploymentGVR.Group)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list trackeddeployments: %w", err)
+	}
+	return nil
+}
+
+// updateStatusResource records the result of posting the record of
+// the container in the TrackedDeployment of its workload, if status
+// resources are enabled. Failing to write it is logged, as the record
+// has been posted (or failed) already.
+func (c *Controller) updateStatusResource(ctx context.Context, ns string, wl workload, container string, record *deploymentrecord.DeploymentRecord, postErr error) {
+	if c.statusStore == nil || wl.Name == "" {
+		return
+	}
+
+	cs := trackedContainerStatus{
+		Name:           container,
+		DeploymentName: record.DeploymentName,
+		Digest:         record.Digest,
+		Status:         record.Status,
+		Result:         statusResultPosted,
+		LastPostTime:   metav1.Now(),
+	}
+	if postErr != nil {
+		cs.Result = statusResultFailed
+		var clientErr *deploymentrecord.ClientError
+		if errors.As(postErr, &clientErr) {
+			cs.Result = statusResultRejected
+		}
+		cs.Error = postErr.Error()
+	}
+
+	if err := c.statusStore.update(ctx, ns, wl, cs); err != nil {
+		slog.Warn("Failed to update trackeddeployment status",
+			"namespace", ns,
+			"name", trackedDeploymentName(wl),
+			"container", container,
+			"error", err,
+		)
+	}
+}
+
+// loadStatusResources populates the observation cache with the
+// records posted according to the TrackedDeployments of the tracked
+// namespaces. Failing to load is not fatal, as the cache is best
+// effort.
+func (c *Controller) loadStatusResources(ctx context.Context) {
+	posted, err := c.statusStore.posted(ctx, c.namespaceTracked)
+	if err != nil {
+		slog.Warn("Failed to load trackeddeployments",
+			"error", err,
+		)
+	}
+	for key, t := range posted {
+		c.observedDeployments.store(key, t)
+	}
+	slog.Info("Loaded observation cache from trackeddeployments",
+		"count", len(posted),
+	)
+}
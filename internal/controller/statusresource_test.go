@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newTestStatusStore() *statusStore {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{trackedDeploymentGVR: "TrackedDeploymentList"})
+	return newStatusStore(client)
+}
+
+func TestStatusStore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStatusStore()
+	wl := workload{Kind: kindDeployment, Name: "web"}
+	res := s.client.Resource(trackedDeploymentGVR).Namespace("default")
+
+	containerStatus := func(name, digest, status, result string) trackedContainerStatus {
+		return trackedContainerStatus{
+			Name:           name,
+			DeploymentName: "default/web/" + name,
+			Digest:         digest,
+			Status:         status,
+			Result:         result,
+			LastPostTime:   metav1.Now(),
+		}
+	}
+
+	// Decommissioning a workload without TrackedDeployment is a no-op
+	if err := s.update(ctx, "default", wl, containerStatus("app", "sha256:a", deploymentrecord.StatusDecommissioned, statusResultPosted)); err != nil {
+		t.Fatalf("update() unexpected error: %v", err)
+	}
+	if _, err := res.Get(ctx, "deployment-web", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Fatalf("Get() error = %v, want not found", err)
+	}
+
+	updates := []trackedContainerStatus{
+		containerStatus("app", "sha256:a", deploymentrecord.StatusDeployed, statusResultPosted),
+		containerStatus("sidecar", "sha256:b", deploymentrecord.StatusDeployed, statusResultFailed),
+		containerStatus("app", "sha256:c", deploymentrecord.StatusDeployed, statusResultPosted),
+	}
+	for _, cs := range updates {
+		if err := s.update(ctx, "default", wl, cs); err != nil {
+			t.Fatalf("update() unexpected error: %v", err)
+		}
+	}
+
+	obj, err := res.Get(ctx, "deployment-web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	status, err := trackedStatus(obj)
+	if err != nil {
+		t.Fatalf("trackedStatus() unexpected error: %v", err)
+	}
+	if len(status.Containers) != 2 || status.Containers[0].Digest != "sha256:c" || status.Containers[1].Result != statusResultFailed {
+		t.Errorf("containers = %+v, want app at sha256:c and failed sidecar", status.Containers)
+	}
+	if status.Result != statusResultPosted || status.LastPostTime == nil {
+		t.Errorf("summary = %s, %v, want the latest post", status.Result, status.LastPostTime)
+	}
+
+	posted, err := s.posted(ctx, func(string) bool { return true })
+	if err != nil {
+		t.Fatalf("posted() unexpected error: %v", err)
+	}
+	if _, ok := posted[getCacheKey("default/web/app", "sha256:c")]; !ok || len(posted) != 1 {
+		t.Errorf("posted() = %v, want only the app record", posted)
+	}
+	posted, err = s.posted(ctx, func(string) bool { return false })
+	if err != nil || len(posted) != 0 {
+		t.Errorf("posted() of untracked namespaces = %v, %v, want none", posted, err)
+	}
+
+	// Decommissioning all containers deletes the TrackedDeployment
+	for _, cs := range []trackedContainerStatus{
+		containerStatus("app", "sha256:c", deploymentrecord.StatusDecommissioned, statusResultPosted),
+		containerStatus("sidecar", "sha256:b", deploymentrecord.StatusDecommissioned, statusResultPosted),
+	} {
+		if err := s.update(ctx, "default", wl, cs); err != nil {
+			t.Fatalf("update() unexpected error: %v", err)
+		}
+	}
+	if _, err := res.Get(ctx, "deployment-web", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Get() after decommission error = %v, want not found", err)
+	}
+}
+
+func TestUpdateStatusResource(t *testing.T) {
+	// A client error, as returned by the client on 4xx responses
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	clientErr := client.PostOne(context.Background(),
+		newTestRecord("default/web/app", "sha256:a", deploymentrecord.StatusDeployed))
+
+	tests := []struct {
+		name   string
+		err    error
+		result string
+	}{
+		{
+			name:   "posted",
+			result: statusResultPosted,
+		},
+		{
+			name:   "rejected",
+			err:    clientErr,
+			result: statusResultRejected,
+		},
+		{
+			name:   "failed",
+			err:    errors.New("connection refused"),
+			result: statusResultFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := &Controller{statusStore: newTestStatusStore()}
+			record := newTestRecord("default/web/app", "sha256:a", deploymentrecord.StatusDeployed)
+			c.updateStatusResource(ctx, "default", workload{Kind: kindDeployment, Name: "web"}, "app", record, tt.err)
+
+			obj, err := c.statusStore.client.Resource(trackedDeploymentGVR).Namespace("default").
+				Get(ctx, "deployment-web", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Get() unexpected error: %v", err)
+			}
+			status, err := trackedStatus(obj)
+			if err != nil {
+				t.Fatalf("trackedStatus() unexpected error: %v", err)
+			}
+			if len(status.Containers) != 1 || status.Containers[0].Result != tt.result {
+				t.Fatalf("containers = %+v, want result %s", status.Containers, tt.result)
+			}
+			if (status.Containers[0].Error != "") != (tt.err != nil) {
+				t.Errorf("error = %q, want set only on failure", status.Containers[0].Error)
+			}
+		})
+	}
+}
+
+func TestCheckStatusResources(t *testing.T) {
+	ctx := context.Background()
+
+	c := &Controller{}
+	if err := c.CheckStatusResources(ctx); err != nil {
+		t.Errorf("CheckStatusResources() without status resources unexpected error: %v", err)
+	}
+
+	c.statusStore = newTestStatusStore()
+	if err := c.CheckStatusResources(ctx); err != nil {
+		t.Errorf("CheckStatusResources() unexpected error: %v", err)
+	}
+}
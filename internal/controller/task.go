@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/github/deployment-tracker/internal/orchestrator"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TaskRecords returns the deployed records of the containers of a task
+// of an orchestrator other than Kubernetes. The task stands in for a
+// pod: the namespace, workload, pod name and node placeholders of the
+// template of cfg are the namespace, workload, ID and node of the
+// task, and its labels are the tags or metadata of the task. Images
+// the orchestrator reports no digest of must have one in their
+// reference, or else it is resolved with resolve, if not nil;
+// containers whose digest can't be determined are logged and skipped.
+//
+// The ignore and track annotations apply as labels of the task, and
+// excluded containers are skipped, as in the controller.
+func TaskRecords(ctx context.Context, cfg *Config, task orchestrator.Task, resolve DigestResolver) []*deploymentrecord.DeploymentRecord {
+	if isTrue(task.Labels[ignoreAnnotation]) || (cfg.OptIn && !isTrue(task.Labels[trackAnnotation])) {
+		return nil
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      task.ID,
+			Namespace: task.Namespace,
+			Labels:    task.Labels,
+		},
+		Spec: corev1.PodSpec{NodeName: task.Node},
+	}
+	wl := workload{Kind: task.Kind, Name: task.Workload}
+
+	// Without informers, the controller only provides the record
+	// helpers working on the pod itself
+	c := &Controller{}
+	tmpl := c.template(cfg, pod.Namespace)
+	var records []*deploymentrecord.DeploymentRecord
+	for _, tc := range task.Containers {
+		container := corev1.Container{Name: tc.Name, Image: tc.Image}
+		if containerExcluded(cfg, container) {
+			continue
+		}
+		dn := getARDeploymentName(pod, container, wl, tmpl, cfg.Cluster)
+		digest := tc.Digest
+		var err error
+		if digest == "" {
+			digest, err = manifestDigest(ctx, tc.Image, resolve)
+		}
+		if err != nil || digest == "" {
+			slog.Warn("Skipping container without a digest",
+				"deployment_name", dn,
+				"image", tc.Image,
+				"error", err,
+			)
+			continue
+		}
+		records = append(records, c.newRecord(cfg, pod, container, dn, digest, deploymentrecord.StatusDeployed))
+	}
+	return records
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/github/deployment-tracker/internal/orchestrator"
+)
+
+func TestTaskRecords(t *testing.T) {
+	task := orchestrator.Task{
+		ID:        "arn:aws:ecs:us-east-1:123456789012:task/prod/0123",
+		Namespace: "prod",
+		Kind:      orchestrator.KindECSService,
+		Workload:  "web",
+		Node:      "i-1",
+		Labels:    map[string]string{"team": "payments"},
+		Containers: []orchestrator.Container{
+			{Name: "app", Image: "ghcr.io/org/web:v2", Digest: "sha256:abc"},
+			{Name: "log-router", Image: "amazon/aws-for-fluent-bit:stable"},
+			{Name: "worker", Image: "ghcr.io/org/worker@sha256:def"},
+		},
+	}
+	resolve := func(_ context.Context, _ string) (string, error) {
+		return "sha256:fff", nil
+	}
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		labels   map[string]string
+		resolve  DigestResolver
+		expected []string
+	}{
+		{
+			name: "reported and referenced digests",
+			cfg:  Config{Template: scanTestTemplate},
+			expected: []string{
+				"prod/web/app sha256:abc ghcr.io/org/web:v2",
+				"prod/web/worker sha256:def ghcr.io/org/worker:",
+			},
+		},
+		{
+			name:    "resolved, excluded containers",
+			cfg:     Config{Template: scanTestTemplate, ExcludeContainers: "app,worker"},
+			resolve: resolve,
+			expected: []string{
+				"prod/web/log-router sha256:fff amazon/aws-for-fluent-bit:stable",
+			},
+		},
+		{
+			name: "task placeholders",
+			cfg:  Config{Template: TmplWK + "/" + TmplNode + "/{{" + TmplLabelPrefix + "team}}/" + TmplCN, ExcludeContainers: "worker"},
+			expected: []string{
+				"ECSService/i-1/payments/app sha256:abc ghcr.io/org/web:v2",
+			},
+		},
+		{
+			name:   "ignored",
+			cfg:    Config{Template: scanTestTemplate},
+			labels: map[string]string{ignoreAnnotation: "true"},
+		},
+		{
+			name: "opt in",
+			cfg:  Config{Template: scanTestTemplate, OptIn: true},
+		},
+		{
+			name:   "opted in",
+			cfg:    Config{Template: scanTestTemplate, OptIn: true, ExcludeContainers: "worker"},
+			labels: map[string]string{trackAnnotation: "true"},
+			expected: []string{
+				"prod/web/app sha256:abc ghcr.io/org/web:v2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := task
+			if tt.labels != nil {
+				task.Labels = tt.labels
+			}
+			records := TaskRecords(context.Background(), &tt.cfg, task, tt.resolve)
+			var got []string
+			for _, r := range records {
+				got = append(got, r.DeploymentName+" "+r.Digest+" "+r.Name+":"+r.Version)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("records = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"log/slog"
+)
+
+// templateAnnotation overrides the deployment name template for the
+// pods of a namespace, when template annotations are enabled.
+const templateAnnotation = "deployment-tracker.github.com/template"
+
+// template returns the deployment name template for the pods of the
+// namespace ns. The most specific template wins: the template
+// annotation of the namespace, then the namespace template of the
+// configuration, then the default template. Invalid annotations are
+// logged and ignored.
+func (c *Controller) template(cfg *Config, ns string) string {
+	if cfg.TemplateAnnotations && c.nsLister != nil {
+		if t := c.annotatedTemplate(ns); t != "" {
+			return t
+		}
+	}
+	if t, ok := cfg.NamespaceTemplates[ns]; ok {
+		return t
+	}
+	return cfg.Template
+}
+
+// annotatedTemplate returns the valid template annotation of the
+// namespace ns, or an empty string.
+func (c *Controller) annotatedTemplate(ns string) string {
+	namespace, err := c.nsLister.Get(ns)
+	if err != nil {
+		return ""
+	}
+	t := namespace.Annotations[templateAnnotation]
+	if t == "" {
+		return ""
+	}
+	if err := ValidateTemplate(t); err != nil {
+		slog.Warn("Ignoring invalid template annotation",
+			"namespace", ns,
+			"error", err,
+		)
+		return ""
+	}
+	return t
+}
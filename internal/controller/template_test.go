@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestTemplate(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for name, tmpl := range map[string]string{
+		"annotated": "{{labels.team}}/{{deploymentName}}",
+		"invalid":   "{{team}}",
+		"plain":     "",
+	} {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if tmpl != "" {
+			ns.Annotations = map[string]string{templateAnnotation: tmpl}
+		}
+		if err := indexer.Add(ns); err != nil {
+			t.Fatalf("failed to add namespace: %v", err)
+		}
+	}
+	c := &Controller{nsLister: corelisters.NewNamespaceLister(indexer)}
+
+	cfg := &Config{
+		Template: "{{namespace}}/{{deploymentName}}/{{containerName}}",
+		NamespaceTemplates: map[string]string{
+			"annotated": "{{namespace}}/{{deploymentName}}",
+			"invalid":   "{{namespace}}/{{deploymentName}}",
+			"plain":     "{{namespace}}/{{deploymentName}}",
+		},
+	}
+
+	tests := []struct {
+		name        string
+		annotations bool
+		namespace   string
+		expected    string
+	}{
+		{
+			name:      "default template",
+			namespace: "other",
+			expected:  cfg.Template,
+		},
+		{
+			name:      "namespace template",
+			namespace: "plain",
+			expected:  "{{namespace}}/{{deploymentName}}",
+		},
+		{
+			name:      "annotation ignored when disabled",
+			namespace: "annotated",
+			expected:  "{{namespace}}/{{deploymentName}}",
+		},
+		{
+			name:        "annotation overrides namespace template",
+			annotations: true,
+			namespace:   "annotated",
+			expected:    "{{labels.team}}/{{deploymentName}}",
+		},
+		{
+			name:        "invalid annotation falls back",
+			annotations: true,
+			namespace:   "invalid",
+			expected:    "{{namespace}}/{{deploymentName}}",
+		},
+		{
+			name:        "unknown namespace falls back",
+			annotations: true,
+			namespace:   "other",
+			expected:    cfg.Template,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := *cfg
+			cfg.TemplateAnnotations = tt.annotations
+			if got := c.template(&cfg, tt.namespace); got != tt.expected {
+				t.Errorf("template(%q) = %q, expected %q", tt.namespace, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"cmp"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodeTopology returns the topology of the pod's node, or nil if the
+// pod isn't scheduled. If the node is not cached, only its name is
+// set. The deprecated failure-domain and instance-type labels are read
+// for nodes without the well-known topology labels.
+func (c *Controller) nodeTopology(pod *corev1.Pod) *deploymentrecord.Topology {
+	if pod.Spec.NodeName == "" {
+		return nil
+	}
+	topology := &deploymentrecord.Topology{Node: pod.Spec.NodeName}
+	if c.nodeLister == nil {
+		return topology
+	}
+	node, err := c.nodeLister.Get(pod.Spec.NodeName)
+	if err != nil {
+		return topology
+	}
+
+	labels := node.Labels
+	topology.Zone = cmp.Or(labels[corev1.LabelTopologyZone], labels[corev1.LabelFailureDomainBetaZone])
+	topology.Region = cmp.Or(labels[corev1.LabelTopologyRegion], labels[corev1.LabelFailureDomainBetaRegion])
+	topology.InstanceType = cmp.Or(labels[corev1.LabelInstanceTypeStable], labels[corev1.LabelInstanceType])
+	return topology
+}
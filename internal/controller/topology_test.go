@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNodeTopology(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range []*corev1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+				Labels: map[string]string{
+					corev1.LabelTopologyZone:       "eu-west-1a",
+					corev1.LabelTopologyRegion:     "eu-west-1",
+					corev1.LabelInstanceTypeStable: "m5.large",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-2",
+				Labels: map[string]string{
+					corev1.LabelFailureDomainBetaZone:   "us-east-1b",
+					corev1.LabelFailureDomainBetaRegion: "us-east-1",
+					corev1.LabelInstanceType:            "c5.xlarge",
+				},
+			},
+		},
+	} {
+		if err := indexer.Add(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &Controller{nodeLister: corelisters.NewNodeLister(indexer)}
+
+	tests := []struct {
+		name     string
+		nodeName string
+		expected *deploymentrecord.Topology
+	}{
+		{
+			name:     "topology labels",
+			nodeName: "node-1",
+			expected: &deploymentrecord.Topology{
+				Node:         "node-1",
+				Zone:         "eu-west-1a",
+				Region:       "eu-west-1",
+				InstanceType: "m5.large",
+			},
+		},
+		{
+			name:     "deprecated labels",
+			nodeName: "node-2",
+			expected: &deploymentrecord.Topology{
+				Node:         "node-2",
+				Zone:         "us-east-1b",
+				Region:       "us-east-1",
+				InstanceType: "c5.xlarge",
+			},
+		},
+		{
+			name:     "uncached node",
+			nodeName: "node-3",
+			expected: &deploymentrecord.Topology{Node: "node-3"},
+		},
+		{
+			name:     "unscheduled",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newTestPod("web-5d8f7c9b4")
+			pod.Spec.NodeName = tt.nodeName
+
+			got := c.nodeTopology(pod)
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("nodeTopology() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
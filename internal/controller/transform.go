@@ -0,0 +1,87 @@
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lastAppliedAnnotation holds the last applied configuration of
+// kubectl apply, a full copy of the object.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// stripObject is the transform of all informers. It drops the fields
+// the controller never reads before objects are stored in the informer
+// caches, which hold every pod, Deployment, ReplicaSet and Job of the
+// watched namespaces: managed fields, the last applied configuration,
+// volumes, and the environment, command, mounts and probes of
+// containers. Names, images, resources, labels, annotations, owner
+// references and statuses are kept.
+func stripObject(obj any) (any, error) {
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		stripObjectMeta(&o.ObjectMeta)
+		stripPodSpec(&o.Spec)
+	case *appsv1.Deployment:
+		stripObjectMeta(&o.ObjectMeta)
+		stripObjectMeta(&o.Spec.Template.ObjectMeta)
+		stripPodSpec(&o.Spec.Template.Spec)
+	case *appsv1.ReplicaSet:
+		stripObjectMeta(&o.ObjectMeta)
+		stripObjectMeta(&o.Spec.Template.ObjectMeta)
+		stripPodSpec(&o.Spec.Template.Spec)
+	case *batchv1.Job:
+		stripObjectMeta(&o.ObjectMeta)
+		stripObjectMeta(&o.Spec.Template.ObjectMeta)
+		stripPodSpec(&o.Spec.Template.Spec)
+	case *corev1.Namespace:
+		stripObjectMeta(&o.ObjectMeta)
+	case *corev1.Node:
+		// Only the labels of nodes are read, their status lists
+		// every image on the node
+		stripObjectMeta(&o.ObjectMeta)
+		o.Spec = corev1.NodeSpec{}
+		o.Status = corev1.NodeStatus{}
+	}
+	return obj, nil
+}
+
+// stripObjectMeta drops the managed fields and the last applied
+// configuration.
+func stripObjectMeta(m *metav1.ObjectMeta) {
+	m.ManagedFields = nil
+	delete(m.Annotations, lastAppliedAnnotation)
+}
+
+// stripPodSpec drops the volumes and the container fields unused by
+// the controller.
+func stripPodSpec(spec *corev1.PodSpec) {
+	spec.Volumes = nil
+	for i := range spec.Containers {
+		stripContainer(&spec.Containers[i])
+	}
+	for i := range spec.InitContainers {
+		stripContainer(&spec.InitContainers[i])
+	}
+	for i := range spec.EphemeralContainers {
+		c := corev1.Container(spec.EphemeralContainers[i].EphemeralContainerCommon)
+		stripContainer(&c)
+		spec.EphemeralContainers[i].EphemeralContainerCommon = corev1.EphemeralContainerCommon(c)
+	}
+}
+
+// stripContainer drops the environment, command, mounts, probes and
+// lifecycle hooks of the container.
+func stripContainer(c *corev1.Container) {
+	c.Env = nil
+	c.EnvFrom = nil
+	c.Command = nil
+	c.Args = nil
+	c.VolumeMounts = nil
+	c.VolumeDevices = nil
+	c.LivenessProbe = nil
+	c.ReadinessProbe = nil
+	c.StartupProbe = nil
+	c.Lifecycle = nil
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStripObject(t *testing.T) {
+	container := func() corev1.Container {
+		return corev1.Container{
+			Name:    "app",
+			Image:   "ghcr.io/org/app:v1",
+			Command: []string{"/app"},
+			Env:     []corev1.EnvVar{{Name: "TOKEN", Value: "secret"}},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "config", MountPath: "/etc/app"},
+			},
+			LivenessProbe: &corev1.Probe{},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			},
+		}
+	}
+	meta := func() metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      "web-111-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "web"},
+			Annotations: map[string]string{
+				lastAppliedAnnotation: "{}",
+				trackAnnotation:       "true",
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		}
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: meta(),
+		Spec: corev1.PodSpec{
+			Containers:     []corev1.Container{container()},
+			InitContainers: []corev1.Container{container()},
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon(container())},
+			},
+			Volumes: []corev1.Volume{{Name: "config"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", ImageID: "ghcr.io/org/app@sha256:abc"},
+			},
+		},
+	}
+	rs := &appsv1.ReplicaSet{ObjectMeta: meta()}
+	rs.Spec.Template.Spec.Containers = []corev1.Container{container()}
+	deployment := &appsv1.Deployment{ObjectMeta: meta()}
+	deployment.Spec.Template.Spec.Containers = []corev1.Container{container()}
+
+	if _, err := stripObject(pod); err != nil {
+		t.Fatalf("stripObject() unexpected error: %v", err)
+	}
+	if _, err := stripObject(rs); err != nil {
+		t.Fatalf("stripObject() unexpected error: %v", err)
+	}
+	if _, err := stripObject(deployment); err != nil {
+		t.Fatalf("stripObject() unexpected error: %v", err)
+	}
+
+	metas := map[string]metav1.ObjectMeta{
+		"pod":        pod.ObjectMeta,
+		"replicaset": rs.ObjectMeta,
+		"deployment": deployment.ObjectMeta,
+	}
+	for name, m := range metas {
+		if m.ManagedFields != nil {
+			t.Errorf("%s managed fields = %v, expected nil", name, m.ManagedFields)
+		}
+		if _, ok := m.Annotations[lastAppliedAnnotation]; ok {
+			t.Errorf("%s has the last applied configuration", name)
+		}
+		if m.Annotations[trackAnnotation] != "true" || m.Labels["app"] != "web" {
+			t.Errorf("%s labels and annotations = %v, %v, expected them kept", name, m.Labels, m.Annotations)
+		}
+	}
+	if pod.Spec.Volumes != nil {
+		t.Errorf("pod volumes = %v, expected nil", pod.Spec.Volumes)
+	}
+
+	containers := []corev1.Container{
+		pod.Spec.Containers[0],
+		pod.Spec.InitContainers[0],
+		corev1.Container(pod.Spec.EphemeralContainers[0].EphemeralContainerCommon),
+		rs.Spec.Template.Spec.Containers[0],
+		deployment.Spec.Template.Spec.Containers[0],
+	}
+	for _, c := range containers {
+		if c.Env != nil || c.Command != nil || c.VolumeMounts != nil || c.LivenessProbe != nil {
+			t.Errorf("container = %+v, expected env, command, mounts and probes stripped", c)
+		}
+		if c.Name != "app" || c.Image != "ghcr.io/org/app:v1" || c.Resources.Requests.Cpu().String() != "100m" {
+			t.Errorf("container = %+v, expected name, image and resources kept", c)
+		}
+	}
+	if getContainerDigest(pod, "app") != "sha256:abc" {
+		t.Errorf("pod status = %+v, expected it kept", pod.Status)
+	}
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/vault"
+)
+
+// vaultReadTimeout bounds reading the GitHub App private key from
+// Vault when the controller is created.
+const vaultReadTimeout = 30 * time.Second
+
+// vaultClientOptions returns the API client options reading the
+// credentials configured in cfg from Vault. The API token is read
+// when needed and renewed before its lease expires. The GitHub App
+// private key is read once.
+func vaultClientOptions(cfg *Config) ([]deploymentrecord.ClientOption, error) {
+	if cfg.VaultAddr == "" {
+		return nil, nil
+	}
+
+	vc, err := vault.NewClient(vault.Config{
+		Address:   cfg.VaultAddr,
+		Namespace: cfg.VaultNamespace,
+		Token:     cfg.VaultToken,
+		Role:      cfg.VaultRole,
+		AuthMount: cfg.VaultAuthMount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault client: %w", err)
+	}
+
+	var opts []deploymentrecord.ClientOption
+	if cfg.VaultAPIToken != "" {
+		path, field := vault.ParsePath(cfg.VaultAPIToken, "token")
+		opts = append(opts, deploymentrecord.WithTokenFunc(vc.TokenFunc(path, field)))
+	}
+	if cfg.VaultGHAppKey != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), vaultReadTimeout)
+		defer cancel()
+		path, field := vault.ParsePath(cfg.VaultGHAppKey, "private_key")
+		secret, err := vc.Read(ctx, path, field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key from Vault: %w", err)
+		}
+		opts = append(opts, deploymentrecord.WithGHAppKey(cfg.GHAppID, cfg.GHInstallID, []byte(secret.Value)))
+	}
+	return opts, nil
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+)
+
+// verifyTimeout bounds the registry requests resolving an image, so
+// an unreachable registry doesn't hold up posting the record.
+const verifyTimeout = 10 * time.Second
+
+// verifyDigest resolves the image reference of the record against its
+// registry and sets the verification result of the record. A digest
+// differing from the one the registry serves may point to a poisoned
+// node cache or a stale mirror, or to a tag that was pushed again.
+// Registry failures leave the record unverified, it is posted anyway.
+func (c *Controller) verifyDigest(ctx context.Context, namespace, ref string, record *deploymentrecord.DeploymentRecord) {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	digest, err := c.registry.Digest(ctx, ref)
+	switch {
+	case err != nil:
+		slog.Warn("Failed to resolve image digest against the registry",
+			"image", ref,
+			"deployment_name", record.DeploymentName,
+			"error", err,
+		)
+		record.DigestVerification = deploymentrecord.DigestUnverified
+	case digest != record.Digest:
+		slog.Warn("Image digest differs from the registry",
+			"image", ref,
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+			"registry_digest", digest,
+		)
+		record.DigestVerification = deploymentrecord.DigestMismatch
+		record.RegistryDigest = digest
+	default:
+		record.DigestVerification = deploymentrecord.DigestVerified
+	}
+	metrics.DigestVerifications.WithLabelValues(namespace, record.DigestVerification).Inc()
+}
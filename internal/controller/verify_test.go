@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/registry"
+)
+
+func TestVerifyDigest(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/org/app/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:a")
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	tests := []struct {
+		name           string
+		image          string
+		digest         string
+		want           string
+		registryDigest string
+	}{
+		{
+			name:   "verified",
+			image:  host + "/org/app:v1",
+			digest: "sha256:a",
+			want:   deploymentrecord.DigestVerified,
+		},
+		{
+			name:           "mismatch",
+			image:          host + "/org/app:v1",
+			digest:         "sha256:b",
+			want:           deploymentrecord.DigestMismatch,
+			registryDigest: "sha256:a",
+		},
+		{
+			name:   "unknown tag",
+			image:  host + "/org/app:v2",
+			digest: "sha256:a",
+			want:   deploymentrecord.DigestUnverified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{registry: registry.NewClient(registry.WithHTTPClient(srv.Client()))}
+			record := newTestRecord("ns/web/app", tt.digest, deploymentrecord.StatusDeployed)
+
+			c.verifyDigest(context.Background(), "ns", tt.image, record)
+
+			if record.DigestVerification != tt.want {
+				t.Errorf("DigestVerification = %s, want %s", record.DigestVerification, tt.want)
+			}
+			if record.RegistryDigest != tt.registryDigest {
+				t.Errorf("RegistryDigest = %s, want %s", record.RegistryDigest, tt.registryDigest)
+			}
+		})
+	}
+}
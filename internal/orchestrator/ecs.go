@@ -0,0 +1,190 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	// ecsDescribeBatch is the maximum number of tasks DescribeTasks
+	// accepts.
+	ecsDescribeBatch = 100
+
+	// KindECSService is the kind of the tasks of ECS services.
+	KindECSService = "ECSService"
+	// KindECSTask is the kind of standalone ECS tasks, e.g. of
+	// scheduled tasks, by task definition family.
+	KindECSTask = "ECSTask"
+)
+
+// ECS reports the running tasks of AWS ECS clusters, polling the ECS
+// API. Requests are signed with the credentials of the default chain
+// of the AWS SDK, e.g. the task role or a web identity token.
+type ECS struct {
+	clusters []string
+	interval time.Duration
+	client   *ecs.Client
+}
+
+// NewECS creates the adapter of the ECS clusters, names or ARNs, in
+// the region, polled every interval.
+func NewECS(region string, clusters []string, interval time.Duration) (*ECS, error) {
+	if region == "" {
+		return nil, errors.New("an AWS region is required")
+	}
+	if len(clusters) == 0 {
+		return nil, errors.New("at least one ECS cluster is required")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %s, must be positive", interval)
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithRegion(region),
+		config.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(30*time.Second)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return &ECS{
+		clusters: clusters,
+		interval: interval,
+		client:   ecs.NewFromConfig(cfg),
+	}, nil
+}
+
+// Name implements Adapter.
+func (e *ECS) Name() string {
+	return "ecs"
+}
+
+// Run implements Adapter. The tasks of all clusters are synced every
+// poll; a poll that fails is logged and skipped, so tasks are not
+// decommissioned while the API is unavailable.
+func (e *ECS) Run(ctx context.Context, h Handler) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if tasks, err := e.poll(ctx); err != nil {
+			slog.Error("Failed to list ECS tasks",
+				"error", err)
+		} else {
+			h.Sync(ctx, tasks)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll returns the running tasks of the clusters.
+func (e *ECS) poll(ctx context.Context) ([]Task, error) {
+	var res []Task
+	for _, cluster := range e.clusters {
+		arns, err := e.listTasks(ctx, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster, err)
+		}
+		for start := 0; start < len(arns); start += ecsDescribeBatch {
+			tasks, err := e.describeTasks(ctx, cluster, arns[start:min(start+ecsDescribeBatch, len(arns))])
+			if err != nil {
+				return nil, fmt.Errorf("cluster %s: %w", cluster, err)
+			}
+			res = append(res, tasks...)
+		}
+	}
+	return res, nil
+}
+
+// listTasks returns the ARNs of the tasks of the cluster desired to
+// run.
+func (e *ECS) listTasks(ctx context.Context, cluster string) ([]string, error) {
+	var arns []string
+	pages := ecs.NewListTasksPaginator(e.client, &ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ListTasks request failed: %w", err)
+		}
+		arns = append(arns, page.TaskArns...)
+	}
+	return arns, nil
+}
+
+// describeTasks returns the tasks of the ARNs that are running.
+// Pending tasks are reported once running, when the digests of their
+// images are known.
+func (e *ECS) describeTasks(ctx context.Context, cluster string, arns []string) ([]Task, error) {
+	output, err := e.client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+		Cluster: aws.String(cluster),
+		Tasks:   arns,
+		Include: []ecstypes.TaskField{ecstypes.TaskFieldTags},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTasks request failed: %w", err)
+	}
+
+	var res []Task
+	for _, t := range output.Tasks {
+		if aws.ToString(t.LastStatus) != "RUNNING" {
+			continue
+		}
+		res = append(res, ecsTaskToTask(t))
+	}
+	return res, nil
+}
+
+// ecsTaskToTask converts an ECS task. Tasks are namespaced by cluster
+// name and belong to their service, or else to the family of their
+// task definition.
+func ecsTaskToTask(t ecstypes.Task) Task {
+	group := aws.ToString(t.Group)
+	task := Task{
+		ID:        aws.ToString(t.TaskArn),
+		Namespace: arnResource(aws.ToString(t.ClusterArn)),
+		Kind:      KindECSTask,
+		Workload:  group,
+		Node:      arnResource(aws.ToString(t.ContainerInstanceArn)),
+	}
+	if kind, name, ok := strings.Cut(group, ":"); ok {
+		task.Workload = name
+		if kind == "service" {
+			task.Kind = KindECSService
+		}
+	}
+	if len(t.Tags) > 0 {
+		task.Labels = make(map[string]string, len(t.Tags))
+		for _, tag := range t.Tags {
+			task.Labels[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	for _, c := range t.Containers {
+		task.Containers = append(task.Containers, Container{
+			Name:   aws.ToString(c.Name),
+			Image:  aws.ToString(c.Image),
+			Digest: aws.ToString(c.ImageDigest),
+		})
+	}
+	return task
+}
+
+// arnResource returns the last segment of the resource of an ARN,
+// e.g. the name of a cluster.
+func arnResource(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
@@ -0,0 +1,162 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+func TestNewECS(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   string
+		clusters []string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			region:   "eu-west-1",
+			clusters: []string{"prod"},
+			interval: time.Minute,
+		},
+		{
+			name:     "no region",
+			clusters: []string{"prod"},
+			interval: time.Minute,
+			wantErr:  true,
+		},
+		{
+			name:     "no cluster",
+			region:   "eu-west-1",
+			interval: time.Minute,
+			wantErr:  true,
+		},
+		{
+			name:     "no interval",
+			region:   "eu-west-1",
+			clusters: []string{"prod"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewECS(tt.region, tt.clusters, tt.interval)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewECS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && e.client.Options().Region != tt.region {
+				t.Errorf("region = %s, expected %s", e.client.Options().Region, tt.region)
+			}
+		})
+	}
+}
+
+// fakeHandler records the tasks reported by an adapter.
+type fakeHandler struct {
+	syncs   chan []Task
+	running chan Task
+	stopped chan Task
+}
+
+func newFakeHandler() *fakeHandler {
+	return &fakeHandler{
+		syncs:   make(chan []Task, 10),
+		running: make(chan Task, 10),
+		stopped: make(chan Task, 10),
+	}
+}
+
+func (h *fakeHandler) Sync(_ context.Context, tasks []Task) { h.syncs <- tasks }
+func (h *fakeHandler) Running(_ context.Context, task Task) { h.running <- task }
+func (h *fakeHandler) Stopped(_ context.Context, task Task) { h.stopped <- task }
+
+func TestECSPoll(t *testing.T) {
+	const taskArn = "arn:aws:ecs:us-east-1:123456789012:task/prod/0123"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/ecs/aws4_request") {
+			t.Errorf("request not signed for ECS: %s", r.Header.Get("Authorization"))
+		}
+		var input map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonEC2ContainerServiceV20141113.ListTasks":
+			// Two pages
+			if input["nextToken"] == nil {
+				_, _ = w.Write([]byte(`{"taskArns":["` + taskArn + `"],"nextToken":"next"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"taskArns":["arn:aws:ecs:us-east-1:123456789012:task/prod/4567"]}`))
+		case "AmazonEC2ContainerServiceV20141113.DescribeTasks":
+			if tasks, _ := input["tasks"].([]any); len(tasks) != 2 {
+				t.Errorf("DescribeTasks tasks = %v, expected 2", input["tasks"])
+			}
+			_, _ = w.Write([]byte(`{"tasks":[
+				{"taskArn":"` + taskArn + `","clusterArn":"arn:aws:ecs:us-east-1:123456789012:cluster/prod",
+				 "group":"service:web","lastStatus":"RUNNING",
+				 "containerInstanceArn":"arn:aws:ecs:us-east-1:123456789012:container-instance/prod/i-1",
+				 "containers":[{"name":"app","image":"ghcr.io/org/web:v2","imageDigest":"sha256:abc"}],
+				 "tags":[{"key":"team","value":"payments"}]},
+				{"taskArn":"arn:aws:ecs:us-east-1:123456789012:task/prod/4567","group":"family:migrate","lastStatus":"PENDING"}
+			]}`))
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	e, err := NewECS("us-east-1", []string{"prod"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.client = ecs.NewFromConfig(aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+		HTTPClient:   srv.Client(),
+		BaseEndpoint: aws.String(srv.URL),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newFakeHandler()
+	go func() { _ = e.Run(ctx, h) }()
+
+	var tasks []Task
+	select {
+	case tasks = <-h.syncs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no sync")
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("synced %d tasks, expected the running one", len(tasks))
+	}
+	task := tasks[0]
+	if task.ID != taskArn || task.Namespace != "prod" || task.Kind != KindECSService ||
+		task.Workload != "web" || task.Node != "i-1" || task.Labels["team"] != "payments" {
+		t.Errorf("task = %+v", task)
+	}
+	if len(task.Containers) != 1 || task.Containers[0].Digest != "sha256:abc" {
+		t.Errorf("containers = %+v", task.Containers)
+	}
+}
+
+func TestECSTaskToTask(t *testing.T) {
+	task := ecsTaskToTask(ecstypes.Task{
+		TaskArn:    aws.String("arn:aws:ecs:us-east-1:123456789012:task/batch/89ab"),
+		ClusterArn: aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/batch"),
+		Group:      aws.String("family:migrate"),
+	})
+	if task.Kind != KindECSTask || task.Workload != "migrate" || task.Namespace != "batch" || task.Node != "" {
+		t.Errorf("task = %+v", task)
+	}
+}
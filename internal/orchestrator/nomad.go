@@ -0,0 +1,306 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// nomadRequestTimeout is the timeout of the Nomad API requests,
+	// other than the event stream.
+	nomadRequestTimeout = 30 * time.Second
+	// nomadRetryInterval is how long to wait before listing the
+	// allocations again after the event stream failed.
+	nomadRetryInterval = 10 * time.Second
+
+	// KindNomadJob is the kind of the allocations of Nomad jobs.
+	KindNomadJob = "NomadJob"
+)
+
+// Nomad reports the running allocations of a Nomad cluster: the
+// allocations are listed, and then followed with the Allocation topic
+// of the event stream. Only the tasks of drivers with an image, e.g.
+// docker and podman, are recorded.
+type Nomad struct {
+	addr      string
+	token     string
+	namespace string
+
+	retryInterval time.Duration
+	httpClient    *http.Client
+}
+
+// NewNomad creates the adapter of the Nomad cluster at addr, e.g.
+// http://127.0.0.1:4646, authenticating with the ACL token, if set.
+// namespace selects the namespace of the allocations, * for all.
+func NewNomad(addr, token, namespace string) (*Nomad, error) {
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Nomad address %q, expected an http or https URL", addr)
+	}
+	if namespace == "" {
+		namespace = "*"
+	}
+	return &Nomad{
+		addr:          strings.TrimSuffix(addr, "/"),
+		token:         token,
+		namespace:     namespace,
+		retryInterval: nomadRetryInterval,
+		// The event stream is long-lived, requests have their own
+		// timeouts
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// Name implements Adapter.
+func (n *Nomad) Name() string {
+	return "nomad"
+}
+
+// Run implements Adapter. When the event stream fails, the allocations
+// are listed again, so no change is missed.
+func (n *Nomad) Run(ctx context.Context, h Handler) error {
+	for {
+		index, running, err := n.sync(ctx, h)
+		if err == nil {
+			err = n.stream(ctx, h, index, running)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		slog.Error("Failed to follow Nomad allocations, retrying",
+			"error", err,
+			"retry_interval", n.retryInterval)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(n.retryInterval):
+		}
+	}
+}
+
+// nomadAllocation is an allocation of the Nomad API.
+type nomadAllocation struct {
+	ID            string    `json:"ID"`
+	Namespace     string    `json:"Namespace"`
+	JobID         string    `json:"JobID"`
+	TaskGroup     string    `json:"TaskGroup"`
+	NodeName      string    `json:"NodeName"`
+	ClientStatus  string    `json:"ClientStatus"`
+	DesiredStatus string    `json:"DesiredStatus"`
+	Job           *nomadJob `json:"Job"`
+}
+
+// nomadJob is the job of an allocation.
+type nomadJob struct {
+	Meta       map[string]string `json:"Meta"`
+	TaskGroups []struct {
+		Name  string            `json:"Name"`
+		Meta  map[string]string `json:"Meta"`
+		Tasks []struct {
+			Name   string         `json:"Name"`
+			Config map[string]any `json:"Config"`
+		} `json:"Tasks"`
+	} `json:"TaskGroups"`
+}
+
+// running returns whether the allocation runs.
+func (a *nomadAllocation) running() bool {
+	return a.ClientStatus == "running" && a.DesiredStatus == "run"
+}
+
+// stopped returns whether the allocation is terminal, or is being
+// stopped.
+func (a *nomadAllocation) stopped() bool {
+	switch {
+	case a.ClientStatus == "complete", a.ClientStatus == "failed", a.ClientStatus == "lost":
+		return true
+	case a.DesiredStatus == "stop", a.DesiredStatus == "evict":
+		return true
+	}
+	return false
+}
+
+// task converts the allocation, whose job must be set.
+func (a *nomadAllocation) task() Task {
+	task := Task{
+		ID:        a.ID,
+		Namespace: a.Namespace,
+		Kind:      KindNomadJob,
+		Workload:  a.JobID,
+		Node:      a.NodeName,
+	}
+	if a.Job == nil {
+		return task
+	}
+	for _, group := range a.Job.TaskGroups {
+		if group.Name != a.TaskGroup {
+			continue
+		}
+		// The metadata of the group takes precedence, as in Nomad
+		if len(a.Job.Meta) > 0 || len(group.Meta) > 0 {
+			task.Labels = maps.Clone(a.Job.Meta)
+			if task.Labels == nil {
+				task.Labels = make(map[string]string, len(group.Meta))
+			}
+			maps.Copy(task.Labels, group.Meta)
+		}
+		for _, t := range group.Tasks {
+			if img, ok := t.Config["image"].(string); ok && img != "" {
+				task.Containers = append(task.Containers, Container{
+					Name:  t.Name,
+					Image: img,
+				})
+			}
+		}
+	}
+	return task
+}
+
+// sync lists the running allocations and syncs their tasks to h. It
+// returns the index to follow the event stream from and the IDs of
+// the running allocations.
+func (n *Nomad) sync(ctx context.Context, h Handler) (uint64, map[string]bool, error) {
+	var stubs []nomadAllocation
+	index, err := n.get(ctx, "/v1/allocations", &stubs)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var tasks []Task
+	running := make(map[string]bool)
+	for _, stub := range stubs {
+		if !stub.running() {
+			continue
+		}
+		alloc, err := n.allocation(ctx, stub.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		tasks = append(tasks, alloc.task())
+		running[alloc.ID] = true
+	}
+	h.Sync(ctx, tasks)
+	return index, running, nil
+}
+
+// allocation returns the allocation with its job.
+func (n *Nomad) allocation(ctx context.Context, id string) (*nomadAllocation, error) {
+	var alloc nomadAllocation
+	if _, err := n.get(ctx, "/v1/allocation/"+url.PathEscape(id), &alloc); err != nil {
+		return nil, err
+	}
+	return &alloc, nil
+}
+
+// stream follows the allocation events after index until the stream
+// fails or ctx is done. running are the IDs of the running
+// allocations, whose jobs are only fetched once.
+func (n *Nomad) stream(ctx context.Context, h Handler, index uint64, running map[string]bool) error {
+	query := url.Values{
+		"topic":     {"Allocation"},
+		"namespace": {n.namespace},
+		"index":     {strconv.FormatUint(index+1, 10)},
+	}
+	req, err := n.newRequest(ctx, "/v1/event/stream", query)
+	if err != nil {
+		return err
+	}
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("event stream request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream: unexpected status code: %d", resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		// Heartbeats are empty objects
+		var frame struct {
+			Events []struct {
+				Topic   string `json:"Topic"`
+				Payload struct {
+					Allocation *nomadAllocation `json:"Allocation"`
+				} `json:"Payload"`
+			} `json:"Events"`
+		}
+		if err := decoder.Decode(&frame); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("event stream closed")
+			}
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+		for _, event := range frame.Events {
+			alloc := event.Payload.Allocation
+			if event.Topic != "Allocation" || alloc == nil {
+				continue
+			}
+			switch {
+			case alloc.stopped() && running[alloc.ID]:
+				delete(running, alloc.ID)
+				h.Stopped(ctx, alloc.task())
+			case alloc.running() && !running[alloc.ID]:
+				// Events don't include the job
+				full, err := n.allocation(ctx, alloc.ID)
+				if err != nil {
+					return err
+				}
+				running[alloc.ID] = true
+				h.Running(ctx, full.task())
+			}
+		}
+	}
+}
+
+// get gets the path of the Nomad API in the namespace of the adapter,
+// decoding the response into v. It returns the index of the response.
+func (n *Nomad) get(ctx context.Context, path string, v any) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, nomadRequestTimeout)
+	defer cancel()
+	req, err := n.newRequest(ctx, path, url.Values{"namespace": {n.namespace}})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s: unexpected status code: %d", path, resp.StatusCode)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return 0, fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Nomad-Index"), 10, 64)
+	return index, nil
+}
+
+// newRequest creates a GET request of the path of the Nomad API.
+func (n *Nomad) newRequest(ctx context.Context, path string, query url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.addr+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if n.token != "" {
+		req.Header.Set("X-Nomad-Token", n.token)
+	}
+	return req, nil
+}
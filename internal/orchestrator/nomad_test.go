@@ -0,0 +1,126 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewNomad(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		wantErr bool
+	}{
+		{name: "http", addr: "http://127.0.0.1:4646"},
+		{name: "https", addr: "https://nomad.example.com/"},
+		{name: "no scheme", addr: "127.0.0.1:4646", wantErr: true},
+		{name: "empty", addr: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewNomad(tt.addr, "", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewNomad() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && n.namespace != "*" {
+				t.Errorf("namespace = %s, expected *", n.namespace)
+			}
+		})
+	}
+}
+
+// nomadTestAllocation returns an allocation of the web job, with its
+// job if full.
+func nomadTestAllocation(id, clientStatus string, full bool) string {
+	job := ""
+	if full {
+		job = `,"Job":{"Meta":{"team":"payments","tier":"job"},"TaskGroups":[
+			{"Name":"web","Meta":{"tier":"group"},"Tasks":[
+				{"Name":"app","Driver":"docker","Config":{"image":"ghcr.io/org/web@sha256:abc"}},
+				{"Name":"setup","Driver":"exec","Config":{"command":"/bin/setup"}}]},
+			{"Name":"other","Tasks":[{"Name":"worker","Config":{"image":"ghcr.io/org/worker:v1"}}]}]}`
+	}
+	return fmt.Sprintf(`{"ID":%q,"Namespace":"prod","JobID":"web","TaskGroup":"web","NodeName":"node-1","ClientStatus":%q,"DesiredStatus":"run"%s}`,
+		id, clientStatus, job)
+}
+
+func TestNomadRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Nomad-Token") != "token" {
+			t.Errorf("X-Nomad-Token = %q, expected token", r.Header.Get("X-Nomad-Token"))
+		}
+		if r.URL.Query().Get("namespace") != "*" {
+			t.Errorf("namespace = %q, expected *", r.URL.Query().Get("namespace"))
+		}
+		switch r.URL.Path {
+		case "/v1/allocations":
+			w.Header().Set("X-Nomad-Index", "41")
+			fmt.Fprintf(w, "[%s,%s]", nomadTestAllocation("a1", "running", false), nomadTestAllocation("a0", "complete", false))
+		case "/v1/allocation/a1", "/v1/allocation/a2":
+			fmt.Fprint(w, nomadTestAllocation(r.URL.Path[len("/v1/allocation/"):], "running", true))
+		case "/v1/event/stream":
+			if r.URL.Query().Get("index") != "42" || r.URL.Query().Get("topic") != "Allocation" {
+				t.Errorf("event stream query = %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, "{}\n")
+			fmt.Fprintf(w, `{"Index":43,"Events":[{"Topic":"Allocation","Payload":{"Allocation":%s}}]}`+"\n", nomadTestAllocation("a2", "running", false))
+			fmt.Fprintf(w, `{"Index":44,"Events":[{"Topic":"Allocation","Payload":{"Allocation":%s}}]}`+"\n", nomadTestAllocation("a1", "complete", false))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := NewNomad(srv.URL, "token", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newFakeHandler()
+	go func() { _ = n.Run(ctx, h) }()
+
+	select {
+	case tasks := <-h.syncs:
+		if len(tasks) != 1 {
+			t.Fatalf("synced %d tasks, expected the running one", len(tasks))
+		}
+		task := tasks[0]
+		if task.ID != "a1" || task.Namespace != "prod" || task.Kind != KindNomadJob ||
+			task.Workload != "web" || task.Node != "node-1" {
+			t.Errorf("task = %+v", task)
+		}
+		if task.Labels["team"] != "payments" || task.Labels["tier"] != "group" {
+			t.Errorf("labels = %v, expected the job metadata overridden by the group", task.Labels)
+		}
+		if len(task.Containers) != 1 || task.Containers[0].Name != "app" || task.Containers[0].Image != "ghcr.io/org/web@sha256:abc" {
+			t.Errorf("containers = %+v, expected the task with an image", task.Containers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no sync")
+	}
+
+	select {
+	case task := <-h.running:
+		if task.ID != "a2" || len(task.Containers) != 1 {
+			t.Errorf("running task = %+v, expected a2 with its job", task)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no running task")
+	}
+	select {
+	case task := <-h.stopped:
+		if task.ID != "a1" {
+			t.Errorf("stopped task = %s, expected a1", task.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no stopped task")
+	}
+}
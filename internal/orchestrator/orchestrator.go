@@ -0,0 +1,205 @@
+// Package orchestrator tracks the containers of orchestrators other
+// than Kubernetes, e.g. AWS ECS and Nomad. Adapters report the running
+// tasks of an orchestrator to a Tracker, which posts the deployed and
+// decommissioned records of their containers.
+package orchestrator
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+)
+
+// Task is a running unit of work of an orchestrator, the counterpart
+// of a pod: an ECS task or a Nomad allocation.
+type Task struct {
+	// ID identifies the task in the orchestrator, e.g. its ARN.
+	ID string
+	// Namespace groups the tasks, e.g. the ECS cluster or the Nomad
+	// namespace.
+	Namespace string
+	// Kind is the kind of workload that started the task, e.g.
+	// ECSService.
+	Kind string
+	// Workload is the name of the workload that started the task,
+	// e.g. the ECS service or the Nomad job.
+	Workload string
+	// Node is the host the task runs on, if known.
+	Node string
+	// Labels are the tags or metadata of the task.
+	Labels map[string]string
+	// Containers are the containers of the task.
+	Containers []Container
+}
+
+// Container is a container of a task.
+type Container struct {
+	Name  string
+	Image string
+	// Digest is the digest of the image the container runs, if the
+	// orchestrator reports it.
+	Digest string
+}
+
+// Handler receives the tasks reported by an adapter.
+type Handler interface {
+	// Sync replaces the running tasks with tasks.
+	Sync(ctx context.Context, tasks []Task)
+	// Running reports a task that started running.
+	Running(ctx context.Context, task Task)
+	// Stopped reports a task that stopped.
+	Stopped(ctx context.Context, task Task)
+}
+
+// Adapter reports the running tasks of an orchestrator.
+type Adapter interface {
+	// Name returns the name of the orchestrator, for logs and
+	// metrics.
+	Name() string
+	// Run reports the tasks to h until ctx is done.
+	Run(ctx context.Context, h Handler) error
+}
+
+// Poster posts records to the deployment records API, e.g.
+// deploymentrecord.Client.
+type Poster interface {
+	PostOne(ctx context.Context, record *deploymentrecord.DeploymentRecord) error
+}
+
+// RecordFunc returns the deployed records of the containers of a task.
+type RecordFunc func(ctx context.Context, task Task) []*deploymentrecord.DeploymentRecord
+
+// Tracker posts a deployed record when a deployment name and digest
+// starts running in a task, and a decommissioned record when no task
+// runs it anymore. Records that fail to post are posted again on the
+// next change.
+type Tracker struct {
+	adapter string
+	poster  Poster
+	records RecordFunc
+
+	mu sync.Mutex
+	// tasks are the records of the running tasks, keyed by task ID
+	tasks map[string][]*deploymentrecord.DeploymentRecord
+	// deployed are the deployed records posted, keyed by deployment
+	// name and digest
+	deployed map[string]*deploymentrecord.DeploymentRecord
+}
+
+// NewTracker creates a tracker of the tasks of the adapter named
+// adapter, posting the records returned by records.
+func NewTracker(adapter string, poster Poster, records RecordFunc) *Tracker {
+	return &Tracker{
+		adapter:  adapter,
+		poster:   poster,
+		records:  records,
+		tasks:    make(map[string][]*deploymentrecord.DeploymentRecord),
+		deployed: make(map[string]*deploymentrecord.DeploymentRecord),
+	}
+}
+
+// Sync implements Handler.
+func (t *Tracker) Sync(ctx context.Context, tasks []Task) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	running := make(map[string][]*deploymentrecord.DeploymentRecord, len(tasks))
+	for _, task := range tasks {
+		// The records of known tasks are kept, their containers
+		// don't change
+		records, ok := t.tasks[task.ID]
+		if !ok {
+			records = t.records(ctx, task)
+		}
+		running[task.ID] = records
+	}
+	t.tasks = running
+	t.reconcile(ctx)
+}
+
+// Running implements Handler.
+func (t *Tracker) Running(ctx context.Context, task Task) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.tasks[task.ID]; !ok {
+		t.tasks[task.ID] = t.records(ctx, task)
+	}
+	t.reconcile(ctx)
+}
+
+// Stopped implements Handler.
+func (t *Tracker) Stopped(ctx context.Context, task Task) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.tasks, task.ID)
+	t.reconcile(ctx)
+}
+
+// reconcile posts the deployed records of the running tasks that were
+// not posted yet, and decommissions the posted records no task runs
+// anymore.
+func (t *Tracker) reconcile(ctx context.Context) {
+	running := make(map[string]*deploymentrecord.DeploymentRecord)
+	for _, records := range t.tasks {
+		for _, record := range records {
+			running[recordKey(record)] = record
+		}
+	}
+	metrics.OrchestratorTasks.WithLabelValues(t.adapter).Set(float64(len(t.tasks)))
+
+	for _, key := range slices.Sorted(maps.Keys(running)) {
+		if _, ok := t.deployed[key]; ok {
+			continue
+		}
+		if t.post(ctx, running[key]) {
+			t.deployed[key] = running[key]
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(t.deployed)) {
+		if _, ok := running[key]; ok {
+			continue
+		}
+		record := *t.deployed[key]
+		record.Status = deploymentrecord.StatusDecommissioned
+		record.ObservedAt = time.Now().UTC()
+		if t.post(ctx, &record) {
+			delete(t.deployed, key)
+		}
+	}
+}
+
+// post posts the record and returns whether it succeeded.
+func (t *Tracker) post(ctx context.Context, record *deploymentrecord.DeploymentRecord) bool {
+	if err := t.poster.PostOne(ctx, record); err != nil {
+		slog.Error("Failed to post record",
+			"adapter", t.adapter,
+			"deployment_name", record.DeploymentName,
+			"digest", record.Digest,
+			"status", record.Status,
+			"error", err,
+		)
+		metrics.OrchestratorRecords.WithLabelValues(t.adapter, record.Status, "failed").Inc()
+		return false
+	}
+	slog.Info("Posted record",
+		"adapter", t.adapter,
+		"deployment_name", record.DeploymentName,
+		"digest", record.Digest,
+		"status", record.Status,
+	)
+	metrics.OrchestratorRecords.WithLabelValues(t.adapter, record.Status, "ok").Inc()
+	return true
+}
+
+// recordKey returns the key of the record in the tracker.
+func recordKey(record *deploymentrecord.DeploymentRecord) string {
+	return record.DeploymentName + "||" + record.Digest
+}
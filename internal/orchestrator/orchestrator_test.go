@@ -0,0 +1,124 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// fakePoster records the posted records, failing while err is set.
+type fakePoster struct {
+	posted []*deploymentrecord.DeploymentRecord
+	err    error
+}
+
+func (p *fakePoster) PostOne(_ context.Context, record *deploymentrecord.DeploymentRecord) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.posted = append(p.posted, record)
+	return nil
+}
+
+// testRecords returns a record per container, named after the
+// workload and container.
+func testRecords(_ context.Context, task Task) []*deploymentrecord.DeploymentRecord {
+	var res []*deploymentrecord.DeploymentRecord
+	for _, c := range task.Containers {
+		res = append(res, &deploymentrecord.DeploymentRecord{
+			DeploymentName: task.Namespace + "/" + task.Workload + "/" + c.Name,
+			Digest:         c.Digest,
+			Status:         deploymentrecord.StatusDeployed,
+		})
+	}
+	return res
+}
+
+func testTask(id, digest string) Task {
+	return Task{
+		ID:         id,
+		Namespace:  "prod",
+		Workload:   "web",
+		Containers: []Container{{Name: "app", Image: "ghcr.io/org/web", Digest: digest}},
+	}
+}
+
+// statuses returns the statuses and digests of the records posted
+// since the last call.
+func (p *fakePoster) statuses() []string {
+	var res []string
+	for _, r := range p.posted {
+		res = append(res, r.Status+" "+r.Digest)
+	}
+	p.posted = nil
+	return res
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	poster := &fakePoster{}
+	tracker := NewTracker("test", poster, testRecords)
+
+	steps := []struct {
+		name     string
+		run      func()
+		expected []string
+	}{
+		{
+			name:     "first task deploys",
+			run:      func() { tracker.Running(ctx, testTask("t1", "sha256:aaa")) },
+			expected: []string{"deployed sha256:aaa"},
+		},
+		{
+			name: "second task of the same digest",
+			run:  func() { tracker.Running(ctx, testTask("t2", "sha256:aaa")) },
+		},
+		{
+			name: "one of the tasks stops",
+			run:  func() { tracker.Stopped(ctx, testTask("t1", "")) },
+		},
+		{
+			name: "sync replaces the tasks with a new digest",
+			run: func() {
+				tracker.Sync(ctx, []Task{testTask("t3", "sha256:bbb")})
+			},
+			expected: []string{"deployed sha256:bbb", "decommissioned sha256:aaa"},
+		},
+		{
+			name:     "last task stops",
+			run:      func() { tracker.Stopped(ctx, testTask("t3", "")) },
+			expected: []string{"decommissioned sha256:bbb"},
+		},
+	}
+	for _, step := range steps {
+		step.run()
+		if got := poster.statuses(); !slices.Equal(got, step.expected) {
+			t.Errorf("%s: posted %v, expected %v", step.name, got, step.expected)
+		}
+	}
+}
+
+func TestTrackerRetry(t *testing.T) {
+	ctx := context.Background()
+	poster := &fakePoster{err: errors.New("unexpected status code: 500")}
+	tracker := NewTracker("test", poster, testRecords)
+
+	tracker.Running(ctx, testTask("t1", "sha256:aaa"))
+	if got := poster.statuses(); len(got) != 0 {
+		t.Fatalf("posted %v while the API fails", got)
+	}
+
+	// The record that failed is posted on the next change
+	poster.err = nil
+	tracker.Sync(ctx, []Task{testTask("t1", "sha256:aaa")})
+	if got, expected := poster.statuses(), []string{"deployed sha256:aaa"}; !slices.Equal(got, expected) {
+		t.Errorf("posted %v, expected %v", got, expected)
+	}
+	tracker.Sync(ctx, []Task{testTask("t1", "sha256:aaa")})
+	if got := poster.statuses(); len(got) != 0 {
+		t.Errorf("posted %v again", got)
+	}
+}
@@ -0,0 +1,191 @@
+package relay
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/github/deployment-tracker/pkg/recordpb"
+	"github.com/github/deployment-tracker/pkg/sink"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewGRPCServer creates a gRPC server serving the RecordService of h.
+// It is served over the TLS listener of the relay, see IsGRPC.
+func NewGRPCServer(h *Handler) *grpc.Server {
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(maxEventBytes))
+	recordpb.RegisterRecordServiceServer(srv, h)
+	return srv
+}
+
+// IsGRPC returns whether r is a gRPC call, sent over HTTP/2 with a
+// gRPC content type.
+func IsGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// SubmitDeploymentRecord posts the deployment record of the request.
+func (h *Handler) SubmitDeploymentRecord(ctx context.Context, req *recordpb.SubmitDeploymentRecordRequest) (*recordpb.SubmitDeploymentRecordResponse, error) {
+	if req.GetRecord() == nil {
+		metrics.RelayRecords.WithLabelValues("", resultInvalid).Inc()
+		return nil, status.Error(codes.InvalidArgument, "record is required")
+	}
+	record := toDeploymentRecord(req.GetRecord())
+	err := h.submit(ctx, record.Cluster, sink.EventDeploymentRecord, func(ctx context.Context) error {
+		return h.poster.PostOne(ctx, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &recordpb.SubmitDeploymentRecordResponse{}, nil
+}
+
+// SubmitEnvironmentRecord posts the environment record of the request.
+func (h *Handler) SubmitEnvironmentRecord(ctx context.Context, req *recordpb.SubmitEnvironmentRecordRequest) (*recordpb.SubmitEnvironmentRecordResponse, error) {
+	if req.GetRecord() == nil {
+		metrics.RelayRecords.WithLabelValues("", resultInvalid).Inc()
+		return nil, status.Error(codes.InvalidArgument, "record is required")
+	}
+	record := toEnvironmentRecord(req.GetRecord())
+	err := h.submit(ctx, record.Cluster, sink.EventEnvironmentRecord, func(ctx context.Context) error {
+		return h.poster.PostEnvironment(ctx, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &recordpb.SubmitEnvironmentRecordResponse{}, nil
+}
+
+// submit authenticates a gRPC call for cluster and posts its record.
+// Calls carry the secret of the cluster of their record as a bearer
+// token in the authorization metadata; unlike webhook events, they
+// are not signed, as they are only accepted over TLS.
+func (h *Handler) submit(ctx context.Context, cluster, typ string, post func(ctx context.Context) error) error {
+	secret, ok := h.secrets[cluster]
+	if !ok || !hmac.Equal([]byte(bearerToken(ctx)), secret) {
+		slog.Warn("Rejected submitted record with an invalid token",
+			"cluster", cluster,
+			"type", typ,
+		)
+		metrics.RelayRecords.WithLabelValues("", resultUnauthorized).Inc()
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	if err := h.post(ctx, post); err != nil {
+		var clientErr *deploymentrecord.ClientError
+		if errors.As(err, &clientErr) {
+			slog.Warn("Submitted record rejected by the API",
+				"cluster", cluster,
+				"type", typ,
+				"error", err,
+			)
+			metrics.RelayRecords.WithLabelValues(cluster, resultRejected).Inc()
+			return status.Error(codes.InvalidArgument, "record rejected by the API")
+		}
+		slog.Error("Failed to post submitted record",
+			"cluster", cluster,
+			"type", typ,
+			"error", err,
+		)
+		metrics.RelayRecords.WithLabelValues(cluster, resultFailed).Inc()
+		return status.Error(codes.Unavailable, "failed to post record")
+	}
+	metrics.RelayRecords.WithLabelValues(cluster, resultOk).Inc()
+	return nil
+}
+
+// bearerToken returns the bearer token of the authorization metadata
+// of an incoming call, or "" if none is set.
+func bearerToken(ctx context.Context) string {
+	for _, v := range metadata.ValueFromIncomingContext(ctx, "authorization") {
+		if token, ok := strings.CutPrefix(v, "Bearer "); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// toDeploymentRecord converts a submitted deployment record.
+func toDeploymentRecord(r *recordpb.DeploymentRecord) *deploymentrecord.DeploymentRecord {
+	record := &deploymentrecord.DeploymentRecord{
+		Name:                r.GetName(),
+		Digest:              r.GetDigest(),
+		Version:             r.GetVersion(),
+		LogicalEnvironment:  r.GetLogicalEnvironment(),
+		PhysicalEnvironment: r.GetPhysicalEnvironment(),
+		Cluster:             r.GetCluster(),
+		Status:              r.GetStatus(),
+		DeploymentName:      r.GetDeploymentName(),
+		TrackerVersion:      r.GetTrackerVersion(),
+		KubernetesVersion:   r.GetKubernetesVersion(),
+		CommitSHA:           r.GetCommitSha(),
+		Metadata:            r.GetMetadata(),
+		DigestVerification:  r.GetDigestVerification(),
+		RegistryDigest:      r.GetRegistryDigest(),
+		Signed:              r.Signed,
+		Attested:            r.Attested,
+		SignerIdentity:      r.GetSignerIdentity(),
+		SignerIssuer:        r.GetSignerIssuer(),
+		Replicas:            r.Replicas,
+		Source:              r.GetSource(),
+		PodUID:              r.GetPodUid(),
+		PodTemplateHash:     r.GetPodTemplateHash(),
+		Revision:            r.GetRevision(),
+		RevisionReplicas:    r.RevisionReplicas,
+	}
+	if r.GetObservedAt() != nil {
+		record.ObservedAt = r.GetObservedAt().AsTime()
+	}
+	if res := r.GetResources(); res != nil {
+		record.Resources = &deploymentrecord.Resources{
+			Requests: res.GetRequests(),
+			Limits:   res.GetLimits(),
+		}
+	}
+	if t := r.GetTopology(); t != nil {
+		record.Topology = &deploymentrecord.Topology{
+			Node:         t.GetNode(),
+			Zone:         t.GetZone(),
+			Region:       t.GetRegion(),
+			InstanceType: t.GetInstanceType(),
+		}
+	}
+	if p := r.GetPlatform(); p != nil {
+		record.Platform = &deploymentrecord.Platform{
+			OS:             p.GetOs(),
+			Architecture:   p.GetArchitecture(),
+			OSVersion:      p.GetOsVersion(),
+			ManifestDigest: p.GetManifestDigest(),
+		}
+	}
+	if g := r.GetGitops(); g != nil {
+		record.GitOps = &deploymentrecord.GitOps{
+			Tool: g.GetTool(),
+			Kind: g.GetKind(),
+			Name: g.GetName(),
+		}
+	}
+	return record
+}
+
+// toEnvironmentRecord converts a submitted environment record.
+func toEnvironmentRecord(r *recordpb.EnvironmentRecord) *deploymentrecord.EnvironmentRecord {
+	return &deploymentrecord.EnvironmentRecord{
+		Name:                r.GetName(),
+		LogicalEnvironment:  r.GetLogicalEnvironment(),
+		PhysicalEnvironment: r.GetPhysicalEnvironment(),
+		Cluster:             r.GetCluster(),
+		Status:              r.GetStatus(),
+		TrackerVersion:      r.GetTrackerVersion(),
+		KubernetesVersion:   r.GetKubernetesVersion(),
+	}
+}
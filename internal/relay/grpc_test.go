@@ -0,0 +1,193 @@
+package relay
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+	"github.com/github/deployment-tracker/pkg/recordpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newTestGRPCClient serves the RecordService of h over an HTTP/2 TLS
+// test server, like the relay, and returns a client calling it.
+func newTestGRPCClient(t *testing.T, h *Handler) recordpb.RecordServiceClient {
+	t.Helper()
+	grpcSrv := NewGRPCServer(h)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGRPC(r) {
+			http.Error(w, "not a gRPC call", http.StatusBadRequest)
+			return
+		}
+		grpcSrv.ServeHTTP(w, r)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	conn, err := grpc.NewClient(srv.Listener.Addr().String(),
+		grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "example.com")))
+	if err != nil {
+		t.Fatalf("grpc.NewClient() unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return recordpb.NewRecordServiceClient(conn)
+}
+
+func TestSubmitDeploymentRecord(t *testing.T) {
+	observedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	replicas := int32(3)
+	record := &recordpb.DeploymentRecord{
+		Name:           "ghcr.io/org/web",
+		Digest:         "sha256:abc",
+		Cluster:        "edge-1",
+		Status:         deploymentrecord.StatusDeployed,
+		DeploymentName: "default/web/app",
+		Replicas:       &replicas,
+		ObservedAt:     timestamppb.New(observedAt),
+		Topology:       &recordpb.Topology{Zone: "eu-west-1a"},
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		record   *recordpb.DeploymentRecord
+		postErr  error
+		expected codes.Code
+		posted   int
+	}{
+		{
+			name:     "submitted",
+			token:    "edge-1-secret",
+			record:   record,
+			expected: codes.OK,
+			posted:   1,
+		},
+		{
+			name:     "missing record",
+			token:    "edge-1-secret",
+			expected: codes.InvalidArgument,
+		},
+		{
+			name:     "no token",
+			record:   record,
+			expected: codes.Unauthenticated,
+		},
+		{
+			name:     "token of another cluster",
+			token:    "edge-2-secret",
+			record:   record,
+			expected: codes.Unauthenticated,
+		},
+		{
+			name:     "post failure",
+			token:    "edge-1-secret",
+			record:   record,
+			postErr:  errors.New("unreachable"),
+			expected: codes.Unavailable,
+			posted:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := &fakePoster{err: tt.postErr}
+			h, err := NewHandler(poster, map[string]string{"edge-1": "edge-1-secret", "edge-2": "edge-2-secret"})
+			if err != nil {
+				t.Fatalf("NewHandler() unexpected error: %v", err)
+			}
+			client := newTestGRPCClient(t, h)
+
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tt.token)
+			}
+			_, err = client.SubmitDeploymentRecord(ctx, &recordpb.SubmitDeploymentRecordRequest{Record: tt.record})
+			if code := status.Code(err); code != tt.expected {
+				t.Errorf("SubmitDeploymentRecord() code = %v, expected %v (%v)", code, tt.expected, err)
+			}
+			if len(poster.deployments) != tt.posted {
+				t.Fatalf("posted %d records, expected %d", len(poster.deployments), tt.posted)
+			}
+			if tt.posted == 0 {
+				return
+			}
+			got := poster.deployments[0]
+			if got.DeploymentName != "default/web/app" || got.Status != deploymentrecord.StatusDeployed {
+				t.Errorf("posted record = %+v", got)
+			}
+			if got.Replicas == nil || *got.Replicas != 3 {
+				t.Errorf("posted replicas = %v, expected 3", got.Replicas)
+			}
+			if !got.ObservedAt.Equal(observedAt) {
+				t.Errorf("posted observed_at = %v, expected %v", got.ObservedAt, observedAt)
+			}
+			if got.Topology == nil || got.Topology.Zone != "eu-west-1a" {
+				t.Errorf("posted topology = %+v", got.Topology)
+			}
+		})
+	}
+}
+
+func TestSubmitEnvironmentRecord(t *testing.T) {
+	poster := &fakePoster{}
+	h, err := NewHandler(poster, map[string]string{"edge-1": "edge-1-secret"})
+	if err != nil {
+		t.Fatalf("NewHandler() unexpected error: %v", err)
+	}
+	client := newTestGRPCClient(t, h)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer edge-1-secret")
+	_, err = client.SubmitEnvironmentRecord(ctx, &recordpb.SubmitEnvironmentRecordRequest{
+		Record: &recordpb.EnvironmentRecord{Name: "default", Cluster: "edge-1", Status: "created"},
+	})
+	if err != nil {
+		t.Fatalf("SubmitEnvironmentRecord() unexpected error: %v", err)
+	}
+	if len(poster.environments) != 1 || poster.environments[0].Name != "default" {
+		t.Errorf("posted environments = %+v, expected default", poster.environments)
+	}
+}
+
+func TestSubmitRejected(t *testing.T) {
+	srv := deploymentrecordtest.NewServer()
+	defer srv.Close()
+	srv.FailNext(1, http.StatusUnprocessableEntity)
+	apiClient, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	h, err := NewHandler(apiClient, map[string]string{"edge-1": "edge-1-secret"})
+	if err != nil {
+		t.Fatalf("NewHandler() unexpected error: %v", err)
+	}
+	client := newTestGRPCClient(t, h)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer edge-1-secret")
+	_, err = client.SubmitDeploymentRecord(ctx, &recordpb.SubmitDeploymentRecordRequest{
+		Record: &recordpb.DeploymentRecord{
+			Name:           "ghcr.io/org/web",
+			Digest:         "sha256:abc",
+			Cluster:        "edge-1",
+			Status:         deploymentrecord.StatusDeployed,
+			DeploymentName: "default/web/app",
+		},
+	})
+	// A client error of the API is not an unavailable relay
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("SubmitDeploymentRecord() code = %v, expected %v", code, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,220 @@
+// Package relay implements the relay mode, in which a central
+// deployment-tracker accepts the records of edge instances that can't
+// reach the deployment records API, and posts them with its own
+// credentials.
+package relay
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/github/deployment-tracker/pkg/recordpb"
+	"github.com/github/deployment-tracker/pkg/sink"
+)
+
+const (
+	// maxEventBytes is the maximum size of a relayed event.
+	maxEventBytes = 1 << 20
+	// maxSignatureAge is the maximum difference between the signing
+	// time of an event and the time it is received, so captured
+	// requests can't be replayed later.
+	maxSignatureAge = 5 * time.Minute
+	// DefaultPostTimeout is the default timeout of the posts of
+	// relayed records, see WithPostTimeout.
+	DefaultPostTimeout = 30 * time.Second
+)
+
+// Relay results, the result label of metrics.RelayRecords.
+const (
+	resultOk           = "ok"
+	resultInvalid      = "invalid"
+	resultUnauthorized = "unauthorized"
+	resultRejected     = "rejected"
+	resultFailed       = "failed"
+)
+
+// Poster posts the relayed records to the API.
+type Poster interface {
+	PostOne(ctx context.Context, record *deploymentrecord.DeploymentRecord) error
+	PostEnvironment(ctx context.Context, record *deploymentrecord.EnvironmentRecord) error
+}
+
+// Handler accepts the events of the webhook sink of edge instances and
+// posts their records. Each edge cluster has its own secret: an event
+// is only accepted if it is signed with the secret of the cluster of
+// its record, see sink.SignatureHeader, so an edge can't post records
+// of other clusters, and within maxSignatureAge of its signing time.
+// Handler also implements the RecordService of the record submission
+// API, see NewGRPCServer.
+type Handler struct {
+	recordpb.UnimplementedRecordServiceServer
+
+	poster      Poster
+	secrets     map[string][]byte
+	postTimeout time.Duration
+}
+
+// Option is a function that configures the Handler.
+type Option func(*Handler)
+
+// WithPostTimeout bounds the post of each record, including the
+// retries and rate limit waits of the API client, DefaultPostTimeout
+// by default. Records are posted while the edge instance waits for the
+// answer, so the timeout of its webhook sink must be above it.
+func WithPostTimeout(d time.Duration) Option {
+	return func(h *Handler) {
+		h.postTimeout = d
+	}
+}
+
+// NewHandler creates a handler posting with poster the records of the
+// clusters in secrets, keyed by cluster name. Returns an error if no
+// secrets are set.
+func NewHandler(poster Poster, secrets map[string]string, opts ...Option) (*Handler, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("at least one cluster secret is required")
+	}
+	h := &Handler{
+		poster:      poster,
+		secrets:     make(map[string][]byte, len(secrets)),
+		postTimeout: DefaultPostTimeout,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	for cluster, secret := range secrets {
+		if secret == "" {
+			return nil, fmt.Errorf("empty secret for cluster %s", cluster)
+		}
+		h.secrets[cluster] = []byte(secret)
+	}
+	return h, nil
+}
+
+// event is a relayed sink.Event, with the record left to decode by
+// type.
+type event struct {
+	Type   string          `json:"type"`
+	Record json.RawMessage `json:"record"`
+}
+
+// ServeHTTP posts the record of the event in the request body.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventBytes))
+	if err != nil {
+		metrics.RelayRecords.WithLabelValues("", resultInvalid).Inc()
+		http.Error(w, "failed to read event", http.StatusBadRequest)
+		return
+	}
+
+	var ev event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		metrics.RelayRecords.WithLabelValues("", resultInvalid).Inc()
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	var cluster string
+	var post func(ctx context.Context) error
+	switch ev.Type {
+	case sink.EventDeploymentRecord:
+		var record deploymentrecord.DeploymentRecord
+		err = json.Unmarshal(ev.Record, &record)
+		cluster = record.Cluster
+		post = func(ctx context.Context) error {
+			return h.poster.PostOne(ctx, &record)
+		}
+	case sink.EventEnvironmentRecord:
+		var record deploymentrecord.EnvironmentRecord
+		err = json.Unmarshal(ev.Record, &record)
+		cluster = record.Cluster
+		post = func(ctx context.Context) error {
+			return h.poster.PostEnvironment(ctx, &record)
+		}
+	default:
+		err = fmt.Errorf("unknown event type: %s", ev.Type)
+	}
+	if err != nil {
+		metrics.RelayRecords.WithLabelValues("", resultInvalid).Inc()
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.verify(r, cluster, body); err != nil {
+		slog.Warn("Rejected relayed record",
+			"cluster", cluster,
+			"remote_addr", r.RemoteAddr,
+			"error", err,
+		)
+		metrics.RelayRecords.WithLabelValues("", resultUnauthorized).Inc()
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.post(r.Context(), post); err != nil {
+		// Records rejected by the API would be rejected again
+		var clientErr *deploymentrecord.ClientError
+		if errors.As(err, &clientErr) {
+			slog.Warn("Relayed record rejected by the API",
+				"cluster", cluster,
+				"type", ev.Type,
+				"error", err,
+			)
+			metrics.RelayRecords.WithLabelValues(cluster, resultRejected).Inc()
+			http.Error(w, "record rejected by the API", http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("Failed to post relayed record",
+			"cluster", cluster,
+			"type", ev.Type,
+			"error", err,
+		)
+		metrics.RelayRecords.WithLabelValues(cluster, resultFailed).Inc()
+		http.Error(w, "failed to post record", http.StatusBadGateway)
+		return
+	}
+	metrics.RelayRecords.WithLabelValues(cluster, resultOk).Inc()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// post calls post with ctx bounded by the post timeout.
+func (h *Handler) post(ctx context.Context, post func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, h.postTimeout)
+	defer cancel()
+	return post(ctx)
+}
+
+// verify checks the signature of the request with the secret of the
+// cluster, and that it was signed within maxSignatureAge.
+func (h *Handler) verify(r *http.Request, cluster string, body []byte) error {
+	secret, ok := h.secrets[cluster]
+	if !ok {
+		return errors.New("invalid signature")
+	}
+	timestamp := r.Header.Get(sink.TimestampHeader)
+	if !hmac.Equal([]byte(r.Header.Get(sink.SignatureHeader)), []byte(sink.Sign(secret, timestamp, body))) {
+		return errors.New("invalid signature")
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return errors.New("expired signature")
+	}
+	return nil
+}
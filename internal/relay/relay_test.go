@@ -0,0 +1,231 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/deploymentrecord/deploymentrecordtest"
+	"github.com/github/deployment-tracker/pkg/sink"
+)
+
+// fakePoster records the posted records.
+type fakePoster struct {
+	deployments  []*deploymentrecord.DeploymentRecord
+	environments []*deploymentrecord.EnvironmentRecord
+	err          error
+}
+
+func (p *fakePoster) PostOne(_ context.Context, record *deploymentrecord.DeploymentRecord) error {
+	p.deployments = append(p.deployments, record)
+	return p.err
+}
+
+func (p *fakePoster) PostEnvironment(_ context.Context, record *deploymentrecord.EnvironmentRecord) error {
+	p.environments = append(p.environments, record)
+	return p.err
+}
+
+func TestNewHandler(t *testing.T) {
+	if _, err := NewHandler(&fakePoster{}, nil); err == nil {
+		t.Error("NewHandler() expected an error without secrets")
+	}
+	if _, err := NewHandler(&fakePoster{}, map[string]string{"edge-1": ""}); err == nil {
+		t.Error("NewHandler() expected an error for an empty secret")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	const deployment = `{"type":"deployment_record","record":{"name":"ghcr.io/org/web","digest":"sha256:abc","cluster":"edge-1","status":"deployed","deployment_name":"default/web/app"}}`
+	const environment = `{"type":"environment_record","record":{"name":"default","cluster":"edge-1","status":"created"}}`
+
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		secret       string
+		timestamp    time.Time
+		postErr      error
+		expected     int
+		deployments  int
+		environments int
+	}{
+		{
+			name:        "deployment record",
+			body:        deployment,
+			secret:      "edge-1-secret",
+			expected:    http.StatusAccepted,
+			deployments: 1,
+		},
+		{
+			name:         "environment record",
+			body:         environment,
+			secret:       "edge-1-secret",
+			expected:     http.StatusAccepted,
+			environments: 1,
+		},
+		{
+			name:     "secret of another cluster",
+			body:     deployment,
+			secret:   "edge-2-secret",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "unknown cluster",
+			body:     strings.Replace(deployment, "edge-1", "edge-3", 1),
+			secret:   "edge-1-secret",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:      "expired",
+			body:      deployment,
+			secret:    "edge-1-secret",
+			timestamp: time.Now().Add(-time.Hour),
+			expected:  http.StatusUnauthorized,
+		},
+		{
+			name:      "future",
+			body:      deployment,
+			secret:    "edge-1-secret",
+			timestamp: time.Now().Add(time.Hour),
+			expected:  http.StatusUnauthorized,
+		},
+		{
+			name:     "unsigned",
+			body:     deployment,
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "unknown type",
+			body:     `{"type":"other","record":{}}`,
+			secret:   "edge-1-secret",
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid JSON",
+			body:     `{`,
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "GET",
+			method:   http.MethodGet,
+			expected: http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "API failure",
+			body:        deployment,
+			secret:      "edge-1-secret",
+			postErr:     errors.New("unexpected status code: 500"),
+			expected:    http.StatusBadGateway,
+			deployments: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := &fakePoster{err: tt.postErr}
+			h, err := NewHandler(poster, map[string]string{
+				"edge-1": "edge-1-secret",
+				"edge-2": "edge-2-secret",
+			})
+			if err != nil {
+				t.Fatalf("NewHandler() unexpected error: %v", err)
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/events", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				ts := tt.timestamp
+				if ts.IsZero() {
+					ts = time.Now()
+				}
+				timestamp := strconv.FormatInt(ts.Unix(), 10)
+				req.Header.Set(sink.TimestampHeader, timestamp)
+				req.Header.Set(sink.SignatureHeader, sink.Sign([]byte(tt.secret), timestamp, []byte(tt.body)))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("status = %d, expected %d", rec.Code, tt.expected)
+			}
+			if len(poster.deployments) != tt.deployments || len(poster.environments) != tt.environments {
+				t.Errorf("posted %d deployment and %d environment records, expected %d and %d",
+					len(poster.deployments), len(poster.environments), tt.deployments, tt.environments)
+			}
+			if tt.deployments > 0 && poster.deployments[0].DeploymentName != "default/web/app" {
+				t.Errorf("DeploymentName = %s, expected default/web/app", poster.deployments[0].DeploymentName)
+			}
+		})
+	}
+}
+
+func TestHandlerRejected(t *testing.T) {
+	const body = `{"type":"deployment_record","record":{"name":"ghcr.io/org/web","digest":"sha256:abc","cluster":"edge-1","status":"deployed","deployment_name":"default/web/app"}}`
+	srv := deploymentrecordtest.NewServer()
+	defer srv.Close()
+	srv.FailNext(1, http.StatusUnprocessableEntity)
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org", deploymentrecord.WithRetries(0))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	h, err := NewHandler(client, map[string]string{"edge-1": "edge-1-secret"})
+	if err != nil {
+		t.Fatalf("NewHandler() unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(sink.TimestampHeader, timestamp)
+	req.Header.Set(sink.SignatureHeader, sink.Sign([]byte("edge-1-secret"), timestamp, []byte(body)))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	// A client error of the API is not a gateway failure
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, expected %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestHandlerPostTimeout(t *testing.T) {
+	const body = `{"type":"deployment_record","record":{"name":"ghcr.io/org/web","digest":"sha256:abc","cluster":"edge-1","status":"deployed","deployment_name":"default/web/app"}}`
+	srv := deploymentrecordtest.NewServer()
+	defer srv.Close()
+	srv.FailNext(100, http.StatusServiceUnavailable)
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org",
+		deploymentrecord.WithRetries(100),
+		deploymentrecord.WithBackoff(deploymentrecord.Backoff{Base: time.Second, Multiplier: 2, Max: time.Minute}))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	h, err := NewHandler(client, map[string]string{"edge-1": "edge-1-secret"}, WithPostTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewHandler() unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(sink.TimestampHeader, timestamp)
+	req.Header.Set(sink.SignatureHeader, sink.Sign([]byte("edge-1-secret"), timestamp, []byte(body)))
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+
+	// The retries are cut short, so the edge retries before its
+	// webhook request times out
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, expected %d", rec.Code, http.StatusBadGateway)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("post took %s, expected the post timeout", d)
+	}
+}
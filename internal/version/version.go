@@ -0,0 +1,45 @@
+package version
+
+import (
+	"runtime/debug"
+)
+
+// Version is the deployment-tracker version. It is set at build time
+// via -ldflags "-X github.com/github/deployment-tracker/internal/version.Version=...".
+var Version = "dev"
+
+// Commit is the source commit deployment-tracker was built from. It is
+// set at build time via -ldflags "-X github.com/github/deployment-tracker/internal/version.Commit=...".
+var Commit string
+
+// Get returns the deployment-tracker version. If no version was set
+// at build time, the module version from the build info is used when
+// available.
+func Get() string {
+	if Version != "dev" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok &&
+		info.Main.Version != "" &&
+		info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return Version
+}
+
+// GetCommit returns the source commit deployment-tracker was built
+// from. If no commit was set at build time, the VCS revision from the
+// build info is used when available.
+func GetCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
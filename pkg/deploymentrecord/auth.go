@@ -0,0 +1,77 @@
+package deploymentrecord
+
+import (
+	"context"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+)
+
+// AuthProvider provides the bearer tokens authenticating requests to
+// the API. Token is called for each request, so implementations are
+// expected to cache tokens, and must be safe for concurrent use.
+type AuthProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// The precedence of the authentication options, when several are set.
+const (
+	authPriorityToken = iota + 1
+	authPriorityTokenFile
+	authPriorityTokenFunc
+	authPriorityExchange
+	authPriorityGHApp
+	authPriorityProvider
+)
+
+// StaticToken is an AuthProvider of a fixed API token.
+type StaticToken string
+
+// Token returns the token.
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// AuthFunc adapts a function to an AuthProvider.
+type AuthFunc func(ctx context.Context) (string, error)
+
+// Token returns the token returned by f.
+func (f AuthFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Token returns the current token of the file.
+func (f *tokenFile) Token(context.Context) (string, error) {
+	return f.get(), nil
+}
+
+// ghAppAuth authenticates as a GitHub App installation, with the
+// installation tokens of the transport.
+type ghAppAuth struct {
+	transport *ghinstallation.Transport
+}
+
+// Token returns the installation token, refreshing it if expired.
+func (a ghAppAuth) Token(ctx context.Context) (string, error) {
+	// Token is thread safe, so no need for external locking
+	return a.transport.Token(ctx)
+}
+
+// WithAuthProvider authenticates requests with the bearer tokens of
+// p, for authentication schemes without a dedicated option. It takes
+// precedence over all other authentication options.
+func WithAuthProvider(p AuthProvider) ClientOption {
+	return func(c *Client) {
+		c.setAuth(p, authPriorityProvider)
+	}
+}
+
+// setAuth sets the authentication provider, unless one of higher
+// precedence is already set, so the precedence of the options doesn't
+// depend on their order.
+func (c *Client) setAuth(p AuthProvider, priority int) {
+	if priority < c.authPriority {
+		return
+	}
+	c.auth = p
+	c.authPriority = priority
+}
@@ -0,0 +1,96 @@
+package deploymentrecord
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthPrecedence(t *testing.T) {
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	provider := AuthFunc(func(context.Context) (string, error) {
+		return "provider-token", nil
+	})
+	fn := func(context.Context) (string, error) {
+		return "func-token", nil
+	}
+
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want string
+	}{
+		{
+			name: "no auth",
+		},
+		{
+			name: "api token",
+			opts: []ClientOption{WithAPIToken("api-token")},
+			want: "Bearer api-token",
+		},
+		{
+			name: "token func over api token",
+			opts: []ClientOption{WithTokenFunc(fn), WithAPIToken("api-token")},
+			want: "Bearer func-token",
+		},
+		{
+			name: "provider over token func",
+			opts: []ClientOption{WithTokenFunc(fn), WithAuthProvider(provider)},
+			want: "Bearer provider-token",
+		},
+		{
+			name: "provider set first",
+			opts: []ClientOption{WithAuthProvider(provider), WithAPIToken("api-token")},
+			want: "Bearer provider-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var auth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(srv.URL, "my-org", tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+			if err := c.PostOne(context.Background(), record); err != nil {
+				t.Fatalf("PostOne() unexpected error: %v", err)
+			}
+			if auth != tt.want {
+				t.Errorf("Authorization = %q, want %q", auth, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthProviderError(t *testing.T) {
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	var called bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org", WithAuthProvider(AuthFunc(func(context.Context) (string, error) {
+		return "", errors.New("no credentials")
+	})))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	err = c.PostOne(context.Background(), record)
+	if err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("PostOne() error = %v, want the provider error", err)
+	}
+	if called {
+		t.Error("request sent without a token")
+	}
+}
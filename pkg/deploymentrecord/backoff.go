@@ -0,0 +1,55 @@
+package deploymentrecord
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff is the exponential backoff between retries of failed
+// requests. The n-th retry waits Base * Multiplier^n plus a random
+// jitter of up to Jitter, capped at Max.
+type Backoff struct {
+	Base       time.Duration
+	Multiplier float64
+	Max        time.Duration
+	Jitter     time.Duration
+}
+
+// DefaultBackoff waits 200ms before the first retry, doubling up to 5s.
+var DefaultBackoff = Backoff{
+	Base:       100 * time.Millisecond,
+	Multiplier: 2,
+	Max:        5 * time.Second,
+	Jitter:     50 * time.Millisecond,
+}
+
+// Validate returns an error if the backoff is invalid.
+func (b Backoff) Validate() error {
+	switch {
+	case b.Base <= 0:
+		return fmt.Errorf("invalid backoff base %s, must be positive", b.Base)
+	case b.Multiplier < 1:
+		return fmt.Errorf("invalid backoff multiplier %v, must be at least 1", b.Multiplier)
+	case b.Max < b.Base:
+		return fmt.Errorf("invalid backoff maximum %s, must be at least the base %s", b.Max, b.Base)
+	case b.Jitter < 0:
+		return fmt.Errorf("invalid backoff jitter %s, must not be negative", b.Jitter)
+	}
+	return nil
+}
+
+// delay returns the time to wait before the retry.
+func (b Backoff) delay(retry int) time.Duration {
+	d := float64(b.Base) * math.Pow(b.Multiplier, float64(retry))
+	if b.Jitter > 0 {
+		//nolint:gosec
+		d += float64(rand.Int64N(int64(b.Jitter)))
+	}
+	// Compare as floats, the duration could overflow
+	if d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,88 @@
+package deploymentrecord
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff Backoff
+		retry   int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{
+			name:    "default first retry",
+			backoff: DefaultBackoff,
+			retry:   1,
+			min:     200 * time.Millisecond,
+			max:     250 * time.Millisecond,
+		},
+		{
+			name:    "default capped",
+			backoff: DefaultBackoff,
+			retry:   10,
+			min:     5 * time.Second,
+			max:     5 * time.Second,
+		},
+		{
+			name:    "slow without jitter",
+			backoff: Backoff{Base: time.Second, Multiplier: 3, Max: time.Minute},
+			retry:   2,
+			min:     9 * time.Second,
+			max:     9 * time.Second,
+		},
+		{
+			name:    "constant",
+			backoff: Backoff{Base: time.Second, Multiplier: 1, Max: time.Second},
+			retry:   5,
+			min:     time.Second,
+			max:     time.Second,
+		},
+		{
+			name:    "huge retry count",
+			backoff: Backoff{Base: time.Second, Multiplier: 10, Max: time.Hour},
+			retry:   100,
+			min:     time.Hour,
+			max:     time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 10 {
+				if got := tt.backoff.delay(tt.retry); got < tt.min || got > tt.max {
+					t.Errorf("delay(%d) = %v, want between %v and %v", tt.retry, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff Backoff
+		wantErr bool
+	}{
+		{name: "default", backoff: DefaultBackoff},
+		{name: "zero base", backoff: Backoff{Multiplier: 2, Max: time.Second}, wantErr: true},
+		{name: "shrinking", backoff: Backoff{Base: time.Second, Multiplier: 0.5, Max: time.Second}, wantErr: true},
+		{name: "max below base", backoff: Backoff{Base: time.Second, Multiplier: 2, Max: time.Millisecond}, wantErr: true},
+		{name: "negative jitter", backoff: Backoff{Base: time.Second, Multiplier: 2, Max: time.Second, Jitter: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.backoff.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, err := NewClient("https://api.github.com", "my-org", WithBackoff(tt.backoff))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
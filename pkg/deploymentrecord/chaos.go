@@ -0,0 +1,105 @@
+package deploymentrecord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/metrics"
+)
+
+// errChaos is returned for requests failed by chaos injection without
+// a status code, as if the API was unreachable.
+var errChaos = errors.New("chaos: injected connection failure")
+
+// Chaos injects artificial failures and latency into the requests to
+// the API, to soak test rate limiting, retries and queueing. It must
+// never be enabled in production. The zero value injects nothing.
+type Chaos struct {
+	// FailureRate is the fraction (0 to 1) of request attempts that
+	// fail, chosen at random.
+	FailureRate float64
+	// FailureStatus is the HTTP status code of failed attempts. 0
+	// fails them without a response, as a connection error.
+	FailureStatus int
+	// Latency is added to every attempt, plus a random jitter of up
+	// to LatencyJitter.
+	Latency       time.Duration
+	LatencyJitter time.Duration
+}
+
+// Validate returns an error if the chaos settings are invalid.
+func (ch Chaos) Validate() error {
+	switch {
+	case ch.FailureRate < 0 || ch.FailureRate > 1:
+		return fmt.Errorf("invalid chaos failure rate %v, must be between 0 and 1", ch.FailureRate)
+	case ch.FailureStatus != 0 && (ch.FailureStatus < 100 || ch.FailureStatus > 599):
+		return fmt.Errorf("invalid chaos failure status %d, must be an HTTP status code or 0", ch.FailureStatus)
+	case ch.Latency < 0:
+		return fmt.Errorf("invalid chaos latency %s, must not be negative", ch.Latency)
+	case ch.LatencyJitter < 0:
+		return fmt.Errorf("invalid chaos latency jitter %s, must not be negative", ch.LatencyJitter)
+	}
+	return nil
+}
+
+// Enabled returns true if the settings inject any fault.
+func (ch Chaos) Enabled() bool {
+	return ch.FailureRate > 0 || ch.Latency > 0 || ch.LatencyJitter > 0
+}
+
+// WithChaos injects failures and latency into the requests of the
+// client. Invalid settings make NewClient fail.
+func WithChaos(ch Chaos) ClientOption {
+	return func(c *Client) {
+		if err := ch.Validate(); err != nil {
+			c.err = err
+			return
+		}
+		if ch.Enabled() {
+			c.chaos = &ch
+		}
+	}
+}
+
+// inject delays the request attempt, and returns the injected response
+// or error if it fails it. It returns a nil response and error if the
+// attempt should be sent.
+func (ch *Chaos) inject(ctx context.Context) (*http.Response, error) {
+	if delay := ch.latency(); delay > 0 {
+		metrics.ChaosInjections.WithLabelValues("latency").Inc()
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if ch.FailureRate == 0 || rand.Float64() >= ch.FailureRate {
+		return nil, nil
+	}
+
+	metrics.ChaosInjections.WithLabelValues("failure").Inc()
+	if ch.FailureStatus == 0 {
+		return nil, errChaos
+	}
+	return &http.Response{
+		StatusCode: ch.FailureStatus,
+		Status:     fmt.Sprintf("%d %s", ch.FailureStatus, http.StatusText(ch.FailureStatus)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"message":"chaos: injected failure"}`))),
+	}, nil
+}
+
+// latency returns the delay to add to an attempt.
+func (ch *Chaos) latency() time.Duration {
+	delay := ch.Latency
+	if ch.LatencyJitter > 0 {
+		delay += rand.N(ch.LatencyJitter)
+	}
+	return delay
+}
@@ -0,0 +1,110 @@
+package deploymentrecord
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChaosValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		chaos   Chaos
+		wantErr bool
+	}{
+		{name: "zero"},
+		{name: "valid", chaos: Chaos{FailureRate: 0.5, FailureStatus: 503, Latency: time.Second}},
+		{name: "connection errors", chaos: Chaos{FailureRate: 1}},
+		{name: "rate above 1", chaos: Chaos{FailureRate: 1.5}, wantErr: true},
+		{name: "negative rate", chaos: Chaos{FailureRate: -0.1}, wantErr: true},
+		{name: "invalid status", chaos: Chaos{FailureRate: 1, FailureStatus: 42}, wantErr: true},
+		{name: "negative latency", chaos: Chaos{Latency: -time.Second}, wantErr: true},
+		{name: "negative jitter", chaos: Chaos{LatencyJitter: -time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.chaos.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChaos(t *testing.T) {
+	tests := []struct {
+		name       string
+		chaos      Chaos
+		wantStatus int
+		wantErr    error
+		requests   int32
+	}{
+		{
+			name:       "status failures",
+			chaos:      Chaos{FailureRate: 1, FailureStatus: http.StatusServiceUnavailable},
+			wantStatus: http.StatusServiceUnavailable,
+			requests:   0,
+		},
+		{
+			name:     "connection failures",
+			chaos:    Chaos{FailureRate: 1},
+			wantErr:  errChaos,
+			requests: 0,
+		},
+		{
+			name:     "latency",
+			chaos:    Chaos{Latency: 20 * time.Millisecond},
+			requests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(srv.URL, "my-org", WithChaos(tt.chaos), WithRetries(1),
+				WithBackoff(Backoff{Base: time.Millisecond, Multiplier: 1, Max: time.Millisecond}))
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+			record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+
+			start := time.Now()
+			err = c.PostOne(context.Background(), record)
+			var apiErr *APIError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus {
+					t.Errorf("PostOne() error = %v, want status %d", err, tt.wantStatus)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("PostOne() error = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("PostOne() unexpected error: %v", err)
+			}
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("requests = %d, want %d", got, tt.requests)
+			}
+			if elapsed := time.Since(start); elapsed < tt.chaos.Latency {
+				t.Errorf("PostOne() took %s, want at least %s", elapsed, tt.chaos.Latency)
+			}
+		})
+	}
+}
+
+func TestWithChaosInvalid(t *testing.T) {
+	if _, err := NewClient("https://api.github.com", "my-org", WithChaos(Chaos{FailureRate: 2})); err == nil {
+		t.Error("NewClient() expected an error for an invalid failure rate")
+	}
+}
@@ -3,24 +3,48 @@ package deploymentrecord
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"math"
-	"math/rand/v2"
+	"maps"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/github/deployment-tracker/pkg/localurl"
 	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/github/deployment-tracker/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 )
 
+// tracerName is the instrumentation scope of the client's spans.
+const tracerName = "github.com/github/deployment-tracker/pkg/deploymentrecord"
+
+const (
+	// maxRateLimitPause caps the pause requested by the API when
+	// rate limited.
+	maxRateLimitPause = time.Hour
+
+	// secondaryRateLimitPause is the pause after hitting a secondary
+	// rate limit without a Retry-After header, as recommended by the
+	// GitHub API documentation.
+	secondaryRateLimitPause = time.Minute
+
+	// maxResponseBytes is the maximum size of a response body read
+	// from the API.
+	maxResponseBytes = 10 << 20
+)
+
 // ClientOption is a function that configures the Client.
 type ClientOption func(*Client)
 
@@ -30,26 +54,45 @@ var validOrgPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
 // Client is an API client for posting deployment records.
 type Client struct {
-	baseURL     string
-	org         string
-	httpClient  *http.Client
-	retries     int
-	apiToken    string
-	transport   *ghinstallation.Transport
-	rateLimiter *rate.Limiter
+	baseURL    string
+	org        string
+	httpClient *http.Client
+	// transport is the transport of httpClient
+	transport *http.Transport
+	// attemptTimeout bounds each attempt of a request
+	attemptTimeout time.Duration
+	// userAgent and header are sent with all requests
+	userAgent string
+	header    http.Header
+	// slots limits the requests in flight, if set
+	slots chan struct{}
+	// chaos injects failures and latency, if set
+	chaos   *Chaos
+	retries int
+	auth    AuthProvider
+	// authPriority is the precedence of the auth option
+	authPriority int
+	rateLimiter  *rate.Limiter
+	backoff      Backoff
+	fields       *FieldMapping
+	// noAPI accepts posts without sending them, see WithoutAPI
+	noAPI bool
+	// err is the first error of the options, returned by NewClient
+	err error
+	// unreachable is set when the last request failed without a
+	// response from the API
+	unreachable atomic.Bool
+	// pausedUntil is the time (unix nano) until which no requests
+	// are sent, as the API rate limited the client
+	pausedUntil atomic.Int64
 }
 
 // NewClient creates a new API client with the given base URL and
 // organization. Returns an error if the base URL is not HTTPS for
 // non-local hosts.
 func NewClient(baseURL, org string, opts ...ClientOption) (*Client, error) {
-	// Check if URL is local (allowed to use HTTP)
-	isLocal := strings.HasPrefix(baseURL, "http://localhost") ||
-		strings.HasPrefix(baseURL, "http://127.0.0.1") ||
-		strings.Contains(baseURL, ".svc.cluster.local")
-
 	// Reject non-HTTPS URLs for non-local hosts
-	if strings.HasPrefix(baseURL, "http://") && !isLocal {
+	if strings.HasPrefix(baseURL, "http://") && !localurl.IsLocal(baseURL) {
 		return nil, fmt.Errorf("insecure URL not allowed: %s (use HTTPS for non-local hosts)", baseURL)
 	}
 
@@ -63,28 +106,40 @@ func NewClient(baseURL, org string, opts ...ClientOption) (*Client, error) {
 		return nil, fmt.Errorf("invalid organization name: %s (must be alphanumeric, hyphens, or underscores)", org)
 	}
 
+	transport := newTransport()
 	c := &Client{
 		baseURL: baseURL,
 		org:     org,
 		httpClient: &http.Client{
-			Timeout: 5 * time.Second,
+			Transport: transport,
 		},
-		retries: 3,
+		transport: transport,
+		userAgent: DefaultUserAgent,
+		retries:   3,
+		backoff:   DefaultBackoff,
 		// 20 req/sec with burst of 50
 		rateLimiter: rate.NewLimiter(rate.Limit(20), 50),
 	}
 
+	DefaultTimeouts.apply(c)
+
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	metrics.RateLimiterLimit.Set(float64(c.rateLimiter.Limit()))
+	metrics.RateLimiterBurst.Set(float64(c.rateLimiter.Burst()))
 
 	return c, nil
 }
 
-// WithTimeout sets the HTTP client timeout in seconds.
+// WithTimeout sets the timeout of each attempt of a request in
+// seconds. See WithTimeouts to set the other timeouts.
 func WithTimeout(seconds int) ClientOption {
 	return func(c *Client) {
-		c.httpClient.Timeout = time.Duration(seconds) * time.Second
+		c.attemptTimeout = time.Duration(seconds) * time.Second
 	}
 }
 
@@ -95,37 +150,146 @@ func WithRetries(retries int) ClientOption {
 	}
 }
 
+// WithBackoff sets the backoff between retries of failed requests. An
+// invalid backoff makes NewClient fail.
+func WithBackoff(b Backoff) ClientOption {
+	return func(c *Client) {
+		if err := b.Validate(); err != nil {
+			c.err = err
+			return
+		}
+		c.backoff = b
+	}
+}
+
 // WithAPIToken sets the API token for Bearer authentication.
 func WithAPIToken(token string) ClientOption {
 	return func(c *Client) {
-		c.apiToken = token
+		if token != "" {
+			c.setAuth(StaticToken(token), authPriorityToken)
+		}
+	}
+}
+
+// WithAPITokenFile reads the API token for Bearer authentication
+// from the file at path. The file is read again every 10 seconds, so
+// rotated tokens are picked up without restarting. It takes precedence
+// over WithAPIToken.
+func WithAPITokenFile(path string) ClientOption {
+	return func(c *Client) {
+		f, err := newTokenFile(path)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.setAuth(f, authPriorityTokenFile)
 	}
 }
 
-// WithGHApp configures a GitHub app to use for authentication.
-// If provided values are invalid, this will panic.
-// If an API token is also set, the GitHub App will take precedence.
+// WithTokenExchange authenticates requests with API tokens obtained
+// by exchanging the projected service account token at tokenPath, an
+// OIDC token issued by the cluster, at the token exchange endpoint
+// url. The service account token is sent as a bearer token, and the
+// endpoint responds with an API token and its expiry. It takes
+// precedence over API tokens, but not over a GitHub App.
+func WithTokenExchange(url, tokenPath string) ClientOption {
+	return func(c *Client) {
+		if !strings.HasPrefix(url, "https://") && !localurl.IsLocal(url) {
+			c.err = fmt.Errorf("insecure or invalid token exchange URL: %s (use HTTPS for non-local hosts)", url)
+			return
+		}
+		if tokenPath == "" {
+			tokenPath = DefaultServiceAccountTokenPath
+		}
+		c.setAuth(&tokenExchange{
+			url:       url,
+			tokenPath: tokenPath,
+			client:    c.httpClient,
+		}, authPriorityExchange)
+	}
+}
+
+// WithTokenFunc authenticates requests with the bearer tokens returned
+// by fn, e.g. tokens read from a secrets manager. fn is called for
+// each request and is expected to cache the token. It takes precedence
+// over API tokens, but not over a GitHub App or token exchange.
+func WithTokenFunc(fn func(context.Context) (string, error)) ClientOption {
+	return func(c *Client) {
+		c.setAuth(AuthFunc(fn), authPriorityTokenFunc)
+	}
+}
+
+// WithGHApp configures a GitHub app to use for authentication, with
+// the private key read from the file at pk. Invalid IDs or keys make
+// NewClient fail. If an API token is also set, the GitHub App will
+// take precedence.
 func WithGHApp(id, installID, pk string) ClientOption {
 	return func(c *Client) {
-		pid, err := strconv.Atoi(id)
+		pid, piid, err := parseGHAppIDs(id, installID)
 		if err != nil {
-			panic(err)
+			c.err = err
+			return
 		}
-		piid, err := strconv.Atoi(installID)
+		transport, err := ghinstallation.NewKeyFromFile(http.DefaultTransport, pid, piid, pk)
+		if err != nil {
+			c.err = fmt.Errorf("invalid GitHub App private key %s: %w", pk, err)
+			return
+		}
+		c.setAuth(ghAppAuth{transport: transport}, authPriorityGHApp)
+	}
+}
+
+// WithClientCertificate authenticates requests with the client
+// certificate and key in the PEM files, for endpoints requiring
+// mutual TLS. The files are read once, when the client is created.
+func WithClientCertificate(certFile, keyFile string) ClientOption {
+	return func(c *Client) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			c.err = fmt.Errorf("failed to load client certificate: %w", err)
+			return
+		}
+		c.transport.TLSClientConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+}
+
+// WithGHAppKey configures a GitHub app to use for authentication,
+// with the PEM encoded private key passed directly, e.g. from an
+// environment variable set from a Secret. Invalid IDs or keys make
+// NewClient fail. If an API token is also set, the GitHub App will
+// take precedence.
+func WithGHAppKey(id, installID string, pk []byte) ClientOption {
+	return func(c *Client) {
+		pid, piid, err := parseGHAppIDs(id, installID)
 		if err != nil {
-			panic(err)
+			c.err = err
+			return
 		}
-		c.transport, err = ghinstallation.NewKeyFromFile(
-			http.DefaultTransport,
-			int64(pid),
-			int64(piid),
-			pk)
+		transport, err := ghinstallation.New(http.DefaultTransport, pid, piid, pk)
 		if err != nil {
-			panic(err)
+			c.err = fmt.Errorf("invalid GitHub App private key: %w", err)
+			return
 		}
+		c.setAuth(ghAppAuth{transport: transport}, authPriorityGHApp)
 	}
 }
 
+// parseGHAppIDs parses the GitHub App and installation IDs.
+func parseGHAppIDs(id, installID string) (int64, int64, error) {
+	pid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid GitHub App ID %q: %w", id, err)
+	}
+	piid, err := strconv.ParseInt(installID, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid GitHub App installation ID %q: %w", installID, err)
+	}
+	return pid, piid, nil
+}
+
 // WithRateLimiter sets a custom rate limiter for API calls.
 func WithRateLimiter(rps float64, burst int) ClientOption {
 	return func(c *Client) {
@@ -133,7 +297,100 @@ func WithRateLimiter(rps float64, burst int) ClientOption {
 	}
 }
 
-// ClientError represents a client error that can not be retried.
+// WithFieldMapping sets the field mapping used when serializing
+// records, for backends expecting other field names than the GitHub
+// API.
+func WithFieldMapping(m *FieldMapping) ClientOption {
+	return func(c *Client) {
+		c.fields = m
+	}
+}
+
+// WithoutAPI makes the client accept posted records without sending
+// them to the API, and fail to list records with ErrNoAPI. It is used
+// when the records are only delivered to sinks, e.g. by clusters that
+// can't reach the API and relay them through a queue.
+func WithoutAPI() ClientOption {
+	return func(c *Client) {
+		c.noAPI = true
+	}
+}
+
+// Reachable returns false if the last request to the API failed
+// without getting a response (e.g. connection refused or timeout).
+// Any response, including error statuses, counts as reachable.
+func (c *Client) Reachable() bool {
+	return !c.unreachable.Load()
+}
+
+// PausedUntil returns the time until which requests are held back
+// because the API rate limited the client. It is in the past when the
+// client is not paused.
+func (c *Client) PausedUntil() time.Time {
+	return time.Unix(0, c.pausedUntil.Load())
+}
+
+// pause holds back all requests for d. Overlapping pauses are merged,
+// the latest end wins.
+func (c *Client) pause(d time.Duration) {
+	until := time.Now().Add(min(d, maxRateLimitPause)).UnixNano()
+	for {
+		cur := c.pausedUntil.Load()
+		if cur >= until || c.pausedUntil.CompareAndSwap(cur, until) {
+			return
+		}
+	}
+}
+
+// waitPause blocks until the client is no longer paused.
+func (c *Client) waitPause(ctx context.Context) error {
+	for {
+		d := time.Until(c.PausedUntil())
+		if d <= 0 {
+			return nil
+		}
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return fmt.Errorf("context cancelled while rate limited: %w", ctx.Err())
+		}
+	}
+}
+
+// rateLimitDelay returns how long the API asked the client to wait,
+// if resp is a rate limit response. Primary rate limits are reported
+// with X-RateLimit-Remaining and X-RateLimit-Reset, secondary rate
+// limits with Retry-After or only in the response body.
+func rateLimitDelay(resp *http.Response, body []byte, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return 0, false
+	}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return max(t.Sub(now), 0), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil {
+			return max(time.Unix(reset, 0).Sub(now), 0), true
+		}
+	}
+
+	if bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit")) {
+		return secondaryRateLimitPause, true
+	}
+
+	return 0, false
+}
+
+// ClientError represents a client error that can not be retried. The
+// wrapped error is an *APIError if the API responded.
 type ClientError struct {
 	err error
 }
@@ -148,109 +405,295 @@ func (c *ClientError) Unwrap() error {
 
 // PostOne posts a single deployment record to the GitHub deployment
 // records API.
-func (c *Client) PostOne(ctx context.Context, record *DeploymentRecord) error {
+func (c *Client) PostOne(ctx context.Context, record *DeploymentRecord) (err error) {
+	ctx, span := startSpan(ctx, "PostOne")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	if record == nil {
 		return errors.New("record cannot be nil")
 	}
 
-	// Wait for rate limiter
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return fmt.Errorf("rate limiter wait failed: %w", err)
+	url := fmt.Sprintf("%s/orgs/%s/artifacts/metadata/deployment-record", c.baseURL, c.org)
+
+	body, err := c.fields.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/orgs/%s/artifacts/metadata/deployment-record", c.baseURL, c.org)
+	return c.post(ctx, url, body, record.IdempotencyKey())
+}
 
-	body, err := json.Marshal(record)
+// batchBody is the request body for the batch deployment records API.
+type batchBody struct {
+	Records []json.RawMessage `json:"records"`
+}
+
+// PostBatch posts multiple deployment records in a single request to
+// the batch deployment records API. The records are accepted or
+// rejected as a whole.
+func (c *Client) PostBatch(ctx context.Context, records []*DeploymentRecord) (err error) {
+	ctx, span := startSpan(ctx, "PostBatch", attribute.Int("batch_size", len(records)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if len(records) == 0 {
+		return errors.New("records cannot be empty")
+	}
+
+	url := fmt.Sprintf("%s/orgs/%s/artifacts/metadata/deployment-records", c.baseURL, c.org)
+
+	batch := batchBody{
+		Records: make([]json.RawMessage, 0, len(records)),
+	}
+	for _, record := range records {
+		if record == nil {
+			return errors.New("record cannot be nil")
+		}
+		b, err := c.fields.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
+		}
+		batch.Records = append(batch.Records, b)
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	return c.post(ctx, url, body, batchIdempotencyKey(records))
+}
+
+// PostEnvironment posts a single environment record to the
+// environment records API.
+func (c *Client) PostEnvironment(ctx context.Context, record *EnvironmentRecord) (err error) {
+	ctx, span := startSpan(ctx, "PostEnvironment")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if record == nil {
+		return errors.New("record cannot be nil")
+	}
+
+	url := fmt.Sprintf("%s/orgs/%s/artifacts/metadata/environment-record", c.baseURL, c.org)
+
+	body, err := c.fields.Marshal(record)
 	if err != nil {
 		return fmt.Errorf("failed to marshal record: %w", err)
 	}
 
+	return c.post(ctx, url, body, record.IdempotencyKey())
+}
+
+// startSpan starts a client span for an API call.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// post posts the JSON body to url, retrying recoverable failures. The
+// idempotency key, if set, is sent with each attempt.
+func (c *Client) post(ctx context.Context, url string, body []byte, idempotencyKey string) error {
+	if c.noAPI {
+		return nil
+	}
+	var header http.Header
+	if idempotencyKey != "" {
+		header = http.Header{IdempotencyKeyHeader: {idempotencyKey}}
+	}
+	_, err := c.do(ctx, http.MethodPost, url, body, header)
+	return err
+}
+
+// apiResponse is a successful response of the API.
+type apiResponse struct {
+	header http.Header
+	body   []byte
+}
+
+// do sends a request with the JSON body, if any, and the additional
+// header to url, retrying recoverable failures. The trace context of
+// ctx is propagated to the API, and the attempts are recorded on its
+// span. The post metrics are only updated for POST requests.
+func (c *Client) do(ctx context.Context, method, url string, body []byte, header http.Header) (*apiResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	isPost := method == http.MethodPost
+
+	// Wait for rate limiter
+	waitStart := time.Now()
+	err := c.rateLimiter.Wait(ctx)
+	metrics.RateLimiterWaitTimer.Observe(time.Since(waitStart).Seconds())
+	metrics.RateLimiterTokens.Set(c.rateLimiter.Tokens())
+	if err != nil {
+		return nil, fmt.Errorf("rate limiter wait failed: %w", err)
+	}
+
 	bodyReader := bytes.NewReader(body)
 
 	var lastErr error
+	var rateLimited bool
 	// The first attempt is not a retry!
 	for attempt := range c.retries + 1 {
-		if attempt > 0 {
-			backoff := time.Duration(math.Pow(2,
-				float64(attempt))) * 100 * time.Millisecond
-			//nolint:gosec
-			jitter := time.Duration(rand.Int64N(50)) * time.Millisecond
-			delay := backoff + jitter
-
-			if delay > 5*time.Second {
-				delay = 5 * time.Second
-			}
-
+		// Rate limited attempts wait for the pause instead
+		if attempt > 0 && !rateLimited {
 			// Wait with context cancellation support
 			select {
-			case <-time.After(delay):
+			case <-time.After(c.backoff.delay(attempt)):
 			case <-ctx.Done():
-				return fmt.Errorf("context cancelled during retry backoff: %w", ctx.Err())
+				return nil, fmt.Errorf("context cancelled during retry backoff: %w", ctx.Err())
 			}
 		}
+		rateLimited = false
+
+		if err := c.waitPause(ctx); err != nil {
+			return nil, err
+		}
 
 		// Reset reader position for retries
 		bodyReader.Reset(body)
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bodyReader)
+		req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
+			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
-		req.Header.Set("Content-Type", "application/json")
-		if c.transport != nil {
-			// Token is thread safe, so no need for external
-			// locking
-			tok, err := c.transport.Token(ctx)
+		maps.Copy(req.Header, c.header)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", c.userAgent)
+		maps.Copy(req.Header, header)
+		propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+		if c.auth != nil {
+			tok, err := c.auth.Token(ctx)
 			if err != nil {
-				return fmt.Errorf("failed to get access token: %w", err)
+				return nil, fmt.Errorf("failed to get access token: %w", err)
 			}
 			req.Header.Set("Authorization", "Bearer "+tok)
-		} else if c.apiToken != "" {
-			req.Header.Set("Authorization", "Bearer "+c.apiToken)
 		}
 
+		release, err := c.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
 		start := time.Now()
-		resp, err := c.httpClient.Do(req)
+		resp, respBody, err := c.send(req)
 		dur := time.Since(start)
-		metrics.PostDeploymentRecordTimer.Observe(dur.Seconds())
+		release()
+		if isPost {
+			metrics.ObserveWithTrace(ctx, metrics.PostDeploymentRecordTimer, dur.Seconds())
+		}
+		span.SetAttributes(attribute.Int("http.request.resend_count", attempt))
 		if err != nil {
-			lastErr = fmt.Errorf("post request failed: %w", err)
+			lastErr = fmt.Errorf("%s request failed: %w", strings.ToLower(method), err)
+			if ctx.Err() == nil {
+				c.unreachable.Store(true)
+			}
 
 			slog.Warn("recoverable error, re-trying",
 				"attempt", attempt,
 				"retries", c.retries,
 				"error", lastErr)
-			metrics.PostDeploymentRecordSoftFail.Inc()
+			if isPost {
+				metrics.PostDeploymentRecordSoftFail.Inc()
+			}
 			continue
 		}
 
-		// Drain and close response body to enable connection reuse
-		_, _ = io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
+		c.unreachable.Store(false)
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			metrics.PostDeploymentRecordOk.Inc()
-			return nil
+			if isPost {
+				metrics.PostDeploymentRecordOk.Inc()
+			}
+			return &apiResponse{header: resp.Header, body: respBody}, nil
 		}
 
-		lastErr = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		apiErr := parseAPIError(resp.StatusCode, respBody)
+		lastErr = apiErr
+
+		// Pause all requests for as long as the API asks us to
+		if delay, ok := rateLimitDelay(resp, respBody, time.Now()); ok {
+			rateLimited = true
+			c.pause(delay)
+			if isPost {
+				metrics.PostDeploymentRecordRateLimited.Inc()
+			}
+			slog.Warn("rate limited by the API, pausing requests",
+				"attempt", attempt,
+				"status", resp.StatusCode,
+				"paused_until", c.PausedUntil(),
+			)
+			continue
+		}
 
 		// Don't retry on client errors (4xx) except for 429
 		// (rate limit)
 		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != 429 {
-			metrics.PostDeploymentRecordClientError.Inc()
+			if isPost {
+				metrics.PostDeploymentRecordClientError.WithLabelValues(
+					strconv.Itoa(resp.StatusCode), apiErr.Code()).Inc()
+			}
 			slog.Warn("client error, aborting",
 				"attempt", attempt,
+				"status", resp.StatusCode,
+				"message", apiErr.Message,
+				"code", apiErr.Code(),
 				"error", lastErr)
-			return &ClientError{err: lastErr}
+			return nil, &ClientError{err: lastErr}
+		}
+		if isPost {
+			metrics.PostDeploymentRecordSoftFail.Inc()
 		}
-		metrics.PostDeploymentRecordSoftFail.Inc()
 	}
 
-	metrics.PostDeploymentRecordHardFail.Inc()
+	if isPost {
+		metrics.PostDeploymentRecordHardFail.Inc()
+	}
 	slog.Error("all retries exhausted",
 		"count", c.retries,
 		"error", lastErr)
-	return fmt.Errorf("all retries exhausted: %w", lastErr)
+	return nil, fmt.Errorf("all retries exhausted: %w", lastErr)
+}
+
+// send sends one attempt of the request, bounded by the attempt
+// timeout, and returns the response with its body read. Each attempt
+// gets its own deadline, so a slow attempt doesn't use up the time of
+// the retries.
+func (c *Client) send(req *http.Request) (*http.Response, []byte, error) {
+	if c.attemptTimeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), c.attemptTimeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	var resp *http.Response
+	var err error
+	if c.chaos != nil {
+		resp, err = c.chaos.inject(req.Context())
+	}
+	if resp == nil && err == nil {
+		resp, err = c.httpClient.Do(req)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read (up to maxResponseBytes of) and drain the response body
+	// to enable connection reuse
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp, body, nil
 }
@@ -1,9 +1,27 @@
 package deploymentrecord
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/github/deployment-tracker/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNewClient(t *testing.T) {
@@ -134,6 +152,13 @@ func TestNewClient(t *testing.T) {
 			wantErr:     true,
 			errContains: "insecure URL not allowed",
 		},
+		{
+			name:        "HTTP with cluster local query rejected",
+			baseURL:     "http://attacker/?x=.svc.cluster.local",
+			org:         "my-org",
+			wantErr:     true,
+			errContains: "insecure URL not allowed",
+		},
 	}
 
 	for _, tt := range tests {
@@ -179,8 +204,8 @@ func TestNewClientWithOptions(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if client.httpClient.Timeout != 30*time.Second {
-			t.Errorf("timeout = %v, want %v", client.httpClient.Timeout, 30*time.Second)
+		if client.attemptTimeout != 30*time.Second {
+			t.Errorf("timeout = %v, want %v", client.attemptTimeout, 30*time.Second)
 		}
 	})
 
@@ -201,8 +226,39 @@ func TestNewClientWithOptions(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if client.apiToken != "test-token" {
-			t.Errorf("apiToken = %q, want %q", client.apiToken, "test-token")
+		if client.auth != StaticToken("test-token") {
+			t.Errorf("auth = %v, want %q", client.auth, "test-token")
+		}
+	})
+
+	t.Run("WithGHApp invalid values", func(t *testing.T) {
+		for _, tt := range []struct{ id, installID, pk, wantErr string }{
+			{"app", "456", "key.pem", "invalid GitHub App ID"},
+			{"123", "install", "key.pem", "invalid GitHub App installation ID"},
+			{"123", "456", filepath.Join(t.TempDir(), "missing.pem"), "invalid GitHub App private key"},
+		} {
+			_, err := NewClient("https://api.github.com", "my-org",
+				WithGHApp(tt.id, tt.installID, tt.pk))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewClient() error = %v, want %q", err, tt.wantErr)
+			}
+		}
+	})
+
+	t.Run("WithRateLimiter option", func(t *testing.T) {
+		client, err := NewClient("https://api.github.com", "my-org",
+			WithRateLimiter(5, 10))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if client.rateLimiter.Limit() != 5 || client.rateLimiter.Burst() != 10 {
+			t.Errorf("rate limiter = %v/%d, want 5/10", client.rateLimiter.Limit(), client.rateLimiter.Burst())
+		}
+		if got := testutil.ToFloat64(metrics.RateLimiterLimit); got != 5 {
+			t.Errorf("rate limiter limit metric = %v, want 5", got)
+		}
+		if got := testutil.ToFloat64(metrics.RateLimiterBurst); got != 10 {
+			t.Errorf("rate limiter burst metric = %v, want 10", got)
 		}
 	})
 
@@ -214,18 +270,182 @@ func TestNewClientWithOptions(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if client.httpClient.Timeout != 60*time.Second {
-			t.Errorf("timeout = %v, want %v", client.httpClient.Timeout, 60*time.Second)
+		if client.attemptTimeout != 60*time.Second {
+			t.Errorf("timeout = %v, want %v", client.attemptTimeout, 60*time.Second)
 		}
 		if client.retries != 10 {
 			t.Errorf("retries = %d, want %d", client.retries, 10)
 		}
-		if client.apiToken != "multi-token" {
-			t.Errorf("apiToken = %q, want %q", client.apiToken, "multi-token")
+		if client.auth != StaticToken("multi-token") {
+			t.Errorf("auth = %v, want %q", client.auth, "multi-token")
+		}
+	})
+}
+
+// writeClientCertificate writes a self-signed client certificate and
+// its key to PEM files, returning their paths and the certificate.
+func writeClientCertificate(t *testing.T) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "deployment-tracker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	certFile, keyFile, cert := writeClientCertificate(t)
+
+	var subject string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		subject = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+
+	t.Run("with certificate", func(t *testing.T) {
+		c, err := NewClient(srv.URL, "my-org", WithClientCertificate(certFile, keyFile))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		c.httpClient.Transport.(*http.Transport).TLSClientConfig.RootCAs = rootCAs
+
+		if err := c.PostOne(context.Background(), record); err != nil {
+			t.Fatalf("PostOne() error = %v", err)
+		}
+		if subject != "deployment-tracker" {
+			t.Errorf("client certificate subject = %q, want %q", subject, "deployment-tracker")
+		}
+	})
+
+	t.Run("without certificate", func(t *testing.T) {
+		c, err := NewClient(srv.URL, "my-org", WithRetries(0))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		c.httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+		}
+
+		if err := c.PostOne(context.Background(), record); err == nil {
+			t.Error("PostOne() expected an error without client certificate")
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := NewClient(srv.URL, "my-org",
+			WithClientCertificate(certFile, filepath.Join(t.TempDir(), "missing.key")))
+		if err == nil {
+			t.Error("NewClient() expected an error for a missing key")
 		}
 	})
 }
 
+func TestWithGHAppKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	pk := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tests := []struct {
+		name      string
+		id        string
+		installID string
+		pk        []byte
+		wantErr   string
+	}{
+		{
+			name:      "valid",
+			id:        "123",
+			installID: "456",
+			pk:        pk,
+		},
+		{
+			name:      "invalid app id",
+			id:        "app",
+			installID: "456",
+			pk:        pk,
+			wantErr:   "invalid GitHub App ID",
+		},
+		{
+			name:      "invalid installation id",
+			id:        "123",
+			installID: "",
+			pk:        pk,
+			wantErr:   "invalid GitHub App installation ID",
+		},
+		{
+			name:      "invalid key",
+			id:        "123",
+			installID: "456",
+			pk:        []byte("not a key"),
+			wantErr:   "invalid GitHub App private key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient("https://api.github.com", "my-org", WithGHAppKey(tt.id, tt.installID, tt.pk))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("NewClient() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+			if _, ok := c.auth.(ghAppAuth); !ok {
+				t.Errorf("auth = %T, want ghAppAuth", c.auth)
+			}
+		})
+	}
+}
+
 func TestValidOrgPattern(t *testing.T) {
 	validOrgs := []string{
 		"github",
@@ -264,3 +484,152 @@ func TestValidOrgPattern(t *testing.T) {
 		}
 	}
 }
+
+func TestPostPropagatesTraceContext(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	if err := c.PostOne(ctx, record); err != nil {
+		t.Fatalf("PostOne() error = %v", err)
+	}
+
+	expected := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if traceparent != expected {
+		t.Errorf("traceparent = %q, expected %q", traceparent, expected)
+	}
+}
+
+func TestRateLimitDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		body     string
+		expected time.Duration
+		limited  bool
+	}{
+		{
+			name:     "retry after seconds",
+			status:   http.StatusTooManyRequests,
+			header:   http.Header{"Retry-After": {"30"}},
+			expected: 30 * time.Second,
+			limited:  true,
+		},
+		{
+			name:     "retry after date",
+			status:   http.StatusForbidden,
+			header:   http.Header{"Retry-After": {now.Add(time.Minute).UTC().Format(http.TimeFormat)}},
+			expected: time.Minute,
+			limited:  true,
+		},
+		{
+			name:   "rate limit reset",
+			status: http.StatusForbidden,
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"0"},
+				"X-Ratelimit-Reset":     {"1700000120"},
+			},
+			expected: 2 * time.Minute,
+			limited:  true,
+		},
+		{
+			name:   "rate limit reset in the past",
+			status: http.StatusTooManyRequests,
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"0"},
+				"X-Ratelimit-Reset":     {"1699999999"},
+			},
+			expected: 0,
+			limited:  true,
+		},
+		{
+			name:     "secondary rate limit in body",
+			status:   http.StatusForbidden,
+			body:     `{"message":"You have exceeded a secondary rate limit."}`,
+			expected: secondaryRateLimitPause,
+			limited:  true,
+		},
+		{
+			name:   "remaining requests",
+			status: http.StatusForbidden,
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"10"},
+				"X-Ratelimit-Reset":     {"1700000120"},
+			},
+		},
+		{
+			name:   "too many requests without headers",
+			status: http.StatusTooManyRequests,
+		},
+		{
+			name:   "server error",
+			status: http.StatusServiceUnavailable,
+			header: http.Header{"Retry-After": {"30"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: tt.header}
+			if resp.Header == nil {
+				resp.Header = http.Header{}
+			}
+			delay, limited := rateLimitDelay(resp, []byte(tt.body), now)
+			if limited != tt.limited {
+				t.Fatalf("limited = %v, expected %v", limited, tt.limited)
+			}
+			if delay != tt.expected {
+				t.Errorf("delay = %v, expected %v", delay, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPostPausesWhenRateLimited(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org", WithRetries(1))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	start := time.Now()
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	if err := c.PostOne(context.Background(), record); err != nil {
+		t.Fatalf("PostOne() error = %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("calls = %d, expected 2", calls)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("elapsed = %v, expected the post to pause for at least 1s", elapsed)
+	}
+}
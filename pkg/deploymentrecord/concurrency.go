@@ -0,0 +1,48 @@
+package deploymentrecord
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/metrics"
+)
+
+// WithMaxConcurrency limits the number of requests in flight to the
+// API to n, across all goroutines using the client, e.g. to stay below
+// a cap of concurrent connections of the API. It is independent of
+// the rate limiter. Zero means no limit, a negative n makes NewClient
+// fail.
+func WithMaxConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		if n < 0 {
+			c.err = fmt.Errorf("invalid maximum concurrency %d, must not be negative", n)
+			return
+		}
+		c.slots = nil
+		if n > 0 {
+			c.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// acquire waits for a free request slot, and returns the function
+// releasing it once the request is done.
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.slots != nil {
+		start := time.Now()
+		select {
+		case c.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("context cancelled waiting for a request slot: %w", ctx.Err())
+		}
+		metrics.ConcurrencyWaitTimer.Observe(time.Since(start).Seconds())
+	}
+	metrics.APIRequestsInFlight.Inc()
+	return func() {
+		metrics.APIRequestsInFlight.Dec()
+		if c.slots != nil {
+			<-c.slots
+		}
+	}, nil
+}
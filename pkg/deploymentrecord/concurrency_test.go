@@ -0,0 +1,74 @@
+package deploymentrecord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org", WithMaxConcurrency(2), WithRateLimiter(1000, 1000))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if err := c.PostOne(context.Background(), record); err != nil {
+				t.Errorf("PostOne() unexpected error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("max requests in flight = %d, want at most 2", got)
+	}
+	if len(c.slots) != 0 {
+		t.Errorf("%d request slots still held", len(c.slots))
+	}
+}
+
+func TestMaxConcurrencyContextCancelled(t *testing.T) {
+	c, err := NewClient("https://api.github.com", "my-org", WithMaxConcurrency(1))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	// Hold the only slot
+	release, err := c.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire() unexpected error: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.acquire(ctx); err == nil {
+		t.Error("acquire() expected error when the context is done")
+	}
+
+	if _, err := NewClient("https://api.github.com", "my-org", WithMaxConcurrency(-1)); err == nil {
+		t.Error("NewClient() with a negative concurrency expected error")
+	}
+}
@@ -0,0 +1,73 @@
+package deploymentrecord
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ConnPool tunes the connection pool of the HTTP transport of the
+// client. At a high request volume, the two idle connections per host
+// of the default transport make it open and close connections, and so
+// redo TLS handshakes, all the time.
+type ConnPool struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept
+	// open to the API.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long idle connections are kept open, 0
+	// for no limit.
+	IdleConnTimeout time.Duration
+	// ForceAttemptHTTP2 negotiates HTTP/2, multiplexing the requests
+	// over fewer connections.
+	ForceAttemptHTTP2 bool
+	// DisableKeepAlives opens a new connection for each request.
+	DisableKeepAlives bool
+}
+
+// DefaultConnPool keeps up to 20 idle connections open for 90s, and
+// negotiates HTTP/2.
+var DefaultConnPool = ConnPool{
+	MaxIdleConnsPerHost: 20,
+	IdleConnTimeout:     90 * time.Second,
+	ForceAttemptHTTP2:   true,
+}
+
+// Validate returns an error if the connection pool is invalid.
+func (p ConnPool) Validate() error {
+	switch {
+	case p.MaxIdleConnsPerHost < 0:
+		return fmt.Errorf("invalid maximum of idle connections %d, must not be negative", p.MaxIdleConnsPerHost)
+	case p.IdleConnTimeout < 0:
+		return fmt.Errorf("invalid idle connection timeout %s, must not be negative", p.IdleConnTimeout)
+	}
+	return nil
+}
+
+// apply sets the connection pool settings of the transport.
+func (p ConnPool) apply(t *http.Transport) {
+	t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+	t.MaxIdleConns = max(t.MaxIdleConns, p.MaxIdleConnsPerHost)
+	t.IdleConnTimeout = p.IdleConnTimeout
+	t.ForceAttemptHTTP2 = p.ForceAttemptHTTP2
+	t.DisableKeepAlives = p.DisableKeepAlives
+}
+
+// newTransport returns a clone of the default transport with the
+// default connection pool.
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	DefaultConnPool.apply(t)
+	return t
+}
+
+// WithConnPool sets the connection pool of the HTTP transport. An
+// invalid pool makes NewClient fail.
+func WithConnPool(p ConnPool) ClientOption {
+	return func(c *Client) {
+		if err := p.Validate(); err != nil {
+			c.err = err
+			return
+		}
+		p.apply(c.transport)
+	}
+}
@@ -0,0 +1,75 @@
+package deploymentrecord
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnPoolValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    ConnPool
+		wantErr bool
+	}{
+		{
+			name: "default",
+			pool: DefaultConnPool,
+		},
+		{
+			name: "no keep-alives",
+			pool: ConnPool{DisableKeepAlives: true},
+		},
+		{
+			name:    "negative idle connections",
+			pool:    ConnPool{MaxIdleConnsPerHost: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative idle timeout",
+			pool:    ConnPool{MaxIdleConnsPerHost: 10, IdleConnTimeout: -time.Second},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pool.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithConnPool(t *testing.T) {
+	c, err := NewClient("https://api.github.com", "my-org")
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	if c.transport.MaxIdleConnsPerHost != DefaultConnPool.MaxIdleConnsPerHost || !c.transport.ForceAttemptHTTP2 {
+		t.Errorf("default transport = %d idle connections, HTTP/2 %v, want the default pool",
+			c.transport.MaxIdleConnsPerHost, c.transport.ForceAttemptHTTP2)
+	}
+
+	pool := ConnPool{
+		MaxIdleConnsPerHost: 200,
+		IdleConnTimeout:     time.Minute,
+		DisableKeepAlives:   true,
+	}
+	c, err = NewClient("https://api.github.com", "my-org", WithConnPool(pool))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	tr := c.transport
+	if tr.MaxIdleConnsPerHost != 200 || tr.MaxIdleConns < 200 || tr.IdleConnTimeout != time.Minute ||
+		tr.ForceAttemptHTTP2 || !tr.DisableKeepAlives {
+		t.Errorf("transport = %+v, want the pool %+v", tr, pool)
+	}
+	if c.httpClient.Transport != tr {
+		t.Error("HTTP client doesn't use the tuned transport")
+	}
+
+	if _, err := NewClient("https://api.github.com", "my-org", WithConnPool(ConnPool{MaxIdleConnsPerHost: -1})); err == nil {
+		t.Error("NewClient() with an invalid pool expected error")
+	}
+}
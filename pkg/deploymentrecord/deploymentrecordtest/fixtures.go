@@ -0,0 +1,72 @@
+package deploymentrecordtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// Fixture defaults, shared by all generated records.
+const (
+	FixtureCluster             = "test-cluster"
+	FixtureLogicalEnvironment  = "test"
+	FixturePhysicalEnvironment = "test-region"
+	FixtureNamespace           = "default"
+)
+
+// fixtureTime is the observation time of generated records, fixed so
+// fixtures are reproducible.
+var fixtureTime = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Fixture returns the i-th generated deployed record, as the
+// controller would post it for the app container of the Deployment
+// app-<i> in FixtureNamespace, with the default template. The same i
+// always returns the same record, and different ones differ in image,
+// digest and deployment name.
+func Fixture(i int) *deploymentrecord.DeploymentRecord {
+	app := "app-" + strconv.Itoa(i)
+	record := deploymentrecord.NewDeploymentRecord(
+		"ghcr.io/example/"+app,
+		FixtureDigest(i),
+		"v1.0."+strconv.Itoa(i),
+		FixtureLogicalEnvironment,
+		FixturePhysicalEnvironment,
+		FixtureCluster,
+		deploymentrecord.StatusDeployed,
+		FixtureNamespace+"/"+app+"/app",
+	)
+	record.TrackerVersion = "dev"
+	record.KubernetesVersion = "v1.33.0"
+	record.ObservedAt = fixtureTime.Add(time.Duration(i) * time.Second)
+	record.Source = "deployment-tracker/dev"
+	record.PodUID = fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+	record.Revision = "1"
+	return record
+}
+
+// Fixtures returns the first n generated records, see Fixture.
+func Fixtures(n int) []*deploymentrecord.DeploymentRecord {
+	records := make([]*deploymentrecord.DeploymentRecord, n)
+	for i := range records {
+		records[i] = Fixture(i)
+	}
+	return records
+}
+
+// FixtureDigest returns the image digest of the i-th generated record.
+func FixtureDigest(i int) string {
+	sum := sha256.Sum256([]byte("fixture-" + strconv.Itoa(i)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Decommissioned returns a copy of the record with the decommissioned
+// status.
+func Decommissioned(record *deploymentrecord.DeploymentRecord) *deploymentrecord.DeploymentRecord {
+	res := *record
+	res.Status = deploymentrecord.StatusDecommissioned
+	return &res
+}
@@ -0,0 +1,375 @@
+// Package deploymentrecordtest provides an in-memory fake of the
+// deployment records API and record fixtures, to test tooling against
+// the payloads the controller emits without a real API.
+package deploymentrecordtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+const (
+	// defaultPageSize is the page size of list requests without
+	// per_page.
+	defaultPageSize = 30
+	// maxBodyBytes is the maximum size of a request body.
+	maxBodyBytes = 10 << 20
+)
+
+// Server is an in-memory fake of the deployment records API, serving
+// the record, batch, list and environment record endpoints of any
+// organization. Posted records are kept in order; a record replaces
+// the previous one of the same deployment name and digest in list
+// responses, as in the API.
+//
+// Failures and latency can be injected to test retries and timeouts.
+type Server struct {
+	*httptest.Server
+
+	fields *deploymentrecord.FieldMapping
+
+	mu           sync.Mutex
+	records      []*deploymentrecord.DeploymentRecord
+	environments []*deploymentrecord.EnvironmentRecord
+	requests     int
+	latency      time.Duration
+	failNext     int
+	failStatus   int
+	failRate     float64
+	failRateCode int
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithFieldMapping sets the field mapping of the request and response
+// bodies, which must match that of the clients under test. The
+// canonical snake_case field names are used by default.
+func WithFieldMapping(m *deploymentrecord.FieldMapping) Option {
+	return func(s *Server) {
+		s.fields = m
+	}
+}
+
+// WithLatency delays every response by d.
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// WithFailureRate fails the fraction rate (0 to 1) of requests, chosen
+// at random, with the HTTP status code.
+func WithFailureRate(rate float64, status int) Option {
+	return func(s *Server) {
+		s.failRate = rate
+		s.failRateCode = status
+	}
+}
+
+// NewServer starts a fake deployment records API. The caller must
+// Close it when done. Clients are pointed at the server's URL, e.g.
+// deploymentrecord.NewClient(srv.URL, "my-org").
+func NewServer(opts ...Option) *Server {
+	fields, _ := deploymentrecord.NewFieldMapping(deploymentrecord.ProfileDefault, "")
+	s := &Server{
+		fields: fields,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Records returns the deployment records posted, in order, including
+// those of batches.
+func (s *Server) Records() []*deploymentrecord.DeploymentRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.records)
+}
+
+// Find returns the posted deployment records with the deployment name
+// and status, in order. Empty arguments match all records.
+func (s *Server) Find(deploymentName, status string) []*deploymentrecord.DeploymentRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []*deploymentrecord.DeploymentRecord
+	for _, r := range s.records {
+		if (deploymentName == "" || r.DeploymentName == deploymentName) &&
+			(status == "" || r.Status == status) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// EnvironmentRecords returns the environment records posted, in
+// order.
+func (s *Server) EnvironmentRecords() []*deploymentrecord.EnvironmentRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.environments)
+}
+
+// Requests returns the number of requests served, including failed
+// ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// FailNext fails the next n requests with the HTTP status code.
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+	s.failStatus = status
+}
+
+// SetLatency delays every following response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Reset drops the stored records and pending failures, and the
+// request count.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = nil
+	s.environments = nil
+	s.requests = 0
+	s.failNext = 0
+}
+
+// injectedFailure returns the status code of the failure to inject
+// into the request, or 0, and the latency to apply.
+func (s *Server) injectedFailure() (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.failNext > 0 {
+		s.failNext--
+		return s.failStatus, s.latency
+	}
+	if s.failRate > 0 && rand.Float64() < s.failRate {
+		return s.failRateCode, s.latency
+	}
+	return 0, s.latency
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	status, latency := s.injectedFailure()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		writeError(w, status, "injected failure")
+		return
+	}
+
+	_, endpoint, ok := strings.Cut(r.URL.Path, "/artifacts/metadata/")
+	if !ok || !strings.HasPrefix(r.URL.Path, "/orgs/") {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && endpoint == "deployment-record":
+		s.postRecords(w, r, false)
+	case r.Method == http.MethodPost && endpoint == "deployment-records":
+		s.postRecords(w, r, true)
+	case r.Method == http.MethodGet && endpoint == "deployment-records":
+		s.list(w, r)
+	case r.Method == http.MethodPost && endpoint == "environment-record":
+		s.postEnvironment(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// postRecords stores the record, or the records of the batch.
+func (s *Server) postRecords(w http.ResponseWriter, r *http.Request, batch bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	raws := []json.RawMessage{body}
+	if batch {
+		var b struct {
+			Records []json.RawMessage `json:"records"`
+		}
+		if err := json.Unmarshal(body, &b); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid batch: "+err.Error())
+			return
+		}
+		raws = b.Records
+	}
+
+	records := make([]*deploymentrecord.DeploymentRecord, 0, len(raws))
+	for _, raw := range raws {
+		var record deploymentrecord.DeploymentRecord
+		if err := s.fields.Unmarshal(raw, &record); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid record: "+err.Error())
+			return
+		}
+		if err := validate(&record); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		records = append(records, &record)
+	}
+
+	s.mu.Lock()
+	s.records = append(s.records, records...)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// postEnvironment stores the environment record.
+func (s *Server) postEnvironment(w http.ResponseWriter, r *http.Request) {
+	var record deploymentrecord.EnvironmentRecord
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodyBytes)).Decode(&record); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid record: "+err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.environments = append(s.environments, &record)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// list serves the latest record of each deployment name and digest
+// matching the filter of the query, paginated with Link headers.
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	perPage, _ := strconv.Atoi(q.Get("per_page"))
+	if perPage <= 0 {
+		perPage = defaultPageSize
+	}
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	var matches []*deploymentrecord.DeploymentRecord
+	for _, record := range s.latest() {
+		if matchesFilter(record, q.Get) {
+			matches = append(matches, record)
+		}
+	}
+
+	start := min((page-1)*perPage, len(matches))
+	end := min(start+perPage, len(matches))
+	resp := struct {
+		TotalCount        int               `json:"total_count"`
+		DeploymentRecords []json.RawMessage `json:"deployment_records"`
+	}{
+		TotalCount:        len(matches),
+		DeploymentRecords: make([]json.RawMessage, 0, end-start),
+	}
+	for _, record := range matches[start:end] {
+		b, err := s.fields.Marshal(record)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		resp.DeploymentRecords = append(resp.DeploymentRecords, b)
+	}
+
+	if end < len(matches) {
+		q.Set("page", strconv.Itoa(page+1))
+		next := s.URL + r.URL.Path + "?" + q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// latest returns the last record posted for each deployment name and
+// digest, in the order they were first posted.
+func (s *Server) latest() []*deploymentrecord.DeploymentRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := make(map[string]int)
+	var res []*deploymentrecord.DeploymentRecord
+	for _, record := range s.records {
+		key := record.DeploymentName + "@" + record.Digest
+		if i, ok := index[key]; ok {
+			res[i] = record
+			continue
+		}
+		index[key] = len(res)
+		res = append(res, record)
+	}
+	return res
+}
+
+// matchesFilter returns true if the record matches the filter query
+// parameters returned by get. Empty parameters match all records.
+func matchesFilter(record *deploymentrecord.DeploymentRecord, get func(string) string) bool {
+	for param, value := range map[string]string{
+		"name":                 record.Name,
+		"deployment_name":      record.DeploymentName,
+		"logical_environment":  record.LogicalEnvironment,
+		"physical_environment": record.PhysicalEnvironment,
+		"cluster":              record.Cluster,
+		"status":               record.Status,
+	} {
+		if want := get(param); want != "" && want != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validate returns an error if required fields of the record are
+// missing or its status is unknown.
+func validate(record *deploymentrecord.DeploymentRecord) error {
+	switch {
+	case record.Name == "":
+		return errors.New("name is required")
+	case record.Digest == "":
+		return errors.New("digest is required")
+	case record.DeploymentName == "":
+		return errors.New("deployment_name is required")
+	}
+	switch record.Status {
+	case deploymentrecord.StatusDeployed, deploymentrecord.StatusDecommissioned,
+		deploymentrecord.StatusPartiallyDeployed:
+		return nil
+	default:
+		return fmt.Errorf("invalid status: %q", record.Status)
+	}
+}
+
+// writeError writes an error response in the format of the GitHub
+// API.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -0,0 +1,252 @@
+package deploymentrecordtest
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// fastBackoff keeps retries of the tests short.
+var fastBackoff = deploymentrecord.Backoff{
+	Base:       time.Millisecond,
+	Multiplier: 1,
+	Max:        time.Millisecond,
+}
+
+func newTestClient(t *testing.T, srv *Server, opts ...deploymentrecord.ClientOption) *deploymentrecord.Client {
+	t.Helper()
+	opts = append([]deploymentrecord.ClientOption{deploymentrecord.WithBackoff(fastBackoff)}, opts...)
+	client, err := deploymentrecord.NewClient(srv.URL, "my-org", opts...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestServerPostAndList(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	fixtures := Fixtures(5)
+	if err := client.PostOne(ctx, fixtures[0]); err != nil {
+		t.Fatalf("PostOne() error = %v", err)
+	}
+	if err := client.PostBatch(ctx, fixtures[1:]); err != nil {
+		t.Fatalf("PostBatch() error = %v", err)
+	}
+	if err := client.PostOne(ctx, Decommissioned(fixtures[2])); err != nil {
+		t.Fatalf("PostOne() error = %v", err)
+	}
+
+	if got := len(srv.Records()); got != 6 {
+		t.Fatalf("records = %d, expected 6", got)
+	}
+	posted := srv.Records()[0]
+	if posted.DeploymentName != fixtures[0].DeploymentName || posted.Digest != fixtures[0].Digest ||
+		!posted.ObservedAt.Equal(fixtures[0].ObservedAt) {
+		t.Errorf("posted record = %+v, expected %+v", posted, fixtures[0])
+	}
+	if got := srv.Find(fixtures[2].DeploymentName, ""); len(got) != 2 {
+		t.Errorf("records of %s = %d, expected 2", fixtures[2].DeploymentName, len(got))
+	}
+
+	// The decommission replaces the deployed record in list responses
+	deployed, err := client.List(ctx, deploymentrecord.ListFilter{Status: deploymentrecord.StatusDeployed})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(deployed) != 4 {
+		t.Errorf("deployed records = %d, expected 4", len(deployed))
+	}
+	records, err := client.Get(ctx, fixtures[2].DeploymentName)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Status != deploymentrecord.StatusDecommissioned {
+		t.Errorf("Get() = %+v, expected the decommissioned record", records)
+	}
+}
+
+func TestServerListPagination(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	// More records than fit on a page of the client
+	fixtures := Fixtures(250)
+	if err := client.PostBatch(ctx, fixtures); err != nil {
+		t.Fatalf("PostBatch() error = %v", err)
+	}
+
+	records, err := client.List(ctx, deploymentrecord.ListFilter{Cluster: FixtureCluster})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != len(fixtures) {
+		t.Fatalf("records = %d, expected %d", len(records), len(fixtures))
+	}
+	if srv.Requests() != 4 {
+		t.Errorf("requests = %d, expected 4 (1 batch, 3 pages)", srv.Requests())
+	}
+}
+
+func TestServerFailureInjection(t *testing.T) {
+	tests := []struct {
+		name     string
+		failNext int
+		status   int
+		retries  int
+		wantErr  bool
+		requests int
+	}{
+		{
+			name:     "retried server error",
+			failNext: 2,
+			status:   http.StatusBadGateway,
+			retries:  3,
+			requests: 3,
+		},
+		{
+			name:     "retries exhausted",
+			failNext: 5,
+			status:   http.StatusServiceUnavailable,
+			retries:  1,
+			wantErr:  true,
+			requests: 2,
+		},
+		{
+			name:     "client error",
+			failNext: 1,
+			status:   http.StatusBadRequest,
+			retries:  3,
+			wantErr:  true,
+			requests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+			client := newTestClient(t, srv, deploymentrecord.WithRetries(tt.retries))
+
+			srv.FailNext(tt.failNext, tt.status)
+			err := client.PostOne(context.Background(), Fixture(0))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PostOne() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if srv.Requests() != tt.requests {
+				t.Errorf("requests = %d, expected %d", srv.Requests(), tt.requests)
+			}
+			if want := !tt.wantErr; (len(srv.Records()) == 1) != want {
+				t.Errorf("records = %d, expected stored = %v", len(srv.Records()), want)
+			}
+		})
+	}
+}
+
+func TestServerFailureRate(t *testing.T) {
+	srv := NewServer(WithFailureRate(1, http.StatusInternalServerError))
+	defer srv.Close()
+	client := newTestClient(t, srv, deploymentrecord.WithRetries(0))
+
+	if err := client.PostOne(context.Background(), Fixture(0)); err == nil {
+		t.Error("PostOne() expected an error")
+	}
+	if len(srv.Records()) != 0 {
+		t.Errorf("records = %d, expected 0", len(srv.Records()))
+	}
+}
+
+func TestServerLatency(t *testing.T) {
+	srv := NewServer(WithLatency(200 * time.Millisecond))
+	defer srv.Close()
+	client := newTestClient(t, srv,
+		deploymentrecord.WithRetries(0),
+		deploymentrecord.WithTimeouts(deploymentrecord.Timeouts{
+			Dial:           time.Second,
+			TLSHandshake:   time.Second,
+			ResponseHeader: time.Second,
+			Attempt:        50 * time.Millisecond,
+		}),
+	)
+
+	if err := client.PostOne(context.Background(), Fixture(0)); err == nil {
+		t.Error("PostOne() expected a timeout")
+	}
+
+	srv.SetLatency(0)
+	if err := client.PostOne(context.Background(), Fixture(0)); err != nil {
+		t.Errorf("PostOne() error = %v", err)
+	}
+}
+
+func TestServerFieldMapping(t *testing.T) {
+	fields, err := deploymentrecord.NewFieldMapping(deploymentrecord.ProfileCamelCase, "name=image")
+	if err != nil {
+		t.Fatalf("NewFieldMapping() error = %v", err)
+	}
+	srv := NewServer(WithFieldMapping(fields))
+	defer srv.Close()
+	client := newTestClient(t, srv, deploymentrecord.WithFieldMapping(fields))
+
+	if err := client.PostOne(context.Background(), Fixture(1)); err != nil {
+		t.Fatalf("PostOne() error = %v", err)
+	}
+	records := srv.Records()
+	if len(records) != 1 || records[0].Name != Fixture(1).Name {
+		t.Errorf("records = %+v, expected the fixture", records)
+	}
+
+	deployed, err := client.List(context.Background(), deploymentrecord.ListFilter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(deployed) != 1 || deployed[0].Name != Fixture(1).Name {
+		t.Errorf("List() = %+v, expected the fixture", deployed)
+	}
+}
+
+func TestServerEnvironmentRecords(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := newTestClient(t, srv)
+
+	record := deploymentrecord.NewEnvironmentRecord("default", FixtureLogicalEnvironment,
+		FixturePhysicalEnvironment, FixtureCluster, deploymentrecord.StatusCreated)
+	if err := client.PostEnvironment(context.Background(), record); err != nil {
+		t.Fatalf("PostEnvironment() error = %v", err)
+	}
+	if got := srv.EnvironmentRecords(); len(got) != 1 || got[0].Name != "default" {
+		t.Errorf("environment records = %+v, expected one of default", got)
+	}
+
+	srv.Reset()
+	if len(srv.EnvironmentRecords()) != 0 || srv.Requests() != 0 {
+		t.Error("Reset() left records or requests")
+	}
+}
+
+func TestFixture(t *testing.T) {
+	if a, b := Fixture(3), Fixture(3); !reflect.DeepEqual(a, b) {
+		t.Errorf("Fixture(3) is not reproducible: %+v != %+v", a, b)
+	}
+	seen := make(map[string]bool)
+	for _, r := range Fixtures(10) {
+		if seen[r.Digest] || seen[r.DeploymentName] {
+			t.Errorf("duplicate fixture %+v", r)
+		}
+		seen[r.Digest] = true
+		seen[r.DeploymentName] = true
+		if err := validate(r); err != nil {
+			t.Errorf("invalid fixture %+v: %v", r, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package deploymentrecord
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// APIError is a non-2xx response of the API. The message and details
+// are parsed from the JSON error body, if any.
+type APIError struct {
+	StatusCode       int              `json:"-"`
+	Message          string           `json:"message"`
+	DocumentationURL string           `json:"documentation_url"`
+	Errors           []APIErrorDetail `json:"errors"`
+}
+
+// APIErrorDetail describes a single problem with the request, e.g. an
+// invalid field.
+type APIErrorDetail struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// UnmarshalJSON also accepts plain strings, which the API uses for
+// some error details.
+func (d *APIErrorDetail) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := json.Unmarshal(data, &msg); err == nil {
+		*d = APIErrorDetail{Message: msg}
+		return nil
+	}
+
+	type detail APIErrorDetail
+	var res detail
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	*d = APIErrorDetail(res)
+	return nil
+}
+
+// parseAPIError returns the error for a response with the status code
+// and body. Bodies that are not a JSON error object leave the message
+// empty.
+func parseAPIError(statusCode int, body []byte) *APIError {
+	res := &APIError{}
+	if err := json.Unmarshal(body, res); err != nil {
+		res = &APIError{}
+	}
+	res.StatusCode = statusCode
+	return res
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "unexpected status code: %d", e.StatusCode)
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	for _, d := range e.Errors {
+		b.WriteString(" (")
+		b.WriteString(d.String())
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Code returns the first error detail code reported by the API, e.g.
+// "invalid", or an empty string.
+func (e *APIError) Code() string {
+	for _, d := range e.Errors {
+		if d.Code != "" {
+			return d.Code
+		}
+	}
+	return ""
+}
+
+// String formats the detail, e.g. "digest invalid: invalid digest
+// format".
+func (d APIErrorDetail) String() string {
+	parts := make([]string, 0, 2)
+	if d.Field != "" {
+		parts = append(parts, d.Field)
+	}
+	if d.Code != "" {
+		parts = append(parts, d.Code)
+	}
+	res := strings.Join(parts, " ")
+	switch {
+	case res == "":
+		return d.Message
+	case d.Message != "":
+		return res + ": " + d.Message
+	default:
+		return res
+	}
+}
@@ -0,0 +1,90 @@
+package deploymentrecord
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+		code     string
+	}{
+		{
+			name:     "validation error",
+			status:   http.StatusUnprocessableEntity,
+			body:     `{"message":"Validation Failed","errors":[{"resource":"DeploymentRecord","field":"digest","code":"invalid","message":"invalid digest format"}],"documentation_url":"https://docs.github.com/rest"}`,
+			expected: "unexpected status code: 422: Validation Failed (digest invalid: invalid digest format)",
+			code:     "invalid",
+		},
+		{
+			name:     "message only",
+			status:   http.StatusForbidden,
+			body:     `{"message":"Organization is not enabled for artifact metadata"}`,
+			expected: "unexpected status code: 403: Organization is not enabled for artifact metadata",
+		},
+		{
+			name:     "string details",
+			status:   http.StatusBadRequest,
+			body:     `{"message":"Invalid request","errors":["name is too long"]}`,
+			expected: "unexpected status code: 400: Invalid request (name is too long)",
+		},
+		{
+			name:     "not json",
+			status:   http.StatusBadGateway,
+			body:     `<html>Bad Gateway</html>`,
+			expected: "unexpected status code: 502",
+		},
+		{
+			name:     "empty body",
+			status:   http.StatusNotFound,
+			expected: "unexpected status code: 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseAPIError(tt.status, []byte(tt.body))
+			if err.Error() != tt.expected {
+				t.Errorf("Error() = %q, expected %q", err.Error(), tt.expected)
+			}
+			if err.Code() != tt.code {
+				t.Errorf("Code() = %q, expected %q", err.Code(), tt.code)
+			}
+		})
+	}
+}
+
+func TestPostReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"message":"Validation Failed","errors":[{"field":"digest","code":"invalid"}]}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	err = c.PostOne(context.Background(), record)
+
+	var clientErr *ClientError
+	if !errors.As(err, &clientErr) {
+		t.Fatalf("PostOne() error = %v, expected a ClientError", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("PostOne() error = %v, expected an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Message != "Validation Failed" || apiErr.Code() != "invalid" {
+		t.Errorf("APIError = %+v, expected the parsed error body", apiErr)
+	}
+}
@@ -0,0 +1,240 @@
+package deploymentrecord
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Serialization profiles for records.
+const (
+	// ProfileDefault emits the canonical snake_case field names used
+	// by the GitHub API.
+	ProfileDefault = "default"
+	// ProfileCamelCase emits camelCase field names.
+	ProfileCamelCase = "camel"
+)
+
+// freeformFields are the fields holding maps whose keys are data, e.g.
+// metadata keys or resource names, which are never renamed.
+var freeformFields = map[string]bool{
+	"metadata": true,
+	"requests": true,
+	"limits":   true,
+}
+
+// FieldMapping controls the shape of serialized records, so the same
+// record can be emitted to backends expecting different field names.
+// Names are keyed by the canonical (JSON) field name, e.g. "name" or
+// "deployment_name". Explicitly mapped names take precedence over the
+// profile, and only apply to top-level fields; the profile also
+// applies to the fields of nested objects, e.g. "topology", but not to
+// the keys of freeformFields.
+type FieldMapping struct {
+	profile string
+	names   map[string]string
+}
+
+// NewFieldMapping creates a field mapping for the given profile and
+// explicit renames. The renames are given as a comma separated list
+// of canonical=output pairs, e.g. "name=image,digest=imageDigest".
+// Returns an error if a field is renamed twice, or if two fields of the
+// records would have the same output name, e.g. with "name=digest".
+func NewFieldMapping(profile, renames string) (*FieldMapping, error) {
+	switch profile {
+	case "", ProfileDefault:
+		profile = ProfileDefault
+	case ProfileCamelCase:
+	default:
+		return nil, fmt.Errorf("invalid serialization profile: %s", profile)
+	}
+
+	m := &FieldMapping{
+		profile: profile,
+		names:   make(map[string]string),
+	}
+
+	for _, pair := range strings.Split(renames, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		from = strings.TrimSpace(from)
+		to = strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid field mapping: %q (expected canonical=output)", pair)
+		}
+		if _, ok := m.names[from]; ok {
+			return nil, fmt.Errorf("invalid field mapping: %s is renamed twice", from)
+		}
+		m.names[from] = to
+	}
+
+	// The fields of both record types share the output names
+	outputs := make(map[string]string)
+	for _, name := range recordFields() {
+		out := m.fieldName(name)
+		if other, ok := outputs[out]; ok && other != name {
+			return nil, fmt.Errorf("invalid field mapping: %s and %s are both mapped to %s", other, name, out)
+		}
+		outputs[out] = name
+	}
+	for from, to := range m.names {
+		if other, ok := outputs[to]; ok && other != from {
+			return nil, fmt.Errorf("invalid field mapping: %s and %s are both mapped to %s", other, from, to)
+		}
+		outputs[to] = from
+	}
+
+	return m, nil
+}
+
+// recordFields returns the canonical names of the top-level fields of
+// the deployment and environment records.
+func recordFields() []string {
+	var names []string
+	for _, t := range []reflect.Type{
+		reflect.TypeFor[DeploymentRecord](),
+		reflect.TypeFor[EnvironmentRecord](),
+	} {
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Marshal serializes the record with the field names rewritten
+// according to the mapping.
+func (m *FieldMapping) Marshal(record any) ([]byte, error) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || (m.profile == ProfileDefault && len(m.names) == 0) {
+		return body, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		if m.profile == ProfileCamelCase && !freeformFields[k] {
+			if v, err = renameKeys(v, toCamelCase); err != nil {
+				return nil, err
+			}
+		}
+		out[m.fieldName(k)] = v
+	}
+
+	return json.Marshal(out)
+}
+
+// Unmarshal parses a record serialized with the mapping, rewriting
+// the field names back to their canonical names.
+func (m *FieldMapping) Unmarshal(data []byte, record any) error {
+	if m == nil || (m.profile == ProfileDefault && len(m.names) == 0) {
+		return json.Unmarshal(data, record)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	canonical := make(map[string]string, len(m.names))
+	for from, to := range m.names {
+		canonical[to] = from
+	}
+	in := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		name, ok := canonical[k]
+		switch {
+		case ok:
+		case m.profile == ProfileCamelCase:
+			name = toSnakeCase(k)
+		default:
+			name = k
+		}
+		if m.profile == ProfileCamelCase && !freeformFields[name] {
+			var err error
+			if v, err = renameKeys(v, toSnakeCase); err != nil {
+				return err
+			}
+		}
+		in[name] = v
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, record)
+}
+
+// fieldName returns the output name for the canonical field name.
+func (m *FieldMapping) fieldName(name string) string {
+	if n, ok := m.names[name]; ok {
+		return n
+	}
+	if m.profile == ProfileCamelCase {
+		return toCamelCase(name)
+	}
+	return name
+}
+
+// renameKeys renames the keys of v with rename, recursively, if v is
+// an object. The keys of freeformFields are kept.
+func renameKeys(v json.RawMessage, rename func(string) string) (json.RawMessage, error) {
+	if len(v) == 0 || v[0] != '{' {
+		return v, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(v, &fields); err != nil {
+		return nil, err
+	}
+	out := make(map[string]json.RawMessage, len(fields))
+	for k, fv := range fields {
+		if !freeformFields[k] {
+			var err error
+			if fv, err = renameKeys(fv, rename); err != nil {
+				return nil, err
+			}
+		}
+		out[rename(k)] = fv
+	}
+	return json.Marshal(out)
+}
+
+// toCamelCase converts a snake_case name to camelCase.
+func toCamelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// toSnakeCase converts a camelCase name to snake_case.
+func toSnakeCase(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,197 @@
+package deploymentrecord
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewFieldMapping(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     string
+		renames     string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:    "empty profile",
+			profile: "",
+		},
+		{
+			name:    "camel profile with renames",
+			profile: "camel",
+			renames: "name=image, digest=imageDigest",
+		},
+		{
+			name:        "unknown profile",
+			profile:     "pascal",
+			wantErr:     true,
+			errContains: "invalid serialization profile",
+		},
+		{
+			name:        "rename without separator",
+			renames:     "name",
+			wantErr:     true,
+			errContains: "invalid field mapping",
+		},
+		{
+			name:        "rename without target",
+			renames:     "name=",
+			wantErr:     true,
+			errContains: "invalid field mapping",
+		},
+		{
+			name:        "field renamed twice",
+			renames:     "name=image,name=artifact",
+			wantErr:     true,
+			errContains: "renamed twice",
+		},
+		{
+			name:        "duplicate target",
+			renames:     "name=image,digest=image",
+			wantErr:     true,
+			errContains: "both mapped to image",
+		},
+		{
+			name:        "target colliding with a field",
+			renames:     "name=digest",
+			wantErr:     true,
+			errContains: "both mapped to digest",
+		},
+		{
+			name:        "target colliding with a camel field",
+			profile:     "camel",
+			renames:     "name=deploymentName",
+			wantErr:     true,
+			errContains: "both mapped to deploymentName",
+		},
+		{
+			name:    "swapped fields",
+			renames: "name=digest,digest=name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFieldMapping(tt.profile, tt.renames)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error containing %q, got nil", tt.errContains)
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFieldMappingMarshal(t *testing.T) {
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1",
+		"prod", "us-east", "c1", StatusDeployed, "ns/app/web")
+
+	tests := []struct {
+		name     string
+		profile  string
+		renames  string
+		expected string
+	}{
+		{
+			name:     "default profile",
+			expected: `{"name":"ghcr.io/org/app","digest":"sha256:abc","version":"v1","logical_environment":"prod","physical_environment":"us-east","cluster":"c1","status":"deployed","deployment_name":"ns/app/web"}`,
+		},
+		{
+			name:     "camel profile",
+			profile:  ProfileCamelCase,
+			expected: `{"cluster":"c1","deploymentName":"ns/app/web","digest":"sha256:abc","logicalEnvironment":"prod","name":"ghcr.io/org/app","physicalEnvironment":"us-east","status":"deployed","version":"v1"}`,
+		},
+		{
+			name:     "renames take precedence over profile",
+			profile:  ProfileCamelCase,
+			renames:  "name=image,deployment_name=workload",
+			expected: `{"cluster":"c1","digest":"sha256:abc","image":"ghcr.io/org/app","logicalEnvironment":"prod","physicalEnvironment":"us-east","status":"deployed","version":"v1","workload":"ns/app/web"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewFieldMapping(tt.profile, tt.renames)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			body, err := m.Marshal(record)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(body) != tt.expected {
+				t.Errorf("Marshal() = %s, want %s", body, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFieldMappingNested(t *testing.T) {
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1",
+		"prod", "us-east", "c1", StatusDeployed, "ns/app/web")
+	record.Topology = &Topology{InstanceType: "m5.large"}
+	record.Metadata = map[string]string{"team_name": "payments"}
+	record.Resources = &Resources{Requests: map[string]string{"ephemeral_storage": "1Gi"}}
+
+	m, err := NewFieldMapping(ProfileCamelCase, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := m.Marshal(record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Nested fields are renamed, the keys of maps are kept
+	for _, want := range []string{`"topology":{"instanceType":"m5.large"}`, `"metadata":{"team_name":"payments"}`, `"resources":{"requests":{"ephemeral_storage":"1Gi"}}`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Marshal() = %s, want it to contain %s", body, want)
+		}
+	}
+}
+
+func TestFieldMappingUnmarshal(t *testing.T) {
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "iad", "cluster", StatusDeployed, "default/app/app")
+	record.TrackerVersion = "1.2.3"
+	record.Metadata = map[string]string{"team_name": "payments"}
+	record.Topology = &Topology{Zone: "us-east-1a", InstanceType: "m5.large"}
+
+	profiles := []struct {
+		profile string
+		renames string
+	}{
+		{profile: "default"},
+		{profile: "camel"},
+		{profile: "camel", renames: "name=image,digest=imageDigest"},
+		{profile: "default", renames: "deployment_name=workload"},
+	}
+
+	for _, p := range profiles {
+		t.Run(p.profile+" "+p.renames, func(t *testing.T) {
+			m, err := NewFieldMapping(p.profile, p.renames)
+			if err != nil {
+				t.Fatalf("NewFieldMapping() error = %v", err)
+			}
+			data, err := m.Marshal(record)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var got DeploymentRecord
+			if err := m.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, *record) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, *record)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package deploymentrecord
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultUserAgent is the User-Agent of requests without WithUserAgent.
+const DefaultUserAgent = "deployment-tracker"
+
+// headerNamePattern matches the token characters of header names
+// (RFC 9110).
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// WithUserAgent sets the User-Agent of all requests to the API, e.g.
+// the tracker name, version and cluster, so they can be told apart in
+// the server logs.
+func WithUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithHeader adds a header to all requests to the API, e.g. for
+// routing through a front door. The headers set by the client, such
+// as Authorization, Content-Type or User-Agent, can't be overridden.
+// Invalid names or values make NewClient fail.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		if !headerNamePattern.MatchString(key) {
+			c.err = fmt.Errorf("invalid header name: %q", key)
+			return
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			c.err = fmt.Errorf("invalid value of header %s", key)
+			return
+		}
+		if c.header == nil {
+			c.header = make(http.Header)
+		}
+		c.header.Add(key, value)
+	}
+}
@@ -0,0 +1,91 @@
+package deploymentrecord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want map[string]string
+	}{
+		{
+			name: "default user agent",
+			want: map[string]string{"User-Agent": DefaultUserAgent},
+		},
+		{
+			name: "user agent and headers",
+			opts: []ClientOption{
+				WithUserAgent("deployment-tracker/v1.2.0 (cluster prod)"),
+				WithHeader("X-Route", "ghes-east"),
+				WithHeader("x-tenant", "platform"),
+			},
+			want: map[string]string{
+				"User-Agent": "deployment-tracker/v1.2.0 (cluster prod)",
+				"X-Route":    "ghes-east",
+				"X-Tenant":   "platform",
+			},
+		},
+		{
+			name: "client headers not overridden",
+			opts: []ClientOption{
+				WithAPIToken("token"),
+				WithHeader("Authorization", "Bearer other"),
+				WithHeader("Content-Type", "text/plain"),
+			},
+			want: map[string]string{
+				"Authorization": "Bearer token",
+				"Content-Type":  "application/json",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(srv.URL, "my-org", tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient() unexpected error: %v", err)
+			}
+			if err := c.PostOne(context.Background(), record); err != nil {
+				t.Fatalf("PostOne() unexpected error: %v", err)
+			}
+			for k, v := range tt.want {
+				if got.Get(k) != v {
+					t.Errorf("header %s = %q, want %q", k, got.Get(k), v)
+				}
+			}
+		})
+	}
+}
+
+func TestWithHeaderInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{name: "empty name", key: "", value: "v"},
+		{name: "space in name", key: "X Route", value: "v"},
+		{name: "newline in value", key: "X-Route", value: "a\r\nX-Injected: b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient("https://api.github.com", "my-org", WithHeader(tt.key, tt.value)); err == nil {
+				t.Error("NewClient() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package deploymentrecord
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// IdempotencyKeyHeader is the header carrying the idempotency key of
+// record posts.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKey returns a deterministic key of the record, a hash of
+// its deployment name, digest, status, cluster and pod UID. Posts of
+// the same record, including retries after an ambiguous network
+// failure, send the same key, so the API can drop the duplicates,
+// while a redeploy of the same digest, observed from a new pod, gets
+// a new key.
+func (r *DeploymentRecord) IdempotencyKey() string {
+	return hashFields(r.DeploymentName, r.Digest, r.Status, r.Cluster, r.PodUID)
+}
+
+// IdempotencyKey returns a deterministic key of the environment
+// record, a hash of its name, status, cluster and namespace UID, so
+// a namespace recreated with the same name gets a new key.
+func (r *EnvironmentRecord) IdempotencyKey() string {
+	return hashFields(r.Name, r.Status, r.Cluster, r.NamespaceUID)
+}
+
+// hashFields returns the hex SHA-256 hash of the fields.
+func hashFields(fields ...string) string {
+	h := sha256.New()
+	for _, s := range fields {
+		// The separator keeps the fields apart, e.g. "ab"+"c" and
+		// "a"+"bc"
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// batchIdempotencyKey returns the idempotency key of a batch post, a
+// hash of the keys of its records in order.
+func batchIdempotencyKey(records []*DeploymentRecord) string {
+	h := sha256.New()
+	for _, r := range records {
+		h.Write([]byte(r.IdempotencyKey()))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,157 @@
+package deploymentrecord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	base := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	base.PodUID = "uid-1"
+	key := base.IdempotencyKey()
+
+	same := *base
+	same.Version = "v2"
+	same.Metadata = map[string]string{"team": "platform"}
+	same.ObservedAt = time.Now()
+	if got := same.IdempotencyKey(); got != key {
+		t.Errorf("IdempotencyKey() of a record differing in other fields = %q, want %q", got, key)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *DeploymentRecord)
+	}{
+		{
+			name:   "deployment name",
+			modify: func(r *DeploymentRecord) { r.DeploymentName = "default/other/app" },
+		},
+		{
+			name:   "digest",
+			modify: func(r *DeploymentRecord) { r.Digest = "sha256:def" },
+		},
+		{
+			name:   "status",
+			modify: func(r *DeploymentRecord) { r.Status = StatusDecommissioned },
+		},
+		{
+			name:   "cluster",
+			modify: func(r *DeploymentRecord) { r.Cluster = "other" },
+		},
+		{
+			name:   "redeploy",
+			modify: func(r *DeploymentRecord) { r.PodUID = "uid-2" },
+		},
+		{
+			name: "fields shifted",
+			modify: func(r *DeploymentRecord) {
+				r.DeploymentName += "s"
+				r.Digest = "ha256:abc"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *base
+			tt.modify(&r)
+			if r.IdempotencyKey() == key {
+				t.Errorf("IdempotencyKey() = %q, want a different key", key)
+			}
+		})
+	}
+}
+
+func TestEnvironmentIdempotencyKey(t *testing.T) {
+	base := NewEnvironmentRecord("default", "prod", "", "cluster", StatusCreated)
+	base.NamespaceUID = "uid-1"
+	key := base.IdempotencyKey()
+
+	same := *base
+	same.TrackerVersion = "v2"
+	if got := same.IdempotencyKey(); got != key {
+		t.Errorf("IdempotencyKey() of a record differing in other fields = %q, want %q", got, key)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *EnvironmentRecord)
+	}{
+		{
+			name:   "name",
+			modify: func(r *EnvironmentRecord) { r.Name = "other" },
+		},
+		{
+			name:   "status",
+			modify: func(r *EnvironmentRecord) { r.Status = StatusDecommissioned },
+		},
+		{
+			name:   "cluster",
+			modify: func(r *EnvironmentRecord) { r.Cluster = "other" },
+		},
+		{
+			name:   "recreated namespace",
+			modify: func(r *EnvironmentRecord) { r.NamespaceUID = "uid-2" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *base
+			tt.modify(&r)
+			if r.IdempotencyKey() == key {
+				t.Errorf("IdempotencyKey() = %q, want a different key", key)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyHeader(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		// Fail the first attempt, so the retry is checked too
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org", WithBackoff(Backoff{Base: 1, Multiplier: 1, Max: 1}))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	if err := c.PostOne(context.Background(), record); err != nil {
+		t.Fatalf("PostOne() unexpected error: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != record.IdempotencyKey() || keys[1] != keys[0] {
+		t.Errorf("idempotency keys = %q, want %q on each attempt", keys, record.IdempotencyKey())
+	}
+
+	keys = keys[:0]
+	other := NewDeploymentRecord("ghcr.io/org/app", "sha256:def", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	if err := c.PostBatch(context.Background(), []*DeploymentRecord{record, other}); err != nil {
+		t.Fatalf("PostBatch() unexpected error: %v", err)
+	}
+	if len(keys) == 0 || keys[0] == "" || keys[0] == record.IdempotencyKey() {
+		t.Errorf("batch idempotency keys = %q, want the key of the batch", keys)
+	}
+	if reversed := batchIdempotencyKey([]*DeploymentRecord{other, record}); len(keys) > 0 && reversed == keys[0] {
+		t.Error("batch idempotency key doesn't depend on the order of the records")
+	}
+
+	keys = keys[:0]
+	env := NewEnvironmentRecord("default", "prod", "", "cluster", StatusCreated)
+	if err := c.PostEnvironment(context.Background(), env); err != nil {
+		t.Fatalf("PostEnvironment() unexpected error: %v", err)
+	}
+	if len(keys) == 0 || keys[0] != env.IdempotencyKey() {
+		t.Errorf("environment idempotency keys = %q, want %q", keys, env.IdempotencyKey())
+	}
+}
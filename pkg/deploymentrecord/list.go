@@ -0,0 +1,145 @@
+package deploymentrecord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/github/deployment-tracker/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// listPageSize is the number of records requested per page.
+const listPageSize = 100
+
+// ErrNoAPI is returned when reading records with a client created
+// WithoutAPI.
+var ErrNoAPI = errors.New("the deployment records API is disabled")
+
+// ListFilter selects the deployment records returned by List. Empty
+// fields match all records.
+type ListFilter struct {
+	Name                string
+	DeploymentName      string
+	LogicalEnvironment  string
+	PhysicalEnvironment string
+	Cluster             string
+	Status              string
+}
+
+// query returns the filter as query parameters.
+func (f ListFilter) query() url.Values {
+	q := url.Values{}
+	for k, v := range map[string]string{
+		"name":                 f.Name,
+		"deployment_name":      f.DeploymentName,
+		"logical_environment":  f.LogicalEnvironment,
+		"physical_environment": f.PhysicalEnvironment,
+		"cluster":              f.Cluster,
+		"status":               f.Status,
+	} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	return q
+}
+
+// listBody is the response body of the deployment records list API.
+type listBody struct {
+	TotalCount        int               `json:"total_count"`
+	DeploymentRecords []json.RawMessage `json:"deployment_records"`
+}
+
+// List reads back the deployment records matching the filter from
+// the deployment records API, following the pagination links until
+// all pages are read.
+func (c *Client) List(ctx context.Context, filter ListFilter) (records []*DeploymentRecord, err error) {
+	ctx, span := startSpan(ctx, "List")
+	defer func() {
+		span.SetAttributes(attribute.Int("record_count", len(records)))
+		tracing.End(span, err)
+	}()
+
+	if c.noAPI {
+		return nil, ErrNoAPI
+	}
+
+	q := filter.query()
+	q.Set("per_page", strconv.Itoa(listPageSize))
+	next := fmt.Sprintf("%s/orgs/%s/artifacts/metadata/deployment-records?%s", c.baseURL, c.org, q.Encode())
+
+	for next != "" {
+		resp, err := c.do(ctx, http.MethodGet, next, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page listBody
+		if err := json.Unmarshal(resp.body, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal records: %w", err)
+		}
+		for _, raw := range page.DeploymentRecords {
+			var record DeploymentRecord
+			if err := c.fields.Unmarshal(raw, &record); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal record: %w", err)
+			}
+			records = append(records, &record)
+		}
+
+		next, err = c.nextPage(resp.header)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// Get reads back the records of the deployment, e.g. one per digest
+// deployed under the name.
+func (c *Client) Get(ctx context.Context, deploymentName string) ([]*DeploymentRecord, error) {
+	if deploymentName == "" {
+		return nil, errors.New("deployment name cannot be empty")
+	}
+	return c.List(ctx, ListFilter{DeploymentName: deploymentName})
+}
+
+// nextPage returns the URL of the next page from the Link header, or
+// an empty string on the last page. Links outside of the API are
+// rejected, so the credentials are never sent elsewhere.
+func (c *Client) nextPage(header http.Header) (string, error) {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		next := strings.Trim(strings.TrimSpace(target), "<>")
+		if !strings.HasPrefix(next, c.baseURL+"/") {
+			return "", fmt.Errorf("next page outside of the API: %s", next)
+		}
+		return next, nil
+	}
+	return "", nil
+}
+
+// Check verifies that the API is reachable and accepts the client's
+// credentials for the organization, by reading a single record.
+func (c *Client) Check(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "Check")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if c.noAPI {
+		return nil
+	}
+	_, err = c.do(ctx, http.MethodGet,
+		fmt.Sprintf("%s/orgs/%s/artifacts/metadata/deployment-records?per_page=1", c.baseURL, c.org), nil, nil)
+	return err
+}
@@ -0,0 +1,124 @@
+package deploymentrecord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/orgs/my-org/artifacts/metadata/deployment-records" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.URL.Query().Get("cluster"); got != "cluster" {
+			t.Errorf("cluster filter = %q, expected %q", got, "cluster")
+		}
+
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?cluster=cluster&page=2>; rel="next", <%s%s?cluster=cluster&page=2>; rel="last"`,
+				srv.URL, r.URL.Path, srv.URL, r.URL.Path))
+			_, _ = w.Write([]byte(`{"total_count":2,"deployment_records":[{"name":"ghcr.io/org/web","digest":"sha256:a","deployment_name":"default/web/app","status":"deployed"}]}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"total_count":2,"deployment_records":[{"name":"ghcr.io/org/db","digest":"sha256:b","deployment_name":"default/db/postgres","status":"decommissioned"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	records, err := c.List(context.Background(), ListFilter{Cluster: "cluster"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("List() returned %d records, expected 2", len(records))
+	}
+	if records[0].DeploymentName != "default/web/app" || records[1].Status != StatusDecommissioned {
+		t.Errorf("List() = %+v, %+v, expected both pages", records[0], records[1])
+	}
+}
+
+func TestListRejectsForeignNextPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Link", `<https://example.com/steal?page=2>; rel="next"`)
+		_, _ = w.Write([]byte(`{"total_count":0,"deployment_records":[]}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if _, err := c.Get(context.Background(), "default/web/app"); err == nil {
+		t.Error("Get() expected an error for a next page outside of the API")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "bad credentials", status: http.StatusUnauthorized, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("per_page") != "1" {
+					t.Errorf("per_page = %q, expected 1", r.URL.Query().Get("per_page"))
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(srv.URL, "my-org")
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if err := c.Check(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithoutAPI(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org", WithoutAPI())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	record := NewDeploymentRecord("ghcr.io/org/web", "sha256:abc", "v1",
+		"production", "", "kube-1", StatusDeployed, "default/web/app")
+	if err := c.PostOne(context.Background(), record); err != nil {
+		t.Errorf("PostOne() error = %v", err)
+	}
+	if err := c.Check(context.Background()); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if _, err := c.List(context.Background(), ListFilter{}); !errors.Is(err, ErrNoAPI) {
+		t.Errorf("List() error = %v, expected ErrNoAPI", err)
+	}
+	if requests != 0 {
+		t.Errorf("requests = %d, expected 0", requests)
+	}
+}
@@ -1,9 +1,27 @@
 package deploymentrecord
 
+import "time"
+
 // Status constants for deployment records.
 const (
 	StatusDeployed       = "deployed"
 	StatusDecommissioned = "decommissioned"
+	// StatusPartiallyDeployed is the status of records of a rollout
+	// in progress, with only part of the replicas running the digest.
+	StatusPartiallyDeployed = "partially_deployed"
+)
+
+// Status constants for environment records. Environments are
+// decommissioned with StatusDecommissioned.
+const (
+	StatusCreated = "created"
+)
+
+// Digest verification results, see DeploymentRecord.
+const (
+	DigestVerified   = "verified"
+	DigestMismatch   = "mismatch"
+	DigestUnverified = "unverified"
 )
 
 // DeploymentRecord represents a deployment event record.
@@ -16,6 +34,96 @@ type DeploymentRecord struct {
 	Cluster             string `json:"cluster"`
 	Status              string `json:"status"`
 	DeploymentName      string `json:"deployment_name"`
+	TrackerVersion      string `json:"tracker_version,omitempty"`
+	KubernetesVersion   string `json:"kubernetes_version,omitempty"`
+	// CommitSHA is the source commit the image was built from.
+	CommitSHA string `json:"commit_sha,omitempty"`
+	// Metadata holds additional context, e.g. the team owning the
+	// deployment, taken from labels and annotations.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// DigestVerification is the result of resolving the image against
+	// its registry, if enabled. On a mismatch, RegistryDigest is the
+	// digest the registry serves.
+	DigestVerification string `json:"digest_verification,omitempty"`
+	RegistryDigest     string `json:"registry_digest,omitempty"`
+	// Signed and Attested tell whether cosign signatures and
+	// attestations are stored for the digest, if checked.
+	// SignerIdentity and SignerIssuer identify the signer of keyless
+	// signatures.
+	Signed         *bool  `json:"signed,omitempty"`
+	Attested       *bool  `json:"attested,omitempty"`
+	SignerIdentity string `json:"signer_identity,omitempty"`
+	SignerIssuer   string `json:"signer_issuer,omitempty"`
+	// Replicas is the desired replica count of the owning
+	// Deployment, and Resources the resource requests and limits of
+	// the container, if enabled.
+	Replicas  *int32     `json:"replicas,omitempty"`
+	Resources *Resources `json:"resources,omitempty"`
+	// ObservedAt is when the tracker observed the deployment or its
+	// decommission, and Source the tracker that did, e.g.
+	// "deployment-tracker/v1.2.0". PodUID is the UID of the pod the
+	// record was created from. They let the API order records
+	// delivered out of order, e.g. by several clusters or delayed
+	// retries.
+	ObservedAt time.Time `json:"observed_at,omitzero"`
+	Source     string    `json:"source,omitempty"`
+	PodUID     string    `json:"pod_uid,omitempty"`
+	// PodTemplateHash and Revision identify the rollout of the
+	// owning Deployment, so rollouts of the same digest, e.g. config
+	// only changes, can be told apart and ordered.
+	PodTemplateHash string `json:"pod_template_hash,omitempty"`
+	Revision        string `json:"revision,omitempty"`
+	// RevisionReplicas is the number of replicas of the revision of
+	// a partially deployed record, out of Replicas.
+	RevisionReplicas *int32 `json:"revision_replicas,omitempty"`
+	// Topology is where the pod runs, taken from the labels of its
+	// node, if enabled.
+	Topology *Topology `json:"topology,omitempty"`
+	// Platform is the OS and architecture of the node of the pod and
+	// the platform-specific digest of the image, if enabled.
+	Platform *Platform `json:"platform,omitempty"`
+	// GitOps is the GitOps application that applied the owning
+	// workload, if enabled.
+	GitOps *GitOps `json:"gitops,omitempty"`
+}
+
+// Resources holds the resource requests and limits of a container,
+// keyed by resource name, e.g. {"cpu": "500m", "memory": "256Mi"}.
+type Resources struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+// Topology holds the node a container runs on and the node's
+// well-known topology labels. Labels the node doesn't have are empty.
+type Topology struct {
+	Node         string `json:"node,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	Region       string `json:"region,omitempty"`
+	InstanceType string `json:"instance_type,omitempty"`
+}
+
+// Platform holds the OS and architecture of the node a container runs
+// on. For a multi-arch image, Digest is the digest of the image index
+// while ManifestDigest is that of the manifest of the platform, the
+// one the node pulled.
+type Platform struct {
+	OS           string `json:"os,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	// OSVersion is the Windows build of Windows nodes, e.g.
+	// 10.0.20348.
+	OSVersion      string `json:"os_version,omitempty"`
+	ManifestDigest string `json:"manifest_digest,omitempty"`
+}
+
+// GitOps identifies the GitOps application that produced a deployment,
+// e.g. {Tool: "argocd", Kind: "Application", Name: "web"}. Flux
+// names are prefixed with the namespace of the Kustomization or
+// HelmRelease.
+type GitOps struct {
+	Tool string `json:"tool"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
 }
 
 // NewDeploymentRecord creates a new DeploymentRecord with the given status.
@@ -40,3 +148,36 @@ func NewDeploymentRecord(name, digest, version, logicalEnv, physicalEnv,
 		DeploymentName:      deploymentName,
 	}
 }
+
+// EnvironmentRecord represents an environment lifecycle record. An
+// environment maps to a Kubernetes namespace.
+type EnvironmentRecord struct {
+	Name                string `json:"name"`
+	LogicalEnvironment  string `json:"logical_environment"`
+	PhysicalEnvironment string `json:"physical_environment"`
+	Cluster             string `json:"cluster"`
+	Status              string `json:"status"`
+	TrackerVersion      string `json:"tracker_version,omitempty"`
+	KubernetesVersion   string `json:"kubernetes_version,omitempty"`
+	// NamespaceUID is the UID of the namespace, only used in the
+	// idempotency key. It is not posted.
+	NamespaceUID string `json:"-"`
+}
+
+// NewEnvironmentRecord creates a new EnvironmentRecord with the given
+// status. Status must be either StatusCreated or StatusDecommissioned.
+func NewEnvironmentRecord(name, logicalEnv, physicalEnv, cluster,
+	status string) *EnvironmentRecord {
+	// Validate status
+	if status != StatusCreated && status != StatusDecommissioned {
+		status = StatusCreated // default to created if invalid
+	}
+
+	return &EnvironmentRecord{
+		Name:                name,
+		LogicalEnvironment:  logicalEnv,
+		PhysicalEnvironment: physicalEnv,
+		Cluster:             cluster,
+		Status:              status,
+	}
+}
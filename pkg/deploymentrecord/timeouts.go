@@ -0,0 +1,76 @@
+package deploymentrecord
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// dialKeepAlive is the interval of the TCP keep-alive probes of the
+// connections to the API, as in the default transport.
+const dialKeepAlive = 30 * time.Second
+
+// Timeouts bound the phases of the requests to the API separately, so
+// a slow TLS handshake isn't mistaken for a slow response. A zero
+// timeout disables it.
+type Timeouts struct {
+	// Dial bounds establishing the TCP connection.
+	Dial time.Duration
+	// TLSHandshake bounds the TLS handshake.
+	TLSHandshake time.Duration
+	// ResponseHeader bounds waiting for the response headers once
+	// the request is sent.
+	ResponseHeader time.Duration
+	// Attempt bounds each attempt of a request as a whole, including
+	// reading the response body. A timed out attempt is retried,
+	// unless the context of the request is done.
+	Attempt time.Duration
+}
+
+// DefaultTimeouts allow 5s to connect and 5s for the TLS handshake,
+// 10s for the response headers and 15s per attempt.
+var DefaultTimeouts = Timeouts{
+	Dial:           5 * time.Second,
+	TLSHandshake:   5 * time.Second,
+	ResponseHeader: 10 * time.Second,
+	Attempt:        15 * time.Second,
+}
+
+// Validate returns an error if the timeouts are invalid.
+func (t Timeouts) Validate() error {
+	switch {
+	case t.Dial < 0:
+		return fmt.Errorf("invalid dial timeout %s, must not be negative", t.Dial)
+	case t.TLSHandshake < 0:
+		return fmt.Errorf("invalid TLS handshake timeout %s, must not be negative", t.TLSHandshake)
+	case t.ResponseHeader < 0:
+		return fmt.Errorf("invalid response header timeout %s, must not be negative", t.ResponseHeader)
+	case t.Attempt < 0:
+		return fmt.Errorf("invalid attempt timeout %s, must not be negative", t.Attempt)
+	}
+	return nil
+}
+
+// apply sets the connection timeouts of the client's transport and
+// the attempt timeout.
+func (t Timeouts) apply(c *Client) {
+	c.transport.DialContext = (&net.Dialer{
+		Timeout:   t.Dial,
+		KeepAlive: dialKeepAlive,
+	}).DialContext
+	c.transport.TLSHandshakeTimeout = t.TLSHandshake
+	c.transport.ResponseHeaderTimeout = t.ResponseHeader
+	c.attemptTimeout = t.Attempt
+}
+
+// WithTimeouts sets the timeouts of the requests to the API. Invalid
+// timeouts make NewClient fail.
+func WithTimeouts(t Timeouts) ClientOption {
+	return func(c *Client) {
+		if err := t.Validate(); err != nil {
+			c.err = err
+			return
+		}
+		t.apply(c)
+	}
+}
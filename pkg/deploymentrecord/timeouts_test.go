@@ -0,0 +1,81 @@
+package deploymentrecord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimeoutsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts Timeouts
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			timeouts: DefaultTimeouts,
+		},
+		{
+			name:     "disabled",
+			timeouts: Timeouts{},
+		},
+		{
+			name:     "negative dial",
+			timeouts: Timeouts{Dial: -time.Second},
+			wantErr:  true,
+		},
+		{
+			name:     "negative attempt",
+			timeouts: Timeouts{Attempt: -time.Second},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.timeouts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	// The first attempt hangs, the retry succeeds
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			<-release
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c, err := NewClient(srv.URL, "my-org",
+		WithTimeouts(Timeouts{Attempt: 100 * time.Millisecond}),
+		WithBackoff(Backoff{Base: time.Millisecond, Multiplier: 1, Max: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	if c.transport.ResponseHeaderTimeout != 0 {
+		t.Errorf("ResponseHeaderTimeout = %s, want disabled", c.transport.ResponseHeaderTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	if err := c.PostOne(ctx, record); err != nil {
+		t.Fatalf("PostOne() unexpected error: %v", err)
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
@@ -0,0 +1,127 @@
+package deploymentrecord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultServiceAccountTokenPath is the default path of the
+	// projected service account token exchanged for an API token.
+	DefaultServiceAccountTokenPath = "/var/run/secrets/tokens/deployment-tracker"
+
+	// exchangedTokenRefreshMargin is how long before its expiry an
+	// exchanged token is replaced.
+	exchangedTokenRefreshMargin = 5 * time.Minute
+
+	// exchangedTokenDefaultLifetime is the lifetime assumed for
+	// exchanged tokens without an expiry.
+	exchangedTokenDefaultLifetime = 10 * time.Minute
+
+	// exchangeTimeout bounds a token exchange request.
+	exchangeTimeout = 15 * time.Second
+)
+
+// tokenExchange exchanges the projected service account token of the
+// pod, an OIDC token issued by the cluster, for an API token at a
+// token exchange endpoint. The API token is cached until shortly
+// before it expires.
+type tokenExchange struct {
+	url       string
+	tokenPath string
+	client    *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// exchangeResponse is the response of the token exchange endpoint.
+// Both GitHub installation token responses (token, expires_at) and
+// OAuth 2.0 token exchange responses (access_token, expires_in) are
+// accepted.
+type exchangeResponse struct {
+	Token       string    `json:"token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"`
+}
+
+// Token returns the cached API token, exchanging the service account
+// token for a new one if it is about to expire.
+func (e *tokenExchange) Token(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token != "" && time.Until(e.expires) > exchangedTokenRefreshMargin {
+		return e.token, nil
+	}
+
+	tok, expires, err := e.exchange(ctx)
+	if err != nil {
+		return "", fmt.Errorf("token exchange failed: %w", err)
+	}
+	e.token = tok
+	e.expires = expires
+	return tok, nil
+}
+
+// exchange posts the service account token to the endpoint as a
+// bearer token, and returns the API token and its expiry.
+func (e *tokenExchange) exchange(ctx context.Context) (string, time.Time, error) {
+	// The kubelet rotates the projected token, so read it each time
+	data, err := os.ReadFile(e.tokenPath)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	saToken := strings.TrimSpace(string(data))
+
+	ctx, cancel := context.WithTimeout(ctx, exchangeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+saToken)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", time.Time{}, parseAPIError(resp.StatusCode, body)
+	}
+
+	var res exchangeResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case res.Token != "" && !res.ExpiresAt.IsZero():
+		return res.Token, res.ExpiresAt, nil
+	case res.Token != "":
+		return res.Token, now.Add(exchangedTokenDefaultLifetime), nil
+	case res.AccessToken != "" && res.ExpiresIn > 0:
+		return res.AccessToken, now.Add(time.Duration(res.ExpiresIn) * time.Second), nil
+	case res.AccessToken != "":
+		return res.AccessToken, now.Add(exchangedTokenDefaultLifetime), nil
+	default:
+		return "", time.Time{}, fmt.Errorf("no token in response")
+	}
+}
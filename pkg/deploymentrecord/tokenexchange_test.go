@@ -0,0 +1,110 @@
+package deploymentrecord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithTokenExchange(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		status    int
+		wantToken string
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "installation token",
+			response:  `{"token": "ghs_abc", "expires_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`,
+			status:    http.StatusCreated,
+			wantToken: "ghs_abc",
+			wantCalls: 1,
+		},
+		{
+			name:      "oauth token exchange",
+			response:  `{"access_token": "ghs_def", "expires_in": 3600}`,
+			status:    http.StatusOK,
+			wantToken: "ghs_def",
+			wantCalls: 1,
+		},
+		{
+			name:      "expiring token",
+			response:  `{"access_token": "ghs_ghi", "expires_in": 60}`,
+			status:    http.StatusOK,
+			wantToken: "ghs_ghi",
+			wantCalls: 2,
+		},
+		{
+			name:     "rejected",
+			response: `{"message": "audience mismatch"}`,
+			status:   http.StatusForbidden,
+			wantErr:  true,
+		},
+		{
+			name:     "no token",
+			response: `{}`,
+			status:   http.StatusOK,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenPath, []byte("sa-jwt\n"), 0o600); err != nil {
+				t.Fatalf("failed to write token: %v", err)
+			}
+
+			var calls int
+			exchange := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if got := r.Header.Get("Authorization"); got != "Bearer sa-jwt" {
+					t.Errorf("exchange Authorization = %q, want %q", got, "Bearer sa-jwt")
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer exchange.Close()
+
+			var auth string
+			api := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+			}))
+			defer api.Close()
+
+			c, err := NewClient(api.URL, "my-org", WithRetries(0), WithTokenExchange(exchange.URL, tokenPath))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+			for range 2 {
+				err = c.PostOne(context.Background(), record)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Error("PostOne() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PostOne() error = %v", err)
+			}
+			if want := "Bearer " + tt.wantToken; auth != want {
+				t.Errorf("Authorization = %q, want %q", auth, want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("exchange calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+
+	if _, err := NewClient("https://api.github.com", "my-org", WithTokenExchange("http://sts.example.com", "")); err == nil {
+		t.Error("NewClient() expected an error for an insecure exchange URL")
+	}
+}
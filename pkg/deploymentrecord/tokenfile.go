@@ -0,0 +1,78 @@
+package deploymentrecord
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenFileCheckInterval is how often the token file is read again.
+// Polling the contents, rather than watching for file events, also
+// picks up rotations of mounted Secrets, which are swapped in through
+// symlinks.
+const tokenFileCheckInterval = 10 * time.Second
+
+// tokenFile is an API token read from a file, and read again when it
+// is older than the check interval.
+type tokenFile struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	token   string
+	checked time.Time
+}
+
+// newTokenFile reads the token from the file at path. An unreadable
+// or empty file is an error.
+func newTokenFile(path string) (*tokenFile, error) {
+	f := &tokenFile{path: path, interval: tokenFileCheckInterval}
+	tok, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	f.token = tok
+	f.checked = time.Now()
+	return f, nil
+}
+
+// read returns the token in the file, without surrounding whitespace.
+func (f *tokenFile) read() (string, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read API token file: %w", err)
+	}
+	tok := strings.TrimSpace(string(data))
+	if tok == "" {
+		return "", fmt.Errorf("API token file is empty: %s", f.path)
+	}
+	return tok, nil
+}
+
+// get returns the current token. If the file can't be read anymore,
+// e.g. in the middle of a rotation, the last token is kept.
+func (f *tokenFile) get() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.checked) < f.interval {
+		return f.token
+	}
+	f.checked = time.Now()
+
+	tok, err := f.read()
+	if err != nil {
+		slog.Warn("Failed to reload API token, keeping the current token",
+			"error", err)
+		return f.token
+	}
+	if tok != f.token {
+		slog.Info("Reloaded API token",
+			"path", f.path)
+		f.token = tok
+	}
+	return f.token
+}
@@ -0,0 +1,67 @@
+package deploymentrecord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithAPITokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first-token\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "my-org", WithAPIToken("static-token"), WithAPITokenFile(path))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	record := NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "prod", "", "cluster", StatusDeployed, "default/app/app")
+	post := func() {
+		t.Helper()
+		if err := c.PostOne(context.Background(), record); err != nil {
+			t.Fatalf("PostOne() error = %v", err)
+		}
+	}
+
+	post()
+	if auth != "Bearer first-token" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer first-token")
+	}
+
+	// The rotated token is only read once the check interval passed
+	if err := os.WriteFile(path, []byte("second-token"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	post()
+	if auth != "Bearer first-token" {
+		t.Errorf("Authorization before check = %q, want %q", auth, "Bearer first-token")
+	}
+	c.auth.(*tokenFile).interval = 0
+	post()
+	if auth != "Bearer second-token" {
+		t.Errorf("Authorization after rotation = %q, want %q", auth, "Bearer second-token")
+	}
+
+	// A missing file keeps the last token
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove token file: %v", err)
+	}
+	post()
+	if auth != "Bearer second-token" {
+		t.Errorf("Authorization with missing file = %q, want %q", auth, "Bearer second-token")
+	}
+
+	if _, err := NewClient(srv.URL, "my-org", WithAPITokenFile(path)); err == nil {
+		t.Error("NewClient() expected an error for a missing token file")
+	}
+}
@@ -1,29 +1,38 @@
 package image
 
+import (
+	"strings"
+)
+
 // ExtractDigest extracts the digest from an ImageID.
 // ImageID format is typically: docker-pullable://image@sha256:abc123...
 // or docker://sha256:abc123...
+// The digest is taken from the image reference if the ImageID is one,
+// else from its start. ImageIDs without a digest, e.g. the local image
+// store IDs some CRI-O nodes report, return an empty string.
 func ExtractDigest(imageID string) string {
 	if imageID == "" {
 		return ""
 	}
 
-	// Look for sha256: in the imageID
-	for i := 0; i < len(imageID)-7; i++ {
-		if imageID[i:i+7] == "sha256:" {
-			// Return everything from sha256: onwards
-			remaining := imageID[i:]
-			// Find end (could be end of string or next separator)
-			end := len(remaining)
-			for j, c := range remaining {
-				if c == '@' || c == ' ' {
-					end = j
-					break
-				}
-			}
-			return remaining[:end]
-		}
+	// Strip the runtime scheme, e.g. docker-pullable://, and anything
+	// after the ID
+	id := imageID
+	if _, rest, ok := strings.Cut(id, "://"); ok {
+		id = rest
+	}
+	id, _, _ = strings.Cut(id, " ")
+
+	if digestRegexp.MatchString(id) {
+		return id
+	}
+	if ref, err := ParseReference(id); err == nil && ref.Digest != "" {
+		return ref.Digest
+	}
+	// A digest followed by a "/" is a registry host and port
+	if digest := digestPrefixRegexp.FindString(id); digest != "" && !strings.HasPrefix(id[len(digest):], "/") {
+		return digest
 	}
 
-	return imageID
+	return ""
 }
@@ -41,9 +41,9 @@ func TestExtractDigest(t *testing.T) {
 			expected: "sha256:1234567890abcdef",
 		},
 		{
-			name:     "no sha256 prefix returns original",
+			name:     "no sha256 prefix",
 			imageID:  "some-random-id-without-sha",
-			expected: "some-random-id-without-sha",
+			expected: "",
 		},
 		{
 			name:     "digest with trailing space",
@@ -60,6 +60,31 @@ func TestExtractDigest(t *testing.T) {
 			imageID:  "docker-pullable://ghcr.io/github/deployment-tracker@sha256:a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
 			expected: "sha256:a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
 		},
+		{
+			name:     "upper case algorithm",
+			imageID:  "docker-pullable://nginx@SHA256:abc123def456",
+			expected: "SHA256:abc123def456",
+		},
+		{
+			name:     "multiple digests",
+			imageID:  "docker-pullable://nginx@sha256:abc@sha256:def",
+			expected: "",
+		},
+		{
+			name:     "registry with port without digest",
+			imageID:  "docker-pullable://localhost:5000/myapp",
+			expected: "",
+		},
+		{
+			name:     "CRI-O image store ID",
+			imageID:  "6f7b1c5fbd0d3e5b0c6a8e4f0c1f4a2e9b8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b",
+			expected: "",
+		},
+		{
+			name:     "CRI-O image store ID with registry",
+			imageID:  "quay.io/org/app:v1",
+			expected: "",
+		},
 		{
 			name:     "containerd format",
 			imageID:  "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
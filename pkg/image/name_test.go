@@ -89,6 +89,18 @@ func TestExtractName(t *testing.T) {
 			expectedImg: "ghcr.io/owner/repo/image",
 			expectedTag: "v2.0",
 		},
+		{
+			name:        "upper case digest algorithm",
+			image:       "ghcr.io/org/app:v1@SHA256:abc123def456",
+			expectedImg: "ghcr.io/org/app",
+			expectedTag: "v1",
+		},
+		{
+			name:        "multiple digests returned as is",
+			image:       "nginx:1.21@sha256:abc@sha256:def",
+			expectedImg: "nginx:1.21@sha256:abc@sha256:def",
+			expectedTag: "",
+		},
 		{
 			name:        "ecr image with tag",
 			image:       "123456789.dkr.ecr.us-east-1.amazonaws.com/my-app:latest",
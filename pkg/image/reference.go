@@ -0,0 +1,155 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DockerHub is the registry of references without a registry.
+const DockerHub = "docker.io"
+
+// dockerHubAliases are other hosts of Docker Hub references.
+var dockerHubAliases = []string{"index.docker.io", "registry-1.docker.io"}
+
+// maxNameLength is the maximum length of the name (registry and
+// repository) of a reference.
+const maxNameLength = 255
+
+// ErrInvalidReference is returned when a string is not a valid image
+// reference.
+var ErrInvalidReference = errors.New("invalid image reference")
+
+// The grammar of the OCI distribution spec. Algorithms of digests may
+// be upper case, as in the reference grammar of the distribution
+// project; the encoded part follows the OCI image spec.
+const (
+	pathComponent = `[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*`
+	domainName    = `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*`
+	ipv6          = `\[[a-fA-F0-9:]+\]`
+)
+
+var (
+	domainRegexp     = regexp.MustCompile(`^(?:` + domainName + `|` + ipv6 + `)(?::[0-9]+)?$`)
+	repositoryRegexp = regexp.MustCompile(`^` + pathComponent + `(?:/` + pathComponent + `)*$`)
+	tagRegexp        = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp     = regexp.MustCompile(`^[A-Za-z0-9]+(?:[+._-][A-Za-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	// digestPrefixRegexp matches a digest at the start of a string,
+	// e.g. of an image ID followed by other data.
+	digestPrefixRegexp = regexp.MustCompile(`^[A-Za-z0-9]+(?:[+._-][A-Za-z0-9]+)*:[a-zA-Z0-9=_-]+`)
+)
+
+// Reference is a parsed container image reference.
+type Reference struct {
+	// Registry is the registry host, with the port if any, e.g.
+	// "ghcr.io" or "localhost:5000". It is empty for references
+	// without one, e.g. "nginx".
+	Registry string
+	// Repository is the path of the image in the registry, e.g.
+	// "org/app".
+	Repository string
+	// Tag and Digest are empty if the reference has none.
+	Tag    string
+	Digest string
+}
+
+// ParseReference parses an image reference following the grammar of
+// the OCI distribution spec:
+//
+//	[registry "/"] repository [":" tag] ["@" digest]
+//
+// The first path component is the registry if it contains a "." or a
+// ":" or is "localhost", as in the Docker CLI. The reference is not
+// normalized, e.g. "nginx" has no registry.
+func ParseReference(s string) (Reference, error) {
+	if s == "" {
+		return Reference{}, fmt.Errorf("%w: empty", ErrInvalidReference)
+	}
+
+	var ref Reference
+	name, digest, hasDigest := strings.Cut(s, "@")
+	if hasDigest {
+		if strings.Contains(digest, "@") {
+			return Reference{}, fmt.Errorf("%w: %q has more than one digest", ErrInvalidReference, s)
+		}
+		if !digestRegexp.MatchString(digest) {
+			return Reference{}, fmt.Errorf("%w: %q has an invalid digest", ErrInvalidReference, s)
+		}
+		ref.Digest = digest
+	}
+
+	// A ":" after the last "/" separates the tag, others are ports
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagRegexp.MatchString(ref.Tag) {
+			return Reference{}, fmt.Errorf("%w: %q has an invalid tag", ErrInvalidReference, s)
+		}
+	}
+
+	if len(name) > maxNameLength {
+		return Reference{}, fmt.Errorf("%w: name of %q is longer than %d characters", ErrInvalidReference, s, maxNameLength)
+	}
+	ref.Repository = name
+	if first, rest, ok := strings.Cut(name, "/"); ok &&
+		(strings.ContainsAny(first, ".:") || first == "localhost" || strings.HasPrefix(first, "[")) {
+		if !domainRegexp.MatchString(first) {
+			return Reference{}, fmt.Errorf("%w: %q has an invalid registry", ErrInvalidReference, s)
+		}
+		ref.Registry = first
+		ref.Repository = rest
+	}
+	if !repositoryRegexp.MatchString(ref.Repository) {
+		return Reference{}, fmt.Errorf("%w: %q has an invalid repository", ErrInvalidReference, s)
+	}
+
+	return ref, nil
+}
+
+// Normalize returns the canonical form of the reference, so the same
+// image spelled differently has the same name: the implicit Docker Hub
+// registry and "library" namespace are made explicit, e.g. "nginx"
+// becomes "docker.io/library/nginx".
+func (r Reference) Normalize() Reference {
+	if r.Registry == "" || slices.Contains(dockerHubAliases, r.Registry) {
+		r.Registry = DockerHub
+	}
+	if r.Registry == DockerHub && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+	return r
+}
+
+// NormalizeName returns the normalized name of an image reference,
+// without tag or digest, e.g. "docker.io/library/nginx" for
+// "nginx:1.21". Invalid references are returned as is.
+func NormalizeName(image string) string {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return image
+	}
+	return ref.Normalize().Name()
+}
+
+// Name returns the registry and repository of the reference, without
+// tag or digest.
+func (r Reference) Name() string {
+	if r.Registry == "" {
+		return r.Repository
+	}
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the reference in its canonical string form.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
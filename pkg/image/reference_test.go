@@ -0,0 +1,149 @@
+package image
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "nginx", want: "docker.io/library/nginx"},
+		{image: "nginx:1.21@sha256:abc", want: "docker.io/library/nginx"},
+		{image: "library/nginx", want: "docker.io/library/nginx"},
+		{image: "docker.io/nginx", want: "docker.io/library/nginx"},
+		{image: "index.docker.io/library/nginx:1.21", want: "docker.io/library/nginx"},
+		{image: "bitnami/redis:7", want: "docker.io/bitnami/redis"},
+		{image: "ghcr.io/org/app:v1", want: "ghcr.io/org/app"},
+		{image: "ghcr.io/app", want: "ghcr.io/app"},
+		{image: "localhost:5000/app", want: "localhost:5000/app"},
+		{image: "Invalid", want: "Invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := NormalizeName(tt.image); got != tt.want {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		want    Reference
+		wantErr bool
+	}{
+		{
+			name: "repository only",
+			ref:  "nginx",
+			want: Reference{Repository: "nginx"},
+		},
+		{
+			name: "docker hub user repository",
+			ref:  "myuser/my_app-x:v1",
+			want: Reference{Repository: "myuser/my_app-x", Tag: "v1"},
+		},
+		{
+			name: "registry, tag and digest",
+			ref:  "ghcr.io/org/app:v1.2@sha256:abc",
+			want: Reference{Registry: "ghcr.io", Repository: "org/app", Tag: "v1.2", Digest: "sha256:abc"},
+		},
+		{
+			name: "registry with port",
+			ref:  "localhost:5000/app",
+			want: Reference{Registry: "localhost:5000", Repository: "app"},
+		},
+		{
+			name: "localhost",
+			ref:  "localhost/app:v1",
+			want: Reference{Registry: "localhost", Repository: "app", Tag: "v1"},
+		},
+		{
+			name: "ipv6 registry",
+			ref:  "[::1]:5000/app@sha256:abc",
+			want: Reference{Registry: "[::1]:5000", Repository: "app", Digest: "sha256:abc"},
+		},
+		{
+			name: "upper case digest algorithm",
+			ref:  "nginx@SHA256:ABC",
+			want: Reference{Repository: "nginx", Digest: "SHA256:ABC"},
+		},
+		{
+			name: "compound digest algorithm",
+			ref:  "nginx@sha256+b64u:LCa0a2j_xo_5m0U8HTBBNBNCLXBkg7-g-YpeiGJm564",
+			want: Reference{Repository: "nginx", Digest: "sha256+b64u:LCa0a2j_xo_5m0U8HTBBNBNCLXBkg7-g-YpeiGJm564"},
+		},
+		{
+			name:    "empty",
+			ref:     "",
+			wantErr: true,
+		},
+		{
+			name:    "multiple digests",
+			ref:     "nginx@sha256:abc@sha256:def",
+			wantErr: true,
+		},
+		{
+			name:    "invalid digest",
+			ref:     "nginx@sha256",
+			wantErr: true,
+		},
+		{
+			name:    "upper case repository",
+			ref:     "ghcr.io/Org/app",
+			wantErr: true,
+		},
+		{
+			name:    "invalid tag",
+			ref:     "nginx:-v1",
+			wantErr: true,
+		},
+		{
+			name:    "tag too long",
+			ref:     "nginx:" + strings.Repeat("a", 129),
+			wantErr: true,
+		},
+		{
+			name:    "name too long",
+			ref:     "ghcr.io/" + strings.Repeat("a", 250),
+			wantErr: true,
+		},
+		{
+			name:    "invalid registry",
+			ref:     "-ghcr.io/app",
+			wantErr: true,
+		},
+		{
+			name:    "empty path component",
+			ref:     "ghcr.io/org//app",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.ref)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidReference) {
+					t.Fatalf("ParseReference(%q) error = %v, want %v", tt.ref, err, ErrInvalidReference)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReference(%q) unexpected error: %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReference(%q) = %+v, want %+v", tt.ref, got, tt.want)
+			}
+			if got.String() != tt.ref {
+				t.Errorf("String() = %q, want %q", got.String(), tt.ref)
+			}
+		})
+	}
+}
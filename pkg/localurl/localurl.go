@@ -0,0 +1,27 @@
+// Package localurl tells the URLs of local hosts, which may be used
+// without TLS, apart from the others.
+package localurl
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// IsLocal returns true if rawURL is an http URL of a local host:
+// localhost, a loopback address or a cluster service
+// (*.svc.cluster.local). Only the host of the parsed URL is checked,
+// so e.g. http://localhost.example.com or a query string mentioning
+// a cluster service are not local.
+func IsLocal(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".svc.cluster.local") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,32 @@
+package localurl
+
+import "testing"
+
+func TestIsLocal(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{url: "http://localhost:8080", expected: true},
+		{url: "http://127.0.0.1/api", expected: true},
+		{url: "http://[::1]:8200", expected: true},
+		{url: "http://vault.vault.svc.cluster.local:8200", expected: true},
+		{url: "https://localhost"},
+		{url: "http://api.example.com"},
+		{url: "http://attacker/?x=.svc.cluster.local"},
+		{url: "http://attacker/.svc.cluster.local"},
+		{url: "http://svc.cluster.local.attacker"},
+		{url: "http://localhost.attacker.com"},
+		{url: "http://127.0.0.1.attacker.com"},
+		{url: "http://localhost@attacker.com"},
+		{url: "localhost:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := IsLocal(tt.url); got != tt.expected {
+				t.Errorf("IsLocal(%q) = %v, expected %v", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
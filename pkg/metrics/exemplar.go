@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ObserveWithTrace records v on o. If ctx carries a sampled span, its
+// trace ID is attached as an exemplar so a latency sample can be
+// linked to the corresponding trace. Exemplars are only exposed when
+// the metrics endpoint serves the OpenMetrics format.
+func ObserveWithTrace(ctx context.Context, o prometheus.Observer, v float64) {
+	sc := trace.SpanContextFromContext(ctx)
+	eo, ok := o.(prometheus.ExemplarObserver)
+	if !ok || !sc.IsSampled() {
+		o.Observe(v)
+		return
+	}
+
+	eo.ObserveWithExemplar(v, prometheus.Labels{
+		"trace_id": sc.TraceID().String(),
+	})
+}
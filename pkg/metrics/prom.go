@@ -6,6 +6,24 @@ import (
 )
 
 var (
+	//nolint: revive
+	BuildInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "deptracker_build_info",
+			Help: "Always 1, labelled with the version, commit and Go version of the running binary",
+		},
+		[]string{"version", "commit", "go_version"},
+	)
+
+	//nolint: revive
+	ConfigInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "deptracker_config_info",
+			Help: "Always 1, labelled with the non-secret settings of the running configuration",
+		},
+		[]string{"cluster", "logical_environment", "physical_environment", "template_hash"},
+	)
+
 	//nolint: revive
 	EventsProcessedOk = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -33,6 +51,42 @@ var (
 		[]string{"status"},
 	)
 
+	//nolint: revive
+	EventsRequeued = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_events_requeued",
+			Help: "The total number of failed events requeued for retry",
+		},
+		[]string{"event_type"},
+	)
+
+	//nolint: revive
+	EventsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_events_dropped",
+			Help: "The total number of events dropped after exhausting retries",
+		},
+		[]string{"event_type"},
+	)
+
+	//nolint: revive
+	EventsCoalesced = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "deptracker_events_coalesced",
+			Help: "The total number of pod create events dropped because a pod of the same workload and images was enqueued within the coalescing window",
+		},
+	)
+
+	//nolint: revive
+	EventRetries = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "deptracker_event_retries",
+			Help:    "The number of retries an event needed before it succeeded or was dropped",
+			Buckets: []float64{0, 1, 2, 3, 5, 8, 13, 21},
+		},
+		[]string{"event_type"},
+	)
+
 	//nolint: revive
 	PostDeploymentRecordTimer = promauto.NewHistogram(
 		prometheus.HistogramOpts{
@@ -66,10 +120,226 @@ var (
 	)
 
 	//nolint: revive
-	PostDeploymentRecordClientError = promauto.NewCounter(
+	PostDeploymentRecordClientError = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "deptracker_post_record_client_error",
 			Help: "The total number of non-retryable client failures",
 		},
+		[]string{"status", "code"},
+	)
+
+	//nolint: revive
+	DigestVerifications = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_digest_verifications",
+			Help: "The total number of image digests resolved against their registry, by pod namespace and result (verified, mismatch or unverified)",
+		},
+		[]string{"namespace", "result"},
+	)
+
+	//nolint: revive
+	SignatureChecks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_signature_checks",
+			Help: "The total number of image digests checked for cosign signatures, by pod namespace and result (signed, unsigned or error)",
+		},
+		[]string{"namespace", "result"},
+	)
+
+	//nolint: revive
+	NonDigestImageIDs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_non_digest_image_ids",
+			Help: "The total number of containers whose imageID is not a digest, by pod namespace and result (resolved, failed or skipped)",
+		},
+		[]string{"namespace", "result"},
+	)
+
+	//nolint: revive
+	RecordsPostedOk = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_records_posted_ok",
+			Help: "The total number of deployment records posted, by pod namespace and record status",
+		},
+		[]string{"namespace", "status"},
+	)
+
+	//nolint: revive
+	RecordsPostedFailed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_records_posted_failed",
+			Help: "The total number of deployment records that failed to post, by pod namespace and record status",
+		},
+		[]string{"namespace", "status"},
+	)
+
+	//nolint: revive
+	TrackedDeployments = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "deptracker_tracked_deployments",
+			Help: "The number of distinct deployment names and digests running in tracked pods, by cluster and namespace",
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	//nolint: revive
+	ObservedCacheEntries = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_observed_cache_entries",
+			Help: "The number of entries of the observation cache",
+		},
+	)
+
+	//nolint: revive
+	ObservedCacheEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_observed_cache_evictions",
+			Help: "The total number of entries evicted from the observation cache, by reason (size or ttl)",
+		},
+		[]string{"reason"},
+	)
+
+	//nolint: revive
+	CacheStoreDroppedEntries = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_cache_store_dropped_entries",
+			Help: "The number of observation cache entries left out of the last persisted cache, as it exceeded the ConfigMap size limit",
+		},
+	)
+
+	//nolint: revive
+	DeadLetterRecords = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_dead_letter_records",
+			Help: "The number of records held in the dead letter store",
+		},
+	)
+
+	//nolint: revive
+	RetryQueueRecords = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_retry_queue_records",
+			Help: "The number of records held in the disk backed retry queue",
+		},
+	)
+
+	//nolint: revive
+	RetryQueueReplayed = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "deptracker_retry_queue_replayed",
+			Help: "The total number of records posted from the retry queue",
+		},
+	)
+
+	//nolint: revive
+	PostDeploymentRecordRateLimited = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "deptracker_post_record_rate_limited",
+			Help: "The total number of posts rejected by the API rate limits",
+		},
+	)
+
+	//nolint: revive
+	RateLimiterWaitTimer = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "deptracker_rate_limiter_wait_timer",
+			Help:    "The duration (seconds) spent waiting on the API rate limiter",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+	)
+
+	//nolint: revive
+	RateLimiterTokens = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_rate_limiter_tokens",
+			Help: "The number of API rate limiter tokens available after the last wait",
+		},
+	)
+
+	//nolint: revive
+	RateLimiterLimit = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_rate_limiter_limit",
+			Help: "The configured API rate limit (requests per second)",
+		},
+	)
+
+	//nolint: revive
+	RateLimiterBurst = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_rate_limiter_burst",
+			Help: "The configured API rate limiter burst, the maximum number of tokens",
+		},
+	)
+
+	//nolint: revive
+	APIRequestsInFlight = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "deptracker_api_requests_in_flight",
+			Help: "The number of requests to the API in flight",
+		},
+	)
+
+	//nolint: revive
+	ConcurrencyWaitTimer = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "deptracker_concurrency_wait_timer",
+			Help:    "The duration (seconds) spent waiting for a free API request slot",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+	)
+
+	//nolint: revive
+	SinkSendOk = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_sink_send_ok",
+			Help: "The total number of records delivered to additional sinks",
+		},
+		[]string{"sink"},
+	)
+
+	//nolint: revive
+	SinkSendFailed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_sink_send_failed",
+			Help: "The total number of records that failed to be delivered to additional sinks",
+		},
+		[]string{"sink"},
+	)
+
+	//nolint: revive
+	ChaosInjections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_chaos_injections",
+			Help: "The total number of faults injected for soak testing, by fault",
+		},
+		[]string{"fault"},
+	)
+
+	//nolint: revive
+	RelayRecords = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_relay_records",
+			Help: "The total number of records received by the relay, by cluster and result",
+		},
+		[]string{"cluster", "result"},
+	)
+
+	//nolint: revive
+	OrchestratorTasks = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "deptracker_orchestrator_tasks",
+			Help: "The number of running tasks reported by an orchestrator adapter",
+		},
+		[]string{"adapter"},
+	)
+
+	//nolint: revive
+	OrchestratorRecords = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_orchestrator_records",
+			Help: "The total number of records posted for the tasks of an orchestrator adapter, by status and result",
+		},
+		[]string{"adapter", "status", "result"},
 	)
 )
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	workqueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "deptracker_workqueue_depth",
+			Help: "The number of events waiting in the work queue",
+		},
+		[]string{"name"},
+	)
+
+	workqueueAdds = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_workqueue_adds",
+			Help: "The total number of events added to the work queue",
+		},
+		[]string{"name"},
+	)
+
+	workqueueQueueDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "deptracker_workqueue_queue_duration_seconds",
+			Help:    "The duration (seconds) an event waits in the work queue before a worker picks it up",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"name"},
+	)
+
+	workqueueWorkDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "deptracker_workqueue_work_duration_seconds",
+			Help:    "The duration (seconds) a worker spends processing an event",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"name"},
+	)
+
+	workqueueUnfinishedWork = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "deptracker_workqueue_unfinished_work_seconds",
+			Help: "The duration (seconds) of work in progress that has not been observed by work_duration",
+		},
+		[]string{"name"},
+	)
+
+	workqueueLongestRunning = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "deptracker_workqueue_longest_running_processor_seconds",
+			Help: "The duration (seconds) of the longest running worker",
+		},
+		[]string{"name"},
+	)
+
+	workqueueRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "deptracker_workqueue_retries",
+			Help: "The total number of rate limited requeues of events",
+		},
+		[]string{"name"},
+	)
+)
+
+// WorkqueueProvider exports the metrics of named work queues. Pass it
+// as the MetricsProvider of the queue config.
+type WorkqueueProvider struct{}
+
+// NewDepthMetric implements workqueue.MetricsProvider.
+func (WorkqueueProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+// NewAddsMetric implements workqueue.MetricsProvider.
+func (WorkqueueProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+// NewLatencyMetric implements workqueue.MetricsProvider.
+func (WorkqueueProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueQueueDuration.WithLabelValues(name)
+}
+
+// NewWorkDurationMetric implements workqueue.MetricsProvider.
+func (WorkqueueProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+// NewUnfinishedWorkSecondsMetric implements workqueue.MetricsProvider.
+func (WorkqueueProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinishedWork.WithLabelValues(name)
+}
+
+// NewLongestRunningProcessorSecondsMetric implements
+// workqueue.MetricsProvider.
+func (WorkqueueProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunning.WithLabelValues(name)
+}
+
+// NewRetriesMetric implements workqueue.MetricsProvider.
+func (WorkqueueProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
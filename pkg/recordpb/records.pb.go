@@ -0,0 +1,974 @@
+// The record submission API of deployment-tracker, for deployment
+// sources other than the Kubernetes controller, e.g. CI jobs, and for
+// edge instances delivering their records to a relay. The messages
+// mirror the JSON records of pkg/deploymentrecord; the JSON names are
+// those of the deployment records API.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: proto/deploymenttracker/v1/records.proto
+
+package recordpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubmitDeploymentRecordRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Record        *DeploymentRecord      `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitDeploymentRecordRequest) Reset() {
+	*x = SubmitDeploymentRecordRequest{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitDeploymentRecordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitDeploymentRecordRequest) ProtoMessage() {}
+
+func (x *SubmitDeploymentRecordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitDeploymentRecordRequest.ProtoReflect.Descriptor instead.
+func (*SubmitDeploymentRecordRequest) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitDeploymentRecordRequest) GetRecord() *DeploymentRecord {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+type SubmitDeploymentRecordResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitDeploymentRecordResponse) Reset() {
+	*x = SubmitDeploymentRecordResponse{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitDeploymentRecordResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitDeploymentRecordResponse) ProtoMessage() {}
+
+func (x *SubmitDeploymentRecordResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitDeploymentRecordResponse.ProtoReflect.Descriptor instead.
+func (*SubmitDeploymentRecordResponse) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{1}
+}
+
+type SubmitEnvironmentRecordRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Record        *EnvironmentRecord     `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitEnvironmentRecordRequest) Reset() {
+	*x = SubmitEnvironmentRecordRequest{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitEnvironmentRecordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitEnvironmentRecordRequest) ProtoMessage() {}
+
+func (x *SubmitEnvironmentRecordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitEnvironmentRecordRequest.ProtoReflect.Descriptor instead.
+func (*SubmitEnvironmentRecordRequest) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubmitEnvironmentRecordRequest) GetRecord() *EnvironmentRecord {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+type SubmitEnvironmentRecordResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitEnvironmentRecordResponse) Reset() {
+	*x = SubmitEnvironmentRecordResponse{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitEnvironmentRecordResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitEnvironmentRecordResponse) ProtoMessage() {}
+
+func (x *SubmitEnvironmentRecordResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitEnvironmentRecordResponse.ProtoReflect.Descriptor instead.
+func (*SubmitEnvironmentRecordResponse) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{3}
+}
+
+// DeploymentRecord is a deployment of an image digest, see
+// deploymentrecord.DeploymentRecord.
+type DeploymentRecord struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Name                string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Digest              string                 `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+	Version             string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	LogicalEnvironment  string                 `protobuf:"bytes,4,opt,name=logical_environment,proto3" json:"logical_environment,omitempty"`
+	PhysicalEnvironment string                 `protobuf:"bytes,5,opt,name=physical_environment,proto3" json:"physical_environment,omitempty"`
+	Cluster             string                 `protobuf:"bytes,6,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	// status is deployed, partially_deployed or decommissioned.
+	Status             string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	DeploymentName     string                 `protobuf:"bytes,8,opt,name=deployment_name,proto3" json:"deployment_name,omitempty"`
+	TrackerVersion     string                 `protobuf:"bytes,9,opt,name=tracker_version,proto3" json:"tracker_version,omitempty"`
+	KubernetesVersion  string                 `protobuf:"bytes,10,opt,name=kubernetes_version,proto3" json:"kubernetes_version,omitempty"`
+	CommitSha          string                 `protobuf:"bytes,11,opt,name=commit_sha,proto3" json:"commit_sha,omitempty"`
+	Metadata           map[string]string      `protobuf:"bytes,12,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	DigestVerification string                 `protobuf:"bytes,13,opt,name=digest_verification,proto3" json:"digest_verification,omitempty"`
+	RegistryDigest     string                 `protobuf:"bytes,14,opt,name=registry_digest,proto3" json:"registry_digest,omitempty"`
+	Signed             *bool                  `protobuf:"varint,15,opt,name=signed,proto3,oneof" json:"signed,omitempty"`
+	Attested           *bool                  `protobuf:"varint,16,opt,name=attested,proto3,oneof" json:"attested,omitempty"`
+	SignerIdentity     string                 `protobuf:"bytes,17,opt,name=signer_identity,proto3" json:"signer_identity,omitempty"`
+	SignerIssuer       string                 `protobuf:"bytes,18,opt,name=signer_issuer,proto3" json:"signer_issuer,omitempty"`
+	Replicas           *int32                 `protobuf:"varint,19,opt,name=replicas,proto3,oneof" json:"replicas,omitempty"`
+	Resources          *Resources             `protobuf:"bytes,20,opt,name=resources,proto3" json:"resources,omitempty"`
+	ObservedAt         *timestamppb.Timestamp `protobuf:"bytes,21,opt,name=observed_at,proto3" json:"observed_at,omitempty"`
+	Source             string                 `protobuf:"bytes,22,opt,name=source,proto3" json:"source,omitempty"`
+	PodUid             string                 `protobuf:"bytes,23,opt,name=pod_uid,proto3" json:"pod_uid,omitempty"`
+	PodTemplateHash    string                 `protobuf:"bytes,24,opt,name=pod_template_hash,proto3" json:"pod_template_hash,omitempty"`
+	Revision           string                 `protobuf:"bytes,25,opt,name=revision,proto3" json:"revision,omitempty"`
+	RevisionReplicas   *int32                 `protobuf:"varint,26,opt,name=revision_replicas,proto3,oneof" json:"revision_replicas,omitempty"`
+	Topology           *Topology              `protobuf:"bytes,27,opt,name=topology,proto3" json:"topology,omitempty"`
+	Gitops             *GitOps                `protobuf:"bytes,28,opt,name=gitops,proto3" json:"gitops,omitempty"`
+	Platform           *Platform              `protobuf:"bytes,29,opt,name=platform,proto3" json:"platform,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *DeploymentRecord) Reset() {
+	*x = DeploymentRecord{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeploymentRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeploymentRecord) ProtoMessage() {}
+
+func (x *DeploymentRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeploymentRecord.ProtoReflect.Descriptor instead.
+func (*DeploymentRecord) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeploymentRecord) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetLogicalEnvironment() string {
+	if x != nil {
+		return x.LogicalEnvironment
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetPhysicalEnvironment() string {
+	if x != nil {
+		return x.PhysicalEnvironment
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetDeploymentName() string {
+	if x != nil {
+		return x.DeploymentName
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetTrackerVersion() string {
+	if x != nil {
+		return x.TrackerVersion
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetKubernetesVersion() string {
+	if x != nil {
+		return x.KubernetesVersion
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetCommitSha() string {
+	if x != nil {
+		return x.CommitSha
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *DeploymentRecord) GetDigestVerification() string {
+	if x != nil {
+		return x.DigestVerification
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetRegistryDigest() string {
+	if x != nil {
+		return x.RegistryDigest
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetSigned() bool {
+	if x != nil && x.Signed != nil {
+		return *x.Signed
+	}
+	return false
+}
+
+func (x *DeploymentRecord) GetAttested() bool {
+	if x != nil && x.Attested != nil {
+		return *x.Attested
+	}
+	return false
+}
+
+func (x *DeploymentRecord) GetSignerIdentity() string {
+	if x != nil {
+		return x.SignerIdentity
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetSignerIssuer() string {
+	if x != nil {
+		return x.SignerIssuer
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetReplicas() int32 {
+	if x != nil && x.Replicas != nil {
+		return *x.Replicas
+	}
+	return 0
+}
+
+func (x *DeploymentRecord) GetResources() *Resources {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *DeploymentRecord) GetObservedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ObservedAt
+	}
+	return nil
+}
+
+func (x *DeploymentRecord) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetPodUid() string {
+	if x != nil {
+		return x.PodUid
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetPodTemplateHash() string {
+	if x != nil {
+		return x.PodTemplateHash
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetRevision() string {
+	if x != nil {
+		return x.Revision
+	}
+	return ""
+}
+
+func (x *DeploymentRecord) GetRevisionReplicas() int32 {
+	if x != nil && x.RevisionReplicas != nil {
+		return *x.RevisionReplicas
+	}
+	return 0
+}
+
+func (x *DeploymentRecord) GetTopology() *Topology {
+	if x != nil {
+		return x.Topology
+	}
+	return nil
+}
+
+func (x *DeploymentRecord) GetGitops() *GitOps {
+	if x != nil {
+		return x.Gitops
+	}
+	return nil
+}
+
+func (x *DeploymentRecord) GetPlatform() *Platform {
+	if x != nil {
+		return x.Platform
+	}
+	return nil
+}
+
+// Resources are the resource requests and limits of a container,
+// keyed by resource name.
+type Resources struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      map[string]string      `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Limits        map[string]string      `protobuf:"bytes,2,rep,name=limits,proto3" json:"limits,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Resources) Reset() {
+	*x = Resources{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Resources) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Resources) ProtoMessage() {}
+
+func (x *Resources) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Resources.ProtoReflect.Descriptor instead.
+func (*Resources) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Resources) GetRequests() map[string]string {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+func (x *Resources) GetLimits() map[string]string {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+// Topology is the node a container runs on and its topology labels.
+type Topology struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Node          string                 `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Zone          string                 `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	Region        string                 `protobuf:"bytes,3,opt,name=region,proto3" json:"region,omitempty"`
+	InstanceType  string                 `protobuf:"bytes,4,opt,name=instance_type,proto3" json:"instance_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Topology) Reset() {
+	*x = Topology{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Topology) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Topology) ProtoMessage() {}
+
+func (x *Topology) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Topology.ProtoReflect.Descriptor instead.
+func (*Topology) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Topology) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+func (x *Topology) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *Topology) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *Topology) GetInstanceType() string {
+	if x != nil {
+		return x.InstanceType
+	}
+	return ""
+}
+
+// Platform is the OS and architecture of the node a container runs on
+// and the manifest digest of the platform of a multi-arch image.
+type Platform struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Os             string                 `protobuf:"bytes,1,opt,name=os,proto3" json:"os,omitempty"`
+	Architecture   string                 `protobuf:"bytes,2,opt,name=architecture,proto3" json:"architecture,omitempty"`
+	OsVersion      string                 `protobuf:"bytes,3,opt,name=os_version,proto3" json:"os_version,omitempty"`
+	ManifestDigest string                 `protobuf:"bytes,4,opt,name=manifest_digest,proto3" json:"manifest_digest,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Platform) Reset() {
+	*x = Platform{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Platform) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Platform) ProtoMessage() {}
+
+func (x *Platform) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Platform.ProtoReflect.Descriptor instead.
+func (*Platform) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Platform) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *Platform) GetArchitecture() string {
+	if x != nil {
+		return x.Architecture
+	}
+	return ""
+}
+
+func (x *Platform) GetOsVersion() string {
+	if x != nil {
+		return x.OsVersion
+	}
+	return ""
+}
+
+func (x *Platform) GetManifestDigest() string {
+	if x != nil {
+		return x.ManifestDigest
+	}
+	return ""
+}
+
+// GitOps is the GitOps application that applied the workload.
+type GitOps struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tool          string                 `protobuf:"bytes,1,opt,name=tool,proto3" json:"tool,omitempty"`
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GitOps) Reset() {
+	*x = GitOps{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GitOps) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GitOps) ProtoMessage() {}
+
+func (x *GitOps) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GitOps.ProtoReflect.Descriptor instead.
+func (*GitOps) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GitOps) GetTool() string {
+	if x != nil {
+		return x.Tool
+	}
+	return ""
+}
+
+func (x *GitOps) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *GitOps) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// EnvironmentRecord is a namespace lifecycle change, see
+// deploymentrecord.EnvironmentRecord.
+type EnvironmentRecord struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Name                string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	LogicalEnvironment  string                 `protobuf:"bytes,2,opt,name=logical_environment,proto3" json:"logical_environment,omitempty"`
+	PhysicalEnvironment string                 `protobuf:"bytes,3,opt,name=physical_environment,proto3" json:"physical_environment,omitempty"`
+	Cluster             string                 `protobuf:"bytes,4,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	// status is created or decommissioned.
+	Status            string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	TrackerVersion    string `protobuf:"bytes,6,opt,name=tracker_version,proto3" json:"tracker_version,omitempty"`
+	KubernetesVersion string `protobuf:"bytes,7,opt,name=kubernetes_version,proto3" json:"kubernetes_version,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *EnvironmentRecord) Reset() {
+	*x = EnvironmentRecord{}
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnvironmentRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnvironmentRecord) ProtoMessage() {}
+
+func (x *EnvironmentRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_deploymenttracker_v1_records_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnvironmentRecord.ProtoReflect.Descriptor instead.
+func (*EnvironmentRecord) Descriptor() ([]byte, []int) {
+	return file_proto_deploymenttracker_v1_records_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *EnvironmentRecord) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *EnvironmentRecord) GetLogicalEnvironment() string {
+	if x != nil {
+		return x.LogicalEnvironment
+	}
+	return ""
+}
+
+func (x *EnvironmentRecord) GetPhysicalEnvironment() string {
+	if x != nil {
+		return x.PhysicalEnvironment
+	}
+	return ""
+}
+
+func (x *EnvironmentRecord) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *EnvironmentRecord) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *EnvironmentRecord) GetTrackerVersion() string {
+	if x != nil {
+		return x.TrackerVersion
+	}
+	return ""
+}
+
+func (x *EnvironmentRecord) GetKubernetesVersion() string {
+	if x != nil {
+		return x.KubernetesVersion
+	}
+	return ""
+}
+
+var File_proto_deploymenttracker_v1_records_proto protoreflect.FileDescriptor
+
+const file_proto_deploymenttracker_v1_records_proto_rawDesc = "" +
+	"\n" +
+	"(proto/deploymenttracker/v1/records.proto\x12\x14deploymenttracker.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"_\n" +
+	"\x1dSubmitDeploymentRecordRequest\x12>\n" +
+	"\x06record\x18\x01 \x01(\v2&.deploymenttracker.v1.DeploymentRecordR\x06record\" \n" +
+	"\x1eSubmitDeploymentRecordResponse\"a\n" +
+	"\x1eSubmitEnvironmentRecordRequest\x12?\n" +
+	"\x06record\x18\x01 \x01(\v2'.deploymenttracker.v1.EnvironmentRecordR\x06record\"!\n" +
+	"\x1fSubmitEnvironmentRecordResponse\"\xc3\n" +
+	"\n" +
+	"\x10DeploymentRecord\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06digest\x18\x02 \x01(\tR\x06digest\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x120\n" +
+	"\x13logical_environment\x18\x04 \x01(\tR\x13logical_environment\x122\n" +
+	"\x14physical_environment\x18\x05 \x01(\tR\x14physical_environment\x12\x18\n" +
+	"\acluster\x18\x06 \x01(\tR\acluster\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x12(\n" +
+	"\x0fdeployment_name\x18\b \x01(\tR\x0fdeployment_name\x12(\n" +
+	"\x0ftracker_version\x18\t \x01(\tR\x0ftracker_version\x12.\n" +
+	"\x12kubernetes_version\x18\n" +
+	" \x01(\tR\x12kubernetes_version\x12\x1e\n" +
+	"\n" +
+	"commit_sha\x18\v \x01(\tR\n" +
+	"commit_sha\x12P\n" +
+	"\bmetadata\x18\f \x03(\v24.deploymenttracker.v1.DeploymentRecord.MetadataEntryR\bmetadata\x120\n" +
+	"\x13digest_verification\x18\r \x01(\tR\x13digest_verification\x12(\n" +
+	"\x0fregistry_digest\x18\x0e \x01(\tR\x0fregistry_digest\x12\x1b\n" +
+	"\x06signed\x18\x0f \x01(\bH\x00R\x06signed\x88\x01\x01\x12\x1f\n" +
+	"\battested\x18\x10 \x01(\bH\x01R\battested\x88\x01\x01\x12(\n" +
+	"\x0fsigner_identity\x18\x11 \x01(\tR\x0fsigner_identity\x12$\n" +
+	"\rsigner_issuer\x18\x12 \x01(\tR\rsigner_issuer\x12\x1f\n" +
+	"\breplicas\x18\x13 \x01(\x05H\x02R\breplicas\x88\x01\x01\x12=\n" +
+	"\tresources\x18\x14 \x01(\v2\x1f.deploymenttracker.v1.ResourcesR\tresources\x12<\n" +
+	"\vobserved_at\x18\x15 \x01(\v2\x1a.google.protobuf.TimestampR\vobserved_at\x12\x16\n" +
+	"\x06source\x18\x16 \x01(\tR\x06source\x12\x18\n" +
+	"\apod_uid\x18\x17 \x01(\tR\apod_uid\x12,\n" +
+	"\x11pod_template_hash\x18\x18 \x01(\tR\x11pod_template_hash\x12\x1a\n" +
+	"\brevision\x18\x19 \x01(\tR\brevision\x121\n" +
+	"\x11revision_replicas\x18\x1a \x01(\x05H\x03R\x11revision_replicas\x88\x01\x01\x12:\n" +
+	"\btopology\x18\x1b \x01(\v2\x1e.deploymenttracker.v1.TopologyR\btopology\x124\n" +
+	"\x06gitops\x18\x1c \x01(\v2\x1c.deploymenttracker.v1.GitOpsR\x06gitops\x12:\n" +
+	"\bplatform\x18\x1d \x01(\v2\x1e.deploymenttracker.v1.PlatformR\bplatform\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\t\n" +
+	"\a_signedB\v\n" +
+	"\t_attestedB\v\n" +
+	"\t_replicasB\x14\n" +
+	"\x12_revision_replicas\"\x93\x02\n" +
+	"\tResources\x12I\n" +
+	"\brequests\x18\x01 \x03(\v2-.deploymenttracker.v1.Resources.RequestsEntryR\brequests\x12C\n" +
+	"\x06limits\x18\x02 \x03(\v2+.deploymenttracker.v1.Resources.LimitsEntryR\x06limits\x1a;\n" +
+	"\rRequestsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vLimitsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"p\n" +
+	"\bTopology\x12\x12\n" +
+	"\x04node\x18\x01 \x01(\tR\x04node\x12\x12\n" +
+	"\x04zone\x18\x02 \x01(\tR\x04zone\x12\x16\n" +
+	"\x06region\x18\x03 \x01(\tR\x06region\x12$\n" +
+	"\rinstance_type\x18\x04 \x01(\tR\rinstance_type\"\x88\x01\n" +
+	"\bPlatform\x12\x0e\n" +
+	"\x02os\x18\x01 \x01(\tR\x02os\x12\"\n" +
+	"\farchitecture\x18\x02 \x01(\tR\farchitecture\x12\x1e\n" +
+	"\n" +
+	"os_version\x18\x03 \x01(\tR\n" +
+	"os_version\x12(\n" +
+	"\x0fmanifest_digest\x18\x04 \x01(\tR\x0fmanifest_digest\"D\n" +
+	"\x06GitOps\x12\x12\n" +
+	"\x04tool\x18\x01 \x01(\tR\x04tool\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\"\x99\x02\n" +
+	"\x11EnvironmentRecord\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x120\n" +
+	"\x13logical_environment\x18\x02 \x01(\tR\x13logical_environment\x122\n" +
+	"\x14physical_environment\x18\x03 \x01(\tR\x14physical_environment\x12\x18\n" +
+	"\acluster\x18\x04 \x01(\tR\acluster\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12(\n" +
+	"\x0ftracker_version\x18\x06 \x01(\tR\x0ftracker_version\x12.\n" +
+	"\x12kubernetes_version\x18\a \x01(\tR\x12kubernetes_version2\x9e\x02\n" +
+	"\rRecordService\x12\x83\x01\n" +
+	"\x16SubmitDeploymentRecord\x123.deploymenttracker.v1.SubmitDeploymentRecordRequest\x1a4.deploymenttracker.v1.SubmitDeploymentRecordResponse\x12\x86\x01\n" +
+	"\x17SubmitEnvironmentRecord\x124.deploymenttracker.v1.SubmitEnvironmentRecordRequest\x1a5.deploymenttracker.v1.SubmitEnvironmentRecordResponseB<Z:github.com/github/deployment-tracker/pkg/recordpb;recordpbb\x06proto3"
+
+var (
+	file_proto_deploymenttracker_v1_records_proto_rawDescOnce sync.Once
+	file_proto_deploymenttracker_v1_records_proto_rawDescData []byte
+)
+
+func file_proto_deploymenttracker_v1_records_proto_rawDescGZIP() []byte {
+	file_proto_deploymenttracker_v1_records_proto_rawDescOnce.Do(func() {
+		file_proto_deploymenttracker_v1_records_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_deploymenttracker_v1_records_proto_rawDesc), len(file_proto_deploymenttracker_v1_records_proto_rawDesc)))
+	})
+	return file_proto_deploymenttracker_v1_records_proto_rawDescData
+}
+
+var file_proto_deploymenttracker_v1_records_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_proto_deploymenttracker_v1_records_proto_goTypes = []any{
+	(*SubmitDeploymentRecordRequest)(nil),   // 0: deploymenttracker.v1.SubmitDeploymentRecordRequest
+	(*SubmitDeploymentRecordResponse)(nil),  // 1: deploymenttracker.v1.SubmitDeploymentRecordResponse
+	(*SubmitEnvironmentRecordRequest)(nil),  // 2: deploymenttracker.v1.SubmitEnvironmentRecordRequest
+	(*SubmitEnvironmentRecordResponse)(nil), // 3: deploymenttracker.v1.SubmitEnvironmentRecordResponse
+	(*DeploymentRecord)(nil),                // 4: deploymenttracker.v1.DeploymentRecord
+	(*Resources)(nil),                       // 5: deploymenttracker.v1.Resources
+	(*Topology)(nil),                        // 6: deploymenttracker.v1.Topology
+	(*Platform)(nil),                        // 7: deploymenttracker.v1.Platform
+	(*GitOps)(nil),                          // 8: deploymenttracker.v1.GitOps
+	(*EnvironmentRecord)(nil),               // 9: deploymenttracker.v1.EnvironmentRecord
+	nil,                                     // 10: deploymenttracker.v1.DeploymentRecord.MetadataEntry
+	nil,                                     // 11: deploymenttracker.v1.Resources.RequestsEntry
+	nil,                                     // 12: deploymenttracker.v1.Resources.LimitsEntry
+	(*timestamppb.Timestamp)(nil),           // 13: google.protobuf.Timestamp
+}
+var file_proto_deploymenttracker_v1_records_proto_depIdxs = []int32{
+	4,  // 0: deploymenttracker.v1.SubmitDeploymentRecordRequest.record:type_name -> deploymenttracker.v1.DeploymentRecord
+	9,  // 1: deploymenttracker.v1.SubmitEnvironmentRecordRequest.record:type_name -> deploymenttracker.v1.EnvironmentRecord
+	10, // 2: deploymenttracker.v1.DeploymentRecord.metadata:type_name -> deploymenttracker.v1.DeploymentRecord.MetadataEntry
+	5,  // 3: deploymenttracker.v1.DeploymentRecord.resources:type_name -> deploymenttracker.v1.Resources
+	13, // 4: deploymenttracker.v1.DeploymentRecord.observed_at:type_name -> google.protobuf.Timestamp
+	6,  // 5: deploymenttracker.v1.DeploymentRecord.topology:type_name -> deploymenttracker.v1.Topology
+	8,  // 6: deploymenttracker.v1.DeploymentRecord.gitops:type_name -> deploymenttracker.v1.GitOps
+	7,  // 7: deploymenttracker.v1.DeploymentRecord.platform:type_name -> deploymenttracker.v1.Platform
+	11, // 8: deploymenttracker.v1.Resources.requests:type_name -> deploymenttracker.v1.Resources.RequestsEntry
+	12, // 9: deploymenttracker.v1.Resources.limits:type_name -> deploymenttracker.v1.Resources.LimitsEntry
+	0,  // 10: deploymenttracker.v1.RecordService.SubmitDeploymentRecord:input_type -> deploymenttracker.v1.SubmitDeploymentRecordRequest
+	2,  // 11: deploymenttracker.v1.RecordService.SubmitEnvironmentRecord:input_type -> deploymenttracker.v1.SubmitEnvironmentRecordRequest
+	1,  // 12: deploymenttracker.v1.RecordService.SubmitDeploymentRecord:output_type -> deploymenttracker.v1.SubmitDeploymentRecordResponse
+	3,  // 13: deploymenttracker.v1.RecordService.SubmitEnvironmentRecord:output_type -> deploymenttracker.v1.SubmitEnvironmentRecordResponse
+	12, // [12:14] is the sub-list for method output_type
+	10, // [10:12] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_proto_deploymenttracker_v1_records_proto_init() }
+func file_proto_deploymenttracker_v1_records_proto_init() {
+	if File_proto_deploymenttracker_v1_records_proto != nil {
+		return
+	}
+	file_proto_deploymenttracker_v1_records_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_deploymenttracker_v1_records_proto_rawDesc), len(file_proto_deploymenttracker_v1_records_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_deploymenttracker_v1_records_proto_goTypes,
+		DependencyIndexes: file_proto_deploymenttracker_v1_records_proto_depIdxs,
+		MessageInfos:      file_proto_deploymenttracker_v1_records_proto_msgTypes,
+	}.Build()
+	File_proto_deploymenttracker_v1_records_proto = out.File
+	file_proto_deploymenttracker_v1_records_proto_goTypes = nil
+	file_proto_deploymenttracker_v1_records_proto_depIdxs = nil
+}
@@ -0,0 +1,175 @@
+// The record submission API of deployment-tracker, for deployment
+// sources other than the Kubernetes controller, e.g. CI jobs, and for
+// edge instances delivering their records to a relay. The messages
+// mirror the JSON records of pkg/deploymentrecord; the JSON names are
+// those of the deployment records API.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             (unknown)
+// source: proto/deploymenttracker/v1/records.proto
+
+package recordpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RecordService_SubmitDeploymentRecord_FullMethodName  = "/deploymenttracker.v1.RecordService/SubmitDeploymentRecord"
+	RecordService_SubmitEnvironmentRecord_FullMethodName = "/deploymenttracker.v1.RecordService/SubmitEnvironmentRecord"
+)
+
+// RecordServiceClient is the client API for RecordService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RecordService accepts deployment and environment records and posts
+// them to the deployment records API.
+type RecordServiceClient interface {
+	// SubmitDeploymentRecord posts a deployment record.
+	SubmitDeploymentRecord(ctx context.Context, in *SubmitDeploymentRecordRequest, opts ...grpc.CallOption) (*SubmitDeploymentRecordResponse, error)
+	// SubmitEnvironmentRecord posts an environment record.
+	SubmitEnvironmentRecord(ctx context.Context, in *SubmitEnvironmentRecordRequest, opts ...grpc.CallOption) (*SubmitEnvironmentRecordResponse, error)
+}
+
+type recordServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRecordServiceClient(cc grpc.ClientConnInterface) RecordServiceClient {
+	return &recordServiceClient{cc}
+}
+
+func (c *recordServiceClient) SubmitDeploymentRecord(ctx context.Context, in *SubmitDeploymentRecordRequest, opts ...grpc.CallOption) (*SubmitDeploymentRecordResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitDeploymentRecordResponse)
+	err := c.cc.Invoke(ctx, RecordService_SubmitDeploymentRecord_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recordServiceClient) SubmitEnvironmentRecord(ctx context.Context, in *SubmitEnvironmentRecordRequest, opts ...grpc.CallOption) (*SubmitEnvironmentRecordResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitEnvironmentRecordResponse)
+	err := c.cc.Invoke(ctx, RecordService_SubmitEnvironmentRecord_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RecordServiceServer is the server API for RecordService service.
+// All implementations must embed UnimplementedRecordServiceServer
+// for forward compatibility.
+//
+// RecordService accepts deployment and environment records and posts
+// them to the deployment records API.
+type RecordServiceServer interface {
+	// SubmitDeploymentRecord posts a deployment record.
+	SubmitDeploymentRecord(context.Context, *SubmitDeploymentRecordRequest) (*SubmitDeploymentRecordResponse, error)
+	// SubmitEnvironmentRecord posts an environment record.
+	SubmitEnvironmentRecord(context.Context, *SubmitEnvironmentRecordRequest) (*SubmitEnvironmentRecordResponse, error)
+	mustEmbedUnimplementedRecordServiceServer()
+}
+
+// UnimplementedRecordServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRecordServiceServer struct{}
+
+func (UnimplementedRecordServiceServer) SubmitDeploymentRecord(context.Context, *SubmitDeploymentRecordRequest) (*SubmitDeploymentRecordResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SubmitDeploymentRecord not implemented")
+}
+func (UnimplementedRecordServiceServer) SubmitEnvironmentRecord(context.Context, *SubmitEnvironmentRecordRequest) (*SubmitEnvironmentRecordResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SubmitEnvironmentRecord not implemented")
+}
+func (UnimplementedRecordServiceServer) mustEmbedUnimplementedRecordServiceServer() {}
+func (UnimplementedRecordServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeRecordServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RecordServiceServer will
+// result in compilation errors.
+type UnsafeRecordServiceServer interface {
+	mustEmbedUnimplementedRecordServiceServer()
+}
+
+func RegisterRecordServiceServer(s grpc.ServiceRegistrar, srv RecordServiceServer) {
+	// If the following call panics, it indicates UnimplementedRecordServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RecordService_ServiceDesc, srv)
+}
+
+func _RecordService_SubmitDeploymentRecord_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitDeploymentRecordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordServiceServer).SubmitDeploymentRecord(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecordService_SubmitDeploymentRecord_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordServiceServer).SubmitDeploymentRecord(ctx, req.(*SubmitDeploymentRecordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecordService_SubmitEnvironmentRecord_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitEnvironmentRecordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordServiceServer).SubmitEnvironmentRecord(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RecordService_SubmitEnvironmentRecord_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordServiceServer).SubmitEnvironmentRecord(ctx, req.(*SubmitEnvironmentRecordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RecordService_ServiceDesc is the grpc.ServiceDesc for RecordService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RecordService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "deploymenttracker.v1.RecordService",
+	HandlerType: (*RecordServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitDeploymentRecord",
+			Handler:    _RecordService_SubmitDeploymentRecord_Handler,
+		},
+		{
+			MethodName: "SubmitEnvironmentRecord",
+			Handler:    _RecordService_SubmitEnvironmentRecord_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/deploymenttracker/v1/records.proto",
+}
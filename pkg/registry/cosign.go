@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// cosignCertificateAnnotation holds the PEM encoded signing
+// certificate of keyless signatures on the layers of a signature
+// manifest.
+const cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+
+// Fulcio certificate extensions holding the OIDC issuer of the signer.
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Signatures describes the cosign signatures and attestations stored
+// for an image digest. The signatures are not verified: they show an
+// image was signed, not that the signature is valid or trusted.
+type Signatures struct {
+	// Signed is true if a signature is stored for the digest.
+	Signed bool
+	// Attested is true if an attestation, e.g. SLSA provenance, is
+	// stored for the digest.
+	Attested bool
+	// Identity is the subject (email or URI) of the certificate of the
+	// first keyless signature, and Issuer its OIDC issuer. Both are
+	// empty for signatures made with a key.
+	Identity string
+	Issuer   string
+}
+
+// CosignSignatures looks up the cosign signatures and attestations of
+// the digest in the repository of the image reference. They are found
+// with the tag scheme of cosign, e.g. "sha256-<hex>.sig".
+func (c *Client) CosignSignatures(ctx context.Context, ref, digest string) (*Signatures, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	algo, hex, ok := strings.Cut(digest, ":")
+	if !ok || algo == "" || hex == "" {
+		return nil, fmt.Errorf("invalid digest: %q", digest)
+	}
+	tag := algo + "-" + hex
+
+	res := &Signatures{}
+
+	r.Reference = tag + ".sig"
+	resp, err := c.manifest(ctx, http.MethodGet, r)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		res.Signed = true
+		res.Identity, res.Issuer, err = signerIdentity(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.Reference = tag + ".att"
+	resp, err = c.manifest(ctx, http.MethodHead, r)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		_ = resp.Body.Close()
+		res.Attested = true
+	}
+
+	return res, nil
+}
+
+// signerIdentity returns the subject and OIDC issuer of the first
+// signing certificate of the signature manifest, or empty strings if
+// its signatures have no certificate.
+func signerIdentity(body io.Reader) (string, string, error) {
+	var manifest struct {
+		Layers []struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, maxResponseBytes)).Decode(&manifest); err != nil {
+		return "", "", fmt.Errorf("failed to decode signature manifest: %w", err)
+	}
+
+	for _, layer := range manifest.Layers {
+		certPEM := layer.Annotations[cosignCertificateAnnotation]
+		if certPEM == "" {
+			continue
+		}
+		block, _ := pem.Decode([]byte(certPEM))
+		if block == nil {
+			return "", "", errors.New("invalid signing certificate: no PEM data")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid signing certificate: %w", err)
+		}
+
+		var identity string
+		switch {
+		case len(cert.EmailAddresses) > 0:
+			identity = cert.EmailAddresses[0]
+		case len(cert.URIs) > 0:
+			identity = cert.URIs[0].String()
+		}
+		return identity, certIssuer(cert), nil
+	}
+	return "", "", nil
+}
+
+// certIssuer returns the OIDC issuer of a Fulcio certificate, or an
+// empty string.
+func certIssuer(cert *x509.Certificate) string {
+	var legacy string
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidFulcioIssuer):
+			// The deprecated extension holds the raw string
+			legacy = string(ext.Value)
+		}
+	}
+	return legacy
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signingCertificate returns a PEM encoded certificate like those
+// issued by Fulcio for keyless signatures.
+func signingCertificate(t *testing.T, email, issuer string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	issuerExt, err := asn1.Marshal(issuer)
+	if err != nil {
+		t.Fatalf("failed to marshal issuer: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(10 * time.Minute),
+		EmailAddresses: []string{email},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidFulcioIssuerV2, Value: issuerExt},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCosignSignatures(t *testing.T) {
+	manifest, err := json.Marshal(map[string]any{
+		"layers": []map[string]any{
+			{"annotations": map[string]string{
+				cosignCertificateAnnotation: signingCertificate(t, "dev@example.com", "https://token.actions.githubusercontent.com"),
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal manifest: %v", err)
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/org/signed/manifests/sha256-a.sig":
+			_, _ = w.Write(manifest)
+		case "/v2/org/signed/manifests/sha256-a.att":
+		case "/v2/org/keyed/manifests/sha256-a.sig":
+			_, _ = w.Write([]byte(`{"layers":[{"annotations":{}}]}`))
+		case "/v2/org/broken/manifests/sha256-a.sig":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	c := NewClient(WithHTTPClient(srv.Client()))
+
+	tests := []struct {
+		name    string
+		repo    string
+		want    Signatures
+		wantErr bool
+	}{
+		{
+			name: "keyless signature and attestation",
+			repo: "org/signed",
+			want: Signatures{
+				Signed:   true,
+				Attested: true,
+				Identity: "dev@example.com",
+				Issuer:   "https://token.actions.githubusercontent.com",
+			},
+		},
+		{
+			name: "key signature",
+			repo: "org/keyed",
+			want: Signatures{Signed: true},
+		},
+		{
+			name: "unsigned",
+			repo: "org/unsigned",
+			want: Signatures{},
+		},
+		{
+			name:    "registry error",
+			repo:    "org/broken",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.CosignSignatures(context.Background(), host+"/"+tt.repo+":v1", "sha256:a")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("CosignSignatures() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CosignSignatures() unexpected error: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("CosignSignatures() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	if _, err := c.CosignSignatures(context.Background(), host+"/org/signed:v1", "invalid"); err == nil {
+		t.Error("CosignSignatures() with invalid digest expected error, got nil")
+	}
+}
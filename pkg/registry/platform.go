@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Platform is the platform an image manifest is built for, as in the
+// manifests of an OCI image index.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+	// OSVersion is the Windows build, e.g. 10.0.17763.
+	OSVersion string `json:"os.version,omitempty"`
+}
+
+// defaultVariants are the variants container runtimes pick for the
+// architectures without a variant set.
+var defaultVariants = map[string]string{
+	"arm64": "v8",
+	"arm":   "v7",
+}
+
+// PlatformDigest returns the digest of the manifest of the platform p
+// in the image index digest, in the repository of the image reference
+// ref: the platform-specific digest a node of the platform pulls for a
+// multi-arch image. It returns digest itself if it is the manifest of
+// a single-platform image, and ErrNotFound if the index has no
+// manifest for the platform.
+func (c *Client) PlatformDigest(ctx context.Context, ref, digest string, p Platform) (string, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	r.Reference = digest
+
+	resp, err := c.manifest(ctx, http.MethodGet, r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var index struct {
+		MediaType string `json:"mediaType"`
+		Manifests []struct {
+			Digest   string    `json:"digest"`
+			Platform *Platform `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&index); err != nil {
+		return "", fmt.Errorf("failed to decode manifest of %s: %w", digest, err)
+	}
+	if index.Manifests == nil {
+		return digest, nil
+	}
+
+	var candidates []Platform
+	var digests []string
+	for _, m := range index.Manifests {
+		// Attestation manifests have no or an unknown platform
+		if m.Platform != nil && m.Platform.OS == p.OS && m.Platform.Architecture == p.Architecture {
+			candidates = append(candidates, *m.Platform)
+			digests = append(digests, m.Digest)
+		}
+	}
+	if i := matchPlatform(candidates, p); i >= 0 {
+		return digests[i], nil
+	}
+	return "", fmt.Errorf("no manifest for %s/%s in %s: %w", p.OS, p.Architecture, digest, ErrNotFound)
+}
+
+// matchPlatform returns the index of the best match of p in the
+// candidates of its OS and architecture, or -1. The variant must match,
+// the default one of the architecture if p has none. Windows images of
+// the build of p are preferred, other builds only run with Hyper-V
+// isolation.
+func matchPlatform(candidates []Platform, p Platform) int {
+	variant := p.Variant
+	if variant == "" {
+		variant = defaultVariants[p.Architecture]
+	}
+	res := -1
+	for i, c := range candidates {
+		if c.Variant != "" && c.Variant != variant {
+			continue
+		}
+		if p.OSVersion != "" && strings.HasPrefix(c.OSVersion, p.OSVersion) {
+			return i
+		}
+		if res < 0 {
+			res = i
+		}
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testIndex = `{
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {"digest": "sha256:amd64", "platform": {"os": "linux", "architecture": "amd64"}},
+    {"digest": "sha256:armv6", "platform": {"os": "linux", "architecture": "arm", "variant": "v6"}},
+    {"digest": "sha256:armv7", "platform": {"os": "linux", "architecture": "arm", "variant": "v7"}},
+    {"digest": "sha256:arm64", "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+    {"digest": "sha256:ltsc2019", "platform": {"os": "windows", "architecture": "amd64", "os.version": "10.0.17763.5329"}},
+    {"digest": "sha256:ltsc2022", "platform": {"os": "windows", "architecture": "amd64", "os.version": "10.0.20348.2227"}},
+    {"digest": "sha256:attestation", "platform": {"os": "unknown", "architecture": "unknown"}}
+  ]
+}`
+
+func TestPlatformDigest(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/org/multi/manifests/sha256:index":
+			_, _ = w.Write([]byte(testIndex))
+		case "/v2/org/single/manifests/sha256:single":
+			_, _ = w.Write([]byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	c := NewClient(WithHTTPClient(srv.Client()))
+
+	tests := []struct {
+		name     string
+		repo     string
+		digest   string
+		platform Platform
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "amd64",
+			repo:     "org/multi",
+			digest:   "sha256:index",
+			platform: Platform{OS: "linux", Architecture: "amd64"},
+			want:     "sha256:amd64",
+		},
+		{
+			name:     "arm64 default variant",
+			repo:     "org/multi",
+			digest:   "sha256:index",
+			platform: Platform{OS: "linux", Architecture: "arm64"},
+			want:     "sha256:arm64",
+		},
+		{
+			name:     "arm default variant",
+			repo:     "org/multi",
+			digest:   "sha256:index",
+			platform: Platform{OS: "linux", Architecture: "arm"},
+			want:     "sha256:armv7",
+		},
+		{
+			name:     "arm variant",
+			repo:     "org/multi",
+			digest:   "sha256:index",
+			platform: Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			want:     "sha256:armv6",
+		},
+		{
+			name:     "windows build",
+			repo:     "org/multi",
+			digest:   "sha256:index",
+			platform: Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348"},
+			want:     "sha256:ltsc2022",
+		},
+		{
+			name:     "windows without build",
+			repo:     "org/multi",
+			digest:   "sha256:index",
+			platform: Platform{OS: "windows", Architecture: "amd64"},
+			want:     "sha256:ltsc2019",
+		},
+		{
+			name:     "missing platform",
+			repo:     "org/multi",
+			digest:   "sha256:index",
+			platform: Platform{OS: "linux", Architecture: "s390x"},
+			wantErr:  ErrNotFound,
+		},
+		{
+			name:     "single platform",
+			repo:     "org/single",
+			digest:   "sha256:single",
+			platform: Platform{OS: "linux", Architecture: "arm64"},
+			want:     "sha256:single",
+		},
+		{
+			name:     "missing manifest",
+			repo:     "org/missing",
+			digest:   "sha256:index",
+			platform: Platform{OS: "linux", Architecture: "amd64"},
+			wantErr:  ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.PlatformDigest(context.Background(), host+"/"+tt.repo+":v1", tt.digest, tt.platform)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("PlatformDigest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlatformDigest() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PlatformDigest() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// Package registry resolves image references against OCI distribution
+// registries, e.g. to verify the digest a node pulled is the one the
+// registry serves.
+package registry
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/image"
+)
+
+const (
+	// dockerHubHost serves the Docker Hub registry API.
+	dockerHubHost = "registry-1.docker.io"
+
+	// defaultTokenLifetime is used for tokens without an expiry, the
+	// minimum lifetime of the distribution token spec.
+	defaultTokenLifetime = 60 * time.Second
+
+	// maxResponseBytes is the maximum size of a response body read
+	// from a registry.
+	maxResponseBytes = 1 << 20
+)
+
+// manifestMediaTypes are accepted when resolving a reference, so the
+// registry serves the same (index) digest a node pulls.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ErrNotFound is returned when the registry has no manifest for the
+// reference.
+var ErrNotFound = errors.New("manifest not found")
+
+// Client resolves image references with HEAD manifest requests.
+// Registries requiring a token are accessed anonymously with the
+// bearer token flow of the distribution spec, so only public images
+// can be resolved.
+type Client struct {
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]token
+}
+
+type token struct {
+	value   string
+	expires time.Time
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for registry requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// NewClient creates a registry client.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		tokens: make(map[string]token),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Reference is an image reference split into the registry host, the
+// repository and the tag or digest to resolve.
+type Reference struct {
+	Host       string
+	Repository string
+	// Reference is the digest if the image reference has one, else
+	// the tag ("latest" if neither is set).
+	Reference string
+}
+
+// ParseReference splits a container image reference, e.g.
+// "nginx:1.21" or "ghcr.io/org/app@sha256:...". References without a
+// registry host resolve against Docker Hub.
+func ParseReference(ref string) (Reference, error) {
+	parsed, err := image.ParseReference(ref)
+	if err != nil {
+		return Reference{}, err
+	}
+
+	parsed = parsed.Normalize()
+
+	res := Reference{
+		Host:       parsed.Registry,
+		Repository: parsed.Repository,
+		Reference:  cmp.Or(parsed.Digest, parsed.Tag, "latest"),
+	}
+	if res.Host == image.DockerHub {
+		res.Host = dockerHubHost
+	}
+	return res, nil
+}
+
+// Digest returns the digest the registry serves for the image
+// reference. For references with a digest, it confirms the registry
+// has the manifest. Returns ErrNotFound if it doesn't.
+func (c *Client) Digest(ctx context.Context, ref string) (string, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.manifest(ctx, http.MethodHead, r)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		if strings.HasPrefix(r.Reference, "sha256:") {
+			return r.Reference, nil
+		}
+		return "", fmt.Errorf("registry returned no digest for %s", ref)
+	}
+	return digest, nil
+}
+
+// manifest requests the manifest of the reference, authenticating
+// with an anonymous token if the registry asks for one. The caller
+// must close the body of the response. Returns ErrNotFound if the
+// registry has no manifest for the reference.
+func (c *Client) manifest(ctx context.Context, method string, r Reference) (*http.Response, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/manifests/%s", r.Host, r.Repository, r.Reference)
+	resp, err := c.send(ctx, method, u, c.cachedToken(r))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		_ = resp.Body.Close()
+		tok, err := c.fetchToken(ctx, r, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.send(ctx, method, u, tok)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		name := r.Host + "/" + r.Repository + ":" + r.Reference
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		return nil, fmt.Errorf("unexpected status code resolving %s: %d", name, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// send sends a manifest request, with the bearer token if set.
+func (c *Client) send(ctx context.Context, method, u, tok string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if tok != "" {
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// cachedToken returns the unexpired token for the repository, if any.
+func (c *Client) cachedToken(r Reference) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tok, ok := c.tokens[r.Host+"/"+r.Repository]
+	if !ok || time.Now().After(tok.expires) {
+		return ""
+	}
+	return tok.value
+}
+
+// fetchToken requests an anonymous pull token for the repository from
+// the realm of the bearer challenge, and caches it.
+func (c *Client) fetchToken(ctx context.Context, r Reference, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry %s requires unsupported authentication: %q", r.Host, scheme)
+	}
+	attrs := parseChallenge(params)
+	realm := attrs["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry %s sent a bearer challenge without realm", r.Host)
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", realm, err)
+	}
+	q := u.Query()
+	if service := attrs["service"]; service != "" {
+		q.Set("service", service)
+	}
+	scope := attrs["scope"]
+	if scope == "" {
+		scope = "repository:" + r.Repository + ":pull"
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code requesting a token for %s: %d", r.Repository, resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	tok := body.Token
+	if tok == "" {
+		tok = body.AccessToken
+	}
+	if tok == "" {
+		return "", fmt.Errorf("token response for %s has no token", r.Repository)
+	}
+
+	lifetime := defaultTokenLifetime
+	if body.ExpiresIn > 0 {
+		lifetime = time.Duration(body.ExpiresIn) * time.Second
+	}
+	c.mu.Lock()
+	c.tokens[r.Host+"/"+r.Repository] = token{value: tok, expires: time.Now().Add(lifetime)}
+	c.mu.Unlock()
+	return tok, nil
+}
+
+// parseChallenge parses the comma separated key="value" parameters of
+// a WWW-Authenticate challenge. Values may contain commas, e.g. in
+// scopes of several repositories.
+func parseChallenge(params string) map[string]string {
+	res := make(map[string]string)
+	for {
+		params = strings.TrimLeft(params, " ,")
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			return res
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, params, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, params, _ = strings.Cut(rest, ",")
+		}
+		res[key] = value
+	}
+}
@@ -0,0 +1,157 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want Reference
+	}{
+		{
+			ref:  "nginx",
+			want: Reference{Host: "registry-1.docker.io", Repository: "library/nginx", Reference: "latest"},
+		},
+		{
+			ref:  "nginx:1.21",
+			want: Reference{Host: "registry-1.docker.io", Repository: "library/nginx", Reference: "1.21"},
+		},
+		{
+			ref:  "bitnami/redis:7",
+			want: Reference{Host: "registry-1.docker.io", Repository: "bitnami/redis", Reference: "7"},
+		},
+		{
+			ref:  "docker.io/library/nginx:1.21",
+			want: Reference{Host: "registry-1.docker.io", Repository: "library/nginx", Reference: "1.21"},
+		},
+		{
+			ref:  "ghcr.io/org/app:v1@sha256:abc",
+			want: Reference{Host: "ghcr.io", Repository: "org/app", Reference: "sha256:abc"},
+		},
+		{
+			ref:  "localhost:5000/app:v1",
+			want: Reference{Host: "localhost:5000", Repository: "app", Reference: "v1"},
+		},
+		{
+			ref:  "localhost/app",
+			want: Reference{Host: "localhost", Repository: "app", Reference: "latest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseReference(tt.ref)
+			if err != nil {
+				t.Fatalf("ParseReference(%q) unexpected error: %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReference(%q) = %+v, want %+v", tt.ref, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseReference(""); err == nil {
+		t.Error("ParseReference(\"\") expected error, got nil")
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	got := parseChallenge(`realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a:pull,push"`)
+	want := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a:pull,push",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseChallenge() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("parseChallenge()[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	const digest = "sha256:0123456789abcdef"
+
+	var tokenRequests int
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenRequests++
+			if r.URL.Query().Get("scope") != "repository:org/app:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"anonymous","expires_in":300}`))
+		case r.Header.Get("Authorization") != "Bearer anonymous":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:org/app:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method != http.MethodHead || !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json"):
+			w.WriteHeader(http.StatusBadRequest)
+		case r.URL.Path == "/v2/org/app/manifests/v1", r.URL.Path == "/v2/org/app/manifests/"+digest:
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	c := NewClient(WithHTTPClient(srv.Client()))
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "tag",
+			ref:  host + "/org/app:v1",
+			want: digest,
+		},
+		{
+			name: "digest",
+			ref:  host + "/org/app@" + digest,
+			want: digest,
+		},
+		{
+			name:    "missing tag",
+			ref:     host + "/org/app:v2",
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Digest(context.Background(), tt.ref)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Digest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Digest() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Digest() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// The token is cached for its lifetime
+	if tokenRequests != 1 {
+		t.Errorf("token requests = %d, want 1", tokenRequests)
+	}
+}
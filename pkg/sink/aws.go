@@ -0,0 +1,33 @@
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsConfig loads the AWS configuration of the region. Requests are
+// signed with the credentials of the default chain of the SDK: the
+// environment, a web identity token (EKS IAM roles for service
+// accounts), EKS Pod Identity or the instance role.
+func awsConfig(region string) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithRegion(region),
+		config.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(10*time.Second)),
+	)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,212 @@
+package sink
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// repositoryPattern matches owner/name repository names, keeping them
+// from escaping the API path.
+var repositoryPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// GitHubDeployments mirrors the posted deployment records to GitHub
+// Deployments on the source repository of each record, so the
+// environments of the repository reflect what runs in the cluster.
+// Records without a repository, see Event.Repository, are skipped.
+//
+// A deployed record creates a Deployment of the record's commit (or
+// version) to its logical environment, with a success status, which
+// GitHub uses to mark the previous deployments to the environment
+// inactive. A partially deployed record gets an in_progress status
+// instead. A decommissioned record marks the Deployments created for
+// its deployment name and digest inactive.
+type GitHubDeployments struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewGitHubDeployments creates a GitHub Deployments sink calling the
+// REST API at baseURL, e.g. api.github.com, authenticated with token,
+// which needs write access to the deployments of the repositories.
+// Returns an error if baseURL is not HTTPS for non-local hosts.
+func NewGitHubDeployments(baseURL, token string) (*GitHubDeployments, error) {
+	isLocal := strings.HasPrefix(baseURL, "http://localhost") ||
+		strings.HasPrefix(baseURL, "http://127.0.0.1")
+
+	switch {
+	case strings.HasPrefix(baseURL, "https://"):
+	case strings.HasPrefix(baseURL, "http://") && isLocal:
+	case !strings.Contains(baseURL, "://"):
+		baseURL = "https://" + baseURL
+	default:
+		return nil, fmt.Errorf("insecure or invalid GitHub API URL: %s (use HTTPS for non-local hosts)", baseURL)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("a token is required for GitHub Deployments")
+	}
+
+	return &GitHubDeployments{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// Name returns the sink name.
+func (g *GitHubDeployments) Name() string {
+	return "github-deployments"
+}
+
+// githubDeployment is the subset of a GitHub Deployment used by the
+// sink. The payload holds the record the Deployment was created for.
+type githubDeployment struct {
+	ID      int64             `json:"id"`
+	Payload deploymentPayload `json:"payload"`
+}
+
+type deploymentPayload struct {
+	DeploymentName string `json:"deployment_name"`
+	Name           string `json:"name"`
+	Digest         string `json:"digest"`
+	Cluster        string `json:"cluster,omitempty"`
+}
+
+// Send mirrors the deployment record of the event to GitHub
+// Deployments. Other events, and records without a repository or a
+// ref, are skipped.
+func (g *GitHubDeployments) Send(ctx context.Context, event Event) error {
+	record, ok := event.Record.(*deploymentrecord.DeploymentRecord)
+	if event.Type != EventDeploymentRecord || !ok || event.Repository == "" {
+		return nil
+	}
+	if !repositoryPattern.MatchString(event.Repository) {
+		return fmt.Errorf("invalid repository: %s (expected owner/name)", event.Repository)
+	}
+	repo := "/repos/" + event.Repository
+
+	switch record.Status {
+	case deploymentrecord.StatusDecommissioned:
+		return g.deactivate(ctx, repo, record)
+	case deploymentrecord.StatusPartiallyDeployed:
+		return g.deploy(ctx, repo, record, "in_progress")
+	default:
+		return g.deploy(ctx, repo, record, "success")
+	}
+}
+
+// deploy creates a Deployment of the record, with a status of state.
+func (g *GitHubDeployments) deploy(ctx context.Context, repo string, record *deploymentrecord.DeploymentRecord, state string) error {
+	ref := cmp.Or(record.CommitSHA, record.Version)
+	if ref == "" {
+		return nil
+	}
+
+	var deployment githubDeployment
+	err := g.do(ctx, http.MethodPost, repo+"/deployments", map[string]any{
+		"ref":               ref,
+		"task":              "deploy",
+		"environment":       record.LogicalEnvironment,
+		"description":       record.DeploymentName,
+		"auto_merge":        false,
+		"required_contexts": []string{},
+		"payload": deploymentPayload{
+			DeploymentName: record.DeploymentName,
+			Name:           record.Name,
+			Digest:         record.Digest,
+			Cluster:        record.Cluster,
+		},
+	}, &deployment)
+	if err != nil {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+	return g.setStatus(ctx, repo, deployment.ID, record, state)
+}
+
+// deactivate marks the Deployments of the record's deployment name
+// and digest inactive. Only the most recent page of Deployments to
+// the environment is searched.
+func (g *GitHubDeployments) deactivate(ctx context.Context, repo string, record *deploymentrecord.DeploymentRecord) error {
+	var deployments []githubDeployment
+	path := repo + "/deployments?task=deploy&per_page=100&environment=" + url.QueryEscape(record.LogicalEnvironment)
+	if err := g.do(ctx, http.MethodGet, path, nil, &deployments); err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range deployments {
+		if d.Payload.DeploymentName != record.DeploymentName || d.Payload.Digest != record.Digest {
+			continue
+		}
+		if err := g.setStatus(ctx, repo, d.ID, record, "inactive"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setStatus creates a deployment status of state on the Deployment.
+func (g *GitHubDeployments) setStatus(ctx context.Context, repo string, id int64, record *deploymentrecord.DeploymentRecord, state string) error {
+	err := g.do(ctx, http.MethodPost, fmt.Sprintf("%s/deployments/%d/statuses", repo, id), map[string]any{
+		"state":       state,
+		"environment": record.LogicalEnvironment,
+		"description": fmt.Sprintf("%s %s", record.DeploymentName, record.Status),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create deployment status: %w", err)
+	}
+	return nil
+}
+
+// do sends a request to the API path with the JSON body, if any, and
+// decodes the JSON response into out, if not nil.
+func (g *GitHubDeployments) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
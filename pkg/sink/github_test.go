@@ -0,0 +1,190 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// fakeDeploymentsAPI records the requests to the GitHub Deployments
+// API, and serves the deployments.
+type fakeDeploymentsAPI struct {
+	mu          sync.Mutex
+	requests    []string
+	statuses    []string
+	deployments []githubDeployment
+}
+
+func (f *fakeDeploymentsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer t0ken" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	switch {
+	case r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.deployments)
+	case r.URL.Path == "/repos/org/web/deployments":
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(githubDeployment{ID: 42})
+	default:
+		f.statuses = append(f.statuses, r.URL.Path+" "+body["state"].(string))
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestGitHubDeploymentsSend(t *testing.T) {
+	newRecord := func(status string) *deploymentrecord.DeploymentRecord {
+		record := deploymentrecord.NewDeploymentRecord("ghcr.io/org/web", "sha256:abc", "v1.2.0",
+			"production", "", "kube-1", status, "default/web/app")
+		record.CommitSHA = "0123456"
+		return record
+	}
+	partial := newRecord(deploymentrecord.StatusDeployed)
+	partial.Status = deploymentrecord.StatusPartiallyDeployed
+
+	tests := []struct {
+		name     string
+		event    Event
+		requests int
+		statuses []string
+		wantErr  bool
+	}{
+		{
+			name: "deployed",
+			event: Event{
+				Type:       EventDeploymentRecord,
+				Record:     newRecord(deploymentrecord.StatusDeployed),
+				Repository: "org/web",
+			},
+			requests: 2,
+			statuses: []string{"/repos/org/web/deployments/42/statuses success"},
+		},
+		{
+			name: "partially deployed",
+			event: Event{
+				Type:       EventDeploymentRecord,
+				Record:     partial,
+				Repository: "org/web",
+			},
+			requests: 2,
+			statuses: []string{"/repos/org/web/deployments/42/statuses in_progress"},
+		},
+		{
+			name: "decommissioned",
+			event: Event{
+				Type:       EventDeploymentRecord,
+				Record:     newRecord(deploymentrecord.StatusDecommissioned),
+				Repository: "org/web",
+			},
+			requests: 2,
+			statuses: []string{"/repos/org/web/deployments/7/statuses inactive"},
+		},
+		{
+			name: "no repository",
+			event: Event{
+				Type:   EventDeploymentRecord,
+				Record: newRecord(deploymentrecord.StatusDeployed),
+			},
+		},
+		{
+			name: "environment record",
+			event: Event{
+				Type:       EventEnvironmentRecord,
+				Record:     &deploymentrecord.EnvironmentRecord{},
+				Repository: "org/web",
+			},
+		},
+		{
+			name: "invalid repository",
+			event: Event{
+				Type:       EventDeploymentRecord,
+				Record:     newRecord(deploymentrecord.StatusDeployed),
+				Repository: "org/web/../../admin",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeDeploymentsAPI{deployments: []githubDeployment{
+				{ID: 7, Payload: deploymentPayload{DeploymentName: "default/web/app", Digest: "sha256:abc"}},
+				{ID: 8, Payload: deploymentPayload{DeploymentName: "default/web/app", Digest: "sha256:old"}},
+				{ID: 9, Payload: deploymentPayload{DeploymentName: "default/api/app", Digest: "sha256:abc"}},
+			}}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+			g, err := NewGitHubDeployments(srv.URL, "t0ken")
+			if err != nil {
+				t.Fatalf("NewGitHubDeployments() unexpected error: %v", err)
+			}
+
+			err = g.Send(context.Background(), tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(api.requests) != tt.requests {
+				t.Errorf("requests = %v, expected %d", api.requests, tt.requests)
+			}
+			if len(api.statuses) != len(tt.statuses) {
+				t.Fatalf("statuses = %v, expected %v", api.statuses, tt.statuses)
+			}
+			for i, status := range tt.statuses {
+				if api.statuses[i] != status {
+					t.Errorf("status %d = %s, expected %s", i, api.statuses[i], status)
+				}
+			}
+		})
+	}
+}
+
+func TestNewGitHubDeployments(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "no scheme",
+			url:   "api.github.com",
+			token: "t0ken",
+		},
+		{
+			name:  "local http",
+			url:   "http://localhost:8080",
+			token: "t0ken",
+		},
+		{
+			name:    "remote http",
+			url:     "http://github.example.com/api/v3",
+			token:   "t0ken",
+			wantErr: true,
+		},
+		{
+			name:    "no token",
+			url:     "api.github.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGitHubDeployments(tt.url, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewGitHubDeployments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
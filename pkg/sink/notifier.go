@@ -0,0 +1,174 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Notifier formats.
+const (
+	NotifyFormatSlack = "slack"
+	NotifyFormatTeams = "teams"
+)
+
+// DefaultNotifyTemplate is the default text/template of notification
+// messages, executed with the Event.
+const DefaultNotifyTemplate = "{{.Record.DeploymentName}} {{.Record.Status}}: {{.Record.Name}}:{{.Record.Version}} ({{.Record.Digest}})"
+
+// Notifier posts a chat message to a Slack or Microsoft Teams incoming
+// webhook when a deployment record matching its selector is deployed
+// or decommissioned. Other records, e.g. of partial rollouts, and
+// environment records are skipped.
+type Notifier struct {
+	encoder
+	url        string
+	format     string
+	namespaces []string
+	selector   labels.Selector
+	tmpl       *template.Template
+	httpClient *http.Client
+}
+
+// NewNotifier creates a notifier posting to the webhook url in format,
+// NotifyFormatSlack or NotifyFormatTeams. Only the records of the
+// namespaces (comma separated, empty for all) and of pods matching the
+// label selector (empty for all) are notified. The message is
+// rendered with the text/template tmpl, DefaultNotifyTemplate if
+// empty. Returns an error if url is not HTTPS, or the format, selector
+// or template are invalid.
+func NewNotifier(url, format, namespaces, selector, tmpl string, opts ...Option) (*Notifier, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("insecure or invalid notification webhook URL: %s (use HTTPS)", url)
+	}
+	switch format {
+	case "":
+		format = NotifyFormatSlack
+	case NotifyFormatSlack, NotifyFormatTeams:
+	default:
+		return nil, fmt.Errorf("invalid notification format: %s (expected %s or %s)", format, NotifyFormatSlack, NotifyFormatTeams)
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification selector: %w", err)
+	}
+	if tmpl == "" {
+		tmpl = DefaultNotifyTemplate
+	}
+	t, err := template.New("notification").Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+
+	var nsList []string
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			nsList = append(nsList, ns)
+		}
+	}
+	n := &Notifier{
+		url:        url,
+		format:     format,
+		namespaces: nsList,
+		selector:   sel,
+		tmpl:       t,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+	for _, opt := range opts {
+		opt(&n.encoder)
+	}
+	return n, nil
+}
+
+// Name returns the sink name.
+func (n *Notifier) Name() string {
+	return "notifier-" + n.format
+}
+
+// matches returns true if the event should be notified.
+func (n *Notifier) matches(event Event) bool {
+	record, ok := event.Record.(*deploymentrecord.DeploymentRecord)
+	if event.Type != EventDeploymentRecord || !ok {
+		return false
+	}
+	if record.Status != deploymentrecord.StatusDeployed && record.Status != deploymentrecord.StatusDecommissioned {
+		return false
+	}
+	if len(n.namespaces) > 0 && !slices.Contains(n.namespaces, event.Namespace) {
+		return false
+	}
+	return n.selector.Matches(labels.Set(event.Labels))
+}
+
+// Send posts the message of the event, if it matches.
+func (n *Notifier) Send(ctx context.Context, event Event) error {
+	if !n.matches(event) {
+		return nil
+	}
+	event, err := n.mapRecord(event)
+	if err != nil {
+		return fmt.Errorf("failed to map record: %w", err)
+	}
+	var text strings.Builder
+	if err := n.tmpl.Execute(&text, event); err != nil {
+		return fmt.Errorf("failed to render notification: %w", err)
+	}
+	body, err := json.Marshal(n.payload(text.String()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification request failed: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// payload returns the webhook payload of the message text: a Slack
+// message, or a Teams message with an Adaptive Card, as accepted by
+// both Workflows and Office 365 connector webhooks.
+func (n *Notifier) payload(text string) any {
+	if n.format == NotifyFormatSlack {
+		return map[string]any{"text": text}
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []map[string]any{{
+					"type": "TextBlock",
+					"text": text,
+					"wrap": true,
+				}},
+			},
+		}},
+	}
+}
@@ -0,0 +1,191 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+func TestNewNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		format   string
+		selector string
+		tmpl     string
+		wantErr  bool
+	}{
+		{
+			name: "defaults",
+			url:  "https://hooks.slack.com/services/T0/B0/x",
+		},
+		{
+			name:     "teams",
+			url:      "https://example.webhook.office.com/webhookb2/x",
+			format:   NotifyFormatTeams,
+			selector: "tier in (frontend, backend),!canary",
+			tmpl:     "{{.Namespace}}: {{.Record.DeploymentName}}",
+		},
+		{
+			name:    "http",
+			url:     "http://hooks.slack.com/services/T0/B0/x",
+			wantErr: true,
+		},
+		{
+			name:    "unknown format",
+			url:     "https://hooks.slack.com/services/T0/B0/x",
+			format:  "irc",
+			wantErr: true,
+		},
+		{
+			name:     "invalid selector",
+			url:      "https://hooks.slack.com/services/T0/B0/x",
+			selector: "tier in (",
+			wantErr:  true,
+		},
+		{
+			name:    "invalid template",
+			url:     "https://hooks.slack.com/services/T0/B0/x",
+			tmpl:    "{{.Record.DeploymentName",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNotifier(tt.url, tt.format, "", tt.selector, tt.tmpl)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotifierSend(t *testing.T) {
+	newEvent := func(ns, status string, labels map[string]string) Event {
+		return Event{
+			Type: EventDeploymentRecord,
+			Record: deploymentrecord.NewDeploymentRecord("ghcr.io/org/web", "sha256:abc", "v1",
+				"production", "", "kube-1", status, ns+"/web/app"),
+			Namespace: ns,
+			Labels:    labels,
+		}
+	}
+	partial := newEvent("shop", deploymentrecord.StatusDeployed, nil)
+	partial.Record.(*deploymentrecord.DeploymentRecord).Status = deploymentrecord.StatusPartiallyDeployed
+
+	tests := []struct {
+		name       string
+		format     string
+		namespaces string
+		selector   string
+		event      Event
+		expected   string
+	}{
+		{
+			name:     "slack",
+			event:    newEvent("shop", deploymentrecord.StatusDeployed, nil),
+			expected: `{"text":"shop/web/app deployed: ghcr.io/org/web:v1 (sha256:abc)"}`,
+		},
+		{
+			name:     "teams",
+			format:   NotifyFormatTeams,
+			event:    newEvent("shop", deploymentrecord.StatusDecommissioned, nil),
+			expected: `{"attachments":[{"content":{"$schema":"http://adaptivecards.io/schemas/adaptive-card.json","body":[{"text":"shop/web/app decommissioned: ghcr.io/org/web:v1 (sha256:abc)","type":"TextBlock","wrap":true}],"type":"AdaptiveCard","version":"1.4"},"contentType":"application/vnd.microsoft.card.adaptive"}],"type":"message"}`,
+		},
+		{
+			name:       "selected",
+			namespaces: "shop, payments",
+			selector:   "tier=frontend",
+			event:      newEvent("shop", deploymentrecord.StatusDeployed, map[string]string{"tier": "frontend"}),
+			expected:   `{"text":"shop/web/app deployed: ghcr.io/org/web:v1 (sha256:abc)"}`,
+		},
+		{
+			name:       "other namespace",
+			namespaces: "payments",
+			event:      newEvent("shop", deploymentrecord.StatusDeployed, nil),
+		},
+		{
+			name:     "unmatched labels",
+			selector: "tier=frontend",
+			event:    newEvent("shop", deploymentrecord.StatusDeployed, map[string]string{"tier": "backend"}),
+		},
+		{
+			name:  "partially deployed",
+			event: partial,
+		},
+		{
+			name:  "environment record",
+			event: Event{Type: EventEnvironmentRecord, Record: &deploymentrecord.EnvironmentRecord{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("failed to decode notification: %v", err)
+				}
+				data, _ := json.Marshal(body)
+				got = string(data)
+			}))
+			defer srv.Close()
+
+			n, err := NewNotifier(srv.URL, tt.format, tt.namespaces, tt.selector, "")
+			if err != nil {
+				t.Fatalf("NewNotifier() unexpected error: %v", err)
+			}
+			n.httpClient = srv.Client()
+
+			if err := n.Send(context.Background(), tt.event); err != nil {
+				t.Fatalf("Send() unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("notification = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNotifierFieldMapping(t *testing.T) {
+	var got string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode notification: %v", err)
+		}
+		got = body.Text
+	}))
+	defer srv.Close()
+
+	fields, err := deploymentrecord.NewFieldMapping(deploymentrecord.ProfileCamelCase, "name=image")
+	if err != nil {
+		t.Fatalf("NewFieldMapping() unexpected error: %v", err)
+	}
+	// The template is executed with the mapped record
+	n, err := NewNotifier(srv.URL, "", "", "", "{{.Record.deploymentName}} {{.Record.status}}: {{.Record.image}}", WithFieldMapping(fields))
+	if err != nil {
+		t.Fatalf("NewNotifier() unexpected error: %v", err)
+	}
+	n.httpClient = srv.Client()
+
+	err = n.Send(context.Background(), Event{
+		Type: EventDeploymentRecord,
+		Record: deploymentrecord.NewDeploymentRecord("ghcr.io/org/web", "sha256:abc", "v1",
+			"production", "", "kube-1", deploymentrecord.StatusDeployed, "shop/web/app"),
+	})
+	if err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+	if expected := "shop/web/app deployed: ghcr.io/org/web"; got != expected {
+		t.Errorf("notification = %q, expected %q", got, expected)
+	}
+}
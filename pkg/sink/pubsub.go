@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// pubSubTopicPattern matches Pub/Sub topic names.
+var pubSubTopicPattern = regexp.MustCompile(`^projects/[a-z0-9:.-]+/topics/[A-Za-z][A-Za-z0-9._~%+-]{2,254}$`)
+
+// pubSubScope is the OAuth scope of the Pub/Sub API.
+const pubSubScope = "https://www.googleapis.com/auth/pubsub"
+
+// PubSub publishes events as JSON messages to a Google Cloud Pub/Sub
+// topic, with the event type in the type attribute. Requests are
+// authenticated with the Application Default Credentials, e.g. the
+// pod's service account from the GKE metadata server with Workload
+// Identity Federation.
+type PubSub struct {
+	encoder
+	topic string
+	// endpoint is the Pub/Sub API, set for tests
+	endpoint string
+
+	mu sync.Mutex
+	// httpClient authenticates requests, created on first use
+	httpClient *http.Client
+}
+
+// NewPubSub creates a Pub/Sub sink publishing to topic, as
+// projects/<project>/topics/<topic>. Returns an error if topic is not
+// a topic name.
+func NewPubSub(topic string, opts ...Option) (*PubSub, error) {
+	if !pubSubTopicPattern.MatchString(topic) {
+		return nil, fmt.Errorf("invalid Pub/Sub topic: %s (expected projects/<project>/topics/<topic>)", topic)
+	}
+	p := &PubSub{
+		topic:    topic,
+		endpoint: "https://pubsub.googleapis.com",
+	}
+	for _, opt := range opts {
+		opt(&p.encoder)
+	}
+	return p, nil
+}
+
+// Name returns the sink name.
+func (p *PubSub) Name() string {
+	return "pubsub"
+}
+
+// Send publishes the event to the topic.
+func (p *PubSub) Send(ctx context.Context, event Event) error {
+	event, err := p.mapRecord(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	body, err := json.Marshal(map[string]any{
+		"messages": []map[string]any{{
+			"data":       base64.StdEncoding.EncodeToString(message),
+			"attributes": map[string]string{"type": event.Type},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	client, err := p.client()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/v1/"+p.topic+":publish", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pubsub request failed: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// client returns the HTTP client authenticating requests with the
+// Application Default Credentials. Access tokens are cached until
+// shortly before they expire.
+func (p *PubSub) client() (*http.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.httpClient != nil {
+		return p.httpClient, nil
+	}
+
+	// The token source outlives the request, it must not use its
+	// context
+	ts, err := google.DefaultTokenSource(context.Background(), pubSubScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Google credentials: %w", err)
+	}
+	p.httpClient = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, ts),
+			Base:   http.DefaultTransport,
+		},
+	}
+	return p.httpClient, nil
+}
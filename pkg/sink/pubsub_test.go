@@ -0,0 +1,86 @@
+package sink
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestNewPubSub(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			topic: "projects/my-project/topics/records",
+		},
+		{
+			name:    "topic only",
+			topic:   "records",
+			wantErr: true,
+		},
+		{
+			name:    "path traversal",
+			topic:   "projects/my-project/topics/../subscriptions/x",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPubSub(tt.topic)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPubSub() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPubSubSend(t *testing.T) {
+	var auth, path, data, eventType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		path = r.URL.Path
+		var req struct {
+			Messages []struct {
+				Data       string            `json:"data"`
+				Attributes map[string]string `json:"attributes"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) != 1 {
+			t.Errorf("failed to decode request: %v", err)
+			return
+		}
+		decoded, _ := base64.StdEncoding.DecodeString(req.Messages[0].Data)
+		data = string(decoded)
+		eventType = req.Messages[0].Attributes["type"]
+	}))
+	defer srv.Close()
+
+	p, err := NewPubSub("projects/my-project/topics/records")
+	if err != nil {
+		t.Fatalf("NewPubSub() unexpected error: %v", err)
+	}
+	p.endpoint = srv.URL
+	p.httpClient = oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "ya29.token"}))
+
+	if err := p.Send(context.Background(), Event{Type: EventDeploymentRecord, Record: map[string]string{"name": "web"}}); err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+	if auth != "Bearer ya29.token" {
+		t.Errorf("Authorization = %s", auth)
+	}
+	if path != "/v1/projects/my-project/topics/records:publish" {
+		t.Errorf("path = %s", path)
+	}
+	if data != `{"type":"deployment_record","record":{"name":"web"}}` || eventType != EventDeploymentRecord {
+		t.Errorf("unexpected message: %s (type %s)", data, eventType)
+	}
+}
@@ -0,0 +1,82 @@
+// Package sink provides additional destinations for the records
+// posted to the deployment records API.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+// Event types.
+const (
+	// EventDeploymentRecord is the type of events carrying a
+	// deploymentrecord.DeploymentRecord.
+	EventDeploymentRecord = "deployment_record"
+	// EventEnvironmentRecord is the type of events carrying a
+	// deploymentrecord.EnvironmentRecord.
+	EventEnvironmentRecord = "environment_record"
+)
+
+// Event is a record delivered to a sink.
+type Event struct {
+	Type   string `json:"type"`
+	Record any    `json:"record"`
+	// Repository is the source repository of the record's image,
+	// as owner/name, if known.
+	Repository string `json:"repository,omitempty"`
+	// Namespace is the namespace of the record's pods, if known, and
+	// Labels the labels of the pod the record was created from, to
+	// select the events to deliver. Labels are not delivered.
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"-"`
+}
+
+// Sink receives the records that were posted to the deployment
+// records API.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	// Send delivers the event.
+	Send(ctx context.Context, event Event) error
+}
+
+// Option is a function that configures the SQS, SNS, Pub/Sub and
+// notifier sinks.
+type Option func(*encoder)
+
+// WithFieldMapping serializes the records of the events with the
+// field mapping m, rather than with their canonical field names. The
+// notifier template is then executed with the mapped record, e.g.
+// {{.Record.deploymentName}} with the camel profile.
+func WithFieldMapping(m *deploymentrecord.FieldMapping) Option {
+	return func(e *encoder) {
+		e.fields = m
+	}
+}
+
+// encoder maps the field names of the records of events, see
+// WithFieldMapping.
+type encoder struct {
+	fields *deploymentrecord.FieldMapping
+}
+
+// mapRecord returns the event with its record serialized with the
+// field mapping, as a JSON object, or the event unchanged without a
+// field mapping.
+func (e *encoder) mapRecord(event Event) (Event, error) {
+	if e.fields == nil {
+		return event, nil
+	}
+	body, err := e.fields.Marshal(event.Record)
+	if err != nil {
+		return event, err
+	}
+	var record map[string]any
+	if err := json.Unmarshal(body, &record); err != nil {
+		return event, err
+	}
+	event.Record = record
+	return event, nil
+}
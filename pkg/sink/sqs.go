@@ -0,0 +1,162 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+var (
+	// sqsQueuePattern matches SQS queue URLs, capturing the region.
+	sqsQueuePattern = regexp.MustCompile(`^https://sqs\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?/[0-9]+/[A-Za-z0-9_-]+(\.fifo)?$`)
+	// snsTopicPattern matches SNS topic ARNs, capturing the partition
+	// and region.
+	snsTopicPattern = regexp.MustCompile(`^arn:(aws|aws-cn|aws-us-gov):sns:([a-z0-9-]+):[0-9]+:[A-Za-z0-9_-]+(\.fifo)?$`)
+)
+
+// messageGroupID is the message group of the events sent to FIFO
+// queues and topics, keeping all events in order.
+const messageGroupID = "deployment-tracker"
+
+// SQS sends events as JSON messages to an Amazon SQS queue, with the
+// event type in the type message attribute. Requests are signed with
+// the credentials of the pod, see awsConfig.
+type SQS struct {
+	encoder
+	client   *sqs.Client
+	queueURL string
+	fifo     bool
+}
+
+// NewSQS creates an SQS sink sending to the queue at queueURL, e.g.
+// https://sqs.us-east-1.amazonaws.com/123456789012/records. Returns an
+// error if queueURL is not an SQS queue URL.
+func NewSQS(queueURL string, opts ...Option) (*SQS, error) {
+	m := sqsQueuePattern.FindStringSubmatch(queueURL)
+	if m == nil {
+		return nil, fmt.Errorf("invalid SQS queue URL: %s", queueURL)
+	}
+	cfg, err := awsConfig(m[1])
+	if err != nil {
+		return nil, err
+	}
+	q := &SQS{
+		client:   sqs.NewFromConfig(cfg),
+		queueURL: queueURL,
+		fifo:     m[3] != "",
+	}
+	for _, opt := range opts {
+		opt(&q.encoder)
+	}
+	return q, nil
+}
+
+// Name returns the sink name.
+func (q *SQS) Name() string {
+	return "sqs"
+}
+
+// Send sends the event to the queue.
+func (q *SQS) Send(ctx context.Context, event Event) error {
+	event, err := q.mapRecord(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.queueURL),
+		MessageBody: aws.String(string(message)),
+		MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+			"type": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(event.Type),
+			},
+		},
+	}
+	if q.fifo {
+		input.MessageGroupId = aws.String(messageGroupID)
+		input.MessageDeduplicationId = aws.String(sha256Hex(message))
+	}
+	if _, err := q.client.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("sqs request failed: %w", err)
+	}
+	return nil
+}
+
+// SNS publishes events as JSON messages to an Amazon SNS topic, with
+// the event type in the type message attribute. Requests are signed
+// with the credentials of the pod, see awsConfig.
+type SNS struct {
+	encoder
+	client   *sns.Client
+	topicARN string
+	fifo     bool
+}
+
+// NewSNS creates an SNS sink publishing to the topic topicARN, e.g.
+// arn:aws:sns:us-east-1:123456789012:records. Returns an error if
+// topicARN is not an SNS topic ARN.
+func NewSNS(topicARN string, opts ...Option) (*SNS, error) {
+	m := snsTopicPattern.FindStringSubmatch(topicARN)
+	if m == nil {
+		return nil, fmt.Errorf("invalid SNS topic ARN: %s", topicARN)
+	}
+	cfg, err := awsConfig(m[2])
+	if err != nil {
+		return nil, err
+	}
+	t := &SNS{
+		client:   sns.NewFromConfig(cfg),
+		topicARN: topicARN,
+		fifo:     m[3] != "",
+	}
+	for _, opt := range opts {
+		opt(&t.encoder)
+	}
+	return t, nil
+}
+
+// Name returns the sink name.
+func (t *SNS) Name() string {
+	return "sns"
+}
+
+// Send publishes the event to the topic.
+func (t *SNS) Send(ctx context.Context, event Event) error {
+	event, err := t.mapRecord(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	input := &sns.PublishInput{
+		TopicArn: aws.String(t.topicARN),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"type": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(event.Type),
+			},
+		},
+	}
+	if t.fifo {
+		input.MessageGroupId = aws.String(messageGroupID)
+		input.MessageDeduplicationId = aws.String(sha256Hex(message))
+	}
+	if _, err := t.client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("sns request failed: %w", err)
+	}
+	return nil
+}
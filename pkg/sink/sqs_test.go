@@ -0,0 +1,192 @@
+package sink
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+func TestNewSQS(t *testing.T) {
+	tests := []struct {
+		name     string
+		queueURL string
+		region   string
+		fifo     bool
+		wantErr  bool
+	}{
+		{
+			name:     "standard",
+			queueURL: "https://sqs.eu-west-1.amazonaws.com/123456789012/records",
+			region:   "eu-west-1",
+		},
+		{
+			name:     "fifo",
+			queueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/records.fifo",
+			region:   "us-east-1",
+			fifo:     true,
+		},
+		{
+			name:     "http",
+			queueURL: "http://sqs.us-east-1.amazonaws.com/123456789012/records",
+			wantErr:  true,
+		},
+		{
+			name:     "other host",
+			queueURL: "https://example.com/123456789012/records",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := NewSQS(tt.queueURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSQS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if region := q.client.Options().Region; region != tt.region || q.fifo != tt.fifo {
+				t.Errorf("region, fifo = %s, %v, expected %s, %v", region, q.fifo, tt.region, tt.fifo)
+			}
+		})
+	}
+}
+
+func TestNewSNS(t *testing.T) {
+	tests := []struct {
+		name     string
+		topicARN string
+		region   string
+		fifo     bool
+		wantErr  bool
+	}{
+		{
+			name:     "standard",
+			topicARN: "arn:aws:sns:eu-west-1:123456789012:records",
+			region:   "eu-west-1",
+		},
+		{
+			name:     "china",
+			topicARN: "arn:aws-cn:sns:cn-north-1:123456789012:records.fifo",
+			region:   "cn-north-1",
+			fifo:     true,
+		},
+		{
+			name:     "queue",
+			topicARN: "arn:aws:sqs:eu-west-1:123456789012:records",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSNS(tt.topicARN)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSNS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if region := s.client.Options().Region; region != tt.region || s.fifo != tt.fifo {
+				t.Errorf("region, fifo = %s, %v, expected %s, %v", region, s.fifo, tt.region, tt.fifo)
+			}
+		})
+	}
+}
+
+// newTestAWSConfig returns the configuration of clients sending to
+// srv, with static credentials.
+func newTestAWSConfig(srv *httptest.Server) aws.Config {
+	return aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+		HTTPClient:   srv.Client(),
+		BaseEndpoint: aws.String(srv.URL),
+	}
+}
+
+func TestSQSSend(t *testing.T) {
+	var target, auth string
+	var input map[string]any
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.Header.Get("X-Amz-Target")
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		body, _ := input["MessageBody"].(string)
+		sum := md5.Sum([]byte(body))
+		_, _ = w.Write([]byte(`{"MessageId":"1","MD5OfMessageBody":"` + hex.EncodeToString(sum[:]) + `"}`))
+	}))
+	defer srv.Close()
+
+	q, err := NewSQS("https://sqs.us-east-1.amazonaws.com/123456789012/records.fifo")
+	if err != nil {
+		t.Fatalf("NewSQS() unexpected error: %v", err)
+	}
+	q.client = sqs.NewFromConfig(newTestAWSConfig(srv), func(o *sqs.Options) {
+		// The test server doesn't return the MD5 of the attributes
+		o.DisableMessageChecksumValidation = true
+	})
+
+	if err := q.Send(context.Background(), Event{Type: EventDeploymentRecord, Record: map[string]string{"name": "web"}}); err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+	if target != "AmazonSQS.SendMessage" {
+		t.Errorf("X-Amz-Target = %s, expected AmazonSQS.SendMessage", target)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/us-east-1/sqs/aws4_request") {
+		t.Errorf("unexpected Authorization: %s", auth)
+	}
+	if input["MessageBody"] != `{"type":"deployment_record","record":{"name":"web"}}` {
+		t.Errorf("MessageBody = %v", input["MessageBody"])
+	}
+	if input["MessageGroupId"] != messageGroupID || input["MessageDeduplicationId"] == nil {
+		t.Errorf("missing FIFO message group or deduplication ID: %v", input)
+	}
+}
+
+func TestSNSSend(t *testing.T) {
+	var form url.Values
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		form, _ = url.ParseQuery(string(body))
+		_, _ = w.Write([]byte(`<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult></PublishResponse>`))
+	}))
+	defer srv.Close()
+
+	s, err := NewSNS("arn:aws:sns:us-east-1:123456789012:records")
+	if err != nil {
+		t.Fatalf("NewSNS() unexpected error: %v", err)
+	}
+	s.client = sns.NewFromConfig(newTestAWSConfig(srv))
+
+	if err := s.Send(context.Background(), Event{Type: EventEnvironmentRecord, Record: map[string]string{"name": "prod"}}); err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+	if form.Get("Action") != "Publish" || form.Get("TopicArn") != "arn:aws:sns:us-east-1:123456789012:records" {
+		t.Errorf("unexpected request: %v", form)
+	}
+	if form.Get("Message") != `{"type":"environment_record","record":{"name":"prod"}}` {
+		t.Errorf("Message = %s", form.Get("Message"))
+	}
+	if form.Get("MessageAttributes.entry.1.Value.StringValue") != EventEnvironmentRecord {
+		t.Errorf("missing type attribute: %v", form)
+	}
+	if form.Has("MessageGroupId") {
+		t.Errorf("unexpected message group for standard topic")
+	}
+}
@@ -0,0 +1,188 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+	"github.com/github/deployment-tracker/pkg/localurl"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 signature of the
+	// TimestampHeader and the request body, as sha256=<hex digest>,
+	// see Sign.
+	SignatureHeader = "X-Deployment-Tracker-Signature-256"
+	// TimestampHeader holds the time the request was signed at, in
+	// seconds since the Unix epoch, so receivers can reject replayed
+	// requests.
+	TimestampHeader = "X-Deployment-Tracker-Timestamp"
+	// EventHeader holds the type of the delivered event.
+	EventHeader = "X-Deployment-Tracker-Event"
+	// DefaultWebhookTimeout is the default timeout of webhook
+	// requests, see WithTimeout.
+	DefaultWebhookTimeout = 5 * time.Second
+)
+
+// WebhookOption is a function that configures the Webhook.
+type WebhookOption func(*Webhook)
+
+// Webhook posts events as JSON to an HTTP endpoint. Requests are
+// signed with a shared secret, so the receiver can verify them.
+type Webhook struct {
+	encoder
+	url        string
+	secret     []byte
+	headers    map[string]string
+	httpClient *http.Client
+	// err is the first error of the options, returned by NewWebhook
+	err error
+}
+
+// NewWebhook creates a webhook sink posting to url. If secret is not
+// empty, requests carry an HMAC-SHA256 signature of the timestamp and
+// the body in the SignatureHeader. The headers are added to each
+// request. Returns an error if url is not HTTPS for non-local hosts.
+func NewWebhook(url, secret string, headers map[string]string, opts ...WebhookOption) (*Webhook, error) {
+	switch {
+	case strings.HasPrefix(url, "https://"):
+	case localurl.IsLocal(url):
+	default:
+		return nil, fmt.Errorf("insecure or invalid webhook URL: %s (use HTTPS for non-local hosts)", url)
+	}
+
+	w := &Webhook{
+		url:     url,
+		secret:  []byte(secret),
+		headers: headers,
+		httpClient: &http.Client{
+			Timeout: DefaultWebhookTimeout,
+		},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w, nil
+}
+
+// WithClientCertificate authenticates requests with the client
+// certificate and key in the PEM files, for receivers requiring
+// mutual TLS. The files are read once, when the sink is created.
+func WithClientCertificate(certFile, keyFile string) WebhookOption {
+	return func(w *Webhook) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			w.err = fmt.Errorf("failed to load client certificate: %w", err)
+			return
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		w.httpClient.Transport = transport
+	}
+}
+
+// WithTimeout sets the timeout of the requests, DefaultWebhookTimeout
+// by default. Receivers posting the events synchronously, like the
+// relay, need a timeout above their worst-case post time.
+func WithTimeout(d time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.httpClient.Timeout = d
+	}
+}
+
+// WithWebhookFieldMapping serializes the records of the events with
+// the field mapping m, see WithFieldMapping. Don't set it for webhooks
+// delivering to the relay, which expects the canonical field names.
+func WithWebhookFieldMapping(m *deploymentrecord.FieldMapping) WebhookOption {
+	return func(w *Webhook) {
+		w.fields = m
+	}
+}
+
+// Name returns the sink name.
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// Send posts the event to the webhook.
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	event, err := w.mapRecord(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	if len(w.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(w.secret, timestamp, body))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of the request with the timestamp of the
+// TimestampHeader and body for the SignatureHeader: the HMAC-SHA256 of
+// <timestamp>.<body>.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseHeaders parses a comma separated list of Name=value pairs.
+func ParseHeaders(list string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid header: %s (expected Name=value)", pair)
+		}
+		res[k] = strings.TrimSpace(v)
+	}
+	return res, nil
+}
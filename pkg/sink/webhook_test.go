@@ -0,0 +1,279 @@
+package sink
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/deploymentrecord"
+)
+
+func TestNewWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{
+			name: "https",
+			url:  "https://cmdb.example.com/hooks/deployments",
+		},
+		{
+			name: "local http",
+			url:  "http://localhost:8080/hook",
+		},
+		{
+			name: "cluster local http",
+			url:  "http://cmdb.tools.svc.cluster.local/hook",
+		},
+		{
+			name:    "remote http",
+			url:     "http://cmdb.example.com/hook",
+			wantErr: true,
+		},
+		{
+			name:    "no scheme",
+			url:     "cmdb.example.com/hook",
+			wantErr: true,
+		},
+		{
+			name:    "remote http mentioning a cluster service",
+			url:     "http://attacker/?x=.svc.cluster.local",
+			wantErr: true,
+		},
+		{
+			name:    "remote http under localhost",
+			url:     "http://localhost.attacker.com/hook",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWebhook(tt.url, "", nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		status  int
+		wantErr bool
+	}{
+		{
+			name:   "signed",
+			secret: "s3cret",
+			status: http.StatusOK,
+		},
+		{
+			name:   "unsigned",
+			status: http.StatusAccepted,
+		},
+		{
+			name:    "error status",
+			secret:  "s3cret",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			w, err := NewWebhook(srv.URL, tt.secret, map[string]string{"X-Team": "platform"})
+			if err != nil {
+				t.Fatalf("NewWebhook() error = %v", err)
+			}
+
+			err = w.Send(context.Background(), Event{
+				Type:   EventDeploymentRecord,
+				Record: map[string]string{"name": "ghcr.io/org/app"},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := req.Header.Get("X-Team"); got != "platform" {
+				t.Errorf("custom header = %q, expected %q", got, "platform")
+			}
+			if got := req.Header.Get(EventHeader); got != EventDeploymentRecord {
+				t.Errorf("%s = %q, expected %q", EventHeader, got, EventDeploymentRecord)
+			}
+
+			signature := req.Header.Get(SignatureHeader)
+			switch {
+			case tt.secret == "" && signature != "":
+				t.Errorf("unexpected signature %q without secret", signature)
+			case tt.secret != "" && signature != Sign([]byte(tt.secret), req.Header.Get(TimestampHeader), body):
+				t.Errorf("signature %q does not match body", signature)
+			}
+
+			var event struct {
+				Type   string            `json:"type"`
+				Record map[string]string `json:"record"`
+			}
+			if err := json.Unmarshal(body, &event); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if event.Type != EventDeploymentRecord || event.Record["name"] != "ghcr.io/org/app" {
+				t.Errorf("unexpected body: %s", body)
+			}
+		})
+	}
+}
+
+func TestWebhookFieldMapping(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	fields, err := deploymentrecord.NewFieldMapping(deploymentrecord.ProfileCamelCase, "name=image")
+	if err != nil {
+		t.Fatalf("NewFieldMapping() error = %v", err)
+	}
+	w, err := NewWebhook(srv.URL, "", nil, WithWebhookFieldMapping(fields))
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	record := deploymentrecord.NewDeploymentRecord("ghcr.io/org/app", "sha256:abc", "v1", "", "", "", deploymentrecord.StatusDeployed, "ns/app/web")
+	record.Topology = &deploymentrecord.Topology{InstanceType: "m5.large"}
+	if err := w.Send(context.Background(), Event{Type: EventDeploymentRecord, Record: record, Namespace: "ns"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var event struct {
+		Type      string         `json:"type"`
+		Record    map[string]any `json:"record"`
+		Namespace string         `json:"namespace"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	topology, _ := event.Record["topology"].(map[string]any)
+	if event.Record["image"] != "ghcr.io/org/app" || event.Record["deploymentName"] != "ns/app/web" || topology["instanceType"] != "m5.large" {
+		t.Errorf("record = %v, expected mapped field names", event.Record)
+	}
+	// Only the record is mapped
+	if event.Type != EventDeploymentRecord || event.Namespace != "ns" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestWebhookClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "deployment-tracker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	w, err := NewWebhook(srv.URL, "", nil, WithClientCertificate(certFile, keyFile))
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	w.httpClient.Transport.(*http.Transport).TLSClientConfig.RootCAs = rootCAs
+
+	if err := w.Send(context.Background(), Event{Type: EventDeploymentRecord}); err != nil {
+		t.Errorf("Send() error = %v", err)
+	}
+
+	if _, err := NewWebhook(srv.URL, "", nil, WithClientCertificate(keyFile, certFile)); err == nil {
+		t.Error("NewWebhook() expected an error for swapped certificate and key")
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("X-Team=platform, Authorization=Bearer abc=")
+	if err != nil {
+		t.Fatalf("ParseHeaders() error = %v", err)
+	}
+	if headers["X-Team"] != "platform" || headers["Authorization"] != "Bearer abc=" {
+		t.Errorf("ParseHeaders() = %v", headers)
+	}
+
+	if _, err := ParseHeaders("X-Team"); err == nil {
+		t.Errorf("ParseHeaders() expected error for missing value")
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	w, err := NewWebhook(srv.URL, "", nil, WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	if err := w.Send(context.Background(), Event{Type: EventDeploymentRecord}); err == nil {
+		t.Error("Send() expected a timeout error")
+	}
+}
@@ -0,0 +1,86 @@
+// Package tracing sets up the OpenTelemetry SDK, exporting spans to an
+// OTLP/HTTP collector. It is configured through the standard OTEL_*
+// environment variables.
+package tracing
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultServiceName is the service name reported if OTEL_SERVICE_NAME
+// is not set.
+const defaultServiceName = "deployment-tracker"
+
+// Tracer returns a tracer of the global tracer provider. Until Setup
+// is called, the returned tracer does not record any spans.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Setup configures the global tracer provider from the environment
+// and returns a function flushing the remaining spans on shutdown.
+// Tracing is only enabled when OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set. version is reported as
+// the service version.
+func Setup(version string) (func(context.Context) error, error) {
+	endpoint := cmp.Or(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"), os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled || endpoint == "" {
+		slog.Info("Tracing disabled, no OTLP endpoint configured")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	protocol := cmp.Or(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"), os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"))
+	if protocol != "" && protocol != "http/protobuf" {
+		return nil, fmt.Errorf("unsupported OTLP protocol: %s (only http/protobuf is supported)", protocol)
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	// The environment overrides the default service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", defaultServiceName),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource attributes: %w", err)
+	}
+
+	// The sampler is read from OTEL_TRACES_SAMPLER by the SDK
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	slog.Info("Tracing enabled",
+		"endpoint", endpoint,
+	)
+	return tp.Shutdown, nil
+}
+
+// End records err on span, if not nil, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
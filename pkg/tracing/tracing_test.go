@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var otelEnv = []string{
+	"OTEL_SDK_DISABLED",
+	"OTEL_EXPORTER_OTLP_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_PROTOCOL",
+	"OTEL_EXPORTER_OTLP_HEADERS",
+	"OTEL_SERVICE_NAME",
+	"OTEL_TRACES_SAMPLER",
+	"OTEL_TRACES_SAMPLER_ARG",
+}
+
+func TestSetupDisabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "no endpoint",
+		},
+		{
+			name: "sdk disabled",
+			env: map[string]string{
+				"OTEL_SDK_DISABLED":           "true",
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+			},
+		},
+		{
+			name: "unsupported protocol",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317",
+				"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range otelEnv {
+				t.Setenv(key, tt.env[key])
+			}
+			otel.SetTracerProvider(noop.NewTracerProvider())
+
+			shutdown, err := Setup("v1.0.0")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer func() {
+				_ = shutdown(context.Background())
+			}()
+			if _, span := Tracer("test").Start(context.Background(), "root"); span.IsRecording() {
+				t.Errorf("span recorded with tracing disabled")
+			}
+		})
+	}
+}
+
+func TestSetupExport(t *testing.T) {
+	var mu sync.Mutex
+	var received []*collectortrace.ExportTraceServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected export path %s", r.URL.Path)
+		}
+		if r.Header.Get("Api-Key") != "secret" {
+			t.Errorf("missing configured header")
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req collectortrace.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("failed to decode export request: %v", err)
+		}
+		mu.Lock()
+		received = append(received, &req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	for _, key := range otelEnv {
+		t.Setenv(key, "")
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=secret")
+	t.Setenv("OTEL_SERVICE_NAME", "test")
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	shutdown, err := Setup("v1.0.0")
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	ctx, parent := Tracer("test").Start(context.Background(), "parent")
+	_, child := Tracer("test").Start(ctx, "child")
+	End(child, errors.New("boom"))
+	parent.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("received %d export requests, expected 1", len(received))
+	}
+	rs := received[0].GetResourceSpans()[0]
+	for _, kv := range rs.GetResource().GetAttributes() {
+		if kv.GetKey() == "service.name" && kv.GetValue().GetStringValue() != "test" {
+			t.Errorf("service.name = %s, expected test", kv.GetValue().GetStringValue())
+		}
+	}
+	spans := rs.GetScopeSpans()[0].GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, expected 2", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if string(c.GetParentSpanId()) != string(p.GetSpanId()) {
+		t.Errorf("child span not linked to parent")
+	}
+	if c.GetStatus().GetMessage() != "boom" {
+		t.Errorf("child status = %v, expected error", c.GetStatus())
+	}
+}
@@ -0,0 +1,268 @@
+// Package vault reads credentials from HashiCorp Vault, using the
+// Kubernetes auth method or a static Vault token.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/github/deployment-tracker/pkg/localurl"
+)
+
+const (
+	// DefaultAuthMount is the default mount path of the Kubernetes
+	// auth method.
+	DefaultAuthMount = "kubernetes"
+
+	// DefaultJWTPath is the path of the service account token used to
+	// log in with the Kubernetes auth method.
+	DefaultJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// defaultSecretTTL is how long secrets without a lease, e.g. of
+	// the kv engine, are cached, so rotated values are picked up.
+	defaultSecretTTL = 5 * time.Minute
+
+	// maxResponseBytes is the maximum size of a response body read
+	// from Vault.
+	maxResponseBytes = 1 << 20
+)
+
+// Config configures the Vault client.
+type Config struct {
+	// Address is the address of the Vault server, e.g.
+	// https://vault.example.com:8200.
+	Address string
+	// Namespace is the Vault Enterprise namespace, if any.
+	Namespace string
+	// Token is a static Vault token, e.g. written by a Vault agent.
+	// If empty, the client logs in with the Kubernetes auth method.
+	Token string
+	// Role is the role of the Kubernetes auth method.
+	Role string
+	// AuthMount is the mount path of the Kubernetes auth method,
+	// DefaultAuthMount if empty.
+	AuthMount string
+	// JWTPath is the path of the service account token, DefaultJWTPath
+	// if empty.
+	JWTPath string
+}
+
+// Client reads secrets from Vault. Tokens obtained with the Kubernetes
+// auth method are renewed by logging in again before they expire.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewClient creates a Vault client. Returns an error if the address
+// is not HTTPS for non-local hosts, or neither a token nor a role is
+// set.
+func NewClient(cfg Config) (*Client, error) {
+	if !strings.HasPrefix(cfg.Address, "https://") && !localurl.IsLocal(cfg.Address) {
+		return nil, fmt.Errorf("insecure or invalid Vault address: %s (use HTTPS for non-local hosts)", cfg.Address)
+	}
+	if cfg.Token == "" && cfg.Role == "" {
+		return nil, fmt.Errorf("a Vault token or Kubernetes auth role is required")
+	}
+	if cfg.AuthMount == "" {
+		cfg.AuthMount = DefaultAuthMount
+	}
+	if cfg.JWTPath == "" {
+		cfg.JWTPath = DefaultJWTPath
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+
+	return &Client{
+		cfg:   cfg,
+		token: cfg.Token,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// response is the part of a Vault API response used by the client.
+type response struct {
+	Data          map[string]any `json:"data"`
+	LeaseDuration int            `json:"lease_duration"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// Secret is a field of a secret read from Vault.
+type Secret struct {
+	Value string
+	// TTL is the lease duration of the secret, or a default for
+	// secrets without a lease.
+	TTL time.Duration
+}
+
+// Read returns the field of the secret at path, e.g. "secret/data/app"
+// for the kv version 2 engine or "github/token" for the GitHub secrets
+// engine. Secrets of the kv version 2 engine are unwrapped.
+func (c *Client) Read(ctx context.Context, path, field string) (*Secret, error) {
+	token, err := c.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	data := res.Data
+	if inner, ok := data["data"].(map[string]any); ok && data["metadata"] != nil {
+		data = inner
+	}
+	value, ok := data[field].(string)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("secret %s has no field %s", path, field)
+	}
+
+	ttl := time.Duration(res.LeaseDuration) * time.Second
+	if ttl <= 0 {
+		ttl = defaultSecretTTL
+	}
+	return &Secret{Value: value, TTL: ttl}, nil
+}
+
+// login returns the Vault token, logging in with the Kubernetes auth
+// method if there is no static token and the last token expires
+// within a third of its lease.
+func (c *Client) login(ctx context.Context) (string, error) {
+	if c.cfg.Token != "" {
+		return c.cfg.Token, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	jwt, err := os.ReadFile(c.cfg.JWTPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{
+		"role": c.cfg.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	res, err := c.do(ctx, http.MethodPost, "/v1/auth/"+c.cfg.AuthMount+"/login", "", body)
+	if err != nil {
+		return "", fmt.Errorf("vault login failed: %w", err)
+	}
+	if res.Auth == nil || res.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault login failed: no token in response")
+	}
+
+	lease := time.Duration(res.Auth.LeaseDuration) * time.Second
+	c.token = res.Auth.ClientToken
+	c.expires = time.Now().Add(lease * 2 / 3)
+	return c.token, nil
+}
+
+// do sends a request to the Vault API and decodes the response.
+func (c *Client) do(ctx context.Context, method, path, token string, body []byte) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.Address+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.cfg.Namespace)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var res response
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &res); err != nil && resp.StatusCode < 300 {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if len(res.Errors) > 0 {
+			return nil, fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.Join(res.Errors, ", "))
+		}
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return &res, nil
+}
+
+// TokenFunc returns a function returning the field of the secret at
+// path, e.g. an API token. The secret is cached for two thirds of its
+// TTL, and the cached value is kept if Vault is unavailable when it
+// is read again.
+func (c *Client) TokenFunc(path, field string) func(context.Context) (string, error) {
+	var (
+		mu      sync.Mutex
+		value   string
+		refresh time.Time
+	)
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if value != "" && time.Now().Before(refresh) {
+			return value, nil
+		}
+
+		secret, err := c.Read(ctx, path, field)
+		if err != nil {
+			if value != "" {
+				slog.Warn("Failed to read secret from Vault, keeping the current value",
+					"path", path,
+					"error", err)
+				return value, nil
+			}
+			return "", err
+		}
+		value = secret.Value
+		refresh = time.Now().Add(secret.TTL * 2 / 3)
+		return value, nil
+	}
+}
+
+// ParsePath splits a secret reference of the form path#field. The
+// field defaults to defaultField.
+func ParsePath(ref, defaultField string) (string, string) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		field = defaultField
+	}
+	return path, field
+}
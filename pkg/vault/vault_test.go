@@ -0,0 +1,223 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a fake Vault server with the Kubernetes auth
+// method, a kv version 2 secret and the GitHub secrets engine. It
+// counts the logins and reads.
+func newTestServer(t *testing.T, logins, reads *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/kubernetes/login" {
+			*logins++
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["role"] != "deployment-tracker" || body["jwt"] != "sa-jwt" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"auth": {"client_token": "hvs.login", "lease_duration": 3600}}`))
+			return
+		}
+
+		*reads++
+		if tok := r.Header.Get("X-Vault-Token"); tok != "hvs.login" && tok != "hvs.static" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/deployment-tracker":
+			_, _ = w.Write([]byte(`{"data": {"data": {"token": "ghp_kv", "private_key": "PEM"}, "metadata": {"version": 3}}}`))
+		case "/v1/github/token":
+			_, _ = w.Write([]byte(`{"lease_duration": 3600, "data": {"token": "ghs_engine"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRead(t *testing.T) {
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtPath, []byte("sa-jwt\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		path    string
+		field   string
+		want    string
+		wantTTL time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "kv v2 with kubernetes auth",
+			cfg:     Config{Role: "deployment-tracker", JWTPath: jwtPath},
+			path:    "secret/data/deployment-tracker",
+			field:   "token",
+			want:    "ghp_kv",
+			wantTTL: defaultSecretTTL,
+		},
+		{
+			name:    "github engine with static token",
+			cfg:     Config{Token: "hvs.static"},
+			path:    "github/token",
+			field:   "token",
+			want:    "ghs_engine",
+			wantTTL: time.Hour,
+		},
+		{
+			name:    "missing field",
+			cfg:     Config{Token: "hvs.static"},
+			path:    "secret/data/deployment-tracker",
+			field:   "password",
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			cfg:     Config{Token: "hvs.static"},
+			path:    "secret/data/other",
+			field:   "token",
+			wantErr: true,
+		},
+		{
+			name:    "login denied",
+			cfg:     Config{Role: "other", JWTPath: jwtPath},
+			path:    "github/token",
+			field:   "token",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logins, reads int
+			srv := newTestServer(t, &logins, &reads)
+			tt.cfg.Address = srv.URL
+			c, err := NewClient(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			got, err := c.Read(context.Background(), tt.path, tt.field)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Read() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if got.Value != tt.want || got.TTL != tt.wantTTL {
+				t.Errorf("Read() = %q, %v, want %q, %v", got.Value, got.TTL, tt.want, tt.wantTTL)
+			}
+
+			// The login token is reused
+			if _, err := c.Read(context.Background(), tt.path, tt.field); err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if tt.cfg.Token == "" && logins != 1 {
+				t.Errorf("logins = %d, want 1", logins)
+			}
+		})
+	}
+}
+
+func TestTokenFunc(t *testing.T) {
+	var logins, reads int
+	srv := newTestServer(t, &logins, &reads)
+	c, err := NewClient(Config{Address: srv.URL, Token: "hvs.static"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	fn := c.TokenFunc("github/token", "token")
+	for range 3 {
+		tok, err := fn(context.Background())
+		if err != nil {
+			t.Fatalf("token func error = %v", err)
+		}
+		if tok != "ghs_engine" {
+			t.Errorf("token = %q, want %q", tok, "ghs_engine")
+		}
+	}
+	if reads != 1 {
+		t.Errorf("reads = %d, want 1", reads)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "https with role",
+			cfg:  Config{Address: "https://vault.example.com:8200", Role: "deployment-tracker"},
+		},
+		{
+			name: "in-cluster http with token",
+			cfg:  Config{Address: "http://vault.vault.svc.cluster.local:8200", Token: "hvs.static"},
+		},
+		{
+			name:    "insecure address",
+			cfg:     Config{Address: "http://vault.example.com:8200", Role: "deployment-tracker"},
+			wantErr: true,
+		},
+		{
+			name:    "insecure address mentioning a cluster service",
+			cfg:     Config{Address: "http://attacker/?x=.svc.cluster.local", Role: "deployment-tracker"},
+			wantErr: true,
+		},
+		{
+			name:    "no credentials",
+			cfg:     Config{Address: "https://vault.example.com:8200"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		ref       string
+		wantPath  string
+		wantField string
+	}{
+		{"secret/data/app#api_token", "secret/data/app", "api_token"},
+		{"github/token", "github/token", "token"},
+		{"secret/data/app#", "secret/data/app", "token"},
+	}
+
+	for _, tt := range tests {
+		path, field := ParsePath(tt.ref, "token")
+		if path != tt.wantPath || field != tt.wantField {
+			t.Errorf("ParsePath(%q) = %q, %q, want %q, %q", tt.ref, path, field, tt.wantPath, tt.wantField)
+		}
+	}
+}